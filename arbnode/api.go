@@ -15,6 +15,7 @@ import (
 	"github.com/tenderly/nitro/go-ethereum/core/types"
 	"github.com/tenderly/nitro/go-ethereum/log"
 	"github.com/tenderly/nitro/go-ethereum/rpc"
+	"github.com/tenderly/nitro/arbos"
 	"github.com/tenderly/nitro/arbos/arbosState"
 	"github.com/tenderly/nitro/arbos/retryables"
 	"github.com/tenderly/nitro/validator"
@@ -34,19 +35,71 @@ func (a *BlockValidatorAPI) RevalidateBlock(ctx context.Context, blockNum rpc.Bl
 	if !a.blockchain.Config().IsArbitrumNitro(header.Number) {
 		return false, types.ErrUseFallback
 	}
-	var moduleRoot common.Hash
-	if moduleRootOptional != nil {
-		moduleRoot = *moduleRootOptional
-	} else {
-		moduleRoots := a.val.GetModuleRootsToValidate()
-		if len(moduleRoots) == 0 {
-			return false, errors.New("no current WasmModuleRoot configured, must provide parameter")
-		}
-		moduleRoot = moduleRoots[0]
+	moduleRoot, err := a.moduleRoot(moduleRootOptional)
+	if err != nil {
+		return false, err
 	}
 	return a.val.ValidateBlock(ctx, header, moduleRoot)
 }
 
+// ExportValidationBundle returns a bundle with everything needed to replay the validation of the block offline
+func (a *BlockValidatorAPI) ExportValidationBundle(ctx context.Context, blockNum rpc.BlockNumberOrHash, moduleRootOptional *common.Hash) (*validator.ExportedValidationBundle, error) {
+	header, err := arbitrum.HeaderByNumberOrHash(a.blockchain, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	if !a.blockchain.Config().IsArbitrumNitro(header.Number) {
+		return nil, types.ErrUseFallback
+	}
+	moduleRoot, err := a.moduleRoot(moduleRootOptional)
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := a.val.ValidationBundle(ctx, header, moduleRoot)
+	if err != nil {
+		return nil, err
+	}
+	return bundle.Export(), nil
+}
+
+// ValidateBlockRange starts validating the blocks from fromBlock to toBlock inclusive in the background,
+// with the given module roots or else the ones the block validator is validating with
+func (a *BlockValidatorAPI) ValidateBlockRange(ctx context.Context, fromBlock, toBlock rpc.BlockNumber, moduleRoots []common.Hash) (hexutil.Uint64, error) {
+	fromBlock, _ = a.blockchain.ClipToPostNitroGenesis(fromBlock)
+	toBlock, _ = a.blockchain.ClipToPostNitroGenesis(toBlock)
+	if toBlock < fromBlock {
+		return 0, fmt.Errorf("invalid block range: %v to %v", fromBlock.Int64(), toBlock.Int64())
+	}
+	if len(moduleRoots) == 0 {
+		moduleRoots = a.val.GetModuleRootsToValidate()
+	}
+	id, err := a.val.RangeValidator().ValidateRange(uint64(fromBlock), uint64(toBlock), moduleRoots)
+	return hexutil.Uint64(id), err
+}
+
+func (a *BlockValidatorAPI) BlockRangeValidation(ctx context.Context, id hexutil.Uint64) (*validator.RangeValidationProgress, error) {
+	return a.val.RangeValidator().Progress(uint64(id))
+}
+
+func (a *BlockValidatorAPI) BlockRangeValidations(ctx context.Context) []*validator.RangeValidationProgress {
+	return a.val.RangeValidator().AllProgress()
+}
+
+func (a *BlockValidatorAPI) CancelBlockRangeValidation(ctx context.Context, id hexutil.Uint64) error {
+	return a.val.RangeValidator().Cancel(uint64(id))
+}
+
+func (a *BlockValidatorAPI) moduleRoot(moduleRootOptional *common.Hash) (common.Hash, error) {
+	if moduleRootOptional != nil {
+		return *moduleRootOptional, nil
+	}
+	moduleRoots := a.val.GetModuleRootsToValidate()
+	if len(moduleRoots) == 0 {
+		return common.Hash{}, errors.New("no current WasmModuleRoot configured, must provide parameter")
+	}
+	return moduleRoots[0], nil
+}
+
 func (a *BlockValidatorAPI) LatestValidatedBlock(ctx context.Context) (hexutil.Uint64, error) {
 	block := a.val.LastBlockValidated()
 	return hexutil.Uint64(block), nil
@@ -57,6 +110,28 @@ func (a *BlockValidatorAPI) LatestValidatedBlockHash(ctx context.Context) (commo
 	return hash, nil
 }
 
+// the most storage checks a conditional transaction may require
+const maxConditionalKnownSlots = 1000
+
+type ArbTransactionAPI struct {
+	arbInterface *ArbInterface
+}
+
+// SendRawTransactionConditional publishes a transaction which is only sequenced if its preconditions hold
+func (a *ArbTransactionAPI) SendRawTransactionConditional(ctx context.Context, input hexutil.Bytes, options *arbos.ConditionalOptions) (common.Hash, error) {
+	tx := new(types.Transaction)
+	if err := tx.UnmarshalBinary(input); err != nil {
+		return common.Hash{}, err
+	}
+	if options != nil && options.KnownSlots() > maxConditionalKnownSlots {
+		return common.Hash{}, fmt.Errorf("conditional transaction requires %v storage checks, maximum is %v", options.KnownSlots(), maxConditionalKnownSlots)
+	}
+	if err := a.arbInterface.PublishTransactionConditional(ctx, tx, options); err != nil {
+		return common.Hash{}, err
+	}
+	return tx.Hash(), nil
+}
+
 type ArbDebugAPI struct {
 	blockchain        *core.BlockChain
 	blockRangeBound   uint64
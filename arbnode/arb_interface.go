@@ -8,10 +8,11 @@ import (
 
 	"github.com/tenderly/nitro/go-ethereum/core"
 	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/arbos"
 )
 
 type TransactionPublisher interface {
-	PublishTransaction(ctx context.Context, tx *types.Transaction) error
+	PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbos.ConditionalOptions) error
 	Initialize(context.Context) error
 	Start(context.Context) error
 	StopAndWait()
@@ -35,7 +36,11 @@ func (a *ArbInterface) Initialize(n *Node) {
 }
 
 func (a *ArbInterface) PublishTransaction(ctx context.Context, tx *types.Transaction) error {
-	return a.txPublisher.PublishTransaction(ctx, tx)
+	return a.txPublisher.PublishTransaction(ctx, tx, nil)
+}
+
+func (a *ArbInterface) PublishTransactionConditional(ctx context.Context, tx *types.Transaction, options *arbos.ConditionalOptions) error {
+	return a.txPublisher.PublishTransaction(ctx, tx, options)
 }
 
 func (a *ArbInterface) TransactionStreamer() *TransactionStreamer {
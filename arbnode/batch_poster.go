@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"sync/atomic"
 	"time"
 
 	"github.com/tenderly/nitro/arbos"
@@ -17,47 +18,66 @@ import (
 	"github.com/pkg/errors"
 	flag "github.com/spf13/pflag"
 
+	"github.com/tenderly/nitro/go-ethereum"
+	"github.com/tenderly/nitro/go-ethereum/accounts/abi"
 	"github.com/tenderly/nitro/go-ethereum/accounts/abi/bind"
 	"github.com/tenderly/nitro/go-ethereum/common"
-	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/go-ethereum/ethdb"
 	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
 	"github.com/tenderly/nitro/go-ethereum/params"
 	"github.com/tenderly/nitro/go-ethereum/rlp"
+	"github.com/tenderly/nitro/arbnode/dataposter"
 	"github.com/tenderly/nitro/arbstate"
 	"github.com/tenderly/nitro/arbutil"
 	"github.com/tenderly/nitro/das"
 	"github.com/tenderly/nitro/solgen/go/bridgegen"
-	"github.com/tenderly/nitro/util/arbmath"
 	"github.com/tenderly/nitro/util/stopwaiter"
 )
 
+var (
+	batchPosterBacklogGauge          = metrics.NewRegisteredGauge("arb/batchposter/backlog", nil)
+	batchPosterPostedCounter         = metrics.NewRegisteredCounter("arb/batchposter/posted", nil)
+	batchPosterErrorCounter          = metrics.NewRegisteredCounter("arb/batchposter/errors", nil)
+	batchPosterDasFallbackCounter    = metrics.NewRegisteredCounter("arb/batchposter/das/fallback", nil)
+	batchPosterSizeHistogram         = metrics.NewRegisteredHistogram("arb/batchposter/batch/size", nil, metrics.NewExpDecaySample(1028, 0.015))
+	batchPosterUncompressedHistogram = metrics.NewRegisteredHistogram("arb/batchposter/batch/uncompressedsize", nil, metrics.NewExpDecaySample(1028, 0.015))
+	batchPosterCompressionRatioGauge = metrics.NewRegisteredGaugeFloat64("arb/batchposter/batch/compressionratio", nil)
+	batchPosterLatencyHistogram      = metrics.NewRegisteredHistogram("arb/batchposter/batch/latency", nil, metrics.NewExpDecaySample(1028, 0.015))
+)
+
 type BatchPoster struct {
 	stopwaiter.StopWaiter
-	l1Reader            *headerreader.HeaderReader
-	inbox               *InboxTracker
-	streamer            *TransactionStreamer
-	config              *BatchPosterConfig
-	inboxContract       *bridgegen.SequencerInbox
-	gasRefunder         common.Address
-	transactOpts        *bind.TransactOpts
-	building            *buildingBatch
-	pendingMsgTimestamp time.Time
-	lastBatchCount      uint64
-	das                 das.DataAvailabilityService
+	l1Reader     *headerreader.HeaderReader
+	inbox        *InboxTracker
+	streamer     *TransactionStreamer
+	config       *BatchPosterConfig
+	seqInbox     *bridgegen.SequencerInbox
+	seqInboxABI  *abi.ABI
+	seqInboxAddr common.Address
+	gasRefunder  common.Address
+	building     *buildingBatch
+	das          das.DataAvailabilityService
+	dataPoster   *dataposter.DataPoster[batchPosterPosition]
+
+	// Atomic
+	consecutiveErrors int64
 }
 
 type BatchPosterConfig struct {
-	Enable                             bool          `koanf:"enable"`
-	DisableDasFallbackStoreDataOnChain bool          `koanf:"disable-das-fallback-store-data-on-chain"`
-	MaxBatchSize                       int           `koanf:"max-size"`
-	MaxBatchPostInterval               time.Duration `koanf:"max-interval"`
-	BatchPollDelay                     time.Duration `koanf:"poll-delay"`
-	PostingErrorDelay                  time.Duration `koanf:"error-delay"`
-	CompressionLevel                   int           `koanf:"compression-level"`
-	DASRetentionPeriod                 time.Duration `koanf:"das-retention-period"`
-	HighGasThreshold                   float32       `koanf:"high-gas-threshold"`
-	HighGasDelay                       time.Duration `koanf:"high-gas-delay"`
-	GasRefunderAddress                 string        `koanf:"gas-refunder-address"`
+	Enable                             bool                        `koanf:"enable"`
+	DisableDasFallbackStoreDataOnChain bool                        `koanf:"disable-das-fallback-store-data-on-chain"`
+	MaxBatchSize                       int                         `koanf:"max-size"`
+	MaxBatchPostInterval               time.Duration               `koanf:"max-interval"`
+	BatchPollDelay                     time.Duration               `koanf:"poll-delay"`
+	PostingErrorDelay                  time.Duration               `koanf:"error-delay"`
+	CompressionLevel                   int                         `koanf:"compression-level"`
+	DASRetentionPeriod                 time.Duration               `koanf:"das-retention-period"`
+	HighGasThreshold                   float32                     `koanf:"high-gas-threshold"`
+	HighGasDelay                       time.Duration               `koanf:"high-gas-delay"`
+	GasRefunderAddress                 string                      `koanf:"gas-refunder-address"`
+	ExtraBatchGas                      uint64                      `koanf:"extra-batch-gas"`
+	DataPoster                         dataposter.DataPosterConfig `koanf:"data-poster"`
 }
 
 func BatchPosterConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -72,6 +92,8 @@ func BatchPosterConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Float32(prefix+".high-gas-threshold", DefaultBatchPosterConfig.HighGasThreshold, "If the gas price in gwei is above this amount, delay posting a batch")
 	f.Duration(prefix+".high-gas-delay", DefaultBatchPosterConfig.HighGasDelay, "The maximum delay while waiting for the gas price to go below the high gas threshold")
 	f.String(prefix+".gas-refunder-address", DefaultBatchPosterConfig.GasRefunderAddress, "The gas refunder contract address (optional)")
+	f.Uint64(prefix+".extra-batch-gas", DefaultBatchPosterConfig.ExtraBatchGas, "use this much more gas than estimation says is necessary to post batches")
+	dataposter.DataPosterConfigAddOptions(prefix+".data-poster", f)
 }
 
 var DefaultBatchPosterConfig = BatchPosterConfig{
@@ -86,6 +108,8 @@ var DefaultBatchPosterConfig = BatchPosterConfig{
 	HighGasThreshold:                   150.,
 	HighGasDelay:                       14 * time.Hour,
 	GasRefunderAddress:                 "",
+	ExtraBatchGas:                      50_000,
+	DataPoster:                         dataposter.DefaultDataPosterConfig,
 }
 
 var TestBatchPosterConfig = BatchPosterConfig{
@@ -99,25 +123,67 @@ var TestBatchPosterConfig = BatchPosterConfig{
 	HighGasThreshold:     0.,
 	HighGasDelay:         0,
 	GasRefunderAddress:   "",
+	ExtraBatchGas:        10_000,
+	DataPoster:           dataposter.TestDataPosterConfig,
 }
 
-func NewBatchPoster(l1Reader *headerreader.HeaderReader, inbox *InboxTracker, streamer *TransactionStreamer, config *BatchPosterConfig, contractAddress common.Address, transactOpts *bind.TransactOpts, das das.DataAvailabilityService) (*BatchPoster, error) {
-	inboxContract, err := bridgegen.NewSequencerInbox(contractAddress, l1Reader.Client())
+// dataPosterDB persists the batch poster's queued L1 transactions; if nil, they're only kept in memory.
+func NewBatchPoster(l1Reader *headerreader.HeaderReader, inbox *InboxTracker, streamer *TransactionStreamer, config *BatchPosterConfig, contractAddress common.Address, transactOpts *bind.TransactOpts, das das.DataAvailabilityService, dataPosterDB ethdb.Database) (*BatchPoster, error) {
+	seqInbox, err := bridgegen.NewSequencerInbox(contractAddress, l1Reader.Client())
 	if err != nil {
 		return nil, err
 	}
 	if len(config.GasRefunderAddress) > 0 && !common.IsHexAddress(config.GasRefunderAddress) {
 		return nil, fmt.Errorf("invalid gas refunder address \"%v\"", config.GasRefunderAddress)
 	}
-	return &BatchPoster{
-		l1Reader:      l1Reader,
-		inbox:         inbox,
-		streamer:      streamer,
-		config:        config,
-		inboxContract: inboxContract,
-		transactOpts:  transactOpts,
-		gasRefunder:   common.HexToAddress(config.GasRefunderAddress),
-		das:           das,
+	seqInboxABI, err := bridgegen.SequencerInboxMetaData.GetAbi()
+	if err != nil {
+		return nil, err
+	}
+	b := &BatchPoster{
+		l1Reader:     l1Reader,
+		inbox:        inbox,
+		streamer:     streamer,
+		config:       config,
+		seqInbox:     seqInbox,
+		seqInboxABI:  seqInboxABI,
+		seqInboxAddr: contractAddress,
+		gasRefunder:  common.HexToAddress(config.GasRefunderAddress),
+		das:          das,
+	}
+	b.dataPoster, err = dataposter.NewDataPoster(l1Reader, transactOpts, dataPosterDB, &config.DataPoster, b.getBatchPosterPosition)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// batchPosterPosition is the metadata stored with each queued batch transaction,
+// describing where the next batch should start after it.
+type batchPosterPosition struct {
+	MessageCount        arbutil.MessageIndex
+	DelayedMessageCount uint64
+	NextSeqNum          uint64
+}
+
+func (b *BatchPoster) getBatchPosterPosition(ctx context.Context, blockNum *big.Int) (batchPosterPosition, error) {
+	bigInboxBatchCount, err := b.seqInbox.BatchCount(&bind.CallOpts{Context: ctx, BlockNumber: blockNum})
+	if err != nil {
+		return batchPosterPosition{}, fmt.Errorf("error getting latest batch count: %w", err)
+	}
+	inboxBatchCount := bigInboxBatchCount.Uint64()
+	var prevBatchMeta BatchMetadata
+	if inboxBatchCount > 0 {
+		var err error
+		prevBatchMeta, err = b.inbox.GetBatchMetadata(inboxBatchCount - 1)
+		if err != nil {
+			return batchPosterPosition{}, fmt.Errorf("error getting latest batch metadata: %w", err)
+		}
+	}
+	return batchPosterPosition{
+		MessageCount:        prevBatchMeta.MessageCount,
+		DelayedMessageCount: prevBatchMeta.DelayedMessageCount,
+		NextSeqNum:          inboxBatchCount,
 	}, nil
 }
 
@@ -139,9 +205,9 @@ type batchSegments struct {
 }
 
 type buildingBatch struct {
-	segments    *batchSegments
-	batchSeqNum uint64
-	msgCount    arbutil.MessageIndex
+	segments      *batchSegments
+	startMsgCount arbutil.MessageIndex
+	msgCount      arbutil.MessageIndex
 }
 
 func newBatchSegments(firstDelayed uint64, config *BatchPosterConfig) *batchSegments {
@@ -331,41 +397,65 @@ func (s *batchSegments) CloseAndGetBytes() ([]byte, error) {
 	return fullMsg, nil
 }
 
-func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context, batchSeqNum uint64) (*types.Transaction, error) {
-	inboxContractCount, err := b.inboxContract.BatchCount(&bind.CallOpts{Context: ctx, Pending: true})
+func (b *BatchPoster) encodeAddBatch(seqNum *big.Int, message []byte, delayedMsg uint64) ([]byte, error) {
+	return b.seqInboxABI.Pack("addSequencerL2BatchFromOrigin", seqNum, message, new(big.Int).SetUint64(delayedMsg), b.gasRefunder)
+}
+
+func (b *BatchPoster) estimateGas(ctx context.Context, sequencerMessage []byte, delayedMessages uint64) (uint64, error) {
+	// Earlier batches may still be in flight, so estimate against the pending batch count.
+	// The gas used doesn't depend on the sequence number, only on the data.
+	pendingBatchCount, err := b.seqInbox.BatchCount(&bind.CallOpts{Context: ctx, Pending: true})
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
-	timeSinceNextMessage := time.Since(b.pendingMsgTimestamp)
-	if !arbmath.BigEquals(inboxContractCount, arbmath.UintToBig(batchSeqNum)) {
-		// If it's been under a minute since the last batch was posted, and the inbox tracker is exactly one batch behind,
-		// then there isn't an error. We're just waiting for the inbox tracker to read the most recently posted batch.
-		if timeSinceNextMessage <= time.Minute && arbmath.BigEquals(inboxContractCount, arbmath.UintToBig(batchSeqNum+1)) {
-			return nil, nil
-		}
-		return nil, fmt.Errorf("inbox tracker not synced: contract has %v batches but inbox tracker has %v", inboxContractCount, batchSeqNum)
+	data, err := b.encodeAddBatch(pendingBatchCount, sequencerMessage, delayedMessages)
+	if err != nil {
+		return 0, err
 	}
-	var prevBatchMeta BatchMetadata
-	if batchSeqNum > 0 {
-		var err error
-		prevBatchMeta, err = b.inbox.GetBatchMetadata(batchSeqNum - 1)
-		if err != nil {
-			return nil, err
-		}
+	gas, err := b.l1Reader.Client().EstimateGas(ctx, ethereum.CallMsg{
+		From: b.dataPoster.From(),
+		To:   &b.seqInboxAddr,
+		Data: data,
+	})
+	if err != nil {
+		return 0, err
 	}
-	if b.building == nil || b.building.batchSeqNum != batchSeqNum {
-		b.building = &buildingBatch{
-			segments:    newBatchSegments(prevBatchMeta.DelayedMessageCount, b.config),
-			msgCount:    prevBatchMeta.MessageCount,
-			batchSeqNum: batchSeqNum,
-		}
+	return gas + b.config.ExtraBatchGas, nil
+}
+
+// Returns true if a batch was posted
+func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context) (bool, error) {
+	nonce, batchPosition, err := b.dataPoster.GetNextNonceAndMeta(ctx)
+	if errors.Is(err, dataposter.ErrTooManyPending) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
 	}
 	msgCount, err := b.streamer.GetMessageCount()
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+	if msgCount <= batchPosition.MessageCount {
+		// There's nothing after the newest batch, therefore batch posting was not required
+		return false, nil
+	}
+	firstMsg, err := b.streamer.GetMessage(batchPosition.MessageCount)
+	if err != nil {
+		return false, err
+	}
+	firstMsgTime := time.Unix(int64(firstMsg.Message.Header.Timestamp), 0)
+	batchPosterBacklogGauge.Update(int64(msgCount - batchPosition.MessageCount))
+
+	if b.building == nil || b.building.startMsgCount != batchPosition.MessageCount {
+		b.building = &buildingBatch{
+			segments:      newBatchSegments(batchPosition.DelayedMessageCount, b.config),
+			msgCount:      batchPosition.MessageCount,
+			startMsgCount: batchPosition.MessageCount,
+		}
 	}
 
-	forcePostBatch := timeSinceNextMessage >= b.config.MaxBatchPostInterval
+	forcePostBatch := time.Since(firstMsgTime) >= b.config.MaxBatchPostInterval
 	haveUsefulMessage := false
 
 	for b.building.msgCount < msgCount {
@@ -393,151 +483,123 @@ func (b *BatchPoster) maybePostSequencerBatch(ctx context.Context, batchSeqNum u
 
 	if b.building.segments.IsEmpty() {
 		// we don't need to post a batch for the time being
-		b.pendingMsgTimestamp = time.Now()
-		return nil, nil
+		return false, nil
 	}
 	if !forcePostBatch || !haveUsefulMessage {
 		// the batch isn't full yet and we've posted a batch recently
 		// don't post anything for now
-		return nil, nil
-	}
-	sequencerMsg, err := b.building.segments.CloseAndGetBytes()
-	if err != nil {
-		return nil, err
-	}
-	if sequencerMsg == nil {
-		log.Debug("BatchPoster: batch nil", "sequence nr.", batchSeqNum, "from", prevBatchMeta.MessageCount, "prev delayed", prevBatchMeta.DelayedMessageCount)
-		b.building = nil // a closed batchSegments can't be reused
-		return nil, nil
-	}
-
-	if b.das != nil {
-		cert, err := b.das.Store(ctx, sequencerMsg, uint64(time.Now().Add(b.config.DASRetentionPeriod).Unix()), []byte{}) // b.das will append signature if enabled
-		if err != nil {
-			log.Warn("Unable to batch to DAS, falling back to storing data on chain", "err", err)
-			if b.config.DisableDasFallbackStoreDataOnChain {
-				return nil, errors.New("Unable to batch to DAS and fallback storing data on chain is disabled")
-			}
-		} else {
-			sequencerMsg = das.Serialize(cert)
-		}
+		return false, nil
 	}
 
-	txOpts := *b.transactOpts
-	txOpts.Context = ctx
-	txOpts.NoSend = true
-	tx, err := b.inboxContract.AddSequencerL2BatchFromOrigin(&txOpts, new(big.Int).SetUint64(batchSeqNum), sequencerMsg, new(big.Int).SetUint64(b.building.segments.delayedMsg), b.gasRefunder)
-	if err != nil {
-		return nil, err
-	}
-	highGasThreshold := new(big.Int).SetUint64(uint64(b.config.HighGasThreshold * params.GWei))
-	if b.config.HighGasThreshold != 0 && tx.GasFeeCap().Cmp(highGasThreshold) >= 0 && timeSinceNextMessage < b.config.HighGasDelay {
-		// The gas fee cap abigen recommended is above the high gas threshold. Check if this is necessary:
+	if b.config.HighGasThreshold != 0 && time.Since(firstMsgTime) < b.config.HighGasDelay {
 		lastHeader, err := b.l1Reader.LastHeader(ctx)
 		if err != nil {
-			return nil, err
+			return false, err
 		}
-		if lastHeader.BaseFee.Cmp(highGasThreshold) >= 0 {
+		highGasThreshold := new(big.Int).SetUint64(uint64(b.config.HighGasThreshold * params.GWei))
+		if lastHeader.BaseFee != nil && lastHeader.BaseFee.Cmp(highGasThreshold) >= 0 {
 			log.Info(
 				"not posting batch yet as gas price is high",
 				"baseFee", float32(lastHeader.BaseFee.Uint64())/params.GWei,
 				"highGasThreshold", b.config.HighGasThreshold,
-				"timeSinceBatchPosted", timeSinceNextMessage,
+				"timeSinceFirstMessage", time.Since(firstMsgTime),
 				"highGasDelay", b.config.HighGasDelay,
 			)
-			return nil, nil
-		} else {
-			// The base fee is below the high gas threshold, so let's lower the gas fee cap and try it.
-			tx = types.NewTx(&types.DynamicFeeTx{
-				ChainID:    tx.ChainId(),
-				Nonce:      tx.Nonce(),
-				GasTipCap:  tx.GasTipCap(),
-				GasFeeCap:  arbmath.BigMulByFrac(highGasThreshold, 6, 5),
-				Gas:        tx.Gas(),
-				To:         tx.To(),
-				Value:      tx.Value(),
-				Data:       tx.Data(),
-				AccessList: tx.AccessList(),
-			})
-			tx, err = txOpts.Signer(txOpts.From, tx)
-			if err != nil {
-				return nil, err
-			}
+			return false, nil
 		}
 	}
-	err = b.l1Reader.Client().SendTransaction(ctx, tx)
+
+	sequencerMsg, err := b.building.segments.CloseAndGetBytes()
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	postingMsgCount := b.building.msgCount
-	log.Info("BatchPoster: batch sent", "tx", tx.Hash(), "sequence nr.", batchSeqNum, "from", prevBatchMeta.MessageCount, "to", postingMsgCount, "prev delayed", prevBatchMeta.DelayedMessageCount, "current delayed", b.building.segments.delayedMsg, "total segments", len(b.building.segments.rawSegments))
-	b.building = nil
-	_, err = b.l1Reader.WaitForTxApproval(ctx, tx)
-	if err != nil {
-		return tx, err
+	if sequencerMsg == nil {
+		log.Debug("BatchPoster: batch nil", "sequence nr.", batchPosition.NextSeqNum, "from", batchPosition.MessageCount, "prev delayed", batchPosition.DelayedMessageCount)
+		b.building = nil // a closed batchSegments can't be reused
+		return false, nil
 	}
-	if postingMsgCount < msgCount {
-		msg, err := b.streamer.GetMessage(postingMsgCount)
+	uncompressedSize := 0
+	for _, segment := range b.building.segments.rawSegments {
+		uncompressedSize += len(segment)
+	}
+	batchPosterSizeHistogram.Update(int64(len(sequencerMsg)))
+	batchPosterUncompressedHistogram.Update(int64(uncompressedSize))
+	batchPosterCompressionRatioGauge.Update(float64(uncompressedSize) / float64(len(sequencerMsg)))
+
+	if b.das != nil {
+		cert, err := b.das.Store(ctx, sequencerMsg, uint64(time.Now().Add(b.config.DASRetentionPeriod).Unix()), []byte{}) // b.das will append signature if enabled
 		if err != nil {
-			return tx, err
+			log.Warn("Unable to batch to DAS, falling back to storing data on chain", "err", err)
+			batchPosterDasFallbackCounter.Inc(1)
+			if b.config.DisableDasFallbackStoreDataOnChain {
+				return false, errors.New("Unable to batch to DAS and fallback storing data on chain is disabled")
+			}
+		} else {
+			sequencerMsg = das.Serialize(cert)
 		}
-		b.pendingMsgTimestamp = time.Unix(int64(msg.Message.Header.Timestamp), 0)
-	} else {
-		b.pendingMsgTimestamp = time.Now()
 	}
-	return tx, nil
-}
 
-// Returns the timestamp of the next message to post, or time.Now() if there's no new messages
-func (b *BatchPoster) recomputePendingMsgTimestamp(ctx context.Context, batchCount uint64) error {
-	if batchCount == 0 {
-		// No batches, therefore batch posting was not required
-		b.pendingMsgTimestamp = time.Now()
-		return nil
-	}
-	batchMsgCount, err := b.inbox.GetBatchMessageCount(batchCount - 1)
+	data, err := b.encodeAddBatch(new(big.Int).SetUint64(batchPosition.NextSeqNum), sequencerMsg, b.building.segments.delayedMsg)
 	if err != nil {
-		return err
+		return false, err
 	}
-	msgCount, err := b.streamer.GetMessageCount()
+	gasLimit, err := b.estimateGas(ctx, sequencerMsg, b.building.segments.delayedMsg)
 	if err != nil {
-		return err
+		return false, err
 	}
-	if msgCount <= batchMsgCount {
-		// There's nothing after the newest batch, therefore batch posting was not required
-		b.pendingMsgTimestamp = time.Now()
-		return nil
+	newMeta := batchPosterPosition{
+		MessageCount:        b.building.msgCount,
+		DelayedMessageCount: b.building.segments.delayedMsg,
+		NextSeqNum:          batchPosition.NextSeqNum + 1,
 	}
-	msg, err := b.streamer.GetMessage(batchMsgCount - 1)
+	tx, err := b.dataPoster.PostTransaction(ctx, firstMsgTime, nonce, newMeta, b.seqInboxAddr, data, gasLimit, nil)
 	if err != nil {
-		return err
+		return false, err
 	}
-	b.pendingMsgTimestamp = time.Unix(int64(msg.Message.Header.Timestamp), 0)
-	return nil
+	batchPosterPostedCounter.Inc(1)
+	batchPosterLatencyHistogram.Update(time.Since(firstMsgTime).Nanoseconds())
+	log.Info(
+		"BatchPoster: batch sent",
+		"tx", tx.Hash(),
+		"nonce", nonce,
+		"sequence nr.", batchPosition.NextSeqNum,
+		"from", batchPosition.MessageCount,
+		"to", b.building.msgCount,
+		"prev delayed", batchPosition.DelayedMessageCount,
+		"current delayed", b.building.segments.delayedMsg,
+		"total segments", len(b.building.segments.rawSegments),
+	)
+	b.building = nil
+	return true, nil
 }
 
 func (b *BatchPoster) Start(ctxIn context.Context) {
+	b.dataPoster.Start(ctxIn)
 	b.StopWaiter.Start(ctxIn)
 	b.CallIteratively(func(ctx context.Context) time.Duration {
-		batchSeqNum, err := b.inbox.GetBatchCount()
-		if err != nil {
-			log.Error("error getting inbox batch count", "err", err)
-			return b.config.PostingErrorDelay
-		}
-		if batchSeqNum != b.lastBatchCount {
-			err := b.recomputePendingMsgTimestamp(ctx, batchSeqNum)
-			if err != nil {
-				log.Error("error getting next message time", "err", err)
-				return b.config.PostingErrorDelay
-			}
-			b.lastBatchCount = batchSeqNum
-		}
-		_, err = b.maybePostSequencerBatch(ctx, batchSeqNum)
+		posted, err := b.maybePostSequencerBatch(ctx)
 		if err != nil {
 			b.building = nil
+			batchPosterErrorCounter.Inc(1)
+			atomic.AddInt64(&b.consecutiveErrors, 1)
 			log.Error("error posting batch", "err", err)
 			return b.config.PostingErrorDelay
 		}
+		atomic.StoreInt64(&b.consecutiveErrors, 0)
+		if posted {
+			// Immediately check whether another batch can be posted
+			return 0
+		}
 		return b.config.BatchPollDelay
 	})
 }
+
+// ConsecutiveErrors is the number of attempts to post a batch that have failed since the last one that didn't
+func (b *BatchPoster) ConsecutiveErrors() int64 {
+	return atomic.LoadInt64(&b.consecutiveErrors)
+}
+
+func (b *BatchPoster) StopAndWait() {
+	b.StopWaiter.StopAndWait()
+	b.dataPoster.StopAndWait()
+}
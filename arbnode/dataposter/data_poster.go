@@ -0,0 +1,478 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package dataposter implements a persistent, nonce-ordered L1 transaction queue.
+// Queued transactions are stored before they're sent, and are replaced with higher
+// EIP-1559 fees on a configurable schedule until they're included on L1.
+package dataposter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/go-ethereum/accounts/abi/bind"
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/go-ethereum/ethdb"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/params"
+	"github.com/tenderly/nitro/go-ethereum/rlp"
+	"github.com/tenderly/nitro/arbutil"
+	"github.com/tenderly/nitro/util/arbmath"
+	"github.com/tenderly/nitro/util/headerreader"
+	"github.com/tenderly/nitro/util/stopwaiter"
+)
+
+type DataPosterConfig struct {
+	ReplacementTimes       string        `koanf:"replacement-times"`
+	MaxMempoolTransactions uint64        `koanf:"max-mempool-transactions"`
+	UpdateInterval         time.Duration `koanf:"update-interval"`
+	TargetPriceGwei        float64       `koanf:"target-price-gwei"`
+	UrgencyGwei            float64       `koanf:"urgency-gwei"`
+	MinFeeCapGwei          float64       `koanf:"min-fee-cap-gwei"`
+	MinTipCapGwei          float64       `koanf:"min-tip-cap-gwei"`
+	MaxTipCapGwei          float64       `koanf:"max-tip-cap-gwei"`
+}
+
+func DataPosterConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".replacement-times", DefaultDataPosterConfig.ReplacementTimes, "comma-separated list of durations since first posting to attempt a replace-by-fee")
+	f.Uint64(prefix+".max-mempool-transactions", DefaultDataPosterConfig.MaxMempoolTransactions, "the maximum number of transactions to have queued in the mempool at once (0 = unlimited)")
+	f.Duration(prefix+".update-interval", DefaultDataPosterConfig.UpdateInterval, "the maximum interval between checks for transactions needing to be sent or replaced")
+	f.Float64(prefix+".target-price-gwei", DefaultDataPosterConfig.TargetPriceGwei, "the target price to use for maximum fee cap calculation")
+	f.Float64(prefix+".urgency-gwei", DefaultDataPosterConfig.UrgencyGwei, "the urgency to use for maximum fee cap calculation")
+	f.Float64(prefix+".min-fee-cap-gwei", DefaultDataPosterConfig.MinFeeCapGwei, "the minimum fee cap to post transactions at")
+	f.Float64(prefix+".min-tip-cap-gwei", DefaultDataPosterConfig.MinTipCapGwei, "the minimum tip cap to post transactions at")
+	f.Float64(prefix+".max-tip-cap-gwei", DefaultDataPosterConfig.MaxTipCapGwei, "the maximum tip cap to post transactions at")
+}
+
+var DefaultDataPosterConfig = DataPosterConfig{
+	ReplacementTimes:       "5m,10m,20m,30m,1h,2h,4h,6h,8h,12h,16h,18h,20h,22h",
+	MaxMempoolTransactions: 10,
+	UpdateInterval:         time.Second * 10,
+	TargetPriceGwei:        60.,
+	UrgencyGwei:            2.,
+	MinFeeCapGwei:          0,
+	MinTipCapGwei:          0.05,
+	MaxTipCapGwei:          5,
+}
+
+var TestDataPosterConfig = DataPosterConfig{
+	ReplacementTimes:       "1s,2s,5s,10s,20s,30s,1m,5m",
+	MaxMempoolTransactions: 10,
+	UpdateInterval:         time.Millisecond * 100,
+	TargetPriceGwei:        60.,
+	UrgencyGwei:            2.,
+	MinFeeCapGwei:          0,
+	MinTipCapGwei:          0.05,
+	MaxTipCapGwei:          5,
+}
+
+// ErrTooManyPending is returned when the configured maximum of mempool transactions is already in flight.
+var ErrTooManyPending = errors.New("too many transactions pending in the mempool")
+
+// DataPoster posts transactions to L1, tracking nonces and replacing stuck transactions with bumped fees.
+// Meta is arbitrary RLP-encodable metadata stored alongside each transaction,
+// which lets users of the DataPoster resume after a restart without re-posting data.
+type DataPoster[Meta any] struct {
+	stopwaiter.StopWaiter
+	headerReader      *headerreader.HeaderReader
+	client            arbutil.L1Interface
+	auth              *bind.TransactOpts
+	config            *DataPosterConfig
+	replacementTimes  []time.Duration
+	metadataRetriever func(ctx context.Context, blockNum *big.Int) (Meta, error)
+
+	// these fields are protected by the mutex
+	mutex     sync.Mutex
+	lastBlock *big.Int
+	balance   *big.Int
+	nonce     uint64
+	queue     QueueStorage[queuedTransaction[Meta]]
+}
+
+// If db is nil, queued transactions are only kept in memory.
+func NewDataPoster[Meta any](headerReader *headerreader.HeaderReader, auth *bind.TransactOpts, db ethdb.Database, config *DataPosterConfig, metadataRetriever func(ctx context.Context, blockNum *big.Int) (Meta, error)) (*DataPoster[Meta], error) {
+	replacementTimes, err := parseReplacementTimes(config.ReplacementTimes)
+	if err != nil {
+		return nil, err
+	}
+	var queue QueueStorage[queuedTransaction[Meta]]
+	if db != nil {
+		queue = NewDatabaseStorage[queuedTransaction[Meta]](db)
+	} else {
+		queue = NewSliceStorage[queuedTransaction[Meta]]()
+	}
+	return &DataPoster[Meta]{
+		headerReader:      headerReader,
+		client:            headerReader.Client(),
+		auth:              auth,
+		config:            config,
+		replacementTimes:  replacementTimes,
+		metadataRetriever: metadataRetriever,
+		queue:             queue,
+	}, nil
+}
+
+func parseReplacementTimes(val string) ([]time.Duration, error) {
+	var res []time.Duration
+	var lastReplacementTime time.Duration
+	for _, s := range strings.Split(val, ",") {
+		if s == "" {
+			continue
+		}
+		t, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse replacement time %q: %w", s, err)
+		}
+		if t <= lastReplacementTime {
+			return nil, errors.New("replacement times must be increasing")
+		}
+		res = append(res, t)
+		lastReplacementTime = t
+	}
+	if len(res) == 0 {
+		log.Warn("disabling replace-by-fee for data poster")
+	}
+	// To avoid special casing "don't replace again", replace in 10 years
+	return append(res, time.Hour*24*365*10), nil
+}
+
+// rlpTime is a time.Time which can be stored with RLP
+type rlpTime time.Time
+
+func (b *rlpTime) DecodeRLP(s *rlp.Stream) error {
+	var nanos uint64
+	err := s.Decode(&nanos)
+	if err != nil {
+		return err
+	}
+	*b = rlpTime(time.Unix(0, int64(nanos)))
+	return nil
+}
+
+func (b rlpTime) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, uint64(time.Time(b).UnixNano()))
+}
+
+type queuedTransaction[Meta any] struct {
+	FullTx          *types.Transaction
+	Data            types.DynamicFeeTx
+	Meta            Meta
+	Sent            bool
+	Created         rlpTime // may be earlier than the tx was given to the data poster
+	NextReplacement rlpTime
+}
+
+func (p *DataPoster[Meta]) From() common.Address {
+	return p.auth.From
+}
+
+// GetNextNonceAndMeta returns the nonce and the metadata the next posted transaction should build on:
+// the metadata of the last queued transaction if there is one, otherwise the metadata retrieved from L1.
+func (p *DataPoster[Meta]) GetNextNonceAndMeta(ctx context.Context) (uint64, Meta, error) {
+	var emptyMeta Meta
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	err := p.updateState(ctx)
+	if err != nil {
+		return 0, emptyMeta, err
+	}
+	lastQueueItem, err := p.queue.GetLast(ctx)
+	if err != nil {
+		return 0, emptyMeta, err
+	}
+	if lastQueueItem != nil {
+		nextNonce := lastQueueItem.Data.Nonce + 1
+		if p.config.MaxMempoolTransactions > 0 && nextNonce >= p.nonce+p.config.MaxMempoolTransactions {
+			return 0, emptyMeta, ErrTooManyPending
+		}
+		return nextNonce, lastQueueItem.Meta, nil
+	}
+	meta, err := p.metadataRetriever(ctx, p.lastBlock)
+	return p.nonce, meta, err
+}
+
+// PendingTransactionCount returns the number of queued transactions which aren't yet included on L1.
+func (p *DataPoster[Meta]) PendingTransactionCount(ctx context.Context) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	err := p.updateState(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return p.queue.Length(ctx)
+}
+
+const minRbfIncrease = arbmath.OneInBips * 11 / 10
+
+func (p *DataPoster[Meta]) getFeeAndTipCaps(ctx context.Context, gasLimit uint64, lastFeeCap *big.Int, lastTipCap *big.Int, dataCreatedAt time.Time) (*big.Int, *big.Int, error) {
+	latestHeader, err := p.headerReader.LastHeader(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if latestHeader.BaseFee == nil {
+		return nil, nil, fmt.Errorf("latest L1 block %v missing BaseFee (either the L1 does not have EIP-1559 or the L1 node is not synced)", latestHeader.Number)
+	}
+	newTipCap, err := p.client.SuggestGasTipCap(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	newTipCap = arbmath.BigMax(newTipCap, floatGweiToBig(p.config.MinTipCapGwei))
+	newTipCap = arbmath.BigMin(newTipCap, floatGweiToBig(p.config.MaxTipCapGwei))
+	newFeeCap := arbmath.BigAdd(arbmath.BigMulByUint(latestHeader.BaseFee, 2), newTipCap)
+	newFeeCap = arbmath.BigMax(newFeeCap, floatGweiToBig(p.config.MinFeeCapGwei))
+
+	// The longer the data has been waiting, the more we're willing to pay to get it included
+	elapsedHours := time.Since(dataCreatedAt).Hours()
+	maxFeeCapGwei := p.config.TargetPriceGwei + p.config.UrgencyGwei*elapsedHours*elapsedHours
+	newFeeCap = arbmath.BigMin(newFeeCap, floatGweiToBig(maxFeeCapGwei))
+
+	if lastFeeCap != nil {
+		// A replacement must bump both caps to be accepted into the mempool
+		newFeeCap = arbmath.BigMax(newFeeCap, arbmath.BigMulByBips(lastFeeCap, minRbfIncrease))
+	}
+	if lastTipCap != nil {
+		newTipCap = arbmath.BigMax(newTipCap, arbmath.BigMulByBips(lastTipCap, minRbfIncrease))
+	}
+
+	if p.balance != nil && gasLimit > 0 {
+		balanceFeeCap := arbmath.BigDivByUint(p.balance, gasLimit)
+		if arbmath.BigGreaterThan(newFeeCap, balanceFeeCap) {
+			log.Warn(
+				"lack of L1 balance prevents posting transaction with desired fee cap",
+				"balance", p.balance,
+				"gasLimit", gasLimit,
+				"desiredFeeCap", newFeeCap,
+				"balanceFeeCap", balanceFeeCap,
+			)
+			newFeeCap = balanceFeeCap
+		}
+	}
+	if arbmath.BigGreaterThan(newTipCap, newFeeCap) {
+		newTipCap = newFeeCap
+	}
+	return newFeeCap, newTipCap, nil
+}
+
+func floatGweiToBig(gwei float64) *big.Int {
+	res, _ := new(big.Float).Mul(big.NewFloat(gwei), big.NewFloat(params.GWei)).Int(nil)
+	return res
+}
+
+// PostTransaction queues a new transaction, which must use the nonce returned by GetNextNonceAndMeta.
+// The transaction is persisted before it's sent, so it will be sent again after a restart if necessary.
+func (p *DataPoster[Meta]) PostTransaction(ctx context.Context, dataCreatedAt time.Time, nonce uint64, meta Meta, to common.Address, calldata []byte, gasLimit uint64, value *big.Int) (*types.Transaction, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	err := p.updateState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	expectedNonce := p.nonce
+	lastQueueItem, err := p.queue.GetLast(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if lastQueueItem != nil {
+		expectedNonce = lastQueueItem.Data.Nonce + 1
+	}
+	if nonce != expectedNonce {
+		return nil, fmt.Errorf("data poster expected next transaction to have nonce %v but was requested to post transaction with nonce %v", expectedNonce, nonce)
+	}
+	if value == nil {
+		value = common.Big0
+	}
+	feeCap, tipCap, err := p.getFeeAndTipCaps(ctx, gasLimit, nil, nil, dataCreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	inner := types.DynamicFeeTx{
+		Nonce:     nonce,
+		GasTipCap: tipCap,
+		GasFeeCap: feeCap,
+		Gas:       gasLimit,
+		To:        &to,
+		Value:     value,
+		Data:      calldata,
+	}
+	fullTx, err := p.signTx(&inner)
+	if err != nil {
+		return nil, err
+	}
+	queuedTx := queuedTransaction[Meta]{
+		Data:            inner,
+		FullTx:          fullTx,
+		Meta:            meta,
+		Sent:            false,
+		Created:         rlpTime(dataCreatedAt),
+		NextReplacement: rlpTime(time.Now().Add(p.replacementTimes[0])),
+	}
+	return fullTx, p.sendTx(ctx, nil, &queuedTx)
+}
+
+func (p *DataPoster[Meta]) signTx(inner *types.DynamicFeeTx) (*types.Transaction, error) {
+	return p.auth.Signer(p.auth.From, types.NewTx(inner))
+}
+
+// the mutex must be held by the caller
+func (p *DataPoster[Meta]) saveTx(ctx context.Context, prevTx *queuedTransaction[Meta], newTx *queuedTransaction[Meta]) error {
+	return p.queue.Put(ctx, newTx.Data.Nonce, prevTx, newTx)
+}
+
+// the mutex must be held by the caller
+func (p *DataPoster[Meta]) sendTx(ctx context.Context, prevTx *queuedTransaction[Meta], newTx *queuedTransaction[Meta]) error {
+	if prevTx != newTx {
+		if err := p.saveTx(ctx, prevTx, newTx); err != nil {
+			return err
+		}
+	}
+	err := p.client.SendTransaction(ctx, newTx.FullTx)
+	if err != nil && !isAlreadyKnownError(err) {
+		log.Warn("error sending L1 transaction, will retry", "nonce", newTx.Data.Nonce, "tx", newTx.FullTx.Hash(), "err", err)
+		// The transaction is saved, so the update loop will attempt to send it again.
+		return nil
+	}
+	newerTx := *newTx
+	newerTx.Sent = true
+	return p.saveTx(ctx, newTx, &newerTx)
+}
+
+func isAlreadyKnownError(err error) bool {
+	s := err.Error()
+	return strings.Contains(s, "already known") || strings.Contains(s, "nonce too low")
+}
+
+// the mutex must be held by the caller
+func (p *DataPoster[Meta]) replaceTx(ctx context.Context, prevTx *queuedTransaction[Meta], elapsed time.Duration) error {
+	newFeeCap, newTipCap, err := p.getFeeAndTipCaps(ctx, prevTx.Data.Gas, prevTx.Data.GasFeeCap, prevTx.Data.GasTipCap, time.Time(prevTx.Created))
+	if err != nil {
+		return err
+	}
+
+	minNewFeeCap := arbmath.BigMulByBips(prevTx.Data.GasFeeCap, minRbfIncrease)
+	newTx := *prevTx
+	if newFeeCap.Cmp(minNewFeeCap) < 0 {
+		log.Debug(
+			"no need to replace by fee transaction",
+			"nonce", prevTx.Data.Nonce,
+			"lastFeeCap", prevTx.Data.GasFeeCap,
+			"recommendedFeeCap", newFeeCap,
+			"lastTipCap", prevTx.Data.GasTipCap,
+			"recommendedTipCap", newTipCap,
+		)
+		newTx.NextReplacement = rlpTime(time.Now().Add(time.Minute))
+		return p.sendTx(ctx, prevTx, &newTx)
+	}
+
+	for _, replacement := range p.replacementTimes {
+		if elapsed >= replacement {
+			continue
+		}
+		newTx.NextReplacement = rlpTime(time.Time(prevTx.Created).Add(replacement))
+		break
+	}
+	newTx.Sent = false
+	newTx.Data.GasFeeCap = newFeeCap
+	newTx.Data.GasTipCap = newTipCap
+	newTx.FullTx, err = p.signTx(&newTx.Data)
+	if err != nil {
+		return err
+	}
+	log.Info(
+		"replacing L1 transaction with higher fee",
+		"nonce", newTx.Data.Nonce,
+		"oldTx", prevTx.FullTx.Hash(),
+		"newTx", newTx.FullTx.Hash(),
+		"feeCap", newFeeCap,
+		"tipCap", newTipCap,
+	)
+	return p.sendTx(ctx, prevTx, &newTx)
+}
+
+// the mutex must be held by the caller
+func (p *DataPoster[Meta]) updateState(ctx context.Context) error {
+	header, err := p.headerReader.LastHeader(ctx)
+	if err != nil {
+		return err
+	}
+	if p.lastBlock != nil && arbmath.BigEquals(p.lastBlock, header.Number) {
+		return nil
+	}
+	nonce, err := p.client.NonceAt(ctx, p.auth.From, header.Number)
+	if err != nil {
+		return err
+	}
+	balance, err := p.client.BalanceAt(ctx, p.auth.From, header.Number)
+	if err != nil {
+		return err
+	}
+	if nonce > p.nonce {
+		log.Info("data poster transactions confirmed", "previousNonce", p.nonce, "newNonce", nonce, "l1Block", header.Number)
+		err = p.queue.Prune(ctx, nonce)
+		if err != nil {
+			return err
+		}
+	}
+	p.lastBlock = header.Number
+	p.balance = balance
+	p.nonce = nonce
+	return nil
+}
+
+const minWait = time.Second
+
+func (p *DataPoster[Meta]) Start(ctxIn context.Context) {
+	p.StopWaiter.Start(ctxIn)
+	p.CallIteratively(func(ctx context.Context) time.Duration {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		err := p.updateState(ctx)
+		if err != nil {
+			log.Warn("failed to update data poster state", "err", err)
+			return minWait
+		}
+		now := time.Now()
+		nextCheck := now.Add(p.config.UpdateInterval)
+		maxTxsToCheck := uint64(1024)
+		if p.config.MaxMempoolTransactions > 0 {
+			maxTxsToCheck = p.config.MaxMempoolTransactions
+		}
+		queueContents, err := p.queue.GetContents(ctx, p.nonce, maxTxsToCheck)
+		if err != nil {
+			log.Warn("failed to get data poster queue contents", "err", err)
+			return minWait
+		}
+		for _, tx := range queueContents {
+			replacing := false
+			if now.After(time.Time(tx.NextReplacement)) {
+				replacing = true
+				err := p.replaceTx(ctx, tx, now.Sub(time.Time(tx.Created)))
+				if err != nil {
+					log.Warn("failed to replace-by-fee L1 transaction", "nonce", tx.Data.Nonce, "err", err)
+				}
+			}
+			if nextCheck.After(time.Time(tx.NextReplacement)) {
+				nextCheck = time.Time(tx.NextReplacement)
+			}
+			if !replacing && !tx.Sent {
+				err := p.sendTx(ctx, tx, tx)
+				if err != nil {
+					log.Warn("failed to re-send L1 transaction", "nonce", tx.Data.Nonce, "err", err)
+				}
+			}
+		}
+		wait := time.Until(nextCheck)
+		if wait < minWait {
+			wait = minWait
+		}
+		return wait
+	})
+}
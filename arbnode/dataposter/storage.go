@@ -0,0 +1,314 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package dataposter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync"
+
+	"github.com/tenderly/nitro/go-ethereum/ethdb"
+	"github.com/tenderly/nitro/go-ethereum/rlp"
+	"github.com/pkg/errors"
+)
+
+// QueueStorage stores a contiguous range of items, indexed by nonce.
+// Put with a nil prevItem appends an item, otherwise it replaces an existing item if it's unchanged since prevItem was read.
+type QueueStorage[Item any] interface {
+	GetContents(ctx context.Context, startingIndex uint64, maxResults uint64) ([]*Item, error)
+	GetLast(ctx context.Context) (*Item, error)
+	Prune(ctx context.Context, keepStartingAt uint64) error
+	Put(ctx context.Context, index uint64, prevItem *Item, newItem *Item) error
+	Length(ctx context.Context) (int, error)
+	IsPersistent() bool
+}
+
+var ErrStorageRace = errors.New("storage race condition detected")
+
+func encodeItem[Item any](item *Item) ([]byte, error) {
+	if item == nil {
+		return nil, nil
+	}
+	return rlp.EncodeToBytes(item)
+}
+
+func decodeItem[Item any](data []byte) (*Item, error) {
+	var item Item
+	err := rlp.DecodeBytes(data, &item)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// SliceStorage is an in-memory QueueStorage. Its contents are lost on restart.
+type SliceStorage[Item any] struct {
+	mutex      sync.Mutex
+	firstIndex uint64
+	queue      [][]byte
+}
+
+func NewSliceStorage[Item any]() *SliceStorage[Item] {
+	return &SliceStorage[Item]{}
+}
+
+func (s *SliceStorage[Item]) GetContents(ctx context.Context, startingIndex uint64, maxResults uint64) ([]*Item, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if startingIndex < s.firstIndex {
+		startingIndex = s.firstIndex
+	}
+	if startingIndex >= s.firstIndex+uint64(len(s.queue)) || maxResults == 0 {
+		return nil, nil
+	}
+	start := startingIndex - s.firstIndex
+	end := uint64(len(s.queue))
+	if end-start > maxResults {
+		end = start + maxResults
+	}
+	var items []*Item
+	for _, data := range s.queue[start:end] {
+		item, err := decodeItem[Item](data)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *SliceStorage[Item]) GetLast(ctx context.Context) (*Item, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.queue) == 0 {
+		return nil, nil
+	}
+	return decodeItem[Item](s.queue[len(s.queue)-1])
+}
+
+func (s *SliceStorage[Item]) Prune(ctx context.Context, keepStartingAt uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if keepStartingAt >= s.firstIndex+uint64(len(s.queue)) {
+		s.queue = nil
+	} else if keepStartingAt >= s.firstIndex {
+		s.queue = s.queue[keepStartingAt-s.firstIndex:]
+	} else {
+		return nil
+	}
+	s.firstIndex = keepStartingAt
+	return nil
+}
+
+func (s *SliceStorage[Item]) Put(ctx context.Context, index uint64, prevItem *Item, newItem *Item) error {
+	if newItem == nil {
+		return fmt.Errorf("tried to insert nil item at index %v", index)
+	}
+	prevEnc, err := encodeItem(prevItem)
+	if err != nil {
+		return err
+	}
+	newEnc, err := encodeItem(newItem)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.queue) == 0 {
+		if prevItem != nil {
+			return ErrStorageRace
+		}
+		s.firstIndex = index
+		s.queue = append(s.queue, newEnc)
+		return nil
+	}
+	if index < s.firstIndex {
+		return fmt.Errorf("index %v below first index %v", index, s.firstIndex)
+	}
+	queueIdx := index - s.firstIndex
+	if queueIdx > uint64(len(s.queue)) {
+		return fmt.Errorf("attempted to set out-of-bounds index %v in queue starting at %v of length %v", index, s.firstIndex, len(s.queue))
+	}
+	if queueIdx == uint64(len(s.queue)) {
+		if prevItem != nil {
+			return ErrStorageRace
+		}
+		s.queue = append(s.queue, newEnc)
+		return nil
+	}
+	if !bytes.Equal(s.queue[queueIdx], prevEnc) {
+		return ErrStorageRace
+	}
+	s.queue[queueIdx] = newEnc
+	return nil
+}
+
+func (s *SliceStorage[Item]) Length(ctx context.Context) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.queue), nil
+}
+
+func (s *SliceStorage[Item]) IsPersistent() bool {
+	return false
+}
+
+var databaseItemPrefix []byte = []byte("q") // maps a nonce to a queued item
+
+// DatabaseStorage is a QueueStorage persisted in a (typically prefixed) node database.
+type DatabaseStorage[Item any] struct {
+	mutex sync.Mutex
+	db    ethdb.Database
+}
+
+func NewDatabaseStorage[Item any](db ethdb.Database) *DatabaseStorage[Item] {
+	return &DatabaseStorage[Item]{db: db}
+}
+
+func databaseItemKey(index uint64) []byte {
+	key := make([]byte, len(databaseItemPrefix)+8)
+	copy(key, databaseItemPrefix)
+	binary.BigEndian.PutUint64(key[len(databaseItemPrefix):], index)
+	return key
+}
+
+func databaseItemIndex(key []byte) (uint64, error) {
+	if len(key) != len(databaseItemPrefix)+8 || !bytes.HasPrefix(key, databaseItemPrefix) {
+		return 0, fmt.Errorf("unexpected data poster database key %v", key)
+	}
+	return binary.BigEndian.Uint64(key[len(databaseItemPrefix):]), nil
+}
+
+func (s *DatabaseStorage[Item]) GetContents(ctx context.Context, startingIndex uint64, maxResults uint64) ([]*Item, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	iter := s.db.NewIterator(databaseItemPrefix, databaseItemKey(startingIndex)[len(databaseItemPrefix):])
+	defer iter.Release()
+	var items []*Item
+	for uint64(len(items)) < maxResults && iter.Next() {
+		item, err := decodeItem[Item](iter.Value())
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, iter.Error()
+}
+
+func (s *DatabaseStorage[Item]) lastIndex() (uint64, []byte, error) {
+	iter := s.db.NewIterator(databaseItemPrefix, nil)
+	defer iter.Release()
+	var lastKey, lastValue []byte
+	for iter.Next() {
+		lastKey = copyBytes(iter.Key())
+		lastValue = copyBytes(iter.Value())
+	}
+	if err := iter.Error(); err != nil {
+		return 0, nil, err
+	}
+	if lastKey == nil {
+		return 0, nil, nil
+	}
+	index, err := databaseItemIndex(lastKey)
+	return index, lastValue, err
+}
+
+func (s *DatabaseStorage[Item]) GetLast(ctx context.Context) (*Item, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, value, err := s.lastIndex()
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return decodeItem[Item](value)
+}
+
+func (s *DatabaseStorage[Item]) Prune(ctx context.Context, keepStartingAt uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	iter := s.db.NewIterator(databaseItemPrefix, nil)
+	defer iter.Release()
+	batch := s.db.NewBatch()
+	for iter.Next() {
+		index, err := databaseItemIndex(iter.Key())
+		if err != nil {
+			return err
+		}
+		if index >= keepStartingAt {
+			break
+		}
+		if err := batch.Delete(copyBytes(iter.Key())); err != nil {
+			return err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return batch.Write()
+}
+
+func (s *DatabaseStorage[Item]) Put(ctx context.Context, index uint64, prevItem *Item, newItem *Item) error {
+	if newItem == nil {
+		return fmt.Errorf("tried to insert nil item at index %v", index)
+	}
+	prevEnc, err := encodeItem(prevItem)
+	if err != nil {
+		return err
+	}
+	newEnc, err := encodeItem(newItem)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	key := databaseItemKey(index)
+	has, err := s.db.Has(key)
+	if err != nil {
+		return err
+	}
+	if has {
+		existing, err := s.db.Get(key)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(existing, prevEnc) {
+			return ErrStorageRace
+		}
+	} else {
+		if prevItem != nil {
+			return ErrStorageRace
+		}
+		lastIndex, lastValue, err := s.lastIndex()
+		if err != nil {
+			return err
+		}
+		if lastValue != nil && lastIndex+1 != index {
+			return fmt.Errorf("attempted to set out-of-bounds index %v after last index %v", index, lastIndex)
+		}
+	}
+	return s.db.Put(key, newEnc)
+}
+
+func (s *DatabaseStorage[Item]) Length(ctx context.Context) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	iter := s.db.NewIterator(databaseItemPrefix, nil)
+	defer iter.Release()
+	length := 0
+	for iter.Next() {
+		length++
+	}
+	return length, iter.Error()
+}
+
+func (s *DatabaseStorage[Item]) IsPersistent() bool {
+	return true
+}
+
+// iterator keys and values are only valid until the next call to Next
+func copyBytes(data []byte) []byte {
+	return append([]byte{}, data...)
+}
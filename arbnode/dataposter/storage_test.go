@@ -0,0 +1,150 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package dataposter
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/core/rawdb"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/go-ethereum/rlp"
+	"github.com/tenderly/nitro/util/testhelpers"
+)
+
+type testMeta struct {
+	MessageCount uint64
+	NextSeqNum   uint64
+}
+
+func Require(t *testing.T, err error, printables ...interface{}) {
+	t.Helper()
+	testhelpers.RequireImpl(t, err, printables...)
+}
+
+func Fail(t *testing.T, printables ...interface{}) {
+	t.Helper()
+	testhelpers.FailImpl(t, printables...)
+}
+
+func newTestItem(nonce uint64) *queuedTransaction[testMeta] {
+	to := common.HexToAddress("0x1234")
+	inner := types.DynamicFeeTx{
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(2),
+		Gas:       21000,
+		To:        &to,
+		Value:     big.NewInt(0),
+		Data:      []byte{byte(nonce)},
+	}
+	return &queuedTransaction[testMeta]{
+		FullTx:          types.NewTx(&inner),
+		Data:            inner,
+		Meta:            testMeta{MessageCount: nonce * 10, NextSeqNum: nonce + 1},
+		Created:         rlpTime(time.Unix(1000, 5)),
+		NextReplacement: rlpTime(time.Unix(2000, 0)),
+	}
+}
+
+func testQueueStorage(t *testing.T, storage QueueStorage[queuedTransaction[testMeta]]) {
+	ctx := context.Background()
+
+	last, err := storage.GetLast(ctx)
+	Require(t, err)
+	if last != nil {
+		Fail(t, "expected empty storage")
+	}
+
+	for nonce := uint64(5); nonce < 10; nonce++ {
+		Require(t, storage.Put(ctx, nonce, nil, newTestItem(nonce)))
+	}
+	if err := storage.Put(ctx, 11, nil, newTestItem(11)); err == nil {
+		Fail(t, "expected error putting item with gap in nonces")
+	}
+	length, err := storage.Length(ctx)
+	Require(t, err)
+	if length != 5 {
+		Fail(t, "unexpected length", length)
+	}
+
+	last, err = storage.GetLast(ctx)
+	Require(t, err)
+	if last.Data.Nonce != 9 || last.Meta.NextSeqNum != 10 || last.FullTx.Nonce() != 9 {
+		Fail(t, "unexpected last item", last.Data.Nonce, last.Meta)
+	}
+	if !time.Time(last.Created).Equal(time.Unix(1000, 5)) {
+		Fail(t, "created time not preserved", time.Time(last.Created))
+	}
+
+	contents, err := storage.GetContents(ctx, 6, 2)
+	Require(t, err)
+	if len(contents) != 2 || contents[0].Data.Nonce != 6 || contents[1].Data.Nonce != 7 {
+		Fail(t, "unexpected contents", contents)
+	}
+
+	replacement := *contents[0]
+	replacement.Sent = true
+	Require(t, storage.Put(ctx, 6, contents[0], &replacement))
+	if err := storage.Put(ctx, 6, contents[0], &replacement); !errors.Is(err, ErrStorageRace) {
+		Fail(t, "expected storage race, got", err)
+	}
+	contents, err = storage.GetContents(ctx, 6, 1)
+	Require(t, err)
+	if !contents[0].Sent {
+		Fail(t, "replacement not stored")
+	}
+
+	Require(t, storage.Prune(ctx, 8))
+	contents, err = storage.GetContents(ctx, 0, 100)
+	Require(t, err)
+	if len(contents) != 2 || contents[0].Data.Nonce != 8 {
+		Fail(t, "unexpected contents after prune", contents)
+	}
+	Require(t, storage.Put(ctx, 10, nil, newTestItem(10)))
+
+	Require(t, storage.Prune(ctx, 20))
+	length, err = storage.Length(ctx)
+	Require(t, err)
+	if length != 0 {
+		Fail(t, "unexpected length after pruning everything", length)
+	}
+}
+
+func TestSliceStorage(t *testing.T) {
+	testQueueStorage(t, NewSliceStorage[queuedTransaction[testMeta]]())
+}
+
+func TestDatabaseStorage(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	storage := NewDatabaseStorage[queuedTransaction[testMeta]](rawdb.NewTable(db, "b"))
+	testQueueStorage(t, storage)
+
+	// A second storage over the same database sees the same queue, as after a restart
+	Require(t, storage.Put(context.Background(), 3, nil, newTestItem(3)))
+	restarted := NewDatabaseStorage[queuedTransaction[testMeta]](rawdb.NewTable(db, "b"))
+	last, err := restarted.GetLast(context.Background())
+	Require(t, err)
+	if last == nil || last.Data.Nonce != 3 {
+		Fail(t, "queue not persisted", last)
+	}
+}
+
+func TestQueuedTransactionEncoding(t *testing.T) {
+	item := newTestItem(42)
+	enc, err := rlp.EncodeToBytes(item)
+	Require(t, err)
+	var decoded queuedTransaction[testMeta]
+	Require(t, rlp.DecodeBytes(enc, &decoded))
+	if decoded.FullTx.Hash() != item.FullTx.Hash() || decoded.Meta != item.Meta {
+		Fail(t, "decoded item differs", decoded.Meta, item.Meta)
+	}
+	if !time.Time(decoded.NextReplacement).Equal(time.Time(item.NextReplacement)) {
+		Fail(t, "decoded replacement time differs")
+	}
+}
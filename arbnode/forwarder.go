@@ -6,14 +6,18 @@ package arbnode
 import (
 	"context"
 
+	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
 	"github.com/tenderly/nitro/go-ethereum/core/types"
 	"github.com/tenderly/nitro/go-ethereum/ethclient"
+	"github.com/tenderly/nitro/go-ethereum/rpc"
+	"github.com/tenderly/nitro/arbos"
 	"github.com/pkg/errors"
 )
 
 type TxForwarder struct {
-	target string
-	client *ethclient.Client
+	target    string
+	rpcClient *rpc.Client
+	client    *ethclient.Client
 }
 
 func NewForwarder(target string) *TxForwarder {
@@ -22,23 +26,32 @@ func NewForwarder(target string) *TxForwarder {
 	}
 }
 
-func (f *TxForwarder) PublishTransaction(ctx context.Context, tx *types.Transaction) error {
+func (f *TxForwarder) PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbos.ConditionalOptions) error {
 	if f.client == nil {
 		return errors.New("sequencer temporarily unavailable")
 	}
-	return f.client.SendTransaction(ctx, tx)
+	if options == nil {
+		return f.client.SendTransaction(ctx, tx)
+	}
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return f.rpcClient.CallContext(ctx, nil, "eth_sendRawTransactionConditional", hexutil.Bytes(data), options)
 }
 
 func (f *TxForwarder) Initialize(ctx context.Context) error {
 	if f.target == "" {
+		f.rpcClient = nil
 		f.client = nil
 		return nil
 	}
-	client, err := ethclient.DialContext(ctx, f.target)
+	rpcClient, err := rpc.DialContext(ctx, f.target)
 	if err != nil {
 		return err
 	}
-	f.client = client
+	f.rpcClient = rpcClient
+	f.client = ethclient.NewClient(rpcClient)
 	return nil
 }
 
@@ -54,7 +67,7 @@ func NewTxDropper() *TxDropper {
 	return &TxDropper{}
 }
 
-func (f *TxDropper) PublishTransaction(ctx context.Context, tx *types.Transaction) error {
+func (f *TxDropper) PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbos.ConditionalOptions) error {
 	return errors.New("transactions not supported by this endpoint")
 }
 
@@ -0,0 +1,339 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/util/stopwaiter"
+)
+
+type HealthConfig struct {
+	Addr                 string        `koanf:"addr"`
+	L1MaxHeaderAge       time.Duration `koanf:"l1-max-header-age"`
+	StreamerMaxLag       uint64        `koanf:"streamer-max-lag"`
+	ValidatorMaxLag      uint64        `koanf:"validator-max-lag"`
+	BatchPosterMaxErrors int64         `koanf:"batch-poster-max-errors"`
+	L1MaxStall           time.Duration `koanf:"l1-max-stall"`
+}
+
+var DefaultHealthConfig = HealthConfig{
+	Addr:                 "",
+	L1MaxHeaderAge:       5 * time.Minute,
+	StreamerMaxLag:       20,
+	ValidatorMaxLag:      1000,
+	BatchPosterMaxErrors: 5,
+	L1MaxStall:           30 * time.Minute,
+}
+
+func HealthConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".addr", DefaultHealthConfig.Addr, "if non-empty, launch an HTTP service binding to this address serving /livez and /readyz")
+	f.Duration(prefix+".l1-max-header-age", DefaultHealthConfig.L1MaxHeaderAge, "the node isn't ready if no new L1 header was read for this long")
+	f.Uint64(prefix+".streamer-max-lag", DefaultHealthConfig.StreamerMaxLag, "the node isn't ready if more than this many messages are waiting for blocks to be produced")
+	f.Uint64(prefix+".validator-max-lag", DefaultHealthConfig.ValidatorMaxLag, "the node isn't ready if the block validator is more than this many blocks behind the chain head")
+	f.Int64(prefix+".batch-poster-max-errors", DefaultHealthConfig.BatchPosterMaxErrors, "the node isn't ready if this many attempts to post a batch failed in a row (0 to ignore batch poster errors)")
+	f.Duration(prefix+".l1-max-stall", DefaultHealthConfig.L1MaxStall, "the node isn't live if no new L1 header was read for this long, so it gets restarted (0 to only check its components are running)")
+}
+
+// ComponentHealth is the state of a single component of the node
+type ComponentHealth struct {
+	Healthy bool                   `json:"healthy"`
+	Error   string                 `json:"error,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func healthy(details map[string]interface{}) *ComponentHealth {
+	return &ComponentHealth{Healthy: true, Details: details}
+}
+
+func unhealthy(details map[string]interface{}, format string, args ...interface{}) *ComponentHealth {
+	return &ComponentHealth{Error: fmt.Sprintf(format, args...), Details: details}
+}
+
+// HealthReport is served by the health endpoints, with the state of every component checked
+type HealthReport struct {
+	Healthy    bool                        `json:"healthy"`
+	Components map[string]*ComponentHealth `json:"components"`
+}
+
+type healthCheck struct {
+	name  string
+	check func() *ComponentHealth
+}
+
+// HealthServer serves the node's liveness on /livez and readiness on /readyz.
+// A component failing readiness only means the node shouldn't be sent traffic, while failing liveness means it should be restarted.
+// Liveness checks the components are running and, if reading from L1, that new L1 headers still arrive.
+type HealthServer struct {
+	stopwaiter.StopWaiter
+	config    *HealthConfig
+	liveness  []healthCheck
+	readiness []healthCheck
+	started   time.Time
+
+	// nil if there's no inbox reader
+	inboxCaughtUpChan chan bool
+	// Atomic
+	inboxCaughtUp int32
+}
+
+type stoppable interface {
+	Stopped() bool
+}
+
+func livenessCheck(component stoppable) func() *ComponentHealth {
+	return func() *ComponentHealth {
+		if component.Stopped() {
+			return unhealthy(nil, "stopped")
+		}
+		return healthy(nil)
+	}
+}
+
+func NewHealthServer(config *HealthConfig, n *Node) *HealthServer {
+	h := &HealthServer{config: config}
+	var liveness, readiness []healthCheck
+	addLiveness := func(name string, component stoppable) {
+		liveness = append(liveness, healthCheck{name, livenessCheck(component)})
+	}
+
+	addLiveness("txStreamer", n.TxStreamer)
+	readiness = append(readiness, healthCheck{"txStreamer", func() *ComponentHealth {
+		return checkStreamerHealth(config, n.TxStreamer)
+	}})
+	if n.L1Reader != nil {
+		liveness = append(liveness, healthCheck{"l1Reader", func() *ComponentHealth {
+			if n.L1Reader.Stopped() {
+				return unhealthy(nil, "stopped")
+			}
+			_, received := n.L1Reader.LastHeaderReceived()
+			return checkL1ReaderProgress(config, received, h.started, time.Now())
+		}})
+		readiness = append(readiness, healthCheck{"l1Reader", func() *ComponentHealth {
+			header, received := n.L1Reader.LastHeaderReceived()
+			return checkL1ReaderHealth(config, header, received, n.L1Reader.LastReadError(), time.Now())
+		}})
+	}
+	if n.InboxReader != nil {
+		addLiveness("inboxReader", n.InboxReader)
+		h.inboxCaughtUpChan = n.InboxReader.CaughtUp()
+		readiness = append(readiness, healthCheck{"inboxReader", func() *ComponentHealth {
+			lastReadBlock, batchCount := n.InboxReader.GetLastReadBlockAndBatchCount()
+			details := map[string]interface{}{
+				"lastReadBlock": lastReadBlock,
+				"batchCount":    batchCount,
+			}
+			if atomic.LoadInt32(&h.inboxCaughtUp) == 0 {
+				return unhealthy(details, "catching up with L1")
+			}
+			return healthy(details)
+		}})
+	}
+	if len(n.BroadcastClients) > 0 {
+		readiness = append(readiness, healthCheck{"feed", func() *ComponentHealth {
+			return checkFeedHealth(n.BroadcastClients)
+		}})
+	}
+	if n.BlockValidator != nil {
+		addLiveness("blockValidator", n.BlockValidator)
+		readiness = append(readiness, healthCheck{"blockValidator", func() *ComponentHealth {
+			lag := n.BlockValidator.ValidationLag()
+			details := map[string]interface{}{
+				"lastBlockValidated": n.BlockValidator.LastBlockValidated(),
+				"lag":                lag,
+			}
+			if lag > config.ValidatorMaxLag {
+				return unhealthy(details, "%v blocks behind the chain head", lag)
+			}
+			return healthy(details)
+		}})
+	}
+	if n.BatchPoster != nil {
+		addLiveness("batchPoster", n.BatchPoster)
+		readiness = append(readiness, healthCheck{"batchPoster", func() *ComponentHealth {
+			errorCount := n.BatchPoster.ConsecutiveErrors()
+			details := map[string]interface{}{
+				"consecutiveErrors": errorCount,
+			}
+			if config.BatchPosterMaxErrors > 0 && errorCount >= config.BatchPosterMaxErrors {
+				return unhealthy(details, "failed to post a batch %v times in a row", errorCount)
+			}
+			return healthy(details)
+		}})
+	}
+	h.liveness = liveness
+	h.readiness = readiness
+	return h
+}
+
+func checkL1ReaderHealth(config *HealthConfig, header *types.Header, received time.Time, readErr error, now time.Time) *ComponentHealth {
+	if header == nil {
+		if readErr != nil {
+			return unhealthy(nil, "no L1 header read yet: %v", readErr)
+		}
+		return unhealthy(nil, "no L1 header read yet")
+	}
+	age := now.Sub(received)
+	details := map[string]interface{}{
+		"blockNumber": header.Number.Uint64(),
+		"headerAge":   age.String(),
+	}
+	if readErr != nil {
+		return unhealthy(details, "failed reading L1 header: %v", readErr)
+	}
+	if age > config.L1MaxHeaderAge {
+		return unhealthy(details, "no new L1 header for %v", age)
+	}
+	return healthy(details)
+}
+
+// checkL1ReaderProgress fails if no new L1 header was read for the max stall, since the server started if none was yet
+func checkL1ReaderProgress(config *HealthConfig, received time.Time, started time.Time, now time.Time) *ComponentHealth {
+	if config.L1MaxStall == 0 {
+		return healthy(nil)
+	}
+	if received.IsZero() {
+		received = started
+	}
+	stalled := now.Sub(received)
+	details := map[string]interface{}{
+		"sinceLastHeader": stalled.String(),
+	}
+	if stalled > config.L1MaxStall {
+		return unhealthy(details, "no new L1 header for %v", stalled)
+	}
+	return healthy(details)
+}
+
+func checkStreamerHealth(config *HealthConfig, streamer *TransactionStreamer) *ComponentHealth {
+	lag, err := streamer.BlockProductionLag()
+	if err != nil {
+		return unhealthy(nil, "%v", err)
+	}
+	details := map[string]interface{}{
+		"lag": lag,
+	}
+	if uint64(lag) > config.StreamerMaxLag {
+		return unhealthy(details, "%v messages waiting for blocks to be produced", lag)
+	}
+	return healthy(details)
+}
+
+type feedClient interface {
+	URL() string
+	Connected() bool
+}
+
+func checkFeedHealth[C feedClient](clients []C) *ComponentHealth {
+	connected := make(map[string]interface{})
+	anyConnected := false
+	for _, client := range clients {
+		connected[client.URL()] = client.Connected()
+		anyConnected = anyConnected || client.Connected()
+	}
+	details := map[string]interface{}{
+		"connected": connected,
+	}
+	if !anyConnected {
+		return unhealthy(details, "not connected to any feed")
+	}
+	return healthy(details)
+}
+
+func runHealthChecks(checks []healthCheck) *HealthReport {
+	report := &HealthReport{
+		Healthy:    true,
+		Components: make(map[string]*ComponentHealth, len(checks)),
+	}
+	for _, check := range checks {
+		result := check.check()
+		report.Components[check.name] = result
+		report.Healthy = report.Healthy && result.Healthy
+	}
+	return report
+}
+
+func (h *HealthServer) serveChecks(checks []healthCheck) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		report := runHealthChecks(checks)
+		if h.Stopped() {
+			report.Healthy = false
+		}
+		if !report.Healthy {
+			var failing []string
+			for name, component := range report.Components {
+				if !component.Healthy {
+					failing = append(failing, name)
+				}
+			}
+			sort.Strings(failing)
+			log.Debug("health check failing", "path", request.URL.Path, "components", failing)
+		}
+		response.Header().Set("Content-Type", "application/json")
+		if report.Healthy {
+			response.WriteHeader(http.StatusOK)
+		} else {
+			response.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(response).Encode(report); err != nil {
+			log.Debug("error writing health report", "err", err)
+		}
+	}
+}
+
+func (h *HealthServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/livez", h.serveChecks(h.liveness))
+	mux.HandleFunc("/readyz", h.serveChecks(h.readiness))
+	return mux
+}
+
+func (h *HealthServer) serve(ctx context.Context) {
+	server := &http.Server{
+		Addr:              h.config.Addr,
+		Handler:           h.Handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		err := server.Shutdown(context.Background())
+		if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+			log.Warn("error shutting down health server", "err", err)
+		}
+	}()
+
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Warn("error serving health server", "err", err)
+	}
+}
+
+func (h *HealthServer) Start(ctxIn context.Context) {
+	h.StopWaiter.Start(ctxIn)
+	h.started = time.Now()
+	if h.inboxCaughtUpChan != nil {
+		h.LaunchThread(func(ctx context.Context) {
+			select {
+			case caughtUp := <-h.inboxCaughtUpChan:
+				if caughtUp {
+					atomic.StoreInt32(&h.inboxCaughtUp, 1)
+				}
+			case <-ctx.Done():
+			}
+		})
+	}
+	h.LaunchThread(h.serve)
+}
@@ -0,0 +1,128 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+)
+
+func TestL1ReaderHealth(t *testing.T) {
+	config := DefaultHealthConfig
+	now := time.Now()
+	header := &types.Header{Number: big.NewInt(100)}
+
+	if health := checkL1ReaderHealth(&config, nil, time.Time{}, nil, now); health.Healthy {
+		t.Error("healthy before reading a header")
+	}
+	if health := checkL1ReaderHealth(&config, header, now.Add(-time.Minute), nil, now); !health.Healthy {
+		t.Error("unhealthy with a recent header:", health.Error)
+	}
+	if health := checkL1ReaderHealth(&config, header, now.Add(-config.L1MaxHeaderAge-time.Second), nil, now); health.Healthy {
+		t.Error("healthy with a stale header")
+	}
+	if health := checkL1ReaderHealth(&config, header, now, errors.New("connection refused"), now); health.Healthy {
+		t.Error("healthy after failing to read a header")
+	}
+}
+
+type testFeedClient struct {
+	url       string
+	connected bool
+}
+
+func (c *testFeedClient) URL() string {
+	return c.url
+}
+
+func (c *testFeedClient) Connected() bool {
+	return c.connected
+}
+
+func TestL1ReaderProgress(t *testing.T) {
+	config := DefaultHealthConfig
+	now := time.Now()
+	started := now.Add(-time.Minute)
+	if health := checkL1ReaderProgress(&config, time.Time{}, started, now); !health.Healthy {
+		t.Error("not live right after starting:", health.Error)
+	}
+	if health := checkL1ReaderProgress(&config, time.Time{}, now.Add(-config.L1MaxStall-time.Second), now); health.Healthy {
+		t.Error("live without ever reading a header")
+	}
+	if health := checkL1ReaderProgress(&config, now.Add(-config.L1MaxStall-time.Second), started, now); health.Healthy {
+		t.Error("live with a stalled L1 reader")
+	}
+	config.L1MaxStall = 0
+	if health := checkL1ReaderProgress(&config, now.Add(-time.Hour), started, now); !health.Healthy {
+		t.Error("stall checked when disabled")
+	}
+}
+
+func TestFeedHealth(t *testing.T) {
+	clients := []*testFeedClient{{url: "ws://a"}, {url: "ws://b"}}
+	if health := checkFeedHealth(clients); health.Healthy {
+		t.Error("healthy without a feed connected")
+	}
+	clients[1].connected = true
+	if health := checkFeedHealth(clients); !health.Healthy {
+		t.Error("unhealthy with a feed connected:", health.Error)
+	}
+}
+
+func TestHealthServer(t *testing.T) {
+	ready := false
+	server := &HealthServer{
+		config: &DefaultHealthConfig,
+		liveness: []healthCheck{
+			{"component", func() *ComponentHealth { return healthy(nil) }},
+		},
+		readiness: []healthCheck{
+			{"component", func() *ComponentHealth { return healthy(nil) }},
+			{"other", func() *ComponentHealth {
+				if ready {
+					return healthy(nil)
+				}
+				return unhealthy(nil, "not ready")
+			}},
+		},
+	}
+	server.StopWaiter.Start(context.Background())
+	defer server.StopAndWait()
+	handler := server.Handler()
+
+	get := func(path string) (int, HealthReport) {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		var report HealthReport
+		if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		return recorder.Code, report
+	}
+
+	if code, _ := get("/livez"); code != http.StatusOK {
+		t.Error("livez returned", code)
+	}
+	code, report := get("/readyz")
+	if code != http.StatusServiceUnavailable || report.Healthy {
+		t.Error("readyz returned", code, "while a component isn't ready")
+	}
+	if !report.Components["component"].Healthy || report.Components["other"].Error != "not ready" {
+		t.Error("unexpected components in report", report.Components)
+	}
+	ready = true
+	if code, report := get("/readyz"); code != http.StatusOK || !report.Healthy {
+		t.Error("readyz returned", code, "with every component ready")
+	}
+}
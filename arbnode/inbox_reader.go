@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
 	flag "github.com/spf13/pflag"
 
 	"github.com/tenderly/nitro/arbutil"
@@ -23,28 +24,162 @@ import (
 	"github.com/tenderly/nitro/util/stopwaiter"
 )
 
+var (
+	l1HeadGauge          = metrics.NewRegisteredGauge("arb/inbox/l1/head", nil)
+	l1LastReadBlockGauge = metrics.NewRegisteredGauge("arb/inbox/l1/lastread", nil)
+	l1ReadLagGauge       = metrics.NewRegisteredGauge("arb/inbox/l1/lag", nil)
+	l1BatchCountGauge    = metrics.NewRegisteredGauge("arb/inbox/l1/batchcount", nil)
+	inboxReorgCounter    = metrics.NewRegisteredCounter("arb/inbox/reorgs", nil)
+)
+
+// updateL1ReadMetrics records how far behind the L1 head the inbox reader has read
+func updateL1ReadMetrics(l1Head, lastReadBlock uint64) {
+	l1HeadGauge.Update(int64(l1Head))
+	l1LastReadBlockGauge.Update(int64(lastReadBlock))
+	if l1Head > lastReadBlock {
+		l1ReadLagGauge.Update(int64(l1Head - lastReadBlock))
+	} else {
+		l1ReadLagGauge.Update(0)
+	}
+}
+
 type InboxReaderConfig struct {
-	DelayBlocks uint64        `koanf:"delay-blocks"`
-	CheckDelay  time.Duration `koanf:"check-delay"`
-	HardReorg   bool          `koanf:"hard-reorg"`
+	DelayBlocks         uint64        `koanf:"delay-blocks"`
+	CheckDelay          time.Duration `koanf:"check-delay"`
+	HardReorg           bool          `koanf:"hard-reorg"`
+	DefaultBlocksToRead uint64        `koanf:"default-blocks-to-read"`
+	MaxBlocksToRead     uint64        `koanf:"max-blocks-to-read"`
+	MaxConcurrentReads  int           `koanf:"max-concurrent-reads"`
 }
 
 func InboxReaderConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Uint64(prefix+".delay-blocks", DefaultInboxReaderConfig.DelayBlocks, "number of latest blocks to ignore to reduce reorgs")
 	f.Duration(prefix+".check-delay", DefaultInboxReaderConfig.CheckDelay, "how long to wait between inbox checks")
 	f.Bool(prefix+".hard-reorg", DefaultInboxReaderConfig.HardReorg, "erase future transactions in addition to overwriting existing ones on reorg")
+	f.Uint64(prefix+".default-blocks-to-read", DefaultInboxReaderConfig.DefaultBlocksToRead, "the number of L1 blocks to read logs from at once, before adapting to how the L1 node responds")
+	f.Uint64(prefix+".max-blocks-to-read", DefaultInboxReaderConfig.MaxBlocksToRead, "the most L1 blocks to read logs from at once")
+	f.Int(prefix+".max-concurrent-reads", DefaultInboxReaderConfig.MaxConcurrentReads, "the most L1 queries to make at once while reading the inbox")
 }
 
 var DefaultInboxReaderConfig = InboxReaderConfig{
-	DelayBlocks: 0,
-	CheckDelay:  20 * time.Second,
-	HardReorg:   false,
+	DelayBlocks:         0,
+	CheckDelay:          20 * time.Second,
+	HardReorg:           false,
+	DefaultBlocksToRead: 100,
+	MaxBlocksToRead:     2000,
+	MaxConcurrentReads:  8,
 }
 
 var TestInboxReaderConfig = InboxReaderConfig{
-	DelayBlocks: 0,
-	CheckDelay:  time.Millisecond * 10,
-	HardReorg:   false,
+	DelayBlocks:         0,
+	CheckDelay:          time.Millisecond * 10,
+	HardReorg:           false,
+	DefaultBlocksToRead: 100,
+	MaxBlocksToRead:     2000,
+	MaxConcurrentReads:  8,
+}
+
+// blockRangeSizer adapts how many L1 blocks are read at once to what the L1 node accepts,
+// growing the range after successful reads and shrinking it when the node rejects it
+type blockRangeSizer struct {
+	size uint64
+	max  uint64
+}
+
+func newBlockRangeSizer(config *InboxReaderConfig) *blockRangeSizer {
+	max := config.MaxBlocksToRead
+	if max == 0 {
+		max = 1
+	}
+	size := arbmath.MinUint(config.DefaultBlocksToRead, max)
+	if size == 0 {
+		size = 1
+	}
+	return &blockRangeSizer{size: size, max: max}
+}
+
+func (s *blockRangeSizer) grow() {
+	s.size = arbmath.MinUint(s.size*2, s.max)
+}
+
+// shrink halves the range if err was caused by reading too many blocks at once,
+// and returns whether the read should be retried with the smaller range
+func (s *blockRangeSizer) shrink(err error) bool {
+	if s.size <= 1 || !isRangeTooLargeError(err) {
+		return false
+	}
+	s.size /= 2
+	return true
+}
+
+// Parts of the errors L1 nodes and RPC providers return when a log query covers too many blocks or results
+var rangeTooLargeErrors = []string{
+	"query returned more than",
+	"response size exceeded",
+	"response size is larger",
+	"block range",
+	"range too large",
+	"range is too large",
+	"is limited to",
+	"exceeds limit",
+	"too many results",
+	"too many blocks",
+	"timeout",
+	"timed out",
+}
+
+func isRangeTooLargeError(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	message := strings.ToLower(err.Error())
+	for _, part := range rangeTooLargeErrors {
+		if strings.Contains(message, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// runConcurrently runs the tasks with at most limit of them at once, and returns the first error
+func runConcurrently(ctx context.Context, limit int, tasks ...func(context.Context) error) error {
+	if limit < 1 {
+		limit = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var wg sync.WaitGroup
+	var errMutex sync.Mutex
+	var firstErr error
+	running := make(chan struct{}, limit)
+	for _, task := range tasks {
+		select {
+		case running <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func(task func(context.Context) error) {
+			defer wg.Done()
+			defer func() { <-running }()
+			err := task(ctx)
+			if err != nil {
+				errMutex.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				errMutex.Unlock()
+				cancel()
+			}
+		}(task)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
 }
 
 type InboxReader struct {
@@ -54,6 +189,7 @@ type InboxReader struct {
 	caughtUp          bool
 	firstMessageBlock *big.Int
 	config            *InboxReaderConfig
+	blocksToRead      *blockRangeSizer
 
 	// Thread safe
 	tracker        *InboxTracker
@@ -82,6 +218,7 @@ func NewInboxReader(tracker *InboxTracker, client arbutil.L1Interface, l1Reader
 		firstMessageBlock: firstMessageBlock,
 		caughtUpChan:      make(chan bool, 1),
 		config:            config,
+		blocksToRead:      newBlockRangeSizer(config),
 	}, nil
 }
 
@@ -141,12 +278,12 @@ func (ir *InboxReader) run(ctx context.Context) error {
 	}
 	newHeaders, unsubscribe := ir.l1Reader.Subscribe(false)
 	defer unsubscribe()
-	blocksToFetch := uint64(100)
 	seenBatchCount := uint64(0)
 	seenBatchCountStored := uint64(math.MaxUint64)
 	storeSeenBatchCount := func() {
 		if seenBatchCountStored != seenBatchCount {
 			atomic.StoreUint64(&ir.lastSeenBatchCount, seenBatchCount)
+			l1BatchCountGauge.Update(int64(seenBatchCount))
 			seenBatchCountStored = seenBatchCount
 		}
 	}
@@ -268,6 +405,7 @@ func (ir *InboxReader) run(ctx context.Context) error {
 			ir.lastReadBlock = currentHeight.Uint64()
 			ir.lastReadBatchCount = checkingBatchCount
 			ir.lastReadMutex.Unlock()
+			updateL1ReadMetrics(currentHeightRaw, currentHeight.Uint64())
 			storeSeenBatchCount()
 			continue
 		}
@@ -292,19 +430,32 @@ func (ir *InboxReader) run(ctx context.Context) error {
 					from = currentHeight
 				}
 			}
-			to := new(big.Int).Add(from, new(big.Int).SetUint64(blocksToFetch))
+			to := new(big.Int).Add(from, new(big.Int).SetUint64(ir.blocksToRead.size))
 			if to.Cmp(currentHeight) > 0 {
 				to = currentHeight
 			}
 			var delayedMessages []*DelayedInboxMessage
-			delayedMessages, err := ir.delayedBridge.LookupMessagesInRange(ctx, from, to)
-			if err != nil {
-				return err
-			}
-			sequencerBatches, err := ir.sequencerInbox.LookupBatchesInRange(ctx, from, to)
+			var sequencerBatches []*SequencerInboxBatch
+			err := runConcurrently(ctx, ir.config.MaxConcurrentReads,
+				func(ctx context.Context) error {
+					var err error
+					delayedMessages, err = ir.delayedBridge.LookupMessagesInRange(ctx, from, to)
+					return err
+				},
+				func(ctx context.Context) error {
+					var err error
+					sequencerBatches, err = ir.sequencerInbox.LookupBatchesInRange(ctx, from, to)
+					return err
+				},
+			)
 			if err != nil {
+				if ctx.Err() == nil && ir.blocksToRead.shrink(err) {
+					log.Debug("L1 node rejected log query, reading fewer blocks at once", "from", from.String(), "to", to.String(), "blocks", ir.blocksToRead.size, "err", err)
+					continue
+				}
 				return err
 			}
+			ir.blocksToRead.grow()
 			if !ir.caughtUp && to.Cmp(currentHeight) == 0 {
 				// TODO better caught up tracking
 				ir.caughtUp = true
@@ -378,6 +529,10 @@ func (ir *InboxReader) run(ctx context.Context) error {
 
 			log.Trace("looking up messages", "from", from.String(), "to", to.String(), "reorgingDelayed", reorgingDelayed, "reorgingSequencer", reorgingSequencer)
 			if !reorgingDelayed && !reorgingSequencer && (len(delayedMessages) != 0 || len(sequencerBatches) != 0) {
+				err = ir.prefetchBatchData(ctx, sequencerBatches)
+				if err != nil {
+					return err
+				}
 				delayedMismatch, err := ir.addMessages(ctx, sequencerBatches, delayedMessages)
 				if err != nil {
 					return err
@@ -391,10 +546,12 @@ func (ir *InboxReader) run(ctx context.Context) error {
 					ir.lastReadBlock = to.Uint64()
 					ir.lastReadBatchCount = sequencerBatches[len(sequencerBatches)-1].SequenceNumber + 1
 					ir.lastReadMutex.Unlock()
+					updateL1ReadMetrics(currentHeightRaw, to.Uint64())
 					storeSeenBatchCount()
 				}
 			}
 			if reorgingDelayed || reorgingSequencer {
+				inboxReorgCounter.Inc(1)
 				from, err = ir.getPrevBlockForReorg(from)
 				if err != nil {
 					return err
@@ -409,6 +566,7 @@ func (ir *InboxReader) run(ctx context.Context) error {
 			ir.lastReadBlock = currentHeight.Uint64()
 			ir.lastReadBatchCount = checkingBatchCount
 			ir.lastReadMutex.Unlock()
+			updateL1ReadMetrics(currentHeightRaw, currentHeight.Uint64())
 			storeSeenBatchCount()
 		}
 	}
@@ -428,6 +586,23 @@ func (r *InboxReader) addMessages(ctx context.Context, sequencerBatches []*Seque
 	return false, nil
 }
 
+// prefetchBatchData reads the data of batches that isn't in their batch event concurrently,
+// so the tracker finds it cached instead of reading it one batch at a time
+func (r *InboxReader) prefetchBatchData(ctx context.Context, batches []*SequencerInboxBatch) error {
+	var tasks []func(context.Context) error
+	for _, batch := range batches {
+		if batch.dataLocation != batchDataSeparateEvent && batch.dataLocation != batchDataTxInput {
+			continue
+		}
+		batch := batch
+		tasks = append(tasks, func(ctx context.Context) error {
+			_, err := batch.Serialize(ctx, r.client)
+			return err
+		})
+	}
+	return runConcurrently(ctx, r.config.MaxConcurrentReads, tasks...)
+}
+
 func (r *InboxReader) getPrevBlockForReorg(from *big.Int) (*big.Int, error) {
 	if from.Cmp(r.firstMessageBlock) <= 0 {
 		return nil, errors.New("can't get older messages")
@@ -476,6 +651,11 @@ func (r *InboxReader) GetSequencerMessageBytes(ctx context.Context, seqNum uint6
 	return nil, errors.New("sequencer batch not found")
 }
 
+// CaughtUp returns a channel that receives true once the reader first catches up with the L1 head
+func (ir *InboxReader) CaughtUp() chan bool {
+	return ir.caughtUpChan
+}
+
 func (r *InboxReader) GetLastReadBlockAndBatchCount() (uint64, uint64) {
 	r.lastReadMutex.RLock()
 	defer r.lastReadMutex.RUnlock()
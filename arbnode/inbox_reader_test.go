@@ -0,0 +1,82 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestBlockRangeSizer(t *testing.T) {
+	config := TestInboxReaderConfig
+	config.DefaultBlocksToRead = 100
+	config.MaxBlocksToRead = 300
+	sizer := newBlockRangeSizer(&config)
+
+	sizer.grow()
+	sizer.grow()
+	if sizer.size != 300 {
+		t.Error("range grew to", sizer.size, "instead of the max")
+	}
+	if !sizer.shrink(errors.New("query returned more than 10000 results")) || sizer.size != 150 {
+		t.Error("range didn't shrink after too many results, size", sizer.size)
+	}
+	if !sizer.shrink(errors.WithStack(context.DeadlineExceeded)) || sizer.size != 75 {
+		t.Error("range didn't shrink after a timeout, size", sizer.size)
+	}
+	if sizer.shrink(errors.New("connection refused")) || sizer.size != 75 {
+		t.Error("range shrank after an unrelated error, size", sizer.size)
+	}
+	if sizer.shrink(errors.New("429 Too Many Requests")) || sizer.size != 75 {
+		t.Error("range shrank after being rate limited, size", sizer.size)
+	}
+	sizer.size = 1
+	if sizer.shrink(errors.New("block range too large")) {
+		t.Error("retrying a read of a single block")
+	}
+}
+
+func TestRunConcurrently(t *testing.T) {
+	ctx := context.Background()
+	var running, mostRunning int32
+	var tasks []func(context.Context) error
+	for i := 0; i < 10; i++ {
+		tasks = append(tasks, func(ctx context.Context) error {
+			now := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				most := atomic.LoadInt32(&mostRunning)
+				if now <= most || atomic.CompareAndSwapInt32(&mostRunning, most, now) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			return nil
+		})
+	}
+	if err := runConcurrently(ctx, 3, tasks...); err != nil {
+		t.Fatal(err)
+	}
+	if mostRunning > 3 {
+		t.Error(mostRunning, "tasks ran at once with a limit of 3")
+	}
+
+	failure := errors.New("failed")
+	err := runConcurrently(ctx, 2,
+		func(ctx context.Context) error {
+			return failure
+		},
+		func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	)
+	if !errors.Is(err, failure) {
+		t.Error("expected the task's error, got", err)
+	}
+}
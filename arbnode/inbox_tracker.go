@@ -12,6 +12,7 @@ import (
 	"github.com/tenderly/nitro/go-ethereum/common"
 	"github.com/tenderly/nitro/go-ethereum/ethdb"
 	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
 	"github.com/tenderly/nitro/go-ethereum/rlp"
 	"github.com/tenderly/nitro/arbos"
 	"github.com/tenderly/nitro/arbstate"
@@ -20,6 +21,11 @@ import (
 	"github.com/pkg/errors"
 )
 
+var (
+	batchCountGauge   = metrics.NewRegisteredGauge("arb/inbox/batchcount", nil)
+	delayedCountGauge = metrics.NewRegisteredGauge("arb/inbox/delayedcount", nil)
+)
+
 type InboxTracker struct {
 	db         ethdb.Database
 	txStreamer *TransactionStreamer
@@ -330,10 +336,19 @@ func (t *InboxTracker) setDelayedCountReorgAndWriteBatch(batch ethdb.Batch, newD
 			}
 		}
 		// Writes batch
-		return t.txStreamer.ReorgToAndEndBatch(batch, prevMesssageCount)
+		err = t.txStreamer.ReorgToAndEndBatch(batch, prevMesssageCount)
+		if err != nil {
+			return err
+		}
+		batchCountGauge.Update(int64(count))
 	} else {
-		return batch.Write()
+		err = batch.Write()
+		if err != nil {
+			return err
+		}
 	}
+	delayedCountGauge.Update(int64(newDelayedCount))
+	return nil
 }
 
 type multiplexerBackend struct {
@@ -533,6 +548,7 @@ func (t *InboxTracker) AddSequencerBatches(ctx context.Context, client arbutil.L
 	if err != nil {
 		return err
 	}
+	batchCountGauge.Update(int64(pos))
 
 	if t.validator != nil {
 		batchBytes := make([][]byte, 0, len(batches))
@@ -608,5 +624,10 @@ func (t *InboxTracker) ReorgBatchesTo(count uint64) error {
 		return err
 	}
 	log.Info("InboxTracker", "SequencerBatchCount", count)
-	return t.txStreamer.ReorgToAndEndBatch(dbBatch, prevBatchMeta.MessageCount)
+	err = t.txStreamer.ReorgToAndEndBatch(dbBatch, prevBatchMeta.MessageCount)
+	if err != nil {
+		return err
+	}
+	batchCountGauge.Update(int64(count))
+	return nil
 }
@@ -19,6 +19,7 @@ import (
 	"github.com/tenderly/nitro/go-ethereum/accounts/keystore"
 	"github.com/tenderly/nitro/cmd/genericconf"
 	"github.com/tenderly/nitro/util/headerreader"
+	"github.com/tenderly/nitro/util/l1client"
 	"golang.org/x/term"
 
 	"github.com/tenderly/nitro/go-ethereum/rpc"
@@ -38,6 +39,7 @@ import (
 	"github.com/tenderly/nitro/go-ethereum/params"
 	flag "github.com/spf13/pflag"
 
+	"github.com/tenderly/nitro/arbnode/dataposter"
 	"github.com/tenderly/nitro/arbos"
 	"github.com/tenderly/nitro/arbos/arbosState"
 	"github.com/tenderly/nitro/arbstate"
@@ -407,6 +409,7 @@ type Config struct {
 	Dangerous            DangerousConfig                `koanf:"dangerous"`
 	Archive              bool                           `koanf:"archive"`
 	TxLookupLimit        uint64                         `koanf:"tx-lookup-limit"`
+	Health               HealthConfig                   `koanf:"health"`
 }
 
 func (c *Config) ForwardingTarget() string {
@@ -434,6 +437,7 @@ func ConfigAddOptions(prefix string, f *flag.FlagSet, feedInputEnable bool, feed
 	DangerousConfigAddOptions(prefix+".dangerous", f)
 	f.Bool(prefix+".archive", ConfigDefault.Archive, "retain past block state")
 	f.Uint64(prefix+".tx-lookup-limit", ConfigDefault.TxLookupLimit, "retain the ability to lookup transactions by hash for the past N blocks (0 = all blocks)")
+	HealthConfigAddOptions(prefix+".health", f)
 }
 
 var ConfigDefault = Config{
@@ -453,6 +457,7 @@ var ConfigDefault = Config{
 	Dangerous:            DefaultDangerousConfig,
 	Archive:              false,
 	TxLookupLimit:        40_000_000,
+	Health:               DefaultHealthConfig,
 }
 
 func ConfigDefaultL1Test() *Config {
@@ -525,6 +530,10 @@ type SequencerConfig struct {
 	MaxRevertGasReject          uint64                   `koanf:"max-revert-gas-reject"`
 	MaxAcceptableTimestampDelta time.Duration            `koanf:"max-acceptable-timestamp-delta"`
 	SenderWhitelist             string                   `koanf:"sender-whitelist"`
+	ForwardTimeout              time.Duration            `koanf:"forward-timeout"`
+	Queue                       SequencerQueueConfig     `koanf:"queue"`
+	Journal                     SequencerJournalConfig   `koanf:"journal"`
+	Filter                      SequencerFilterConfig    `koanf:"filter"`
 	Dangerous                   DangerousSequencerConfig `koanf:"dangerous"`
 }
 
@@ -533,6 +542,10 @@ var DefaultSequencerConfig = SequencerConfig{
 	MaxBlockSpeed:               time.Millisecond * 100,
 	MaxRevertGasReject:          params.TxGas + 10000,
 	MaxAcceptableTimestampDelta: time.Hour,
+	ForwardTimeout:              30 * time.Second,
+	Queue:                       DefaultSequencerQueueConfig,
+	Journal:                     DefaultSequencerJournalConfig,
+	Filter:                      DefaultSequencerFilterConfig,
 	Dangerous:                   DefaultDangerousSequencerConfig,
 }
 
@@ -542,6 +555,10 @@ var TestSequencerConfig = SequencerConfig{
 	MaxRevertGasReject:          params.TxGas + 10000,
 	MaxAcceptableTimestampDelta: time.Hour,
 	SenderWhitelist:             "",
+	ForwardTimeout:              5 * time.Second,
+	Queue:                       TestSequencerQueueConfig,
+	Journal:                     TestSequencerJournalConfig,
+	Filter:                      TestSequencerFilterConfig,
 	Dangerous:                   TestDangerousSequencerConfig,
 }
 
@@ -551,6 +568,10 @@ func SequencerConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Uint64(prefix+".max-revert-gas-reject", DefaultSequencerConfig.MaxRevertGasReject, "maximum gas executed in a revert for the sequencer to reject the transaction instead of posting it (anti-DOS)")
 	f.Duration(prefix+".max-acceptable-timestamp-delta", DefaultSequencerConfig.MaxAcceptableTimestampDelta, "maximum acceptable time difference between the local time and the latest L1 block's timestamp")
 	f.String(prefix+".sender-whitelist", DefaultSequencerConfig.SenderWhitelist, "comma separated whitelist of authorized senders (if empty, everyone is allowed)")
+	f.Duration(prefix+".forward-timeout", DefaultSequencerConfig.ForwardTimeout, "timeout when forwarding transactions to the active sequencer")
+	SequencerQueueConfigAddOptions(prefix+".queue", f)
+	SequencerJournalConfigAddOptions(prefix+".journal", f)
+	SequencerFilterConfigAddOptions(prefix+".filter", f)
 	DangerousSequencerConfigAddOptions(prefix+".dangerous", f)
 }
 
@@ -584,6 +605,7 @@ type Node struct {
 	SeqCoordinator         *SeqCoordinator
 	DASLifecycleManager    *das.LifecycleManager
 	ClassicOutboxRetriever *ClassicOutboxRetriever
+	HealthServer           *HealthServer
 }
 
 func createNodeImpl(
@@ -626,7 +648,14 @@ func createNodeImpl(
 
 	var broadcastServer *broadcaster.Broadcaster
 	if config.Feed.Output.Enable {
-		broadcastServer = broadcaster.NewBroadcaster(config.Feed.Output)
+		var feedSigner broadcaster.FeedSigner
+		if config.Feed.Output.Signed {
+			if daSigner == nil {
+				return nil, errors.New("cannot sign feed messages without a signer")
+			}
+			feedSigner = broadcaster.FeedSigner(daSigner)
+		}
+		broadcastServer = broadcaster.NewBroadcaster(config.Feed.Output, feedSigner)
 	}
 
 	var l1Reader *headerreader.HeaderReader
@@ -687,12 +716,20 @@ func createNodeImpl(
 
 	var broadcastClients []*broadcastclient.BroadcastClient
 	if config.Feed.Input.Enable() {
+		nextSeqNum, err := txStreamer.GetMessageCount()
+		if err != nil {
+			return nil, err
+		}
 		for _, address := range config.Feed.Input.URLs {
-			broadcastClients = append(broadcastClients, broadcastclient.NewBroadcastClient(address, nil, config.Feed.Input.Timeout, txStreamer))
+			client, err := broadcastclient.NewBroadcastClient(config.Feed.Input, address, nextSeqNum, txStreamer)
+			if err != nil {
+				return nil, err
+			}
+			broadcastClients = append(broadcastClients, client)
 		}
 	}
 	if !config.L1Reader.Enable {
-		return &Node{backend, arbInterface, nil, txStreamer, txPublisher, nil, nil, nil, nil, nil, nil, nil, broadcastServer, broadcastClients, coordinator, nil, classicOutbox}, nil
+		return &Node{backend, arbInterface, nil, txStreamer, txPublisher, nil, nil, nil, nil, nil, nil, nil, broadcastServer, broadcastClients, coordinator, nil, classicOutbox, nil}, nil
 	}
 
 	if deployInfo == nil {
@@ -765,8 +802,20 @@ func createNodeImpl(
 
 	var staker *validator.Staker
 	if config.Validator.Enable {
+		var walletDataPoster *dataposter.DataPoster[struct{}]
+		if config.Validator.UseDataPoster {
+			if txOpts == nil {
+				return nil, errors.New("validator data poster, but no TxOpts")
+			}
+			walletDataPoster, err = dataposter.NewDataPoster(l1Reader, txOpts, rawdb.NewTable(arbDb, stakerPrefix), &config.Validator.DataPoster, func(context.Context, *big.Int) (struct{}, error) {
+				return struct{}{}, nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
 		// TODO: remember validator wallet in JSON instead of querying it from L1 every time
-		wallet, err := validator.NewValidatorWallet(nil, deployInfo.ValidatorWalletCreator, deployInfo.Rollup, l1Reader, txOpts, int64(deployInfo.DeployedAt), func(common.Address) {})
+		wallet, err := validator.NewValidatorWallet(nil, deployInfo.ValidatorWalletCreator, deployInfo.Rollup, l1Reader, txOpts, int64(deployInfo.DeployedAt), func(common.Address) {}, walletDataPoster)
 		if err != nil {
 			return nil, err
 		}
@@ -782,7 +831,7 @@ func createNodeImpl(
 		if txOpts == nil {
 			return nil, errors.New("batchposter, but no TxOpts")
 		}
-		batchPoster, err = NewBatchPoster(l1Reader, inboxTracker, txStreamer, &config.BatchPoster, deployInfo.SequencerInbox, txOpts, dataAvailabilityService)
+		batchPoster, err = NewBatchPoster(l1Reader, inboxTracker, txStreamer, &config.BatchPoster, deployInfo.SequencerInbox, txOpts, dataAvailabilityService, rawdb.NewTable(arbDb, batchPosterPrefix))
 		if err != nil {
 			return nil, err
 		}
@@ -796,7 +845,7 @@ func createNodeImpl(
 		return nil, errors.New("sequencer and l1 reader, without delayed sequencer")
 	}
 
-	return &Node{backend, arbInterface, l1Reader, txStreamer, txPublisher, deployInfo, inboxReader, inboxTracker, delayedSequencer, batchPoster, blockValidator, staker, broadcastServer, broadcastClients, coordinator, dasLifecycleManager, classicOutbox}, nil
+	return &Node{backend, arbInterface, l1Reader, txStreamer, txPublisher, deployInfo, inboxReader, inboxTracker, delayedSequencer, batchPoster, blockValidator, staker, broadcastServer, broadcastClients, coordinator, dasLifecycleManager, classicOutbox, nil}, nil
 }
 
 type L1ReaderCloser struct {
@@ -812,15 +861,30 @@ func (c *L1ReaderCloser) String() string {
 	return "l1 reader closer"
 }
 
+type L1ClientCloser struct {
+	l1Client *l1client.Client
+}
+
+func (c *L1ClientCloser) Close(ctx context.Context) error {
+	c.l1Client.StopAndWait()
+	return nil
+}
+
+func (c *L1ClientCloser) String() string {
+	return "l1 client closer"
+}
+
 // Set up a das.DataAvailabilityService stack without relying on any
 // objects already created for setting up the Node.
 func SetUpDataAvailabilityWithoutNode(
 	ctx context.Context,
 	config *das.DataAvailabilityConfig,
 ) (das.DataAvailabilityService, *das.LifecycleManager, error) {
+	var l1Client *l1client.Client
 	var l1Reader *headerreader.HeaderReader
 	if config.L1NodeURL != "" && config.L1NodeURL != "none" {
-		l1Client, err := das.GetL1Client(ctx, config.L1ConnectionAttempts, config.L1NodeURL)
+		var err error
+		l1Client, err = das.GetL1Client(ctx, config.L1ConnectionAttempts, config.L1NodeURL, &config.L1Client)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -828,11 +892,16 @@ func SetUpDataAvailabilityWithoutNode(
 	}
 	das, lifeCycle, err := SetUpDataAvailability(ctx, config, l1Reader, nil)
 	if err != nil {
+		if l1Client != nil {
+			l1Client.StopAndWait()
+		}
 		return nil, nil, err
 	}
 	if l1Reader != nil {
 		l1Reader.Start(ctx)
+		// the reader is closed before the client it reads from
 		lifeCycle.Register(&L1ReaderCloser{l1Reader})
+		lifeCycle.Register(&L1ClientCloser{l1Client})
 	}
 	return das, lifeCycle, err
 }
@@ -1040,6 +1109,9 @@ func CreateNode(
 	if err != nil {
 		return nil, err
 	}
+	if config.Health.Addr != "" {
+		currentNode.HealthServer = NewHealthServer(&config.Health, currentNode)
+	}
 	var apis []rpc.API
 	if currentNode.BlockValidator != nil {
 		apis = append(apis, rpc.API{
@@ -1050,6 +1122,13 @@ func CreateNode(
 		})
 	}
 
+	apis = append(apis, rpc.API{
+		Namespace: "eth",
+		Version:   "1.0",
+		Service:   &ArbTransactionAPI{arbInterface: currentNode.ArbInterface},
+		Public:    true,
+	})
+
 	apis = append(apis, rpc.API{
 		Namespace: "arbdebug",
 		Version:   "1.0",
@@ -1098,7 +1177,10 @@ func (n *Node) Start(ctx context.Context) error {
 		return err
 	}
 	if n.SeqCoordinator != nil {
-		n.SeqCoordinator.Start(ctx)
+		err = n.SeqCoordinator.Start(ctx)
+		if err != nil {
+			return err
+		}
 	}
 	if n.DelayedSequencer != nil {
 		n.DelayedSequencer.Start(ctx)
@@ -1137,10 +1219,16 @@ func (n *Node) Start(ctx context.Context) error {
 	for _, client := range n.BroadcastClients {
 		client.Start(ctx)
 	}
+	if n.HealthServer != nil {
+		n.HealthServer.Start(ctx)
+	}
 	return nil
 }
 
 func (n *Node) StopAndWait() {
+	if n.HealthServer != nil {
+		n.HealthServer.StopAndWait()
+	}
 	for _, client := range n.BroadcastClients {
 		client.StopAndWait()
 	}
@@ -1150,6 +1238,9 @@ func (n *Node) StopAndWait() {
 	if n.L1Reader != nil {
 		n.L1Reader.StopAndWait()
 	}
+	if n.Staker != nil {
+		n.Staker.StopAndWait()
+	}
 	if n.BlockValidator != nil {
 		n.BlockValidator.StopAndWait()
 	}
@@ -5,10 +5,14 @@ package arbnode
 
 var (
 	blockValidatorPrefix     string = "v"         // the prefix for all block validator keys
+	batchPosterPrefix        string = "b"         // the prefix for all batch poster data poster keys
+	stakerPrefix             string = "w"         // the prefix for all staker (validator wallet) data poster keys
+	seqCoordinatorRaftPrefix string = "r"         // the prefix for all sequencer coordinator raft log keys
 	messagePrefix            []byte = []byte("m") // maps a message sequence number to a message
 	delayedMessagePrefix     []byte = []byte("d") // maps a delayed sequence number to an accumulator and a message
 	sequencerBatchMetaPrefix []byte = []byte("s") // maps a batch sequence number to BatchMetadata
 	delayedSequencedPrefix   []byte = []byte("a") // maps a delayed message count to the first sequencer batch sequence number with this delayed count
+	sequencerJournalPrefix   []byte = []byte("j") // maps a journal id to a transaction accepted but not yet sequenced by the sequencer
 
 	messageCountKey        []byte = []byte("_messageCount")        // contains the current message count
 	delayedMessageCountKey []byte = []byte("_delayedMessageCount") // contains the current delayed message count
@@ -17,15 +17,16 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
 	flag "github.com/spf13/pflag"
 
 	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/core/rawdb"
 	"github.com/tenderly/nitro/go-ethereum/crypto"
 	"github.com/tenderly/nitro/go-ethereum/log"
 	"github.com/tenderly/nitro/arbstate"
 	"github.com/tenderly/nitro/arbutil"
+	"github.com/tenderly/nitro/util/raftkv"
 	"github.com/tenderly/nitro/util/stopwaiter"
 )
 
@@ -38,14 +39,53 @@ const LIVELINESS_VAL string = "OK"
 const INVALID_VAL string = "INVALID"
 const INVALID_URL string = "<?INVALID-URL?>"
 
+const (
+	SeqCoordinatorBackendRedis = "redis"
+	SeqCoordinatorBackendRaft  = "raft"
+)
+
+// SeqCoordinatorBackend stores the state shared between the coordinated sequencers
+type SeqCoordinatorBackend interface {
+	Start(ctx context.Context) error
+	// Priorities returns the sequencer urls, most preferred first
+	Priorities(ctx context.Context) ([]string, error)
+	// FirstAlive returns the first of the urls whose liveliness is set, or "" if none is
+	FirstAlive(ctx context.Context, urls []string) (string, error)
+	SetAlive(ctx context.Context, url string, until time.Time) error
+	ReleaseAlive(ctx context.Context, url string) error
+	// MsgCount returns the signed message count, or nil if it's unset
+	MsgCount(ctx context.Context) ([]byte, error)
+	// Message returns the signed message at the position, and an error if it's unset
+	Message(ctx context.Context, pos arbutil.MessageIndex) ([]byte, error)
+	SetMessage(ctx context.Context, pos arbutil.MessageIndex, data []byte, expiration time.Duration) error
+	// UpdateChosen atomically takes or extends the chosen sequencer lock, and writes the message count and message.
+	// It fails with ErrRetrySequencer if another sequencer holds the lock, or the state changed concurrently.
+	UpdateChosen(ctx context.Context, update *SeqCoordinatorChosenUpdate) error
+	// ReleaseChosen releases the chosen sequencer lock if it's held by the url
+	ReleaseChosen(ctx context.Context, url string) error
+	Close() error
+}
+
+type SeqCoordinatorChosenUpdate struct {
+	MyUrl string
+	// Called with the current message count (nil if unset) before writing, and aborts the update if it returns an error
+	CheckMsgCount func(msgCount []byte) error
+	MsgCount      []byte
+	MsgPos        arbutil.MessageIndex
+	Message       []byte // nil if there's no message to write
+	LockoutUntil  time.Time
+	// How long the message count and message are kept
+	DataDuration time.Duration
+}
+
 type SeqCoordinator struct {
 	stopwaiter.StopWaiter
 
 	streamer                *TransactionStreamer
 	sequencer               *Sequencer
-	client                  redis.UniversalClient
+	backend                 SeqCoordinatorBackend
 	config                  SeqCoordinatorConfig
-	signingKey              *[32]byte // if not nil, the redis message signing key
+	signingKey              *[32]byte // if not nil, the message signing key
 	fallbackVerificationKey *[32]byte
 
 	prevChosenSequencer string
@@ -54,13 +94,16 @@ type SeqCoordinator struct {
 	lockoutUntil int64 // atomic
 
 	chosenUpdateMutex sync.Mutex // mannages access to chosenOneUpdate
-	redisErrors       int        // error counter, from wrokthread
+	backendErrors     int        // error counter, from wrokthread
 }
 
 type SeqCoordinatorConfig struct {
 	Enable                  bool                          `koanf:"enable"`
 	ChosenHealthcheckAddr   string                        `koanf:"chosen-healthcheck-addr"`
+	Backend                 string                        `koanf:"backend"`
 	RedisUrl                string                        `koanf:"redis-url"`
+	Raft                    raftkv.Config                 `koanf:"raft"`
+	Priorities              []string                      `koanf:"priorities"`
 	LockoutDuration         time.Duration                 `koanf:"lockout-duration"`
 	LockoutSpare            time.Duration                 `koanf:"lockout-spare"`
 	SeqNumDuration          time.Duration                 `koanf:"seq-num-duration"`
@@ -81,6 +124,9 @@ type SeqCoordinatorDangerousConfig struct {
 func SeqCoordinatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultSeqCoordinatorConfig.Enable, "enable sequence coordinator")
 	f.String(prefix+".chosen-healthcheck-addr", DefaultSeqCoordinatorConfig.ChosenHealthcheckAddr, "if non-empty, launch an HTTP service binding to this address that returns status code 200 when chosen and 503 otherwise")
+	f.String(prefix+".backend", DefaultSeqCoordinatorConfig.Backend, "where the coordination state is kept (\"redis\" or \"raft\")")
+	raftkv.ConfigAddOptions(prefix+".raft", f)
+	f.StringSlice(prefix+".priorities", DefaultSeqCoordinatorConfig.Priorities, "sequencer urls in priority order (raft backend only, defaults to the priorities stored in the raft log)")
 	f.Duration(prefix+".lockout-duration", DefaultSeqCoordinatorConfig.LockoutDuration, "")
 	f.Duration(prefix+".lockout-spare", DefaultSeqCoordinatorConfig.LockoutSpare, "")
 	f.Duration(prefix+".seq-num-duration", DefaultSeqCoordinatorConfig.SeqNumDuration, "")
@@ -100,7 +146,10 @@ func SeqCoordinatorDangerousConfigAddOptions(prefix string, f *flag.FlagSet) {
 var DefaultSeqCoordinatorConfig = SeqCoordinatorConfig{
 	Enable:                false,
 	ChosenHealthcheckAddr: "",
+	Backend:               SeqCoordinatorBackendRedis,
 	RedisUrl:              "",
+	Raft:                  raftkv.DefaultConfig,
+	Priorities:            []string{},
 	LockoutDuration:       time.Duration(5) * time.Minute,
 	LockoutSpare:          time.Duration(30) * time.Second,
 	SeqNumDuration:        time.Duration(24) * time.Hour,
@@ -119,7 +168,9 @@ var DefaultSeqCoordinatorDangerousConfig = SeqCoordinatorDangerousConfig{
 
 var TestSeqCoordinatorConfig = SeqCoordinatorConfig{
 	Enable:          false,
+	Backend:         SeqCoordinatorBackendRedis,
 	RedisUrl:        "redis://localhost:6379/0",
+	Raft:            raftkv.TestConfig,
 	LockoutDuration: time.Millisecond * 500,
 	LockoutSpare:    time.Millisecond * 10,
 	SeqNumDuration:  time.Minute * 10,
@@ -160,8 +211,33 @@ func loadSigningKey(keyConfig string) (*[32]byte, error) {
 	return &b, nil
 }
 
+func (c *SeqCoordinatorConfig) Validate() error {
+	switch c.Backend {
+	case SeqCoordinatorBackendRedis:
+		return nil
+	case SeqCoordinatorBackendRaft:
+		return c.Raft.Validate()
+	default:
+		return fmt.Errorf("unknown sequencer coordinator backend %v", c.Backend)
+	}
+}
+
+func newSeqCoordinatorBackend(streamer *TransactionStreamer, config *SeqCoordinatorConfig) (SeqCoordinatorBackend, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Backend == SeqCoordinatorBackendRaft {
+		node, err := raftkv.NewNode(&config.Raft, rawdb.NewTable(streamer.db, seqCoordinatorRaftPrefix))
+		if err != nil {
+			return nil, err
+		}
+		return newRaftSeqCoordinatorBackend(node, config.Priorities), nil
+	}
+	return newRedisSeqCoordinatorBackend(config.RedisUrl, config.LockoutDuration)
+}
+
 func NewSeqCoordinator(streamer *TransactionStreamer, sequencer *Sequencer, config SeqCoordinatorConfig) (*SeqCoordinator, error) {
-	redisOptions, err := redis.ParseURL(config.RedisUrl)
+	backend, err := newSeqCoordinatorBackend(streamer, &config)
 	if err != nil {
 		return nil, err
 	}
@@ -182,7 +258,7 @@ func NewSeqCoordinator(streamer *TransactionStreamer, sequencer *Sequencer, conf
 	coordinator := &SeqCoordinator{
 		streamer:                streamer,
 		sequencer:               sequencer,
-		client:                  redis.NewClient(redisOptions),
+		backend:                 backend,
 		config:                  config,
 		signingKey:              signingKey,
 		fallbackVerificationKey: fallbackVerificationKey,
@@ -191,12 +267,22 @@ func NewSeqCoordinator(streamer *TransactionStreamer, sequencer *Sequencer, conf
 	return coordinator, nil
 }
 
-func StandaloneSeqCoordinatorInvalidateMsgIndex(ctx context.Context, redisUrl string, keyConfig string, msgIndex arbutil.MessageIndex) error {
-	redisOptions, err := redis.ParseURL(redisUrl)
+// StandaloneSeqCoordinatorInvalidateMsgIndex marks a message as invalid.
+// The backendUrl is either a redis url, or raft:// followed by a comma-separated list of raft peers,
+// in which case raftJWTSecret is the secret the peers authenticate requests with.
+func StandaloneSeqCoordinatorInvalidateMsgIndex(ctx context.Context, backendUrl string, raftJWTSecret string, keyConfig string, msgIndex arbutil.MessageIndex) error {
+	var backend SeqCoordinatorBackend
+	var err error
+	if strings.HasPrefix(backendUrl, "raft://") {
+		peers := strings.Split(strings.TrimPrefix(backendUrl, "raft://"), ",")
+		backend, err = newRaftClientSeqCoordinatorBackend(peers, raftJWTSecret, raftkv.DefaultConfig.RequestTimeout)
+	} else {
+		backend, err = newRedisSeqCoordinatorBackend(backendUrl, DefaultSeqCoordinatorConfig.LockoutDuration)
+	}
 	if err != nil {
 		return err
 	}
-	r := redis.NewClient(redisOptions)
+	defer backend.Close()
 	signingKey, err := loadSigningKey(keyConfig)
 	if err != nil {
 		return err
@@ -209,31 +295,22 @@ func StandaloneSeqCoordinatorInvalidateMsgIndex(ctx context.Context, redisUrl st
 		hmac = crypto.Keccak256Hash(signingKey[:], msgIndexBytes[:], msg)
 	}
 	data := append(hmac[:], msg...)
-	r.Set(ctx, messageKeyFor(msgIndex), data, DefaultSeqCoordinatorConfig.SeqNumDuration)
-	return nil
+	return backend.SetMessage(ctx, msgIndex, data, DefaultSeqCoordinatorConfig.SeqNumDuration)
 }
 
 func (c *SeqCoordinator) recommendLiveSequencer(ctx context.Context) (string, error) {
-	prioritiesString, err := c.client.Get(ctx, PRIORITIES_KEY).Result()
+	priorities, err := c.backend.Priorities(ctx)
 	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			err = errors.New("sequencer priorities unset")
-		}
 		return "", err
 	}
-	priorities := strings.Split(prioritiesString, ",")
-	for _, url := range priorities {
-		err := c.client.Get(ctx, livelinessKeyFor(url)).Err()
-		if errors.Is(err, redis.Nil) { // liveliness not set
-			continue
-		}
-		if err != nil {
-			return "", err
-		}
-		return url, nil
+	url, err := c.backend.FirstAlive(ctx, priorities)
+	if err != nil {
+		return "", err
+	}
+	if url == "" {
+		log.Info("no sequencer appears live", "priorities", strings.Join(priorities, ","), "self", c.config.MyUrl)
 	}
-	log.Info("no sequencer appears live on redis", "priorities", prioritiesString, "self", c.config.MyUrl)
-	return "", nil
+	return url, nil
 }
 
 func atomicTimeWrite(addr *int64, t time.Time) {
@@ -253,19 +330,6 @@ func messageKeyFor(pos arbutil.MessageIndex) string {
 	return fmt.Sprintf("%s%d", MESSAGE_KEY_PREFIX, pos)
 }
 
-func execTestPipe(pipe redis.Pipeliner, ctx context.Context) error {
-	cmders, err := pipe.Exec(ctx)
-	if err != nil {
-		return err
-	}
-	for _, cmder := range cmders {
-		if err := cmder.Err(); err != nil {
-			return err
-		}
-	}
-	return nil
-}
-
 // On success, extracts the message from the message+signature data passed in, and returns it
 func (c *SeqCoordinator) verifyMessageSignature(prefix []byte, data []byte) ([]byte, error) {
 	if len(data) < 32 {
@@ -306,7 +370,7 @@ func (c *SeqCoordinator) signMessage(prefix []byte, msg []byte) []byte {
 }
 
 func (c *SeqCoordinator) chosenOneUpdate(ctx context.Context, msgCountExpected, msgCountToWrite arbutil.MessageIndex, lastmsg *arbstate.MessageWithMetadata) error {
-	var messageData *string
+	var messageData []byte
 	if lastmsg != nil {
 		msgBytes, err := json.Marshal(lastmsg)
 		if err != nil {
@@ -315,63 +379,32 @@ func (c *SeqCoordinator) chosenOneUpdate(ctx context.Context, msgCountExpected,
 
 		var msgCountBytes [8]byte
 		binary.BigEndian.PutUint64(msgCountBytes[:], uint64(msgCountToWrite-1))
-		msgBytes = c.signMessage(msgCountBytes[:], msgBytes)
-
-		messageString := string(msgBytes)
-		messageData = &messageString
+		messageData = c.signMessage(msgCountBytes[:], msgBytes)
 	}
+	var msgCountBytes [8]byte
+	binary.BigEndian.PutUint64(msgCountBytes[:], uint64(msgCountToWrite))
 	c.chosenUpdateMutex.Lock()
 	defer c.chosenUpdateMutex.Unlock()
 	lockoutUntil := time.Now().Add(c.config.LockoutDuration)
-	err := c.client.Watch(ctx, func(tx *redis.Tx) error {
-		current, err := tx.Get(ctx, CHOSENSEQ_KEY).Result()
-		var wasEmpty bool
-		if errors.Is(err, redis.Nil) {
-			wasEmpty = true
-			err = nil
-		}
-		if err != nil {
-			return err
-		}
-		if !wasEmpty && (current != c.config.MyUrl) {
-			return fmt.Errorf("%w: failed to catch lock. redis shows chosen: %s", ErrRetrySequencer, current)
-		}
-		remoteMsgCount, err := c.getRemoteMsgCountImpl(ctx, tx)
-		if err != nil {
-			return err
-		}
-		if remoteMsgCount > msgCountExpected {
-			log.Info("coordinator failed to become main", "expected", msgCountExpected, "found", remoteMsgCount, "message is nil?", messageData == nil)
-			return fmt.Errorf("%w: failed to catch lock. expected msg %d found %d", ErrRetrySequencer, msgCountExpected, remoteMsgCount)
-		}
-		pipe := tx.TxPipeline()
-		initialDuration := c.config.LockoutDuration
-		if initialDuration < 2*time.Second {
-			initialDuration = 2 * time.Second
-		}
-		if wasEmpty {
-			pipe.Set(ctx, CHOSENSEQ_KEY, c.config.MyUrl, initialDuration)
-		}
-		var msgCountBytes [8]byte
-		binary.BigEndian.PutUint64(msgCountBytes[:], uint64(msgCountToWrite))
-		pipe.Set(ctx, MSG_COUNT_KEY, c.signMessage(nil, msgCountBytes[:]), c.config.SeqNumDuration)
-		myLivelinessKey := livelinessKeyFor(c.config.MyUrl)
-		pipe.Set(ctx, myLivelinessKey, LIVELINESS_VAL, initialDuration)
-		if messageData != nil {
-			pipe.Set(ctx, messageKeyFor(msgCountToWrite-1), *messageData, c.config.SeqNumDuration)
-		}
-		pipe.PExpireAt(ctx, CHOSENSEQ_KEY, lockoutUntil)
-		pipe.PExpireAt(ctx, myLivelinessKey, lockoutUntil)
-		err = execTestPipe(pipe, ctx)
-		if errors.Is(err, redis.TxFailedErr) {
-			return fmt.Errorf("%w: failed to catch sequencer lock", ErrRetrySequencer)
-		}
-		if err != nil {
-			return fmt.Errorf("chosen sequencer failed to update redis: %w", err)
-		}
-		return nil
-	}, CHOSENSEQ_KEY, MSG_COUNT_KEY)
-
+	err := c.backend.UpdateChosen(ctx, &SeqCoordinatorChosenUpdate{
+		MyUrl: c.config.MyUrl,
+		CheckMsgCount: func(msgCount []byte) error {
+			remoteMsgCount, err := c.parseRemoteMsgCount(msgCount)
+			if err != nil {
+				return err
+			}
+			if remoteMsgCount > msgCountExpected {
+				log.Info("coordinator failed to become main", "expected", msgCountExpected, "found", remoteMsgCount, "message is nil?", messageData == nil)
+				return fmt.Errorf("%w: failed to catch lock. expected msg %d found %d", ErrRetrySequencer, msgCountExpected, remoteMsgCount)
+			}
+			return nil
+		},
+		MsgCount:     c.signMessage(nil, msgCountBytes[:]),
+		MsgPos:       msgCountToWrite - 1,
+		Message:      messageData,
+		LockoutUntil: lockoutUntil,
+		DataDuration: c.config.SeqNumDuration,
+	})
 	if err != nil {
 		return err
 	}
@@ -379,16 +412,12 @@ func (c *SeqCoordinator) chosenOneUpdate(ctx context.Context, msgCountExpected,
 	return nil
 }
 
-func (c *SeqCoordinator) getRemoteMsgCountImpl(ctx context.Context, r redis.Cmdable) (arbutil.MessageIndex, error) {
-	resStr, err := r.Get(ctx, MSG_COUNT_KEY).Result()
-	if errors.Is(err, redis.Nil) {
+// parseRemoteMsgCount verifies and decodes the message count stored in the backend, which is 0 if it's unset
+func (c *SeqCoordinator) parseRemoteMsgCount(data []byte) (arbutil.MessageIndex, error) {
+	if data == nil {
 		return 0, nil
 	}
-	if err != nil {
-		return 0, err
-	}
-	resBytes := []byte(resStr)
-	resBytes, err = c.verifyMessageSignature(nil, []byte(resBytes))
+	resBytes, err := c.verifyMessageSignature(nil, data)
 	if err != nil {
 		return 0, err
 	}
@@ -399,85 +428,40 @@ func (c *SeqCoordinator) getRemoteMsgCountImpl(ctx context.Context, r redis.Cmda
 }
 
 func (c *SeqCoordinator) GetRemoteMsgCount(ctx context.Context) (arbutil.MessageIndex, error) {
-	return c.getRemoteMsgCountImpl(ctx, c.client)
+	data, err := c.backend.MsgCount(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return c.parseRemoteMsgCount(data)
 }
 
 func (c *SeqCoordinator) livelinessUpdate(ctx context.Context) error {
-	myLivelinessKey := livelinessKeyFor(c.config.MyUrl)
-	aliveUntil := time.Now().Add(c.config.LockoutDuration)
-	pipe := c.client.TxPipeline()
-	initialDuration := c.config.LockoutDuration
-	if initialDuration < 2*time.Second {
-		initialDuration = 2 * time.Second
-	}
-	pipe.Set(ctx, myLivelinessKey, LIVELINESS_VAL, initialDuration)
-	pipe.PExpireAt(ctx, myLivelinessKey, aliveUntil)
-	err := execTestPipe(pipe, ctx)
+	err := c.backend.SetAlive(ctx, c.config.MyUrl, time.Now().Add(c.config.LockoutDuration))
 	if err != nil {
-		return fmt.Errorf("liveliness failed to update redis: %w", err)
+		return fmt.Errorf("liveliness failed to update coordinator backend: %w", err)
 	}
 	return nil
 }
 
 func (c *SeqCoordinator) chosenOneRelease(ctx context.Context) error {
-	releaseErr := c.client.Watch(ctx, func(tx *redis.Tx) error {
-		current, err := tx.Get(ctx, CHOSENSEQ_KEY).Result()
-		if errors.Is(err, redis.Nil) {
-			return nil
-		}
-		if err != nil {
-			return err
-		}
-		if current != c.config.MyUrl {
-			return nil
-		}
-		pipe := tx.TxPipeline()
-		pipe.Del(ctx, CHOSENSEQ_KEY)
-		err = execTestPipe(pipe, ctx)
-		if err != nil {
-			return fmt.Errorf("chosen sequencer failed to update redis: %w", err)
-		}
-		return nil
-	}, CHOSENSEQ_KEY)
-	if releaseErr == nil {
-		return nil
-	}
-	// got error - was it still released?
-	current, readErr := c.client.Get(ctx, CHOSENSEQ_KEY).Result()
-	if errors.Is(readErr, redis.Nil) {
-		return nil
-	}
-	if current != c.config.MyUrl {
-		return nil
-	}
-	return releaseErr
+	return c.backend.ReleaseChosen(ctx, c.config.MyUrl)
 }
 
 func (c *SeqCoordinator) livelinessRelease(ctx context.Context) error {
-	myLivelinessKey := livelinessKeyFor(c.config.MyUrl)
-	releaseErr := c.client.Del(ctx, myLivelinessKey).Err()
-	if releaseErr == nil {
-		return nil
-	}
-	// got error - was it still deleted?
-	readErr := c.client.Get(ctx, myLivelinessKey).Err()
-	if errors.Is(readErr, redis.Nil) {
-		return nil
-	}
-	return releaseErr
+	return c.backend.ReleaseAlive(ctx, c.config.MyUrl)
 }
 
-func (c *SeqCoordinator) retryAfterRedisError() time.Duration {
-	c.redisErrors++
-	retryIn := c.config.RetryInterval * time.Duration(c.redisErrors)
+func (c *SeqCoordinator) retryAfterBackendError() time.Duration {
+	c.backendErrors++
+	retryIn := c.config.RetryInterval * time.Duration(c.backendErrors)
 	if retryIn > c.config.UpdateInterval {
 		retryIn = c.config.UpdateInterval
 	}
 	return retryIn
 }
 
-func (c *SeqCoordinator) noRedisError() time.Duration {
-	c.redisErrors = 0
+func (c *SeqCoordinator) noBackendError() time.Duration {
+	c.backendErrors = 0
 	return c.config.UpdateInterval
 }
 
@@ -497,16 +481,16 @@ func (c *SeqCoordinator) updatePrevKnownChosen(ctx context.Context, nextChosen s
 		}
 		if err := c.chosenOneRelease(ctx); err != nil {
 			log.Warn("coordinator failed chosen one release", "err", err)
-			return c.retryAfterRedisError()
+			return c.retryAfterBackendError()
 		}
 		c.prevChosenSequencer = setPrevChosenTo
 		log.Info("released chosen-coordinator lock", "nextChosen", nextChosen)
-		return c.noRedisError()
+		return c.noBackendError()
 	}
 	// Was, and still, the active sequencer
 	if time.Now().Add(c.config.UpdateInterval / 3).After(atomicTimeRead(&c.lockoutUntil)) {
 		// if we recently sequenced - no need for an update
-		return c.noRedisError()
+		return c.noBackendError()
 	}
 	localMsgCount, err := c.streamer.GetMessageCount()
 	if err != nil {
@@ -516,17 +500,17 @@ func (c *SeqCoordinator) updatePrevKnownChosen(ctx context.Context, nextChosen s
 	err = c.chosenOneUpdate(ctx, localMsgCount, localMsgCount, nil)
 	if err != nil {
 		log.Warn("coordinator failed chosen-one keepalive", "err", err)
-		return c.retryAfterRedisError()
+		return c.retryAfterBackendError()
 	}
 	c.reportedAlive = true
-	return c.noRedisError()
+	return c.noBackendError()
 }
 
 func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 	chosenSeq, err := c.recommendLiveSequencer(ctx)
 	if err != nil {
 		log.Warn("coordinator failed finding live sequencer", "err", err)
-		return c.retryAfterRedisError()
+		return c.retryAfterBackendError()
 	}
 	if c.prevChosenSequencer == c.config.MyUrl {
 		return c.updatePrevKnownChosen(ctx, chosenSeq)
@@ -546,7 +530,7 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 		}
 	}
 
-	// read messages from redis
+	// read messages from the backend
 	localMsgCount, err := c.streamer.GetMessageCount()
 	if err != nil {
 		log.Error("cannot read message count", "err", err)
@@ -555,7 +539,7 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 	remoteMsgCount, err := c.GetRemoteMsgCount(ctx)
 	if err != nil {
 		log.Warn("cannot get remote message count", "err", err)
-		return c.retryAfterRedisError()
+		return c.retryAfterBackendError()
 	}
 	readUntil := remoteMsgCount
 	if readUntil > localMsgCount+c.config.MaxMsgPerPoll {
@@ -565,13 +549,12 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 	msgToRead := localMsgCount
 	var msgReadErr error
 	for msgToRead < readUntil {
-		var resString string
-		resString, msgReadErr = c.client.Get(ctx, messageKeyFor(msgToRead)).Result()
+		var rsBytes []byte
+		rsBytes, msgReadErr = c.backend.Message(ctx, msgToRead)
 		if msgReadErr != nil {
 			log.Warn("coordinator failed reading message", "pos", msgToRead, "err", msgReadErr)
 			break
 		}
-		rsBytes := []byte(resString)
 		var msgToReadBytes [8]byte
 		binary.BigEndian.PutUint64(msgToReadBytes[:], uint64(msgToRead))
 		rsBytes, msgReadErr = c.verifyMessageSignature(msgToReadBytes[:], rsBytes)
@@ -582,9 +565,9 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 		var message arbstate.MessageWithMetadata
 		err = json.Unmarshal(rsBytes, &message)
 		if err != nil {
-			log.Warn("coordinator failed to parse message from redis", "pos", msgToRead, "err", err)
+			log.Warn("coordinator failed to parse message from backend", "pos", msgToRead, "err", err)
 			msgReadErr = fmt.Errorf("failed to parse message: %w", err)
-			// redis messages spelled "INVALID" will be parsed as invalid L1 message, but only one at a time
+			// messages spelled "INVALID" will be parsed as invalid L1 message, but only one at a time
 			if len(messages) > 0 || string(rsBytes) != INVALID_VAL {
 				break
 			}
@@ -614,14 +597,14 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 	}
 
 	if c.config.MyUrl == INVALID_URL {
-		return c.noRedisError()
+		return c.noBackendError()
 	}
 
 	// can take over as main sequencer?
 	if localMsgCount >= remoteMsgCount && chosenSeq == c.config.MyUrl {
 		if c.sequencer == nil {
 			log.Error("myurl main sequencer, but no sequencer exists")
-			return c.noRedisError()
+			return c.noBackendError()
 		}
 		err := c.chosenOneUpdate(ctx, localMsgCount, localMsgCount, nil)
 		if err != nil {
@@ -631,12 +614,12 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 			if err := c.livelinessUpdate(ctx); err != nil {
 				log.Warn("failed to update liveliness", "err", err)
 			}
-			return c.retryAfterRedisError()
+			return c.retryAfterBackendError()
 		}
 		log.Info("caught chosen-coordinator lock")
 		c.sequencer.DontForward()
 		c.prevChosenSequencer = c.config.MyUrl
-		return c.noRedisError()
+		return c.noBackendError()
 	}
 
 	// update liveliness
@@ -659,9 +642,9 @@ func (c *SeqCoordinator) update(ctx context.Context) time.Duration {
 	}
 
 	if (livelinessErr != nil) || (msgReadErr != nil) {
-		return c.retryAfterRedisError()
+		return c.retryAfterBackendError()
 	}
-	return c.noRedisError()
+	return c.noBackendError()
 }
 
 func (c *SeqCoordinator) DebugPrint() string {
@@ -669,7 +652,7 @@ func (c *SeqCoordinator) DebugPrint() string {
 		" prevChosenSequencer:", c.prevChosenSequencer,
 		" reportedAlive:", c.reportedAlive,
 		" lockoutUntil:", c.lockoutUntil,
-		" redisErrors:", c.redisErrors)
+		" backendErrors:", c.backendErrors)
 }
 
 type seqCoordinatorChosenHealthcheck struct {
@@ -705,12 +688,16 @@ func (c *SeqCoordinator) launchHealthcheckServer(ctx context.Context) {
 	}
 }
 
-func (c *SeqCoordinator) Start(ctxIn context.Context) {
+func (c *SeqCoordinator) Start(ctxIn context.Context) error {
+	if err := c.backend.Start(ctxIn); err != nil {
+		return err
+	}
 	c.StopWaiter.Start(ctxIn)
 	c.CallIteratively(c.update)
 	if c.config.ChosenHealthcheckAddr != "" {
 		c.StopWaiter.LaunchThread(c.launchHealthcheckServer)
 	}
+	return nil
 }
 
 func (c *SeqCoordinator) StopAndWait() {
@@ -721,7 +708,9 @@ func (c *SeqCoordinator) StopAndWait() {
 		_ = c.livelinessRelease(c.GetContext())
 	}
 	c.StopWaiter.StopAndWait()
-	c.client.Close()
+	if err := c.backend.Close(); err != nil {
+		log.Warn("error closing coordinator backend", "err", err)
+	}
 }
 
 func (c *SeqCoordinator) CurrentlyChosen() bool {
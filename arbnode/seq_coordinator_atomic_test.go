@@ -1,24 +1,22 @@
 // Copyright 2021-2022, Offchain Labs, Inc.
 // For license information, see https://github.com/nitro/blob/master/LICENSE
 
-//go:build redistest
-// +build redistest
-
 package arbnode
 
 import (
 	"context"
 	"fmt"
 	"math/rand"
-	"os"
+	"net"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
-	"github.com/go-redis/redis/v8"
+	"github.com/tenderly/nitro/go-ethereum/core/rawdb"
 	"github.com/tenderly/nitro/arbstate"
 	"github.com/tenderly/nitro/arbutil"
+	"github.com/tenderly/nitro/util/raftkv"
 )
 
 const messagesPerRound = 20
@@ -42,6 +40,8 @@ func coordinatorTestThread(ctx context.Context, coord *SeqCoordinator, data *Coo
 			if ctx.Err() != nil {
 				return
 			}
+			// don't starve the backend, which may run in this process
+			time.Sleep(time.Millisecond)
 		}
 		atomicTimeWrite(&coord.lockoutUntil, time.Time{})
 		nextRound++
@@ -94,10 +94,10 @@ func coordinatorTestThread(ctx context.Context, coord *SeqCoordinator, data *Coo
 	}
 }
 
-func TestSeqCoordinatorAtomic(t *testing.T) {
+// testSeqCoordinatorAtomic runs competing coordinators against a backend, and checks no message is sequenced twice.
+// newBackend returns the backend of the coordinator with the given index, and reset clears the backend between rounds.
+func testSeqCoordinatorAtomic(t *testing.T, ctx context.Context, newBackend func(i int) SeqCoordinatorBackend, reset func() error) {
 	NumOfThreads := 10
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 
 	coordConfig := TestSeqCoordinatorConfig
 	coordConfig.LockoutDuration = time.Millisecond * 100
@@ -108,27 +108,18 @@ func TestSeqCoordinatorAtomic(t *testing.T) {
 		sequencer:      make([]string, messagesPerRound),
 	}
 
-	redisUrl := os.Getenv("TEST_REDIS")
-	if redisUrl == "" {
-		redisUrl = coordConfig.RedisUrl
-	}
-	redisOptions, err := redis.ParseURL(redisUrl)
-	Require(t, err)
-
-	redisClient := redis.NewClient(redisOptions)
-
 	for i := 0; i < NumOfThreads; i++ {
 		config := coordConfig
 		config.MyUrl = fmt.Sprint(i)
 		coordinator := &SeqCoordinator{
-			client: redis.NewClient(redisOptions),
-			config: config,
+			backend: newBackend(i),
+			config:  config,
 		}
 		go coordinatorTestThread(ctx, coordinator, &testData)
 	}
 
 	for round := int32(0); round < 10; round++ {
-		redisClient.Del(ctx, CHOSENSEQ_KEY, MSG_COUNT_KEY)
+		Require(t, reset())
 		testData.messageCount = 0
 		for i := 0; i < messagesPerRound; i++ {
 			testData.sequencer[i] = ""
@@ -151,3 +142,53 @@ func TestSeqCoordinatorAtomic(t *testing.T) {
 	}
 
 }
+
+func TestSeqCoordinatorAtomicRaft(t *testing.T) {
+	NumOfPeers := 3
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var listeners []net.Listener
+	var peers []string
+	for i := 0; i < NumOfPeers; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Require(t, err)
+		listeners = append(listeners, listener)
+		peers = append(peers, listener.Addr().String())
+	}
+	for _, listener := range listeners {
+		listener.Close()
+	}
+
+	var backends []*raftSeqCoordinatorBackend
+	for i := 0; i < NumOfPeers; i++ {
+		raftConfig := TestSeqCoordinatorConfig.Raft
+		raftConfig.MyAddr = peers[i]
+		raftConfig.Peers = peers
+		node, err := raftkv.NewNode(&raftConfig, rawdb.NewMemoryDatabase())
+		Require(t, err)
+		backend := newRaftSeqCoordinatorBackend(node, nil)
+		Require(t, backend.Start(ctx))
+		defer backend.Close()
+		backends = append(backends, backend)
+	}
+
+	reset := func() error {
+		_, err := backends[0].execute(ctx, &raftkv.Command{Writes: []raftkv.Write{
+			{Key: CHOSENSEQ_KEY, Delete: true},
+			{Key: MSG_COUNT_KEY, Delete: true},
+		}})
+		return err
+	}
+	for attempt := 0; ; attempt++ {
+		err := reset()
+		if err == nil {
+			break
+		}
+		if attempt > 100 {
+			Fail(t, "raft cluster didn't elect a leader", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	testSeqCoordinatorAtomic(t, ctx, func(i int) SeqCoordinatorBackend { return backends[i%NumOfPeers] }, reset)
+}
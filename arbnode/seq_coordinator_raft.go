@@ -0,0 +1,217 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/arbutil"
+	"github.com/tenderly/nitro/util/raftkv"
+)
+
+type raftExecutor interface {
+	Execute(ctx context.Context, cmd *raftkv.Command) (*raftkv.Result, error)
+}
+
+// raftSeqCoordinatorBackend coordinates sequencers through a raft log replicated among them,
+// removing the need for an external redis server.
+// Every command goes through the raft log, so it needs a majority of the peers to be reachable.
+type raftSeqCoordinatorBackend struct {
+	node       *raftkv.Node // nil if only acting as a client of the cluster
+	executor   raftExecutor
+	priorities []string
+}
+
+func newRaftSeqCoordinatorBackend(node *raftkv.Node, priorities []string) *raftSeqCoordinatorBackend {
+	return &raftSeqCoordinatorBackend{
+		node:       node,
+		executor:   node,
+		priorities: priorities,
+	}
+}
+
+// newRaftClientSeqCoordinatorBackend accesses a raft cluster without being one of its peers
+func newRaftClientSeqCoordinatorBackend(peers []string, jwtSecret string, timeout time.Duration) (*raftSeqCoordinatorBackend, error) {
+	client, err := raftkv.NewClient(peers, jwtSecret, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &raftSeqCoordinatorBackend{
+		executor: client,
+	}, nil
+}
+
+func (b *raftSeqCoordinatorBackend) execute(ctx context.Context, cmd *raftkv.Command) (*raftkv.Result, error) {
+	result, err := b.executor.Execute(ctx, cmd)
+	if errors.Is(err, raftkv.ErrNoLeader) {
+		// the command certainly wasn't applied, so it's safe to retry
+		return nil, fmt.Errorf("%w: %v", ErrRetrySequencer, err)
+	}
+	return result, err
+}
+
+func (b *raftSeqCoordinatorBackend) read(ctx context.Context, keys ...string) ([]raftkv.Value, error) {
+	result, err := b.execute(ctx, &raftkv.Command{Reads: keys})
+	if err != nil {
+		return nil, err
+	}
+	return result.Values, nil
+}
+
+func (b *raftSeqCoordinatorBackend) Start(ctx context.Context) error {
+	if b.node == nil {
+		return nil
+	}
+	return b.node.Start(ctx)
+}
+
+func (b *raftSeqCoordinatorBackend) Priorities(ctx context.Context) ([]string, error) {
+	if len(b.priorities) > 0 {
+		return b.priorities, nil
+	}
+	values, err := b.read(ctx, PRIORITIES_KEY)
+	if err != nil {
+		return nil, err
+	}
+	if !values[0].Exists {
+		return nil, errors.New("sequencer priorities unset")
+	}
+	return strings.Split(string(values[0].Value), ","), nil
+}
+
+func (b *raftSeqCoordinatorBackend) FirstAlive(ctx context.Context, urls []string) (string, error) {
+	keys := make([]string, 0, len(urls))
+	for _, url := range urls {
+		keys = append(keys, livelinessKeyFor(url))
+	}
+	values, err := b.read(ctx, keys...)
+	if err != nil {
+		return "", err
+	}
+	for i, value := range values {
+		if value.Exists {
+			return urls[i], nil
+		}
+	}
+	return "", nil
+}
+
+func (b *raftSeqCoordinatorBackend) SetAlive(ctx context.Context, url string, until time.Time) error {
+	_, err := b.execute(ctx, &raftkv.Command{Writes: []raftkv.Write{
+		{Key: livelinessKeyFor(url), Value: []byte(LIVELINESS_VAL), ExpireAt: until.UnixMilli()},
+	}})
+	return err
+}
+
+func (b *raftSeqCoordinatorBackend) ReleaseAlive(ctx context.Context, url string) error {
+	_, err := b.execute(ctx, &raftkv.Command{Writes: []raftkv.Write{
+		{Key: livelinessKeyFor(url), Delete: true},
+	}})
+	return err
+}
+
+func (b *raftSeqCoordinatorBackend) MsgCount(ctx context.Context) ([]byte, error) {
+	values, err := b.read(ctx, MSG_COUNT_KEY)
+	if err != nil || !values[0].Exists {
+		return nil, err
+	}
+	return values[0].Value, nil
+}
+
+func (b *raftSeqCoordinatorBackend) Message(ctx context.Context, pos arbutil.MessageIndex) ([]byte, error) {
+	values, err := b.read(ctx, messageKeyFor(pos))
+	if err != nil {
+		return nil, err
+	}
+	if !values[0].Exists {
+		return nil, fmt.Errorf("message %v not found", pos)
+	}
+	return values[0].Value, nil
+}
+
+func (b *raftSeqCoordinatorBackend) SetMessage(ctx context.Context, pos arbutil.MessageIndex, data []byte, expiration time.Duration) error {
+	_, err := b.execute(ctx, &raftkv.Command{Writes: []raftkv.Write{
+		{Key: messageKeyFor(pos), Value: data, ExpireAt: time.Now().Add(expiration).UnixMilli()},
+	}})
+	return err
+}
+
+func (b *raftSeqCoordinatorBackend) UpdateChosen(ctx context.Context, update *SeqCoordinatorChosenUpdate) error {
+	values, err := b.read(ctx, CHOSENSEQ_KEY, MSG_COUNT_KEY)
+	if err != nil {
+		return err
+	}
+	chosen, msgCount := values[0], values[1]
+	if chosen.Exists && string(chosen.Value) != update.MyUrl {
+		return fmt.Errorf("%w: failed to catch lock. raft shows chosen: %s", ErrRetrySequencer, string(chosen.Value))
+	}
+	var msgCountData []byte
+	if msgCount.Exists {
+		msgCountData = msgCount.Value
+	}
+	if err := update.CheckMsgCount(msgCountData); err != nil {
+		return err
+	}
+	dataExpireAt := time.Now().Add(update.DataDuration).UnixMilli()
+	cmd := &raftkv.Command{
+		// only apply if neither key changed since they were read
+		Conditions: []raftkv.Condition{
+			{Key: CHOSENSEQ_KEY, Exists: chosen.Exists, Version: chosen.Version},
+			{Key: MSG_COUNT_KEY, Exists: msgCount.Exists, Version: msgCount.Version},
+		},
+		Writes: []raftkv.Write{
+			{Key: CHOSENSEQ_KEY, Value: []byte(update.MyUrl), ExpireAt: update.LockoutUntil.UnixMilli()},
+			{Key: MSG_COUNT_KEY, Value: update.MsgCount, ExpireAt: dataExpireAt},
+			{Key: livelinessKeyFor(update.MyUrl), Value: []byte(LIVELINESS_VAL), ExpireAt: update.LockoutUntil.UnixMilli()},
+		},
+	}
+	if update.Message != nil {
+		cmd.Writes = append(cmd.Writes, raftkv.Write{Key: messageKeyFor(update.MsgPos), Value: update.Message, ExpireAt: dataExpireAt})
+	}
+	result, err := b.execute(ctx, cmd)
+	if err != nil {
+		return fmt.Errorf("chosen sequencer failed to update raft: %w", err)
+	}
+	if !result.Applied {
+		return fmt.Errorf("%w: failed to catch sequencer lock", ErrRetrySequencer)
+	}
+	return nil
+}
+
+func (b *raftSeqCoordinatorBackend) ReleaseChosen(ctx context.Context, url string) error {
+	// retry if the lock was updated between reading and deleting it, as it may still be ours
+	for attempt := 0; attempt < 3; attempt++ {
+		values, err := b.read(ctx, CHOSENSEQ_KEY)
+		if err != nil {
+			return err
+		}
+		chosen := values[0]
+		if !chosen.Exists || string(chosen.Value) != url {
+			return nil
+		}
+		result, err := b.execute(ctx, &raftkv.Command{
+			Conditions: []raftkv.Condition{{Key: CHOSENSEQ_KEY, Exists: true, Version: chosen.Version}},
+			Writes:     []raftkv.Write{{Key: CHOSENSEQ_KEY, Delete: true}},
+		})
+		if err != nil {
+			return err
+		}
+		if result.Applied {
+			return nil
+		}
+	}
+	return errors.New("chosen sequencer lock kept changing while releasing it")
+}
+
+func (b *raftSeqCoordinatorBackend) Close() error {
+	if b.node != nil {
+		b.node.StopAndWait()
+	}
+	return nil
+}
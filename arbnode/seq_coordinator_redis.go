@@ -0,0 +1,208 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/arbutil"
+)
+
+// redisSeqCoordinatorBackend coordinates sequencers through a shared redis server
+type redisSeqCoordinatorBackend struct {
+	client          redis.UniversalClient
+	lockoutDuration time.Duration
+}
+
+func newRedisSeqCoordinatorBackend(redisUrl string, lockoutDuration time.Duration) (*redisSeqCoordinatorBackend, error) {
+	redisOptions, err := redis.ParseURL(redisUrl)
+	if err != nil {
+		return nil, err
+	}
+	return &redisSeqCoordinatorBackend{
+		client:          redis.NewClient(redisOptions),
+		lockoutDuration: lockoutDuration,
+	}, nil
+}
+
+// initialDuration is the expiry set on keys before they're given their exact expiry time
+func (b *redisSeqCoordinatorBackend) initialDuration() time.Duration {
+	if b.lockoutDuration < 2*time.Second {
+		return 2 * time.Second
+	}
+	return b.lockoutDuration
+}
+
+func execTestPipe(pipe redis.Pipeliner, ctx context.Context) error {
+	cmders, err := pipe.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	for _, cmder := range cmders {
+		if err := cmder.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *redisSeqCoordinatorBackend) Start(ctx context.Context) error {
+	return nil
+}
+
+func (b *redisSeqCoordinatorBackend) Priorities(ctx context.Context) ([]string, error) {
+	prioritiesString, err := b.client.Get(ctx, PRIORITIES_KEY).Result()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			err = errors.New("sequencer priorities unset")
+		}
+		return nil, err
+	}
+	return strings.Split(prioritiesString, ","), nil
+}
+
+func (b *redisSeqCoordinatorBackend) FirstAlive(ctx context.Context, urls []string) (string, error) {
+	for _, url := range urls {
+		err := b.client.Get(ctx, livelinessKeyFor(url)).Err()
+		if errors.Is(err, redis.Nil) { // liveliness not set
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return url, nil
+	}
+	return "", nil
+}
+
+func (b *redisSeqCoordinatorBackend) SetAlive(ctx context.Context, url string, until time.Time) error {
+	livelinessKey := livelinessKeyFor(url)
+	pipe := b.client.TxPipeline()
+	pipe.Set(ctx, livelinessKey, LIVELINESS_VAL, b.initialDuration())
+	pipe.PExpireAt(ctx, livelinessKey, until)
+	return execTestPipe(pipe, ctx)
+}
+
+func (b *redisSeqCoordinatorBackend) ReleaseAlive(ctx context.Context, url string) error {
+	livelinessKey := livelinessKeyFor(url)
+	releaseErr := b.client.Del(ctx, livelinessKey).Err()
+	if releaseErr == nil {
+		return nil
+	}
+	// got error - was it still deleted?
+	readErr := b.client.Get(ctx, livelinessKey).Err()
+	if errors.Is(readErr, redis.Nil) {
+		return nil
+	}
+	return releaseErr
+}
+
+func (b *redisSeqCoordinatorBackend) MsgCount(ctx context.Context) ([]byte, error) {
+	return getOptionalBytes(ctx, b.client, MSG_COUNT_KEY)
+}
+
+func getOptionalBytes(ctx context.Context, r redis.Cmdable, key string) ([]byte, error) {
+	res, err := r.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	return res, err
+}
+
+func (b *redisSeqCoordinatorBackend) Message(ctx context.Context, pos arbutil.MessageIndex) ([]byte, error) {
+	return b.client.Get(ctx, messageKeyFor(pos)).Bytes()
+}
+
+func (b *redisSeqCoordinatorBackend) SetMessage(ctx context.Context, pos arbutil.MessageIndex, data []byte, expiration time.Duration) error {
+	return b.client.Set(ctx, messageKeyFor(pos), data, expiration).Err()
+}
+
+func (b *redisSeqCoordinatorBackend) UpdateChosen(ctx context.Context, update *SeqCoordinatorChosenUpdate) error {
+	return b.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, CHOSENSEQ_KEY).Result()
+		var wasEmpty bool
+		if errors.Is(err, redis.Nil) {
+			wasEmpty = true
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+		if !wasEmpty && (current != update.MyUrl) {
+			return fmt.Errorf("%w: failed to catch lock. redis shows chosen: %s", ErrRetrySequencer, current)
+		}
+		msgCount, err := getOptionalBytes(ctx, tx, MSG_COUNT_KEY)
+		if err != nil {
+			return err
+		}
+		if err := update.CheckMsgCount(msgCount); err != nil {
+			return err
+		}
+		pipe := tx.TxPipeline()
+		initialDuration := b.initialDuration()
+		if wasEmpty {
+			pipe.Set(ctx, CHOSENSEQ_KEY, update.MyUrl, initialDuration)
+		}
+		pipe.Set(ctx, MSG_COUNT_KEY, update.MsgCount, update.DataDuration)
+		myLivelinessKey := livelinessKeyFor(update.MyUrl)
+		pipe.Set(ctx, myLivelinessKey, LIVELINESS_VAL, initialDuration)
+		if update.Message != nil {
+			pipe.Set(ctx, messageKeyFor(update.MsgPos), update.Message, update.DataDuration)
+		}
+		pipe.PExpireAt(ctx, CHOSENSEQ_KEY, update.LockoutUntil)
+		pipe.PExpireAt(ctx, myLivelinessKey, update.LockoutUntil)
+		err = execTestPipe(pipe, ctx)
+		if errors.Is(err, redis.TxFailedErr) {
+			return fmt.Errorf("%w: failed to catch sequencer lock", ErrRetrySequencer)
+		}
+		if err != nil {
+			return fmt.Errorf("chosen sequencer failed to update redis: %w", err)
+		}
+		return nil
+	}, CHOSENSEQ_KEY, MSG_COUNT_KEY)
+}
+
+func (b *redisSeqCoordinatorBackend) ReleaseChosen(ctx context.Context, url string) error {
+	releaseErr := b.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := tx.Get(ctx, CHOSENSEQ_KEY).Result()
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if current != url {
+			return nil
+		}
+		pipe := tx.TxPipeline()
+		pipe.Del(ctx, CHOSENSEQ_KEY)
+		err = execTestPipe(pipe, ctx)
+		if err != nil {
+			return fmt.Errorf("chosen sequencer failed to update redis: %w", err)
+		}
+		return nil
+	}, CHOSENSEQ_KEY)
+	if releaseErr == nil {
+		return nil
+	}
+	// got error - was it still released?
+	current, readErr := b.client.Get(ctx, CHOSENSEQ_KEY).Result()
+	if errors.Is(readErr, redis.Nil) {
+		return nil
+	}
+	if current != url {
+		return nil
+	}
+	return releaseErr
+}
+
+func (b *redisSeqCoordinatorBackend) Close() error {
+	return b.client.Close()
+}
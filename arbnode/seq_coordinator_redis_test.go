@@ -0,0 +1,38 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+//go:build redistest
+// +build redistest
+
+package arbnode
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestSeqCoordinatorAtomic(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	redisUrl := os.Getenv("TEST_REDIS")
+	if redisUrl == "" {
+		redisUrl = TestSeqCoordinatorConfig.RedisUrl
+	}
+	redisOptions, err := redis.ParseURL(redisUrl)
+	Require(t, err)
+
+	redisClient := redis.NewClient(redisOptions)
+	reset := func() error {
+		return redisClient.Del(ctx, CHOSENSEQ_KEY, MSG_COUNT_KEY).Err()
+	}
+	newBackend := func(i int) SeqCoordinatorBackend {
+		backend, err := newRedisSeqCoordinatorBackend(redisUrl, TestSeqCoordinatorConfig.LockoutDuration)
+		Require(t, err)
+		return backend
+	}
+	testSeqCoordinatorAtomic(t, ctx, newBackend, reset)
+}
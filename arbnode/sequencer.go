@@ -19,6 +19,8 @@ import (
 	"github.com/tenderly/nitro/go-ethereum/core/types"
 	"github.com/tenderly/nitro/go-ethereum/core/vm"
 	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
+	"github.com/tenderly/nitro/go-ethereum/rpc"
 	"github.com/tenderly/nitro/arbos"
 	"github.com/tenderly/nitro/arbos/arbosState"
 	"github.com/tenderly/nitro/arbos/l1pricing"
@@ -29,13 +31,73 @@ import (
 // 95% of the SequencerInbox limit, leaving ~5KB for headers and such
 const maxTxDataSize uint64 = 112065
 
+var (
+	sequencerQueueDepthGauge     = metrics.NewRegisteredGauge("arb/sequencer/queue/depth", nil)
+	sequencerQueueWaitHistogram  = metrics.NewRegisteredHistogram("arb/sequencer/queue/wait", nil, metrics.NewExpDecaySample(1028, 0.015))
+	sequencerTxSequencedCounter  = metrics.NewRegisteredCounter("arb/sequencer/transactions/sequenced", nil)
+	sequencerTxRejectionCounters = make(map[string]metrics.Counter)
+)
+
+// The reasons transactions are rejected for, each counted by arb/sequencer/transactions/rejected/<reason>
+var sequencerTxRejectionReasons = []string{"whitelist", "queuefull", "expired", "oversized", "nonce", "funds", "gas", "conditions", "reverted", "other"}
+
+func init() {
+	for _, reason := range sequencerTxRejectionReasons {
+		sequencerTxRejectionCounters[reason] = metrics.NewRegisteredCounter("arb/sequencer/transactions/rejected/"+reason, nil)
+	}
+}
+
+var errSenderNotWhitelisted = errors.New("transaction sender is not on the whitelist")
+
+func sequencerTxRejectionReason(err error) string {
+	switch {
+	case errors.Is(err, errSenderNotWhitelisted):
+		return "whitelist"
+	case errors.Is(err, ErrSequencerQueueFull):
+		return "queuefull"
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return "expired"
+	case errors.Is(err, core.ErrOversizedData):
+		return "oversized"
+	case errors.Is(err, core.ErrNonceTooLow), errors.Is(err, core.ErrNonceTooHigh):
+		return "nonce"
+	case errors.Is(err, core.ErrInsufficientFunds), errors.Is(err, core.ErrInsufficientFundsForTransfer):
+		return "funds"
+	case errors.Is(err, core.ErrIntrinsicGas), errors.Is(err, core.ErrGasLimit), errors.Is(err, core.ErrFeeCapTooLow):
+		return "gas"
+	case errors.Is(err, arbos.ErrConditionsNotMet):
+		return "conditions"
+	case errors.Is(err, vm.ErrExecutionReverted):
+		return "reverted"
+	default:
+		return "other"
+	}
+}
+
+func recordSequencerTxResult(err error) {
+	if err == nil {
+		sequencerTxSequencedCounter.Inc(1)
+		return
+	}
+	sequencerTxRejectionCounters[sequencerTxRejectionReason(err)].Inc(1)
+}
+
 type txQueueItem struct {
 	tx         *types.Transaction
+	sender     common.Address
+	options    *arbos.ConditionalOptions
 	resultChan chan<- error
 	ctx        context.Context
+	seq        uint64
+	arrival    time.Time
+	journal    *txJournal
+	journalId  uint64
 }
 
 func (i *txQueueItem) returnResult(err error) {
+	if i.journal != nil {
+		i.journal.remove(i.journalId)
+	}
 	i.resultChan <- err
 	close(i.resultChan)
 }
@@ -44,10 +106,13 @@ type Sequencer struct {
 	stopwaiter.StopWaiter
 
 	txStreamer      *TransactionStreamer
-	txQueue         chan txQueueItem
+	txQueue         *sequencerQueue
 	l1Reader        *headerreader.HeaderReader
 	config          SequencerConfig
 	senderWhitelist map[common.Address]struct{}
+	txFilters       []SequencerTxFilter
+	denyList        *TxDenyList
+	journal         *txJournal
 
 	L1BlockAndTimeMutex sync.Mutex
 	l1BlockNumber       uint64
@@ -69,12 +134,52 @@ func NewSequencer(txStreamer *TransactionStreamer, l1Reader *headerreader.Header
 		}
 		senderWhitelist[common.HexToAddress(address)] = struct{}{}
 	}
+	txFilters, denyList, err := newSequencerTxFilters(config.Filter)
+	if err != nil {
+		return nil, err
+	}
+	txQueue, err := newSequencerQueue(config.Queue)
+	if err != nil {
+		return nil, err
+	}
+	var journal *txJournal
+	if config.Journal.Enable {
+		var entries []journalEntry
+		journal, entries, err = openTxJournal(txStreamer.db)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			log.Info("replaying sequencer journal", "transactions", len(entries))
+		}
+		signer := types.LatestSigner(txStreamer.bc.Config())
+		for _, entry := range entries {
+			item := txQueueItem{
+				tx:         entry.tx,
+				options:    entry.options,
+				resultChan: make(chan error, 1),
+				ctx:        context.Background(),
+				journal:    journal,
+				journalId:  entry.id,
+			}
+			item.sender, err = types.Sender(signer, entry.tx)
+			if err != nil {
+				// this can't be sequenced, so there's no point in keeping it
+				item.returnResult(err)
+				continue
+			}
+			txQueue.pushUnlimited(item)
+		}
+	}
 	return &Sequencer{
 		txStreamer:      txStreamer,
-		txQueue:         make(chan txQueueItem, 128),
+		txQueue:         txQueue,
 		l1Reader:        l1Reader,
 		config:          config,
 		senderWhitelist: senderWhitelist,
+		txFilters:       txFilters,
+		denyList:        denyList,
+		journal:         journal,
 		l1BlockNumber:   0,
 		l1Timestamp:     0,
 	}, nil
@@ -82,29 +187,41 @@ func NewSequencer(txStreamer *TransactionStreamer, l1Reader *headerreader.Header
 
 var ErrRetrySequencer = errors.New("please retry transaction")
 
-func (s *Sequencer) PublishTransaction(ctx context.Context, tx *types.Transaction) error {
+func (s *Sequencer) PublishTransaction(ctx context.Context, tx *types.Transaction, options *arbos.ConditionalOptions) error {
+	signer := types.LatestSigner(s.txStreamer.bc.Config())
+	sender, err := types.Sender(signer, tx)
+	if err != nil {
+		return err
+	}
 	if len(s.senderWhitelist) > 0 {
-		signer := types.LatestSigner(s.txStreamer.bc.Config())
-		sender, err := types.Sender(signer, tx)
-		if err != nil {
-			return err
-		}
 		_, authorized := s.senderWhitelist[sender]
 		if !authorized {
-			return errors.New("transaction sender is not on the whitelist")
+			recordSequencerTxResult(errSenderNotWhitelisted)
+			return errSenderNotWhitelisted
 		}
 	}
 
 	resultChan := make(chan error, 1)
 	queueItem := txQueueItem{
-		tx,
-		resultChan,
-		ctx,
+		tx:         tx,
+		sender:     sender,
+		options:    options,
+		resultChan: resultChan,
+		ctx:        ctx,
 	}
-	select {
-	case s.txQueue <- queueItem:
-	case <-ctx.Done():
-		return ctx.Err()
+	if s.journal != nil {
+		queueItem.journal = s.journal
+		queueItem.journalId, err = s.journal.add(tx, options)
+		if err != nil {
+			return err
+		}
+	}
+	if err := s.txQueue.push(queueItem); err != nil {
+		if s.journal != nil {
+			s.journal.remove(queueItem.journalId)
+		}
+		recordSequencerTxResult(err)
+		return err
 	}
 	select {
 	case res := <-resultChan:
@@ -114,7 +231,18 @@ func (s *Sequencer) PublishTransaction(ctx context.Context, tx *types.Transactio
 	}
 }
 
+// AddTxFilter appends a filter to the sequencer's filter chain. It must be called before Start.
+func (s *Sequencer) AddTxFilter(filter SequencerTxFilter) {
+	s.txFilters = append(s.txFilters, filter)
+}
+
 func (s *Sequencer) preTxFilter(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address) error {
+	for _, filter := range s.txFilters {
+		if err := filter.PreTx(state, tx, sender); err != nil {
+			recordTxFilterRejection(filter, tx, sender, err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -122,6 +250,17 @@ func (s *Sequencer) postTxFilter(state *arbosState.ArbosState, tx *types.Transac
 	if receipt.Status == types.ReceiptStatusFailed && receipt.GasUsed > dataGas && receipt.GasUsed-dataGas <= s.config.MaxRevertGasReject {
 		return vm.ErrExecutionReverted
 	}
+	for _, filter := range s.txFilters {
+		if err := filter.PostTx(state, tx, sender, dataGas, receipt); err != nil {
+			recordTxFilterRejection(filter, tx, sender, err)
+			return err
+		}
+	}
+	for _, filter := range s.txFilters {
+		if listener, ok := filter.(SequencerTxAcceptListener); ok {
+			listener.TxAccepted(tx, sender)
+		}
+	}
 	return nil
 }
 
@@ -159,65 +298,81 @@ func (s *Sequencer) forwardIfSet(queueItems []txQueueItem) bool {
 		return false
 	}
 	for _, item := range queueItems {
-		item.resultChan <- s.forwarder.PublishTransaction(item.ctx, item.tx)
+		ctx, cancel := context.WithTimeout(item.ctx, s.config.ForwardTimeout)
+		err := s.forwarder.PublishTransaction(ctx, item.tx, item.options)
+		cancel()
+		if err != nil && item.journal != nil && item.ctx.Err() == nil && !isForwardAnswer(err) {
+			// Keep the journaled tx, such as one replayed during a handoff, until it reaches a sequencer
+			log.Debug("failed to forward journaled transaction, requeueing it", "tx", item.tx.Hash(), "err", err)
+			s.txQueue.requeue(item)
+			continue
+		}
+		s.returnResult(item, err)
 	}
 	return true
 }
 
+// isForwardAnswer is whether the forwarding error is the sequencer's answer, rather than a failure to reach it
+func isForwardAnswer(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr)
+}
+
+// returnResult returns the result of sequencing the transaction to its publisher, recording it in the metrics
+func (s *Sequencer) returnResult(item txQueueItem, err error) {
+	sequencerQueueWaitHistogram.Update(time.Since(item.arrival).Nanoseconds())
+	recordSequencerTxResult(err)
+	item.returnResult(err)
+}
+
 func (s *Sequencer) sequenceTransactions(ctx context.Context) {
 	var txes types.Transactions
+	var conditionalOptions []*arbos.ConditionalOptions
 	var queueItems []txQueueItem
 	var totalBatchSize int
 	for {
 		var queueItem txQueueItem
+		var ok bool
 		if len(txes) == 0 {
-			select {
-			case queueItem = <-s.txQueue:
-			case <-ctx.Done():
+			queueItem, ok = s.txQueue.popWait(ctx)
+			if !ok {
 				return
 			}
 		} else {
-			done := false
-			select {
-			case queueItem = <-s.txQueue:
-			default:
-				done = true
-			}
-			if done {
+			queueItem, ok = s.txQueue.pop()
+			if !ok {
 				break
 			}
 		}
 		err := queueItem.ctx.Err()
 		if err != nil {
-			queueItem.returnResult(err)
+			s.returnResult(queueItem, err)
 			continue
 		}
 		txBytes, err := queueItem.tx.MarshalBinary()
 		if err != nil {
-			queueItem.returnResult(err)
+			s.returnResult(queueItem, err)
 			continue
 		}
 		if len(txBytes) > int(maxTxDataSize) {
 			// This tx is too large
-			queueItem.returnResult(core.ErrOversizedData)
+			s.returnResult(queueItem, core.ErrOversizedData)
 			continue
 		}
 		if totalBatchSize+len(txBytes) > int(maxTxDataSize) {
 			// This tx would be too large to add to this batch.
-			// Attempt to put it back in the queue, but error if the queue is full.
-			// Then, end the batch here.
-			select {
-			case s.txQueue <- queueItem:
-			default:
-				queueItem.returnResult(core.ErrOversizedData)
-			}
+			// Put it back at the front of the queue and end the batch here.
+			s.txQueue.requeue(queueItem)
 			break
 		}
 		totalBatchSize += len(txBytes)
 		txes = append(txes, queueItem.tx)
+		conditionalOptions = append(conditionalOptions, queueItem.options)
 		queueItems = append(queueItems, queueItem)
 	}
 
+	sequencerQueueDepthGauge.Update(int64(s.txQueue.Len()))
+
 	if s.forwardIfSet(queueItems) {
 		return
 	}
@@ -248,10 +403,11 @@ func (s *Sequencer) sequenceTransactions(ctx context.Context) {
 	}
 
 	hooks := &arbos.SequencingHooks{
-		PreTxFilter:            s.preTxFilter,
-		PostTxFilter:           s.postTxFilter,
-		DiscardInvalidTxsEarly: true,
-		TxErrors:               []error{},
+		PreTxFilter:             s.preTxFilter,
+		PostTxFilter:            s.postTxFilter,
+		DiscardInvalidTxsEarly:  true,
+		TxErrors:                []error{},
+		ConditionalOptionsForTx: conditionalOptions,
 	}
 	err := s.txStreamer.SequenceTransactions(header, txes, hooks)
 	if err == nil && len(hooks.TxErrors) != len(txes) {
@@ -263,37 +419,42 @@ func (s *Sequencer) sequenceTransactions(ctx context.Context) {
 		if s.forwardIfSet(queueItems) {
 			return
 		}
-		// try to add back to queue otherwise
+		// add back to queue otherwise
 		for _, item := range queueItems {
-			select {
-			case s.txQueue <- item:
-			default:
-				item.resultChan <- errors.New("queue full")
-			}
+			s.txQueue.requeue(item)
 		}
 		return
 	}
 	if err != nil {
 		log.Warn("error sequencing transactions", "err", err)
 		for _, queueItem := range queueItems {
-			queueItem.returnResult(err)
+			s.returnResult(queueItem, err)
 		}
 		return
 	}
 
-	for i, err := range hooks.TxErrors {
+	s.returnResults(queueItems, hooks.TxErrors)
+}
+
+// returnResults returns the results of sequencing a block's transactions, except for those which
+// didn't fit in the block, which are requeued for the next block along with their sender's later transactions.
+func (s *Sequencer) returnResults(queueItems []txQueueItem, txErrors []error) {
+	requeuedSenders := make(map[common.Address]struct{})
+	for i, err := range txErrors {
 		queueItem := queueItems[i]
+		if _, ok := requeuedSenders[queueItem.sender]; ok {
+			// The sender's earlier nonce wasn't sequenced, so this one failed or would have
+			s.txQueue.requeue(queueItem)
+			continue
+		}
 		if errors.Is(err, core.ErrGasLimit) {
 			// There's not enough gas left in the block for this tx.
-			// Attempt to re-queue the transaction.
-			// If the queue is full, fall through to returning an error.
-			select {
-			case s.txQueue <- queueItem:
-				continue
-			default:
-			}
+			// Re-queue the transaction for the next block.
+			s.txQueue.requeue(queueItem)
+			requeuedSenders[queueItem.sender] = struct{}{}
+			continue
 		}
-		queueItem.returnResult(err)
+		s.returnResult(queueItem, err)
 	}
 }
 
@@ -346,6 +507,15 @@ func (s *Sequencer) Start(ctxIn context.Context) error {
 
 	}
 
+	if s.denyList != nil {
+		s.CallIteratively(func(ctx context.Context) time.Duration {
+			if err := s.denyList.Reload(); err != nil {
+				log.Error("error reloading sequencer deny list", "err", err)
+			}
+			return s.config.Filter.DenyListReloadInterval
+		})
+	}
+
 	s.CallIteratively(func(ctx context.Context) time.Duration {
 		nextBlock := time.Now().Add(s.config.MaxBlockSpeed)
 		s.sequenceTransactions(ctx)
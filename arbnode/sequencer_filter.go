@@ -0,0 +1,380 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
+	"github.com/tenderly/nitro/arbos/arbosState"
+)
+
+var (
+	ErrSenderDenied        = errors.New("transaction sender is denied")
+	ErrRecipientDenied     = errors.New("transaction recipient is denied")
+	ErrSenderRateLimited   = errors.New("transaction sender is rate limited")
+	ErrTxGasLimitExceeded  = errors.New("transaction gas limit exceeds sequencer maximum")
+	ErrContractInteraction = errors.New("transaction interacted with a blocked contract")
+)
+
+// SequencerTxFilter decides whether the sequencer includes a transaction.
+// PreTx is called before the transaction is executed, and PostTx with its receipt afterwards.
+// Returning an error from either rejects the transaction, and the error is returned to the RPC caller.
+type SequencerTxFilter interface {
+	Name() string
+	PreTx(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address) error
+	PostTx(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address, dataGas uint64, receipt *types.Receipt) error
+}
+
+// SequencerTxAcceptListener is a SequencerTxFilter that's told when every filter accepted a transaction,
+// for filters keeping track of what's sequenced rather than what's attempted.
+type SequencerTxAcceptListener interface {
+	TxAccepted(tx *types.Transaction, sender common.Address)
+}
+
+type SequencerFilterConfig struct {
+	DenyListFile            string        `koanf:"deny-list-file"`
+	DenyListReloadInterval  time.Duration `koanf:"deny-list-reload-interval"`
+	ContractBlocklist       []string      `koanf:"contract-blocklist"`
+	MaxGasPerTx             uint64        `koanf:"max-gas-per-tx"`
+	SenderRateLimit         uint64        `koanf:"sender-rate-limit"`
+	SenderRateLimitInterval time.Duration `koanf:"sender-rate-limit-interval"`
+}
+
+func SequencerFilterConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".deny-list-file", DefaultSequencerFilterConfig.DenyListFile, "file of denied addresses, one per line, optionally prefixed by \"sender\", \"recipient\" or \"contract\" (if empty, no deny list is used)")
+	f.Duration(prefix+".deny-list-reload-interval", DefaultSequencerFilterConfig.DenyListReloadInterval, "how often to check the deny list file for changes")
+	f.StringSlice(prefix+".contract-blocklist", DefaultSequencerFilterConfig.ContractBlocklist, "contracts which transactions may not emit logs from")
+	f.Uint64(prefix+".max-gas-per-tx", DefaultSequencerFilterConfig.MaxGasPerTx, "maximum gas limit of a sequenced transaction (0 = unlimited)")
+	f.Uint64(prefix+".sender-rate-limit", DefaultSequencerFilterConfig.SenderRateLimit, "maximum transactions sequenced per sender per rate limit interval (0 = unlimited)")
+	f.Duration(prefix+".sender-rate-limit-interval", DefaultSequencerFilterConfig.SenderRateLimitInterval, "interval the sender rate limit applies to")
+}
+
+var DefaultSequencerFilterConfig = SequencerFilterConfig{
+	DenyListFile:            "",
+	DenyListReloadInterval:  10 * time.Second,
+	ContractBlocklist:       []string{},
+	MaxGasPerTx:             0,
+	SenderRateLimit:         0,
+	SenderRateLimitInterval: time.Second,
+}
+
+var TestSequencerFilterConfig = SequencerFilterConfig{
+	DenyListFile:            "",
+	DenyListReloadInterval:  100 * time.Millisecond,
+	ContractBlocklist:       []string{},
+	MaxGasPerTx:             0,
+	SenderRateLimit:         0,
+	SenderRateLimitInterval: time.Second,
+}
+
+// newSequencerTxFilters creates the filters enabled by config, and the deny list if one is configured
+func newSequencerTxFilters(config SequencerFilterConfig) ([]SequencerTxFilter, *TxDenyList, error) {
+	var filters []SequencerTxFilter
+	var denyList *TxDenyList
+	if config.DenyListFile != "" {
+		denyList = NewTxDenyList(config.DenyListFile)
+		if err := denyList.Reload(); err != nil {
+			return nil, nil, err
+		}
+		filters = append(filters, denyList)
+	}
+	if len(config.ContractBlocklist) > 0 {
+		contracts, err := parseAddressList(config.ContractBlocklist)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "invalid sequencer contract blocklist")
+		}
+		filters = append(filters, NewContractBlocklistFilter(contracts))
+	}
+	if config.MaxGasPerTx > 0 {
+		filters = append(filters, NewMaxGasFilter(config.MaxGasPerTx))
+	}
+	if config.SenderRateLimit > 0 {
+		if config.SenderRateLimitInterval <= 0 {
+			return nil, nil, errors.New("sequencer sender rate limit interval must be positive")
+		}
+		filters = append(filters, NewSenderRateLimitFilter(config.SenderRateLimit, config.SenderRateLimitInterval))
+	}
+	return filters, denyList, nil
+}
+
+func parseAddressList(entries []string) (map[common.Address]struct{}, error) {
+	addresses := make(map[common.Address]struct{})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !common.IsHexAddress(entry) {
+			return nil, fmt.Errorf("\"%v\" is not a valid address", entry)
+		}
+		addresses[common.HexToAddress(entry)] = struct{}{}
+	}
+	return addresses, nil
+}
+
+type txFilterMetrics struct {
+	rejected metrics.Counter
+}
+
+var txFilterMetricsMutex sync.Mutex
+var txFilterMetricsByName = make(map[string]txFilterMetrics)
+
+func getTxFilterMetrics(name string) txFilterMetrics {
+	txFilterMetricsMutex.Lock()
+	defer txFilterMetricsMutex.Unlock()
+	m, ok := txFilterMetricsByName[name]
+	if !ok {
+		m = txFilterMetrics{
+			rejected: metrics.GetOrRegisterCounter("arb/sequencer/filter/"+name+"/rejected", nil),
+		}
+		txFilterMetricsByName[name] = m
+	}
+	return m
+}
+
+func recordTxFilterRejection(filter SequencerTxFilter, tx *types.Transaction, sender common.Address, err error) {
+	getTxFilterMetrics(filter.Name()).rejected.Inc(1)
+	log.Debug("sequencer filter rejected transaction", "filter", filter.Name(), "tx", tx.Hash(), "sender", sender, "err", err)
+}
+
+// TxDenyList rejects transactions from or to denied addresses, and which emit logs from denied contracts.
+// Its file is reloaded by the sequencer whenever it changes.
+type TxDenyList struct {
+	path string
+
+	mutex      sync.RWMutex
+	modTime    time.Time
+	senders    map[common.Address]struct{}
+	recipients map[common.Address]struct{}
+	contracts  map[common.Address]struct{}
+}
+
+func NewTxDenyList(path string) *TxDenyList {
+	return &TxDenyList{
+		path:       path,
+		senders:    make(map[common.Address]struct{}),
+		recipients: make(map[common.Address]struct{}),
+		contracts:  make(map[common.Address]struct{}),
+	}
+}
+
+func (d *TxDenyList) Name() string {
+	return "denylist"
+}
+
+// Reload reads the deny list file if it changed since it was last read
+func (d *TxDenyList) Reload() error {
+	info, err := os.Stat(d.path)
+	if err != nil {
+		return err
+	}
+	d.mutex.RLock()
+	unchanged := info.ModTime().Equal(d.modTime)
+	d.mutex.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	file, err := os.Open(d.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	senders := make(map[common.Address]struct{})
+	recipients := make(map[common.Address]struct{})
+	contracts := make(map[common.Address]struct{})
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		kind := ""
+		if len(fields) == 2 {
+			kind = strings.ToLower(fields[0])
+			fields = fields[1:]
+		}
+		if len(fields) != 1 || !common.IsHexAddress(fields[0]) {
+			return fmt.Errorf("invalid deny list entry at %v:%v", d.path, lineNum)
+		}
+		addr := common.HexToAddress(fields[0])
+		switch kind {
+		case "":
+			senders[addr] = struct{}{}
+			recipients[addr] = struct{}{}
+		case "sender":
+			senders[addr] = struct{}{}
+		case "recipient":
+			recipients[addr] = struct{}{}
+		case "contract":
+			contracts[addr] = struct{}{}
+		default:
+			return fmt.Errorf("unknown deny list entry kind \"%v\" at %v:%v", kind, d.path, lineNum)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.modTime = info.ModTime()
+	d.senders = senders
+	d.recipients = recipients
+	d.contracts = contracts
+	log.Info("loaded sequencer deny list", "path", d.path, "senders", len(senders), "recipients", len(recipients), "contracts", len(contracts))
+	return nil
+}
+
+func (d *TxDenyList) PreTx(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address) error {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	if _, denied := d.senders[sender]; denied {
+		return ErrSenderDenied
+	}
+	if to := tx.To(); to != nil {
+		if _, denied := d.recipients[*to]; denied {
+			return ErrRecipientDenied
+		}
+	}
+	return nil
+}
+
+func (d *TxDenyList) PostTx(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address, dataGas uint64, receipt *types.Receipt) error {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return checkReceiptContracts(d.contracts, receipt)
+}
+
+func checkReceiptContracts(contracts map[common.Address]struct{}, receipt *types.Receipt) error {
+	if len(contracts) == 0 {
+		return nil
+	}
+	if _, blocked := contracts[receipt.ContractAddress]; blocked && receipt.ContractAddress != (common.Address{}) {
+		return errors.Wrapf(ErrContractInteraction, "created %v", receipt.ContractAddress)
+	}
+	for _, txLog := range receipt.Logs {
+		if _, blocked := contracts[txLog.Address]; blocked {
+			return errors.Wrapf(ErrContractInteraction, "emitted log from %v", txLog.Address)
+		}
+	}
+	return nil
+}
+
+// ContractBlocklistFilter rejects transactions which emit logs from blocked contracts
+type ContractBlocklistFilter struct {
+	contracts map[common.Address]struct{}
+}
+
+func NewContractBlocklistFilter(contracts map[common.Address]struct{}) *ContractBlocklistFilter {
+	return &ContractBlocklistFilter{contracts: contracts}
+}
+
+func (f *ContractBlocklistFilter) Name() string {
+	return "contractblocklist"
+}
+
+func (f *ContractBlocklistFilter) PreTx(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address) error {
+	return nil
+}
+
+func (f *ContractBlocklistFilter) PostTx(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address, dataGas uint64, receipt *types.Receipt) error {
+	return checkReceiptContracts(f.contracts, receipt)
+}
+
+// MaxGasFilter rejects transactions with a gas limit above a maximum
+type MaxGasFilter struct {
+	maxGas uint64
+}
+
+func NewMaxGasFilter(maxGas uint64) *MaxGasFilter {
+	return &MaxGasFilter{maxGas: maxGas}
+}
+
+func (f *MaxGasFilter) Name() string {
+	return "maxgas"
+}
+
+func (f *MaxGasFilter) PreTx(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address) error {
+	if tx.Gas() > f.maxGas {
+		return errors.Wrapf(ErrTxGasLimitExceeded, "gas limit %v above maximum %v", tx.Gas(), f.maxGas)
+	}
+	return nil
+}
+
+func (f *MaxGasFilter) PostTx(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address, dataGas uint64, receipt *types.Receipt) error {
+	return nil
+}
+
+// SenderRateLimitFilter limits how many transactions each sender can have sequenced per interval.
+// Only transactions every filter accepted count towards the limit.
+type SenderRateLimitFilter struct {
+	limit    uint64
+	interval time.Duration
+	now      func() time.Time
+
+	mutex       sync.Mutex
+	windowStart time.Time
+	counts      map[common.Address]uint64
+}
+
+func NewSenderRateLimitFilter(limit uint64, interval time.Duration) *SenderRateLimitFilter {
+	return &SenderRateLimitFilter{
+		limit:    limit,
+		interval: interval,
+		now:      time.Now,
+		counts:   make(map[common.Address]uint64),
+	}
+}
+
+func (f *SenderRateLimitFilter) Name() string {
+	return "ratelimit"
+}
+
+func (f *SenderRateLimitFilter) PreTx(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	now := f.now()
+	if now.Sub(f.windowStart) >= f.interval {
+		f.windowStart = now
+		f.counts = make(map[common.Address]uint64)
+	}
+	if f.counts[sender] >= f.limit {
+		return ErrSenderRateLimited
+	}
+	return nil
+}
+
+func (f *SenderRateLimitFilter) PostTx(state *arbosState.ArbosState, tx *types.Transaction, sender common.Address, dataGas uint64, receipt *types.Receipt) error {
+	return nil
+}
+
+func (f *SenderRateLimitFilter) TxAccepted(tx *types.Transaction, sender common.Address) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	now := f.now()
+	if now.Sub(f.windowStart) >= f.interval {
+		f.windowStart = now
+		f.counts = make(map[common.Address]uint64)
+	}
+	f.counts[sender]++
+}
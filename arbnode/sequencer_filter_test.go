@@ -0,0 +1,140 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+)
+
+func testFilterTx(to common.Address, gas uint64) *types.Transaction {
+	return types.NewTx(&types.LegacyTx{To: &to, Gas: gas})
+}
+
+func TestSequencerDenyListReload(t *testing.T) {
+	sender := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	recipient := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	contract := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	other := common.HexToAddress("0x4444444444444444444444444444444444444444")
+
+	path := filepath.Join(t.TempDir(), "denylist")
+	contents := "# comment\n" +
+		"sender " + sender.Hex() + "\n" +
+		"recipient " + recipient.Hex() + "  # trailing comment\n" +
+		"\n" +
+		"contract " + contract.Hex() + "\n"
+	Require(t, os.WriteFile(path, []byte(contents), 0600))
+
+	config := TestSequencerFilterConfig
+	config.DenyListFile = path
+	filters, denyList, err := newSequencerTxFilters(config)
+	Require(t, err)
+	if len(filters) != 1 || denyList == nil {
+		Fail(t, "expected only the deny list filter, got", len(filters))
+	}
+
+	if err := denyList.PreTx(nil, testFilterTx(other, 21000), sender); !errors.Is(err, ErrSenderDenied) {
+		Fail(t, "expected sender to be denied, got", err)
+	}
+	if err := denyList.PreTx(nil, testFilterTx(recipient, 21000), other); !errors.Is(err, ErrRecipientDenied) {
+		Fail(t, "expected recipient to be denied, got", err)
+	}
+	Require(t, denyList.PreTx(nil, testFilterTx(other, 21000), other))
+
+	receipt := &types.Receipt{Logs: []*types.Log{{Address: other}, {Address: contract}}}
+	if err := denyList.PostTx(nil, testFilterTx(other, 21000), other, 0, receipt); !errors.Is(err, ErrContractInteraction) {
+		Fail(t, "expected contract interaction to be blocked, got", err)
+	}
+
+	// Replace the deny list, making sure the modification time changes
+	Require(t, os.WriteFile(path, []byte(other.Hex()+"\n"), 0600))
+	future := time.Now().Add(time.Minute)
+	Require(t, os.Chtimes(path, future, future))
+	Require(t, denyList.Reload())
+
+	Require(t, denyList.PreTx(nil, testFilterTx(recipient, 21000), sender))
+	if err := denyList.PreTx(nil, testFilterTx(recipient, 21000), other); !errors.Is(err, ErrSenderDenied) {
+		Fail(t, "expected reloaded sender to be denied, got", err)
+	}
+	if err := denyList.PreTx(nil, testFilterTx(other, 21000), sender); !errors.Is(err, ErrRecipientDenied) {
+		Fail(t, "expected reloaded recipient to be denied, got", err)
+	}
+	Require(t, denyList.PostTx(nil, testFilterTx(other, 21000), other, 0, receipt))
+
+	// An invalid deny list is rejected and the previous one kept
+	Require(t, os.WriteFile(path, []byte("not-an-address\n"), 0600))
+	future = future.Add(time.Minute)
+	Require(t, os.Chtimes(path, future, future))
+	if err := denyList.Reload(); err == nil {
+		Fail(t, "expected invalid deny list to fail to load")
+	}
+	if err := denyList.PreTx(nil, testFilterTx(recipient, 21000), other); !errors.Is(err, ErrSenderDenied) {
+		Fail(t, "expected previous deny list to be kept, got", err)
+	}
+}
+
+func TestSequencerMaxGasFilter(t *testing.T) {
+	filter := NewMaxGasFilter(100000)
+	to := common.Address{}
+	Require(t, filter.PreTx(nil, testFilterTx(to, 100000), common.Address{}))
+	if err := filter.PreTx(nil, testFilterTx(to, 100001), common.Address{}); !errors.Is(err, ErrTxGasLimitExceeded) {
+		Fail(t, "expected gas limit to be exceeded, got", err)
+	}
+}
+
+func TestSequencerSenderRateLimitFilter(t *testing.T) {
+	filter := NewSenderRateLimitFilter(2, time.Second)
+	now := time.Unix(1000, 0)
+	filter.now = func() time.Time { return now }
+	sender := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	other := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	tx := testFilterTx(common.Address{}, 21000)
+
+	accept := func(sender common.Address) {
+		t.Helper()
+		Require(t, filter.PreTx(nil, tx, sender))
+		filter.TxAccepted(tx, sender)
+	}
+	// transactions rejected after passing the rate limit aren't counted
+	for i := 0; i < 3; i++ {
+		Require(t, filter.PreTx(nil, tx, sender))
+	}
+	accept(sender)
+	accept(sender)
+	if err := filter.PreTx(nil, tx, sender); !errors.Is(err, ErrSenderRateLimited) {
+		Fail(t, "expected sender to be rate limited, got", err)
+	}
+	accept(other)
+
+	now = now.Add(time.Second)
+	accept(sender)
+}
+
+func TestSequencerContractBlocklistFilter(t *testing.T) {
+	contract := common.HexToAddress("0x3333333333333333333333333333333333333333")
+	config := TestSequencerFilterConfig
+	config.ContractBlocklist = []string{contract.Hex()}
+	filters, _, err := newSequencerTxFilters(config)
+	Require(t, err)
+	if len(filters) != 1 {
+		Fail(t, "expected one filter, got", len(filters))
+	}
+	tx := testFilterTx(common.Address{}, 21000)
+	Require(t, filters[0].PostTx(nil, tx, common.Address{}, 0, &types.Receipt{}))
+	if err := filters[0].PostTx(nil, tx, common.Address{}, 0, &types.Receipt{ContractAddress: contract}); !errors.Is(err, ErrContractInteraction) {
+		Fail(t, "expected contract creation to be blocked, got", err)
+	}
+
+	config.ContractBlocklist = []string{"invalid"}
+	if _, _, err := newSequencerTxFilters(config); err == nil {
+		Fail(t, "expected invalid contract blocklist to be rejected")
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/go-ethereum/ethdb"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/rlp"
+	"github.com/tenderly/nitro/arbos"
+)
+
+type SequencerJournalConfig struct {
+	Enable bool `koanf:"enable"`
+}
+
+func SequencerJournalConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultSequencerJournalConfig.Enable, "persist accepted transactions until they're sequenced or forwarded, and replay them on startup")
+}
+
+var DefaultSequencerJournalConfig = SequencerJournalConfig{
+	Enable: false,
+}
+
+var TestSequencerJournalConfig = SequencerJournalConfig{
+	Enable: false,
+}
+
+type journaledTx struct {
+	Tx      []byte
+	Options []byte // JSON encoded conditional options, empty if the tx is unconditional
+}
+
+type journalEntry struct {
+	id      uint64
+	tx      *types.Transaction
+	options *arbos.ConditionalOptions
+}
+
+// txJournal persists the transactions accepted by the sequencer until a result is returned for them,
+// so that transactions still queued when the sequencer stops can be replayed when it restarts.
+type txJournal struct {
+	db ethdb.Database
+
+	mutex  sync.Mutex
+	nextId uint64
+}
+
+// openTxJournal opens the journal and returns the transactions left in it, in the order they were accepted
+func openTxJournal(db ethdb.Database) (*txJournal, []journalEntry, error) {
+	journal := &txJournal{db: db}
+	iter := db.NewIterator(sequencerJournalPrefix, nil)
+	defer iter.Release()
+	var entries []journalEntry
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != len(sequencerJournalPrefix)+8 {
+			return nil, nil, errors.Errorf("unexpected sequencer journal key %x", key)
+		}
+		id := binary.BigEndian.Uint64(key[len(sequencerJournalPrefix):])
+		journal.nextId = id + 1
+		var journaled journaledTx
+		if err := rlp.DecodeBytes(iter.Value(), &journaled); err != nil {
+			return nil, nil, errors.Wrapf(err, "decoding sequencer journal entry %v", id)
+		}
+		entry := journalEntry{id: id, tx: new(types.Transaction)}
+		if err := entry.tx.UnmarshalBinary(journaled.Tx); err != nil {
+			return nil, nil, errors.Wrapf(err, "decoding sequencer journal tx %v", id)
+		}
+		if len(journaled.Options) > 0 {
+			entry.options = new(arbos.ConditionalOptions)
+			if err := json.Unmarshal(journaled.Options, entry.options); err != nil {
+				return nil, nil, errors.Wrapf(err, "decoding sequencer journal tx %v options", id)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, nil, err
+	}
+	return journal, entries, nil
+}
+
+// add persists a transaction and returns its journal id
+func (j *txJournal) add(tx *types.Transaction, options *arbos.ConditionalOptions) (uint64, error) {
+	var journaled journaledTx
+	var err error
+	journaled.Tx, err = tx.MarshalBinary()
+	if err != nil {
+		return 0, err
+	}
+	if options != nil {
+		journaled.Options, err = json.Marshal(options)
+		if err != nil {
+			return 0, err
+		}
+	}
+	data, err := rlp.EncodeToBytes(journaled)
+	if err != nil {
+		return 0, err
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	id := j.nextId
+	if err := j.db.Put(dbKey(sequencerJournalPrefix, id), data); err != nil {
+		return 0, err
+	}
+	j.nextId++
+	return id, nil
+}
+
+func (j *txJournal) remove(id uint64) {
+	if err := j.db.Delete(dbKey(sequencerJournalPrefix, id)); err != nil {
+		log.Error("error removing transaction from sequencer journal", "id", id, "err", err)
+	}
+}
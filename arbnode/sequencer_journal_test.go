@@ -0,0 +1,70 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
+	"github.com/tenderly/nitro/go-ethereum/core/rawdb"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/arbos"
+)
+
+func TestSequencerJournalReplay(t *testing.T) {
+	db := rawdb.NewMemoryDatabase()
+	journal, entries, err := openTxJournal(db)
+	Require(t, err)
+	if len(entries) != 0 {
+		Fail(t, "expected empty journal, got", len(entries))
+	}
+
+	to := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	var txes []*types.Transaction
+	for i := 0; i < 3; i++ {
+		txes = append(txes, types.NewTx(&types.LegacyTx{Nonce: uint64(i), To: &to, Gas: 21000, GasPrice: big.NewInt(1)}))
+	}
+	minBlock := hexutil.Uint64(10)
+	options := &arbos.ConditionalOptions{BlockNumberMin: &minBlock}
+
+	var ids []uint64
+	for i, tx := range txes {
+		var txOptions *arbos.ConditionalOptions
+		if i == 2 {
+			txOptions = options
+		}
+		id, err := journal.add(tx, txOptions)
+		Require(t, err)
+		ids = append(ids, id)
+	}
+	// returning a result removes the transaction from the journal
+	item := txQueueItem{tx: txes[0], resultChan: make(chan error, 1), journal: journal, journalId: ids[0]}
+	item.returnResult(nil)
+
+	journal, entries, err = openTxJournal(db)
+	Require(t, err)
+	if len(entries) != 2 {
+		Fail(t, "expected 2 journaled transactions, got", len(entries))
+	}
+	for i, entry := range entries {
+		if entry.id != ids[i+1] || entry.tx.Hash() != txes[i+1].Hash() {
+			Fail(t, "unexpected journal entry", i, entry.id, entry.tx.Hash())
+		}
+	}
+	if entries[0].options != nil {
+		Fail(t, "expected unconditional tx to have no options")
+	}
+	if entries[1].options == nil || entries[1].options.BlockNumberMin == nil || *entries[1].options.BlockNumberMin != minBlock {
+		Fail(t, "conditional options weren't journaled", entries[1].options)
+	}
+
+	// new transactions are journaled after the replayed ones
+	id, err := journal.add(txes[0], nil)
+	Require(t, err)
+	if id <= ids[2] {
+		Fail(t, "journal id", id, "reused after reopening")
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/arbos"
+	"github.com/tenderly/nitro/go-ethereum/core"
+	"github.com/tenderly/nitro/go-ethereum/core/vm"
+)
+
+func TestSequencerTxRejectionReason(t *testing.T) {
+	cases := []struct {
+		err    error
+		reason string
+	}{
+		{errSenderNotWhitelisted, "whitelist"},
+		{&SequencerQueueFullError{}, "queuefull"},
+		{context.DeadlineExceeded, "expired"},
+		{core.ErrOversizedData, "oversized"},
+		{fmt.Errorf("%w: address 0x00", core.ErrNonceTooLow), "nonce"},
+		{core.ErrInsufficientFunds, "funds"},
+		{core.ErrIntrinsicGas, "gas"},
+		{arbos.ErrConditionsNotMet, "conditions"},
+		{vm.ErrExecutionReverted, "reverted"},
+		{errors.New("something else"), "other"},
+	}
+	for _, c := range cases {
+		if reason := sequencerTxRejectionReason(c.err); reason != c.reason {
+			t.Errorf("error %q got reason %v, expected %v", c.err, reason, c.reason)
+		}
+		if _, ok := sequencerTxRejectionCounters[c.reason]; !ok {
+			t.Errorf("no counter registered for reason %v", c.reason)
+		}
+	}
+}
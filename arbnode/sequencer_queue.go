@@ -0,0 +1,235 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+)
+
+type SequencerQueueConfig struct {
+	Size           int           `koanf:"size"`
+	PriorityWindow time.Duration `koanf:"priority-window"`
+	RetryAfter     time.Duration `koanf:"retry-after"`
+}
+
+func SequencerQueueConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Int(prefix+".size", DefaultSequencerQueueConfig.Size, "maximum number of transactions waiting to be sequenced")
+	f.Duration(prefix+".priority-window", DefaultSequencerQueueConfig.PriorityWindow, "transactions received within this window of the oldest queued transaction are ordered by priority fee (0 = first come first served)")
+	f.Duration(prefix+".retry-after", DefaultSequencerQueueConfig.RetryAfter, "how long clients are told to wait before retrying when the queue is full")
+}
+
+var DefaultSequencerQueueConfig = SequencerQueueConfig{
+	Size:           1024,
+	PriorityWindow: 0,
+	RetryAfter:     time.Second,
+}
+
+var TestSequencerQueueConfig = SequencerQueueConfig{
+	Size:           128,
+	PriorityWindow: 0,
+	RetryAfter:     100 * time.Millisecond,
+}
+
+var ErrSequencerQueueFull = errors.New("sequencer queue full")
+
+// SequencerQueueFullError is returned to RPC callers when the queue can't accept more transactions.
+// It implements the rpc package's Error and DataError interfaces so the retry hint reaches the client.
+type SequencerQueueFullError struct {
+	RetryAfter time.Duration
+}
+
+func (e *SequencerQueueFullError) Error() string {
+	return fmt.Sprintf("%v, retry after %v", ErrSequencerQueueFull, e.RetryAfter)
+}
+
+func (e *SequencerQueueFullError) Unwrap() error {
+	return ErrSequencerQueueFull
+}
+
+// ErrorCode returns the EIP-1474 "limit exceeded" code
+func (e *SequencerQueueFullError) ErrorCode() int {
+	return -32005
+}
+
+func (e *SequencerQueueFullError) ErrorData() interface{} {
+	return map[string]interface{}{
+		"retryAfter": e.RetryAfter.Seconds(),
+	}
+}
+
+// senderTxQueue holds the queued transactions of a single sender, sorted by nonce
+type senderTxQueue struct {
+	items []txQueueItem
+	// the earliest arrival among the sender's queued transactions
+	seq     uint64
+	arrival time.Time
+}
+
+func (s *senderTxQueue) updateEarliest() {
+	s.seq = s.items[0].seq
+	s.arrival = s.items[0].arrival
+	for _, item := range s.items[1:] {
+		if item.seq < s.seq {
+			s.seq = item.seq
+			s.arrival = item.arrival
+		}
+	}
+}
+
+// sequencerQueue holds transactions waiting to be sequenced.
+// Transactions from the same sender are always dequeued in nonce order.
+// Senders take turns in the order their earliest queued transaction arrived,
+// or by the priority fee of their next transaction within the configured window.
+type sequencerQueue struct {
+	config SequencerQueueConfig
+
+	mutex   sync.Mutex
+	senders map[common.Address]*senderTxQueue
+	size    int
+	nextSeq uint64
+	notify  chan struct{}
+}
+
+func newSequencerQueue(config SequencerQueueConfig) (*sequencerQueue, error) {
+	if config.Size <= 0 {
+		return nil, errors.New("sequencer queue size must be positive")
+	}
+	return &sequencerQueue{
+		config:  config,
+		senders: make(map[common.Address]*senderTxQueue),
+		notify:  make(chan struct{}, 1),
+	}, nil
+}
+
+func (q *sequencerQueue) Len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.size
+}
+
+// push adds a new transaction to the queue, or returns a SequencerQueueFullError if the queue is full
+func (q *sequencerQueue) push(item txQueueItem) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.size >= q.config.Size {
+		return &SequencerQueueFullError{RetryAfter: q.config.RetryAfter}
+	}
+	q.pushLocked(item)
+	return nil
+}
+
+// pushUnlimited adds a transaction regardless of the size limit, for transactions accepted before a restart
+func (q *sequencerQueue) pushUnlimited(item txQueueItem) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.pushLocked(item)
+}
+
+// pushLocked must be called with the mutex held
+func (q *sequencerQueue) pushLocked(item txQueueItem) {
+	item.seq = q.nextSeq
+	item.arrival = time.Now()
+	q.nextSeq++
+	q.insert(item)
+}
+
+// requeue puts back a transaction which was dequeued but couldn't be sequenced yet.
+// It keeps its original position, and is accepted even if the queue is full.
+func (q *sequencerQueue) requeue(item txQueueItem) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.insert(item)
+}
+
+// insert must be called with the mutex held
+func (q *sequencerQueue) insert(item txQueueItem) {
+	senderQueue, ok := q.senders[item.sender]
+	if !ok {
+		senderQueue = &senderTxQueue{seq: item.seq, arrival: item.arrival}
+		q.senders[item.sender] = senderQueue
+	}
+	items := senderQueue.items
+	nonce := item.tx.Nonce()
+	idx := sort.Search(len(items), func(i int) bool {
+		other := items[i]
+		if other.tx.Nonce() != nonce {
+			return other.tx.Nonce() > nonce
+		}
+		return other.seq > item.seq
+	})
+	items = append(items, txQueueItem{})
+	copy(items[idx+1:], items[idx:])
+	items[idx] = item
+	senderQueue.items = items
+	if item.seq < senderQueue.seq {
+		senderQueue.seq = item.seq
+		senderQueue.arrival = item.arrival
+	}
+	q.size++
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// pop removes and returns the next transaction to sequence, if any
+func (q *sequencerQueue) pop() (txQueueItem, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.size == 0 {
+		return txQueueItem{}, false
+	}
+	var oldest *senderTxQueue
+	for _, senderQueue := range q.senders {
+		if oldest == nil || senderQueue.seq < oldest.seq {
+			oldest = senderQueue
+		}
+	}
+	next := oldest
+	if q.config.PriorityWindow > 0 {
+		windowEnd := oldest.arrival.Add(q.config.PriorityWindow)
+		for _, senderQueue := range q.senders {
+			if senderQueue.arrival.After(windowEnd) {
+				continue
+			}
+			cmp := senderQueue.items[0].tx.GasTipCapCmp(next.items[0].tx)
+			if cmp > 0 || (cmp == 0 && senderQueue.seq < next.seq) {
+				next = senderQueue
+			}
+		}
+	}
+	item := next.items[0]
+	if len(next.items) == 1 {
+		delete(q.senders, item.sender)
+	} else {
+		next.items = next.items[1:]
+		next.updateEarliest()
+	}
+	q.size--
+	return item, true
+}
+
+// popWait waits until a transaction is available and returns it, or returns false if the context is done
+func (q *sequencerQueue) popWait(ctx context.Context) (txQueueItem, bool) {
+	for {
+		item, ok := q.pop()
+		if ok {
+			return item, true
+		}
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			return txQueueItem{}, false
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/core"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+)
+
+func testQueueItem(sender common.Address, nonce uint64, tip int64) txQueueItem {
+	tx := types.NewTx(&types.DynamicFeeTx{
+		Nonce:     nonce,
+		GasTipCap: big.NewInt(tip),
+		GasFeeCap: big.NewInt(tip),
+	})
+	return txQueueItem{tx: tx, sender: sender, ctx: context.Background()}
+}
+
+func expectPops(t *testing.T, q *sequencerQueue, expected ...txQueueItem) {
+	t.Helper()
+	for i, want := range expected {
+		got, ok := q.pop()
+		if !ok {
+			Fail(t, "queue empty at pop", i)
+		}
+		if got.tx != want.tx {
+			Fail(t, "unexpected tx at pop", i, "sender", got.sender, "nonce", got.tx.Nonce())
+		}
+	}
+	if _, ok := q.pop(); ok {
+		Fail(t, "expected queue to be empty")
+	}
+}
+
+func TestSequencerQueueNonceOrdering(t *testing.T) {
+	q, err := newSequencerQueue(TestSequencerQueueConfig)
+	Require(t, err)
+	alice := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	bob := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	alice1 := testQueueItem(alice, 1, 1)
+	bob0 := testQueueItem(bob, 0, 1)
+	alice0 := testQueueItem(alice, 0, 1)
+	alice2 := testQueueItem(alice, 2, 1)
+	for _, item := range []txQueueItem{alice1, bob0, alice0, alice2} {
+		Require(t, q.push(item))
+	}
+	// alice's later nonce arrived first, but her earlier nonce must still go first
+	expectPops(t, q, alice0, alice1, bob0, alice2)
+}
+
+func TestSequencerQueueRequeueKeepsPosition(t *testing.T) {
+	config := TestSequencerQueueConfig
+	config.Size = 2
+	q, err := newSequencerQueue(config)
+	Require(t, err)
+	alice := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	bob := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	alice0 := testQueueItem(alice, 0, 1)
+	bob0 := testQueueItem(bob, 0, 1)
+	Require(t, q.push(alice0))
+	popped, ok := q.pop()
+	if !ok {
+		Fail(t, "expected a queued tx")
+	}
+	Require(t, q.push(bob0))
+	Require(t, q.push(testQueueItem(bob, 1, 1)))
+
+	err = q.push(testQueueItem(bob, 2, 1))
+	var fullErr *SequencerQueueFullError
+	if !errors.As(err, &fullErr) || !errors.Is(err, ErrSequencerQueueFull) {
+		Fail(t, "expected queue full error, got", err)
+	}
+	if fullErr.RetryAfter != config.RetryAfter {
+		Fail(t, "unexpected retry after hint", fullErr.RetryAfter)
+	}
+
+	// requeued transactions are accepted even when full, and go back to the front
+	q.requeue(popped)
+	if q.Len() != 3 {
+		Fail(t, "unexpected queue length", q.Len())
+	}
+	got, _ := q.pop()
+	if got.tx != alice0.tx {
+		Fail(t, "expected requeued tx first")
+	}
+}
+
+func TestSequencerQueuePriorityWindow(t *testing.T) {
+	config := TestSequencerQueueConfig
+	config.PriorityWindow = time.Hour
+	q, err := newSequencerQueue(config)
+	Require(t, err)
+	alice := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	bob := common.HexToAddress("0x2222222222222222222222222222222222222222")
+	carol := common.HexToAddress("0x3333333333333333333333333333333333333333")
+
+	alice0 := testQueueItem(alice, 0, 1)
+	alice1 := testQueueItem(alice, 1, 100)
+	bob0 := testQueueItem(bob, 0, 10)
+	carol0 := testQueueItem(carol, 0, 5)
+	for _, item := range []txQueueItem{alice0, alice1, bob0, carol0} {
+		Require(t, q.push(item))
+	}
+	// alice's high tip can't jump ahead of her own earlier nonce
+	expectPops(t, q, bob0, carol0, alice0, alice1)
+}
+
+func TestSequencerRequeuesSenderAfterGasLimit(t *testing.T) {
+	q, err := newSequencerQueue(TestSequencerQueueConfig)
+	Require(t, err)
+	s := &Sequencer{txQueue: q}
+	alice := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	bob := common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	alice0 := testQueueItem(alice, 0, 1)
+	bob0 := testQueueItem(bob, 0, 1)
+	alice1 := testQueueItem(alice, 1, 1)
+	items := []txQueueItem{alice0, bob0, alice1}
+	var results []chan error
+	for i := range items {
+		resultChan := make(chan error, 1)
+		items[i].resultChan = resultChan
+		results = append(results, resultChan)
+	}
+	s.returnResults(items, []error{core.ErrGasLimit, nil, core.ErrNonceTooHigh})
+
+	if err := <-results[1]; err != nil {
+		Fail(t, "unexpected result for bob's tx", err)
+	}
+	for _, i := range []int{0, 2} {
+		select {
+		case err := <-results[i]:
+			Fail(t, "alice's tx wasn't requeued, got result", err)
+		default:
+		}
+	}
+	// alice's later nonce is requeued too rather than failing
+	expectPops(t, q, alice0, alice1)
+}
+
+func TestSequencerQueuePopWait(t *testing.T) {
+	q, err := newSequencerQueue(TestSequencerQueueConfig)
+	Require(t, err)
+	item := testQueueItem(common.Address{}, 0, 1)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		Require(t, q.push(item))
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got, ok := q.popWait(ctx)
+	if !ok || got.tx != item.tx {
+		Fail(t, "expected to receive pushed tx")
+	}
+
+	cancelledCtx, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if _, ok := q.popWait(cancelledCtx); ok {
+		Fail(t, "expected popWait to return when the context is done")
+	}
+}
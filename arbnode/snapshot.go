@@ -0,0 +1,653 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/big"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/arbutil"
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/core"
+	"github.com/tenderly/nitro/go-ethereum/core/rawdb"
+	"github.com/tenderly/nitro/go-ethereum/ethdb"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/node"
+	"github.com/tenderly/nitro/go-ethereum/rlp"
+)
+
+// A snapshot archive is a tar file holding a copy of the chain database directory under snapshotChainDbDir,
+// the arbitrum database entries up to the snapshot's message count in snapshotArbDbFile,
+// and last, a manifest describing the snapshot with a checksum of every other file.
+const (
+	SnapshotVersion = 1
+
+	snapshotManifestFile = "manifest.json"
+	snapshotArbDbFile    = "arbitrumdata.rlp"
+	snapshotChainDbDir   = "l2chaindata"
+	snapshotStagingDir   = "snapshot-import"
+
+	// written while the imported databases are moved out of the staging directory
+	snapshotInstallMarker = "snapshot-import-installing"
+)
+
+type SnapshotFile struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+type SnapshotManifest struct {
+	Version            uint64               `json:"version"`
+	CreatedAt          time.Time            `json:"createdAt"`
+	ChainID            uint64               `json:"chainId"`
+	GenesisBlockNumber uint64               `json:"genesisBlockNumber"`
+	MessageCount       arbutil.MessageIndex `json:"messageCount"`
+	BlockNumber        uint64               `json:"blockNumber"`
+	BlockHash          common.Hash          `json:"blockHash"`
+	BatchCount         uint64               `json:"batchCount"`
+	BatchAccumulator   common.Hash          `json:"batchAccumulator"`
+	BatchL1Block       uint64               `json:"batchL1Block"`
+	DelayedCount       uint64               `json:"delayedCount"`
+	DelayedAccumulator common.Hash          `json:"delayedAccumulator"`
+	Files              []SnapshotFile       `json:"files"`
+}
+
+type snapshotEntry struct {
+	Key   []byte
+	Value []byte
+}
+
+// snapshotPoint finds the last batch ending at or before maxMessageCount.
+// Batches up to it are checked against L1 on import, while any messages past it are checked by the inbox tracker
+// as the batches posted after it are read, just like messages received from the feed.
+func snapshotPoint(tracker *InboxTracker, maxMessageCount arbutil.MessageIndex) (uint64, BatchMetadata, error) {
+	batchCount, err := tracker.GetBatchCount()
+	if err != nil {
+		return 0, BatchMetadata{}, err
+	}
+	// batch message counts never decrease, so find the first batch ending past maxMessageCount
+	var searchErr error
+	count := sort.Search(int(batchCount), func(i int) bool {
+		meta, err := tracker.GetBatchMetadata(uint64(i))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return meta.MessageCount > maxMessageCount
+	})
+	if searchErr != nil {
+		return 0, BatchMetadata{}, searchErr
+	}
+	if count == 0 {
+		return 0, BatchMetadata{}, errors.New("no complete batch to snapshot")
+	}
+	meta, err := tracker.GetBatchMetadata(uint64(count - 1))
+	return uint64(count), meta, err
+}
+
+// ExportSnapshot writes a snapshot archive of the node's databases at maxMessageCount messages, or at the chain head if it's zero.
+// The state of the block at that point must be in the chain database, which unless the node is an archive node
+// is only the case for its latest blocks.
+// The databases must not be written to during the export, so they should be opened read-only by a stopped node.
+func ExportSnapshot(chainDir string, chainDb ethdb.Database, arbDb ethdb.Database, maxMessageCount arbutil.MessageIndex, out io.Writer) (*SnapshotManifest, error) {
+	chainConfig := TryReadStoredChainConfig(chainDb)
+	if chainConfig == nil {
+		return nil, errors.New("chain config not found in chain database")
+	}
+	genesis := chainConfig.ArbitrumChainParams.GenesisBlockNum
+	headNumber := rawdb.ReadHeaderNumber(chainDb, rawdb.ReadHeadBlockHash(chainDb))
+	if headNumber == nil {
+		return nil, errors.New("head block not found in chain database")
+	}
+	tracker := &InboxTracker{db: arbDb}
+	streamer := &TransactionStreamer{db: arbDb}
+	messageCount, err := streamer.GetMessageCount()
+	if err != nil {
+		return nil, err
+	}
+	if blockCount := arbutil.BlockNumberToMessageCount(*headNumber, genesis); blockCount < messageCount {
+		messageCount = blockCount
+	}
+	if maxMessageCount != 0 && maxMessageCount < messageCount {
+		messageCount = maxMessageCount
+	}
+	if messageCount == 0 {
+		return nil, errors.New("no messages to snapshot")
+	}
+	blockNumber := uint64(arbutil.MessageCountToBlockNumber(messageCount, genesis))
+	blockHash := rawdb.ReadCanonicalHash(chainDb, blockNumber)
+	if blockHash == (common.Hash{}) {
+		return nil, fmt.Errorf("block %v not found in chain database", blockNumber)
+	}
+	header := rawdb.ReadHeader(chainDb, blockHash, blockNumber)
+	if header == nil {
+		return nil, fmt.Errorf("header of block %v not found in chain database", blockNumber)
+	}
+	if !rawdb.HasTrieNode(chainDb, header.Root) {
+		return nil, fmt.Errorf("state of block %v isn't in the chain database, snapshot at the chain head or from an archive node instead", blockNumber)
+	}
+	batchCount, lastBatch, err := snapshotPoint(tracker, messageCount)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &SnapshotManifest{
+		Version:            SnapshotVersion,
+		CreatedAt:          time.Now().UTC(),
+		ChainID:            chainConfig.ChainID.Uint64(),
+		GenesisBlockNumber: genesis,
+		MessageCount:       messageCount,
+		BlockNumber:        blockNumber,
+		BlockHash:          blockHash,
+		BatchCount:         batchCount,
+		BatchAccumulator:   lastBatch.Accumulator,
+		BatchL1Block:       lastBatch.L1Block,
+		DelayedCount:       lastBatch.DelayedMessageCount,
+	}
+	if manifest.DelayedCount > 0 {
+		manifest.DelayedAccumulator, err = tracker.GetDelayedAcc(manifest.DelayedCount - 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	log.Info("exporting snapshot", "messageCount", manifest.MessageCount, "block", manifest.BlockNumber, "batchCount", manifest.BatchCount, "delayedCount", manifest.DelayedCount)
+
+	archive := tar.NewWriter(out)
+	err = writeArbDbSnapshot(arbDb, manifest, archive)
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(chainDir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return err
+		}
+		// lock files belong to the database that's open, and would stop the imported one from opening
+		if name := entry.Name(); name == "LOCK" || name == "FLOCK" {
+			return nil
+		}
+		relative, err := filepath.Rel(chainDir, file)
+		if err != nil {
+			return err
+		}
+		name := path.Join(snapshotChainDbDir, filepath.ToSlash(relative))
+		snapshotFile, err := addSnapshotFile(archive, name, file)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, snapshotFile)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	err = archive.WriteHeader(&tar.Header{
+		Name:    snapshotManifestFile,
+		Mode:    0644,
+		Size:    int64(len(manifestData)),
+		ModTime: manifest.CreatedAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := archive.Write(manifestData); err != nil {
+		return nil, err
+	}
+	return manifest, archive.Close()
+}
+
+// writeArbDbSnapshot adds the arbitrum database entries up to the manifest's messages and batches to the archive.
+// The entries are written to a temporary file first, as the archive needs their size up front.
+func writeArbDbSnapshot(arbDb ethdb.Database, manifest *SnapshotManifest, archive *tar.Writer) error {
+	tmp, err := os.CreateTemp("", "nitro-snapshot-*.rlp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	writer := bufio.NewWriter(tmp)
+
+	entries := 0
+	write := func(key []byte) error {
+		value, err := arbDb.Get(key)
+		if err != nil {
+			return errors.Wrapf(err, "reading key %x", key)
+		}
+		entries++
+		return rlp.Encode(writer, snapshotEntry{Key: key, Value: value})
+	}
+	writeRange := func(prefix []byte, count uint64) error {
+		for i := uint64(0); i < count; i++ {
+			if err := write(dbKey(prefix, i)); err != nil {
+				return err
+			}
+			if i > 0 && i%1000000 == 0 {
+				log.Info("exporting arbitrum database", "prefix", string(prefix), "entries", i, "of", count)
+			}
+		}
+		return nil
+	}
+	writeCount := func(key []byte, count uint64) error {
+		value, err := rlp.EncodeToBytes(count)
+		if err != nil {
+			return err
+		}
+		return rlp.Encode(writer, snapshotEntry{Key: key, Value: value})
+	}
+
+	if err := writeRange(messagePrefix, uint64(manifest.MessageCount)); err != nil {
+		return err
+	}
+	if err := writeCount(messageCountKey, uint64(manifest.MessageCount)); err != nil {
+		return err
+	}
+	if err := writeRange(sequencerBatchMetaPrefix, manifest.BatchCount); err != nil {
+		return err
+	}
+	if err := writeCount(sequencerBatchCountKey, manifest.BatchCount); err != nil {
+		return err
+	}
+	if err := writeRange(delayedMessagePrefix, manifest.DelayedCount); err != nil {
+		return err
+	}
+	if err := writeCount(delayedMessageCountKey, manifest.DelayedCount); err != nil {
+		return err
+	}
+	// delayedSequencedPrefix is keyed by delayed message count, and only has entries for counts batches ended with
+	iter := arbDb.NewIterator(delayedSequencedPrefix, nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != len(delayedSequencedPrefix)+8 {
+			continue
+		}
+		if binary.BigEndian.Uint64(key[len(delayedSequencedPrefix):]) > manifest.DelayedCount {
+			break
+		}
+		if err := write(common.CopyBytes(key)); err != nil {
+			return err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	log.Info("exported arbitrum database", "entries", entries)
+
+	snapshotFile, err := addSnapshotFile(archive, snapshotArbDbFile, tmp.Name())
+	if err != nil {
+		return err
+	}
+	manifest.Files = append(manifest.Files, snapshotFile)
+	return nil
+}
+
+func addSnapshotFile(archive *tar.Writer, name string, file string) (SnapshotFile, error) {
+	reader, err := os.Open(file)
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	defer reader.Close()
+	stat, err := reader.Stat()
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	header, err := tar.FileInfoHeader(stat, "")
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	header.Name = name
+	if err := archive.WriteHeader(header); err != nil {
+		return SnapshotFile{}, err
+	}
+	hash := sha256.New()
+	// the size is in the header, so a file growing while it's copied is cut off here and caught by the checksum on import
+	written, err := io.Copy(io.MultiWriter(archive, hash), io.LimitReader(reader, stat.Size()))
+	if err != nil {
+		return SnapshotFile{}, err
+	}
+	if written != stat.Size() {
+		return SnapshotFile{}, fmt.Errorf("%v changed while exporting it", file)
+	}
+	return SnapshotFile{
+		Path:   name,
+		Size:   written,
+		Sha256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// ExtractSnapshot extracts a snapshot archive into a staging directory in instanceDir, checking every file
+// against the manifest, and returns the manifest. The staging directory is removed by InstallSnapshot.
+func ExtractSnapshot(archivePath string, instanceDir string) (*SnapshotManifest, error) {
+	staging := filepath.Join(instanceDir, snapshotStagingDir)
+	if err := os.RemoveAll(staging); err != nil {
+		return nil, err
+	}
+	reader, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	archive := tar.NewReader(bufio.NewReader(reader))
+
+	extracted := make(map[string]SnapshotFile)
+	var manifest *SnapshotManifest
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeDir {
+			continue
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected entry %v in snapshot", header.Name)
+		}
+		if manifest != nil {
+			return nil, fmt.Errorf("unexpected entry %v after snapshot manifest", header.Name)
+		}
+		if header.Name == snapshotManifestFile {
+			manifest = new(SnapshotManifest)
+			if err := json.NewDecoder(archive).Decode(manifest); err != nil {
+				return nil, errors.Wrap(err, "reading snapshot manifest")
+			}
+			continue
+		}
+		name := path.Clean(header.Name)
+		if name != snapshotArbDbFile && !strings.HasPrefix(name, snapshotChainDbDir+"/") {
+			return nil, fmt.Errorf("unexpected entry %v in snapshot", header.Name)
+		}
+		file := filepath.Join(staging, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			return nil, err
+		}
+		writer, err := os.Create(file)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.New()
+		written, err := io.Copy(io.MultiWriter(writer, hash), archive)
+		closeErr := writer.Close()
+		if err != nil {
+			return nil, err
+		}
+		if closeErr != nil {
+			return nil, closeErr
+		}
+		extracted[name] = SnapshotFile{
+			Path:   name,
+			Size:   written,
+			Sha256: hex.EncodeToString(hash.Sum(nil)),
+		}
+	}
+
+	if manifest == nil {
+		return nil, errors.New("snapshot has no manifest")
+	}
+	if manifest.Version != SnapshotVersion {
+		return nil, fmt.Errorf("snapshot version %v isn't supported, expected version %v", manifest.Version, SnapshotVersion)
+	}
+	if len(extracted) != len(manifest.Files) {
+		return nil, fmt.Errorf("snapshot has %v files but its manifest lists %v", len(extracted), len(manifest.Files))
+	}
+	for _, expected := range manifest.Files {
+		if extracted[expected.Path] != expected {
+			return nil, fmt.Errorf("snapshot file %v is missing or doesn't match its checksum", expected.Path)
+		}
+	}
+	return manifest, nil
+}
+
+// VerifySnapshotWithL1 checks the snapshot's last batch and delayed message accumulators against the L1 contracts,
+// so a snapshot of a chain that has since been reorged or of another chain isn't imported.
+func VerifySnapshotWithL1(ctx context.Context, manifest *SnapshotManifest, l1client arbutil.L1Interface, addresses *RollupAddresses) error {
+	l1Block, err := l1client.BlockNumber(ctx)
+	if err != nil {
+		return err
+	}
+	if l1Block < manifest.BatchL1Block {
+		return fmt.Errorf("snapshot's last batch was posted at L1 block %v but the L1 node is at block %v", manifest.BatchL1Block, l1Block)
+	}
+	blockNumber := new(big.Int).SetUint64(l1Block)
+	sequencerInbox, err := NewSequencerInbox(l1client, addresses.SequencerInbox, int64(addresses.DeployedAt))
+	if err != nil {
+		return err
+	}
+	batchCount, err := sequencerInbox.GetBatchCount(ctx, blockNumber)
+	if err != nil {
+		return err
+	}
+	if batchCount < manifest.BatchCount {
+		return fmt.Errorf("snapshot has %v batches but the sequencer inbox only has %v", manifest.BatchCount, batchCount)
+	}
+	batchAcc, err := sequencerInbox.GetAccumulator(ctx, manifest.BatchCount-1, blockNumber)
+	if err != nil {
+		return err
+	}
+	if batchAcc != manifest.BatchAccumulator {
+		return fmt.Errorf("snapshot's accumulator for batch %v is %v but the sequencer inbox has %v", manifest.BatchCount-1, manifest.BatchAccumulator, batchAcc)
+	}
+	if manifest.DelayedCount > 0 {
+		delayedBridge, err := NewDelayedBridge(l1client, addresses.Bridge, addresses.DeployedAt)
+		if err != nil {
+			return err
+		}
+		delayedAcc, err := delayedBridge.GetAccumulator(ctx, manifest.DelayedCount-1, blockNumber)
+		if err != nil {
+			return err
+		}
+		if delayedAcc != manifest.DelayedAccumulator {
+			return fmt.Errorf("snapshot's accumulator for delayed message %v is %v but the bridge has %v", manifest.DelayedCount-1, manifest.DelayedAccumulator, delayedAcc)
+		}
+	}
+	return nil
+}
+
+// ImportSnapshotArbDb writes the extracted arbitrum database entries into an empty arbitrum database,
+// and checks the accumulators they end with match the manifest.
+func ImportSnapshotArbDb(instanceDir string, manifest *SnapshotManifest, arbDb ethdb.Database) error {
+	hasMessages, err := arbDb.Has(messageCountKey)
+	if err != nil {
+		return err
+	}
+	if hasMessages {
+		return errors.New("can't import a snapshot into an arbitrum database that already has messages")
+	}
+	reader, err := os.Open(filepath.Join(instanceDir, snapshotStagingDir, snapshotArbDbFile))
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	stream := rlp.NewStream(bufio.NewReader(reader), 0)
+	batch := arbDb.NewBatch()
+	for {
+		var entry snapshotEntry
+		err := stream.Decode(&entry)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if err := batch.Put(entry.Key, entry.Value); err != nil {
+			return err
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+
+	tracker := &InboxTracker{db: arbDb}
+	streamer := &TransactionStreamer{db: arbDb}
+	messageCount, err := streamer.GetMessageCount()
+	if err != nil {
+		return err
+	}
+	batchCount, err := tracker.GetBatchCount()
+	if err != nil {
+		return err
+	}
+	delayedCount, err := tracker.GetDelayedCount()
+	if err != nil {
+		return err
+	}
+	if messageCount != manifest.MessageCount || batchCount != manifest.BatchCount || delayedCount != manifest.DelayedCount {
+		return fmt.Errorf("imported %v messages, %v batches and %v delayed messages but the manifest has %v, %v and %v",
+			messageCount, batchCount, delayedCount, manifest.MessageCount, manifest.BatchCount, manifest.DelayedCount)
+	}
+	batchAcc, err := tracker.GetBatchAcc(batchCount - 1)
+	if err != nil {
+		return err
+	}
+	if batchAcc != manifest.BatchAccumulator {
+		return fmt.Errorf("imported batch accumulator %v doesn't match the manifest's %v", batchAcc, manifest.BatchAccumulator)
+	}
+	if delayedCount > 0 {
+		delayedAcc, err := tracker.GetDelayedAcc(delayedCount - 1)
+		if err != nil {
+			return err
+		}
+		if delayedAcc != manifest.DelayedAccumulator {
+			return fmt.Errorf("imported delayed accumulator %v doesn't match the manifest's %v", delayedAcc, manifest.DelayedAccumulator)
+		}
+	}
+	return nil
+}
+
+// InstallSnapshot moves the chain database and the arbitrum database imported into the staging directory
+// into instanceDir, and removes the staging directory. A marker file is kept while they're moved,
+// so a partial install is cleared by clearPartialSnapshotInstall before the import is retried.
+func InstallSnapshot(instanceDir string, arbDbName string) error {
+	chainTarget := filepath.Join(instanceDir, snapshotChainDbDir)
+	arbTarget := filepath.Join(instanceDir, arbDbName)
+	for _, target := range []string{chainTarget, arbTarget} {
+		if _, err := os.Stat(target); err == nil {
+			return fmt.Errorf("database %v already exists", target)
+		}
+	}
+	staging := filepath.Join(instanceDir, snapshotStagingDir)
+	marker := filepath.Join(instanceDir, snapshotInstallMarker)
+	if err := os.WriteFile(marker, []byte(arbDbName), 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(staging, arbDbName), arbTarget); err != nil {
+		return err
+	}
+	if err := os.Rename(filepath.Join(staging, snapshotChainDbDir), chainTarget); err != nil {
+		return err
+	}
+	if err := os.Remove(marker); err != nil {
+		return err
+	}
+	return os.RemoveAll(staging)
+}
+
+// clearPartialSnapshotInstall removes the arbitrum database of an install interrupted before the chain database
+// was moved into place, so the snapshot can be imported again
+func clearPartialSnapshotInstall(instanceDir string) error {
+	marker := filepath.Join(instanceDir, snapshotInstallMarker)
+	arbDbName, err := os.ReadFile(marker)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(instanceDir, snapshotChainDbDir)); errors.Is(err, os.ErrNotExist) {
+		arbDb := filepath.Join(instanceDir, filepath.Base(string(arbDbName)))
+		log.Warn("removing arbitrum database of an interrupted snapshot import", "path", arbDb)
+		if err := os.RemoveAll(arbDb); err != nil {
+			return err
+		}
+	}
+	return os.Remove(marker)
+}
+
+// RewindToSnapshot rewinds the imported chain to the snapshot's block,
+// as the chain database has blocks past it when the snapshot wasn't taken at the chain head.
+func RewindToSnapshot(bc *core.BlockChain, manifest *SnapshotManifest) error {
+	block := bc.GetBlockByNumber(manifest.BlockNumber)
+	if block == nil {
+		return fmt.Errorf("snapshot block %v not found in chain", manifest.BlockNumber)
+	}
+	if block.Hash() != manifest.BlockHash {
+		return fmt.Errorf("snapshot block %v has hash %v but the manifest has %v", manifest.BlockNumber, block.Hash(), manifest.BlockHash)
+	}
+	if !bc.HasState(block.Root()) {
+		return fmt.Errorf("state of snapshot block %v isn't in the imported chain database", manifest.BlockNumber)
+	}
+	if bc.CurrentBlock().NumberU64() == manifest.BlockNumber {
+		return nil
+	}
+	log.Info("rewinding imported chain to snapshot block", "from", bc.CurrentBlock().NumberU64(), "to", manifest.BlockNumber)
+	return bc.ReorgToOldBlock(block)
+}
+
+// ImportSnapshot extracts a snapshot archive into the stack's directory once it's been verified against L1,
+// writing its arbitrum database entries into a new arbDbName and installing its chain database as l2chaindata.
+// Both are written in the staging directory first, so an interrupted import can be retried.
+func ImportSnapshot(ctx context.Context, stack *node.Node, archive string, arbDbName string, chainId *big.Int, l1client arbutil.L1Interface, addresses *RollupAddresses) (*SnapshotManifest, error) {
+	if err := clearPartialSnapshotInstall(stack.InstanceDir()); err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(stack.ResolvePath(arbDbName)); err == nil {
+		return nil, fmt.Errorf("can't import a snapshot when arbitrum database %v already exists", stack.ResolvePath(arbDbName))
+	}
+	log.Info("extracting snapshot", "file", archive)
+	manifest, err := ExtractSnapshot(archive, stack.InstanceDir())
+	if err != nil {
+		return nil, err
+	}
+	if manifest.ChainID != chainId.Uint64() {
+		return nil, fmt.Errorf("snapshot is of chain ID %v but the node is for chain ID %v", manifest.ChainID, chainId)
+	}
+	err = VerifySnapshotWithL1(ctx, manifest, l1client, addresses)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("importing snapshot", "messageCount", manifest.MessageCount, "block", manifest.BlockNumber, "batchCount", manifest.BatchCount)
+	arbDb, err := stack.OpenDatabase(filepath.Join(snapshotStagingDir, arbDbName), 0, 0, "", false)
+	if err != nil {
+		return nil, err
+	}
+	err = ImportSnapshotArbDb(stack.InstanceDir(), manifest, arbDb)
+	closeErr := arbDb.Close()
+	if err != nil {
+		return nil, err
+	}
+	if closeErr != nil {
+		return nil, closeErr
+	}
+	return manifest, InstallSnapshot(stack.InstanceDir(), arbDbName)
+}
@@ -0,0 +1,197 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbnode
+
+import (
+	"bytes"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/core/rawdb"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/go-ethereum/ethdb"
+	"github.com/tenderly/nitro/go-ethereum/params"
+	"github.com/tenderly/nitro/go-ethereum/rlp"
+)
+
+func putSnapshotTestValue(t *testing.T, db ethdb.Database, key []byte, value interface{}) {
+	t.Helper()
+	data, err := rlp.EncodeToBytes(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Put(key, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func createSnapshotTestDbs(t *testing.T, headMessageCount uint64) (ethdb.Database, ethdb.Database, string) {
+	chainConfig := params.ArbitrumDevTestChainConfig()
+	genesis := chainConfig.ArbitrumChainParams.GenesisBlockNum
+	chainDb := rawdb.NewMemoryDatabase()
+	for i := uint64(0); i < headMessageCount; i++ {
+		header := &types.Header{
+			Number: new(big.Int).SetUint64(genesis + i),
+			Root:   common.BigToHash(new(big.Int).SetUint64(i + 1)),
+		}
+		rawdb.WriteHeader(chainDb, header)
+		rawdb.WriteCanonicalHash(chainDb, header.Hash(), header.Number.Uint64())
+		rawdb.WriteHeadBlockHash(chainDb, header.Hash())
+		// like a node that isn't an archive node, only the head block has its state on disk
+		if i == headMessageCount-1 {
+			rawdb.WriteTrieNode(chainDb, header.Root, []byte{1})
+		}
+	}
+	rawdb.WriteChainConfig(chainDb, rawdb.ReadCanonicalHash(chainDb, 0), chainConfig)
+
+	arbDb := rawdb.NewMemoryDatabase()
+	for i := uint64(0); i < 10; i++ {
+		if err := arbDb.Put(dbKey(messagePrefix, i), []byte{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	putSnapshotTestValue(t, arbDb, messageCountKey, uint64(10))
+	for i := uint64(0); i < 3; i++ {
+		acc := common.BytesToHash([]byte{0xd, byte(i)})
+		if err := arbDb.Put(dbKey(delayedMessagePrefix, i), append(acc.Bytes(), byte(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	putSnapshotTestValue(t, arbDb, delayedMessageCountKey, uint64(3))
+	batches := []BatchMetadata{
+		{Accumulator: common.Hash{1}, MessageCount: 3, DelayedMessageCount: 1, L1Block: 100},
+		{Accumulator: common.Hash{2}, MessageCount: 7, DelayedMessageCount: 1, L1Block: 110},
+		{Accumulator: common.Hash{3}, MessageCount: 10, DelayedMessageCount: 3, L1Block: 120},
+	}
+	for i, meta := range batches {
+		putSnapshotTestValue(t, arbDb, dbKey(sequencerBatchMetaPrefix, uint64(i)), meta)
+	}
+	putSnapshotTestValue(t, arbDb, sequencerBatchCountKey, uint64(len(batches)))
+	putSnapshotTestValue(t, arbDb, dbKey(delayedSequencedPrefix, 1), uint64(0))
+	putSnapshotTestValue(t, arbDb, dbKey(delayedSequencedPrefix, 3), uint64(2))
+
+	chainDir := filepath.Join(t.TempDir(), "l2chaindata")
+	for name, contents := range map[string]string{
+		"000001.ldb":        "chain data",
+		"LOCK":              "",
+		"ancient/FLOCK":     "",
+		"ancient/headers.0": "ancient data",
+	} {
+		file := filepath.Join(chainDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chainDb, arbDb, chainDir
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	// blocks were only produced for 9 messages, so the snapshot is at the 9th message, after the second batch
+	chainDb, arbDb, chainDir := createSnapshotTestDbs(t, 9)
+	if _, err := ExportSnapshot(chainDir, chainDb, arbDb, 7, io.Discard); err == nil {
+		t.Error("exported a snapshot at a block without state")
+	}
+	var archive bytes.Buffer
+	exported, err := ExportSnapshot(chainDir, chainDb, arbDb, 0, &archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.MessageCount != 9 || exported.BlockNumber != 8 || exported.BatchCount != 2 || exported.DelayedCount != 1 {
+		t.Fatal("unexpected snapshot point", exported.MessageCount, exported.BatchCount, exported.DelayedCount)
+	}
+	if exported.BatchAccumulator != (common.Hash{2}) {
+		t.Error("unexpected batch accumulator", exported.BatchAccumulator)
+	}
+
+	instanceDir := t.TempDir()
+	archivePath := filepath.Join(t.TempDir(), "snapshot.tar")
+	if err := os.WriteFile(archivePath, archive.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := ExtractSnapshot(archivePath, instanceDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.BlockHash != exported.BlockHash || len(manifest.Files) != 3 {
+		t.Error("extracted manifest doesn't match the exported one", manifest)
+	}
+
+	importedDb := rawdb.NewMemoryDatabase()
+	if err := ImportSnapshotArbDb(instanceDir, manifest, importedDb); err != nil {
+		t.Fatal(err)
+	}
+	if has, _ := importedDb.Has(dbKey(messagePrefix, 9)); has {
+		t.Error("imported a message past the snapshot")
+	}
+	if has, _ := importedDb.Has(dbKey(delayedSequencedPrefix, 3)); has {
+		t.Error("imported a delayed sequenced entry past the snapshot")
+	}
+	if message, err := importedDb.Get(dbKey(messagePrefix, 8)); err != nil || !bytes.Equal(message, []byte{8}) {
+		t.Error("message wasn't imported", message, err)
+	}
+	if err := ImportSnapshotArbDb(instanceDir, manifest, importedDb); err == nil {
+		t.Error("imported a snapshot into a database with messages")
+	}
+
+	// the arbitrum database is imported into the staging directory too
+	stagedArbDb := filepath.Join(instanceDir, snapshotStagingDir, "arbdb")
+	if err := os.MkdirAll(stagedArbDb, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stagedArbDb, "CURRENT"), []byte("arb data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := InstallSnapshot(instanceDir, "arbdb"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(instanceDir, "arbdb", "CURRENT")); err != nil || string(data) != "arb data" {
+		t.Error("arbitrum database wasn't installed", string(data), err)
+	}
+	if _, err := os.Stat(filepath.Join(instanceDir, snapshotInstallMarker)); err == nil {
+		t.Error("install marker wasn't removed")
+	}
+	ancient, err := os.ReadFile(filepath.Join(instanceDir, "l2chaindata", "ancient", "headers.0"))
+	if err != nil || string(ancient) != "ancient data" {
+		t.Error("chain database wasn't installed", string(ancient), err)
+	}
+	if _, err := os.Stat(filepath.Join(instanceDir, "l2chaindata", "LOCK")); err == nil {
+		t.Error("lock file was exported")
+	}
+	if _, err := os.Stat(filepath.Join(instanceDir, snapshotStagingDir)); err == nil {
+		t.Error("staging directory wasn't removed")
+	}
+
+	// an install interrupted before the chain database was moved leaves an arbitrum database that's cleared on retry
+	interrupted := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(interrupted, "arbdb"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(interrupted, snapshotInstallMarker), []byte("arbdb"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := clearPartialSnapshotInstall(interrupted); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(interrupted, "arbdb")); err == nil {
+		t.Error("partially installed arbitrum database wasn't removed")
+	}
+	if _, err := os.Stat(filepath.Join(interrupted, snapshotInstallMarker)); err == nil {
+		t.Error("install marker wasn't removed")
+	}
+
+	corrupted := bytes.Replace(archive.Bytes(), []byte("chain data"), []byte("chain DATA"), 1)
+	if err := os.WriteFile(archivePath, corrupted, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExtractSnapshot(archivePath, t.TempDir()); err == nil {
+		t.Error("extracted a snapshot with a corrupted file")
+	}
+}
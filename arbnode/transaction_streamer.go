@@ -20,6 +20,7 @@ import (
 	"github.com/tenderly/nitro/go-ethereum/core/types"
 	"github.com/tenderly/nitro/go-ethereum/ethdb"
 	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
 	"github.com/tenderly/nitro/go-ethereum/rlp"
 	"github.com/tenderly/nitro/arbos"
 	"github.com/tenderly/nitro/arbstate"
@@ -29,6 +30,13 @@ import (
 	"github.com/tenderly/nitro/validator"
 )
 
+var (
+	messageCountGauge       = metrics.NewRegisteredGauge("arb/streamer/messagecount", nil)
+	blockCountGauge         = metrics.NewRegisteredGauge("arb/streamer/blockcount", nil)
+	reorgCounter            = metrics.NewRegisteredCounter("arb/streamer/reorgs", nil)
+	blockProductionDuration = metrics.NewRegisteredHistogram("arb/streamer/blockproduction/duration", nil, metrics.NewExpDecaySample(1028, 0.015))
+)
+
 // Produces blocks from a node's L1 messages, storing the results in the blockchain and recording their positions
 // The streamer is notified when there's new batches to process
 type TransactionStreamer struct {
@@ -195,6 +203,8 @@ func (s *TransactionStreamer) reorgToInternal(batch ethdb.Batch, count arbutil.M
 	if err != nil {
 		return err
 	}
+	reorgCounter.Inc(1)
+	messageCountGauge.Update(int64(count))
 
 	return nil
 }
@@ -236,7 +246,19 @@ func (s *TransactionStreamer) AddMessages(pos arbutil.MessageIndex, force bool,
 	return s.AddMessagesAndEndBatch(pos, force, messages, nil)
 }
 
-func (s *TransactionStreamer) AddBroadcastMessages(pos arbutil.MessageIndex, messages []arbstate.MessageWithMetadata) error {
+func (s *TransactionStreamer) AddBroadcastMessages(feedMessages []*broadcaster.BroadcastFeedMessage) error {
+	if len(feedMessages) == 0 {
+		return nil
+	}
+	pos := feedMessages[0].SequenceNumber
+	messages := make([]arbstate.MessageWithMetadata, 0, len(feedMessages))
+	for i, feedMessage := range feedMessages {
+		if feedMessage.SequenceNumber != pos+arbutil.MessageIndex(i) {
+			return fmt.Errorf("feed messages not contiguous: expected sequence number %v but got %v", pos+arbutil.MessageIndex(i), feedMessage.SequenceNumber)
+		}
+		messages = append(messages, feedMessage.Message)
+	}
+
 	s.insertionMutex.Lock()
 	defer s.insertionMutex.Unlock()
 
@@ -518,7 +540,9 @@ func (s *TransactionStreamer) SequenceTransactions(header *arbos.L1IncomingMessa
 	}
 
 	if s.broadcastServer != nil {
-		s.broadcastServer.BroadcastSingle(msgWithMeta, pos)
+		if err := s.broadcastServer.BroadcastSingle(msgWithMeta, pos); err != nil {
+			log.Error("failed broadcasting message", "pos", pos, "err", err)
+		}
 	}
 
 	// Only write the block after we've written the messages, so if the node dies in the middle of this,
@@ -586,7 +610,9 @@ func (s *TransactionStreamer) SequenceDelayedMessages(ctx context.Context, messa
 
 	for i, msg := range messagesWithMeta {
 		if s.broadcastServer != nil {
-			s.broadcastServer.BroadcastSingle(msg, pos+arbutil.MessageIndex(i))
+			if err := s.broadcastServer.BroadcastSingle(msg, pos+arbutil.MessageIndex(i)); err != nil {
+				log.Error("failed broadcasting message", "pos", pos+arbutil.MessageIndex(i), "err", err)
+			}
 		}
 	}
 
@@ -664,6 +690,7 @@ func (s *TransactionStreamer) writeMessages(pos arbutil.MessageIndex, messages [
 	if err != nil {
 		return err
 	}
+	messageCountGauge.Update(int64(pos) + int64(len(messages)))
 
 	select {
 	case s.newMessageNotifier <- struct{}{}:
@@ -730,6 +757,7 @@ func (s *TransactionStreamer) createBlocks(ctx context.Context) error {
 			return err
 		}
 
+		startTime := time.Now()
 		block, receipts, err := arbos.ProduceBlock(
 			msg.Message,
 			msg.DelayedMessagesRead,
@@ -757,6 +785,8 @@ func (s *TransactionStreamer) createBlocks(ctx context.Context) error {
 		if status == core.SideStatTy {
 			return errors.New("geth rejected block as non-canonical")
 		}
+		blockProductionDuration.Update(time.Since(startTime).Nanoseconds())
+		blockCountGauge.Update(block.Number().Int64())
 
 		if s.validator != nil {
 			s.validator.NewBlock(block, lastBlockHeader, msg)
@@ -777,6 +807,22 @@ func (s *TransactionStreamer) createBlocks(ctx context.Context) error {
 	return nil
 }
 
+// BlockProductionLag returns how many messages are stored that blocks haven't been produced for yet
+func (s *TransactionStreamer) BlockProductionLag() (arbutil.MessageIndex, error) {
+	msgCount, err := s.GetMessageCount()
+	if err != nil {
+		return 0, err
+	}
+	lastBuiltMessage, err := s.BlockNumberToMessageCount(s.bc.CurrentHeader().Number.Uint64())
+	if err != nil {
+		return 0, err
+	}
+	if msgCount <= lastBuiltMessage {
+		return 0, nil
+	}
+	return msgCount - lastBuiltMessage, nil
+}
+
 func (s *TransactionStreamer) SyncProgressMap() map[string]interface{} {
 	res := make(map[string]interface{})
 
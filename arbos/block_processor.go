@@ -82,6 +82,8 @@ type SequencingHooks struct {
 	DiscardInvalidTxsEarly bool
 	PreTxFilter            func(*arbosState.ArbosState, *types.Transaction, common.Address) error
 	PostTxFilter           func(*arbosState.ArbosState, *types.Transaction, common.Address, uint64, *types.Receipt) error
+	// If set, the preconditions of each user tx, indexed like TxErrors. Nil entries are unconditional.
+	ConditionalOptionsForTx []*ConditionalOptions
 }
 
 func noopSequencingHooks() *SequencingHooks {
@@ -94,6 +96,7 @@ func noopSequencingHooks() *SequencingHooks {
 		func(*arbosState.ArbosState, *types.Transaction, common.Address, uint64, *types.Receipt) error {
 			return nil
 		},
+		nil,
 	}
 }
 
@@ -225,6 +228,14 @@ func ProduceBlockAdvanced(
 				return nil, nil, err
 			}
 
+			if txIndex := len(hooks.TxErrors); txIndex < len(hooks.ConditionalOptionsForTx) {
+				if options := hooks.ConditionalOptionsForTx[txIndex]; options != nil {
+					if err := options.Check(header, statedb); err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+
 			if err := hooks.PreTxFilter(state, tx, sender); err != nil {
 				return nil, nil, err
 			}
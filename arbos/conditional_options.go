@@ -0,0 +1,119 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbos
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
+	"github.com/tenderly/nitro/go-ethereum/core/state"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+)
+
+var ErrConditionsNotMet = errors.New("conditions not met")
+
+// ConditionsNotMetError is returned when a conditional transaction's preconditions don't hold.
+// It implements the rpc package's Error interface so callers receive a distinct error code.
+type ConditionsNotMetError struct {
+	Reason string
+}
+
+func (e *ConditionsNotMetError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrConditionsNotMet, e.Reason)
+}
+
+func (e *ConditionsNotMetError) Unwrap() error {
+	return ErrConditionsNotMet
+}
+
+func (e *ConditionsNotMetError) ErrorCode() int {
+	return -32003
+}
+
+func conditionsNotMet(format string, args ...interface{}) error {
+	return &ConditionsNotMetError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// RootHashOrSlots is either the expected storage root of an account, or expected values of some of its storage slots.
+// In JSON, it's either a hash or an object mapping slots to values.
+type RootHashOrSlots struct {
+	RootHash  *common.Hash
+	SlotValue map[common.Hash]common.Hash
+}
+
+func (r *RootHashOrSlots) UnmarshalJSON(data []byte) error {
+	var hash common.Hash
+	if err := json.Unmarshal(data, &hash); err == nil {
+		r.RootHash = &hash
+		return nil
+	}
+	return json.Unmarshal(data, &r.SlotValue)
+}
+
+func (r RootHashOrSlots) MarshalJSON() ([]byte, error) {
+	if r.RootHash != nil {
+		return json.Marshal(*r.RootHash)
+	}
+	return json.Marshal(r.SlotValue)
+}
+
+// ConditionalOptions are the preconditions of a conditional transaction,
+// checked against the state right before the transaction is executed.
+type ConditionalOptions struct {
+	KnownAccounts  map[common.Address]RootHashOrSlots `json:"knownAccounts"`
+	BlockNumberMin *hexutil.Uint64                    `json:"blockNumberMin,omitempty"`
+	BlockNumberMax *hexutil.Uint64                    `json:"blockNumberMax,omitempty"`
+	TimestampMin   *hexutil.Uint64                    `json:"timestampMin,omitempty"`
+	TimestampMax   *hexutil.Uint64                    `json:"timestampMax,omitempty"`
+}
+
+// KnownSlots returns how many storage checks the options require, counting a storage root as one
+func (o *ConditionalOptions) KnownSlots() int {
+	count := 0
+	for _, account := range o.KnownAccounts {
+		if account.RootHash != nil {
+			count++
+		}
+		count += len(account.SlotValue)
+	}
+	return count
+}
+
+// Check returns a ConditionsNotMetError if the options don't hold for a transaction in the given block
+func (o *ConditionalOptions) Check(header *types.Header, statedb *state.StateDB) error {
+	blockNumber := header.Number.Uint64()
+	if o.BlockNumberMin != nil && blockNumber < uint64(*o.BlockNumberMin) {
+		return conditionsNotMet("block number %v below minimum %v", blockNumber, uint64(*o.BlockNumberMin))
+	}
+	if o.BlockNumberMax != nil && blockNumber > uint64(*o.BlockNumberMax) {
+		return conditionsNotMet("block number %v above maximum %v", blockNumber, uint64(*o.BlockNumberMax))
+	}
+	if o.TimestampMin != nil && header.Time < uint64(*o.TimestampMin) {
+		return conditionsNotMet("timestamp %v below minimum %v", header.Time, uint64(*o.TimestampMin))
+	}
+	if o.TimestampMax != nil && header.Time > uint64(*o.TimestampMax) {
+		return conditionsNotMet("timestamp %v above maximum %v", header.Time, uint64(*o.TimestampMax))
+	}
+	for address, expected := range o.KnownAccounts {
+		if expected.RootHash != nil {
+			root := types.EmptyRootHash
+			// StorageTrie includes storage changes made earlier in this block
+			if storageTrie := statedb.StorageTrie(address); storageTrie != nil {
+				root = storageTrie.Hash()
+			}
+			if root != *expected.RootHash {
+				return conditionsNotMet("storage root of %v is %v, expected %v", address, root, *expected.RootHash)
+			}
+		}
+		for slot, value := range expected.SlotValue {
+			if actual := statedb.GetState(address, slot); actual != value {
+				return conditionsNotMet("storage slot %v of %v is %v, expected %v", slot, address, actual, value)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbos
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/arbos/arbosState"
+)
+
+func TestConditionalOptionsJSON(t *testing.T) {
+	input := `{
+		"knownAccounts": {
+			"0x1111111111111111111111111111111111111111": "0x56e81f171bcc55a6ff8345e692c0f86e5b48e01b996cadc001622fb5e363b421",
+			"0x2222222222222222222222222222222222222222": {
+				"0x0000000000000000000000000000000000000000000000000000000000000001": "0x0000000000000000000000000000000000000000000000000000000000000002"
+			}
+		},
+		"blockNumberMin": "0x10",
+		"timestampMax": "0x20"
+	}`
+	var options ConditionalOptions
+	Require(t, json.Unmarshal([]byte(input), &options))
+	if options.KnownSlots() != 2 {
+		Fail(t, "unexpected known slots", options.KnownSlots())
+	}
+	rootAccount := options.KnownAccounts[common.HexToAddress("0x1111111111111111111111111111111111111111")]
+	if rootAccount.RootHash == nil || *rootAccount.RootHash != types.EmptyRootHash {
+		Fail(t, "unexpected root hash", rootAccount.RootHash)
+	}
+	if options.BlockNumberMin == nil || *options.BlockNumberMin != 0x10 || options.BlockNumberMax != nil {
+		Fail(t, "unexpected block number range")
+	}
+
+	encoded, err := json.Marshal(&options)
+	Require(t, err)
+	var decoded ConditionalOptions
+	Require(t, json.Unmarshal(encoded, &decoded))
+	reencoded, err := json.Marshal(&decoded)
+	Require(t, err)
+	if string(encoded) != string(reencoded) {
+		Fail(t, "options didn't round trip", string(encoded), string(reencoded))
+	}
+}
+
+func TestConditionalOptionsCheck(t *testing.T) {
+	_, statedb := arbosState.NewArbosMemoryBackedArbOSState()
+	account := common.HexToAddress("0x1111111111111111111111111111111111111111")
+	slot := common.HexToHash("0x01")
+	value := common.HexToHash("0x02")
+	statedb.SetNonce(account, 1)
+	statedb.SetState(account, slot, value)
+	statedb.Finalise(true)
+	header := &types.Header{Number: big.NewInt(100), Time: 1000}
+
+	uint64Ptr := func(x uint64) *hexutil.Uint64 {
+		h := hexutil.Uint64(x)
+		return &h
+	}
+	emptyRoot := types.EmptyRootHash
+	tests := []struct {
+		options ConditionalOptions
+		ok      bool
+	}{
+		{ConditionalOptions{}, true},
+		{ConditionalOptions{BlockNumberMin: uint64Ptr(100), BlockNumberMax: uint64Ptr(100)}, true},
+		{ConditionalOptions{BlockNumberMin: uint64Ptr(101)}, false},
+		{ConditionalOptions{BlockNumberMax: uint64Ptr(99)}, false},
+		{ConditionalOptions{TimestampMin: uint64Ptr(999), TimestampMax: uint64Ptr(1000)}, true},
+		{ConditionalOptions{TimestampMin: uint64Ptr(1001)}, false},
+		{ConditionalOptions{TimestampMax: uint64Ptr(999)}, false},
+		{ConditionalOptions{KnownAccounts: map[common.Address]RootHashOrSlots{
+			account: {SlotValue: map[common.Hash]common.Hash{slot: value}},
+		}}, true},
+		{ConditionalOptions{KnownAccounts: map[common.Address]RootHashOrSlots{
+			account: {SlotValue: map[common.Hash]common.Hash{slot: {}}},
+		}}, false},
+		{ConditionalOptions{KnownAccounts: map[common.Address]RootHashOrSlots{
+			common.HexToAddress("0x2222222222222222222222222222222222222222"): {RootHash: &emptyRoot},
+		}}, true},
+		{ConditionalOptions{KnownAccounts: map[common.Address]RootHashOrSlots{
+			account: {RootHash: &emptyRoot},
+		}}, false},
+	}
+	for i, test := range tests {
+		err := test.options.Check(header, statedb)
+		if test.ok && err != nil {
+			Fail(t, "test", i, "unexpectedly failed", err)
+		}
+		if !test.ok && !errors.Is(err, ErrConditionsNotMet) {
+			Fail(t, "test", i, "expected conditions not met, got", err)
+		}
+	}
+
+	// The storage root of an account must reflect its current storage
+	root := statedb.StorageTrie(account).Hash()
+	options := ConditionalOptions{KnownAccounts: map[common.Address]RootHashOrSlots{account: {RootHash: &root}}}
+	Require(t, options.Check(header, statedb))
+	statedb.SetState(account, slot, common.HexToHash("0x03"))
+	statedb.Finalise(true)
+	if err := options.Check(header, statedb); !errors.Is(err, ErrConditionsNotMet) {
+		Fail(t, "expected changed storage root to fail, got", err)
+	}
+}
@@ -7,26 +7,41 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"io"
-	"math/big"
 	"net"
+	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/gobwas/httphead"
 	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
 	"github.com/pkg/errors"
 	flag "github.com/spf13/pflag"
 
+	"github.com/tenderly/nitro/go-ethereum/common"
 	"github.com/tenderly/nitro/go-ethereum/log"
-	"github.com/tenderly/nitro/arbstate"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
 	"github.com/tenderly/nitro/arbutil"
 	"github.com/tenderly/nitro/broadcaster"
 	"github.com/tenderly/nitro/util/stopwaiter"
 	"github.com/tenderly/nitro/wsbroadcastserver"
 )
 
+var (
+	feedConnectsCounter         = metrics.NewRegisteredCounter("arb/feed/client/connects", nil)
+	feedConnectErrorsCounter    = metrics.NewRegisteredCounter("arb/feed/client/connects/errors", nil)
+	feedReadErrorsCounter       = metrics.NewRegisteredCounter("arb/feed/client/errors/read", nil)
+	feedUnverifiedCounter       = metrics.NewRegisteredCounter("arb/feed/client/errors/unverified", nil)
+	feedReceivedMessagesCounter = metrics.NewRegisteredCounter("arb/feed/client/messages", nil)
+	feedReceivedBytesCounter    = metrics.NewRegisteredCounter("arb/feed/client/messages/bytes", nil)
+)
+
 type FeedConfig struct {
 	Output wsbroadcastserver.BroadcasterConfig `koanf:"output"`
 	Input  BroadcastClientConfig               `koanf:"input"`
@@ -46,9 +61,26 @@ var FeedConfigDefault = FeedConfig{
 	Input:  DefaultBroadcastClientConfig,
 }
 
+type FeedVerifyConfig struct {
+	Enable           bool     `koanf:"enable"`
+	AllowedAddresses []string `koanf:"allowed-addresses"`
+}
+
+func FeedVerifyConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Bool(prefix+".enable", DefaultFeedVerifyConfig.Enable, "require feed messages to be signed by an allowed address")
+	f.StringSlice(prefix+".allowed-addresses", DefaultFeedVerifyConfig.AllowedAddresses, "addresses allowed to sign feed messages")
+}
+
+var DefaultFeedVerifyConfig = FeedVerifyConfig{
+	Enable:           false,
+	AllowedAddresses: []string{},
+}
+
 type BroadcastClientConfig struct {
-	Timeout time.Duration `koanf:"timeout"`
-	URLs    []string      `koanf:"url"`
+	Timeout           time.Duration    `koanf:"timeout"`
+	URLs              []string         `koanf:"url"`
+	Verify            FeedVerifyConfig `koanf:"verify"`
+	EnableCompression bool             `koanf:"enable-compression"`
 }
 
 func (c *BroadcastClientConfig) Enable() bool {
@@ -58,26 +90,42 @@ func (c *BroadcastClientConfig) Enable() bool {
 func BroadcastClientConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.StringSlice(prefix+".url", DefaultBroadcastClientConfig.URLs, "URL of sequencer feed source")
 	f.Duration(prefix+".timeout", DefaultBroadcastClientConfig.Timeout, "duration to wait before timing out connection to sequencer feed")
+	FeedVerifyConfigAddOptions(prefix+".verify", f)
+	f.Bool(prefix+".enable-compression", DefaultBroadcastClientConfig.EnableCompression, "request per message deflate compression from the sequencer feed")
 }
 
 var DefaultBroadcastClientConfig = BroadcastClientConfig{
-	URLs:    []string{""},
-	Timeout: 20 * time.Second,
+	URLs:              []string{""},
+	Timeout:           20 * time.Second,
+	Verify:            DefaultFeedVerifyConfig,
+	EnableCompression: true,
 }
 
 type TransactionStreamerInterface interface {
-	AddBroadcastMessages(pos arbutil.MessageIndex, messages []arbstate.MessageWithMetadata) error
+	AddBroadcastMessages(feedMessages []*broadcaster.BroadcastFeedMessage) error
+}
+
+var ErrInvalidFeedSignature = errors.New("feed message has an invalid signature")
+
+var nonMetricNameChars = regexp.MustCompile("[^a-zA-Z0-9]+")
+
+// feedMetricsPrefix names the metrics of the client of one feed after its url
+func feedMetricsPrefix(url string) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(url, "ws://"), "wss://")
+	return "arb/feed/client/" + strings.Trim(nonMetricNameChars.ReplaceAllString(name, "_"), "_") + "/"
 }
 
 type BroadcastClient struct {
 	stopwaiter.StopWaiter
 
-	websocketUrl    string
-	lastInboxSeqNum *big.Int
+	websocketUrl string
+	// next sequence number to request from the server on (re)connect, accessed atomically
+	nextSeqNum uint64
 
-	// Protects conn and shuttingDown
+	// Protects conn, connected and shuttingDown
 	connMutex sync.Mutex
 	conn      net.Conn
+	connected bool
 
 	retryCount int64
 
@@ -86,22 +134,59 @@ type BroadcastClient struct {
 	ConfirmedSequenceNumberListener chan arbutil.MessageIndex
 	idleTimeout                     time.Duration
 	txStreamer                      TransactionStreamerInterface
+
+	// nil if feed messages aren't verified
+	allowedSigners map[common.Address]struct{}
+
+	enableCompression bool
+	// whether compression was negotiated for the current connection, protected by connMutex
+	compression bool
+
+	nextSeqNumGauge metrics.Gauge
 }
 
-func NewBroadcastClient(websocketUrl string, lastInboxSeqNum *big.Int, idleTimeout time.Duration, txStreamer TransactionStreamerInterface) *BroadcastClient {
-	var seqNum *big.Int
-	if lastInboxSeqNum == nil {
-		seqNum = big.NewInt(0)
-	} else {
-		seqNum = lastInboxSeqNum
+// nextSeqNum is the first sequence number the client needs, or zero to receive everything the server has buffered
+func NewBroadcastClient(config BroadcastClientConfig, websocketUrl string, nextSeqNum arbutil.MessageIndex, txStreamer TransactionStreamerInterface) (*BroadcastClient, error) {
+	var allowedSigners map[common.Address]struct{}
+	if config.Verify.Enable {
+		allowedSigners = make(map[common.Address]struct{})
+		for _, addr := range config.Verify.AllowedAddresses {
+			if !common.IsHexAddress(addr) {
+				return nil, fmt.Errorf("invalid feed signer address \"%v\"", addr)
+			}
+			allowedSigners[common.HexToAddress(addr)] = struct{}{}
+		}
+		if len(allowedSigners) == 0 {
+			return nil, errors.New("feed verification enabled but no allowed addresses specified")
+		}
 	}
 
 	return &BroadcastClient{
-		websocketUrl:    websocketUrl,
-		lastInboxSeqNum: seqNum,
-		idleTimeout:     idleTimeout,
-		txStreamer:      txStreamer,
+		websocketUrl:      websocketUrl,
+		nextSeqNum:        uint64(nextSeqNum),
+		idleTimeout:       config.Timeout,
+		txStreamer:        txStreamer,
+		allowedSigners:    allowedSigners,
+		enableCompression: config.EnableCompression,
+		nextSeqNumGauge:   metrics.GetOrRegisterGauge(feedMetricsPrefix(websocketUrl)+"nextseqnum", nil),
+	}, nil
+}
+
+// verifyMessages checks that every message is signed by an allowed signer, if verification is enabled
+func (bc *BroadcastClient) verifyMessages(messages []*broadcaster.BroadcastFeedMessage) error {
+	if bc.allowedSigners == nil {
+		return nil
+	}
+	for _, message := range messages {
+		signer, err := message.RecoverSigner()
+		if err != nil {
+			return errors.Wrapf(ErrInvalidFeedSignature, "sequence number %v: %v", message.SequenceNumber, err)
+		}
+		if _, ok := bc.allowedSigners[signer]; !ok {
+			return errors.Wrapf(ErrInvalidFeedSignature, "sequence number %v signed by unexpected address %v", message.SequenceNumber, signer)
+		}
 	}
+	return nil
 }
 
 func (bc *BroadcastClient) Start(ctxIn context.Context) {
@@ -130,20 +215,34 @@ func (bc *BroadcastClient) connect(ctx context.Context) (earlyFrameData io.Reade
 		// Nothing to do
 		return
 	}
+	defer func() {
+		if err != nil {
+			feedConnectErrorsCounter.Inc(1)
+		}
+	}()
 
-	log.Info("connecting to arbitrum inbox message broadcaster", "url", bc.websocketUrl)
+	nextSeqNum := bc.GetNextSeqNum()
+	log.Info("connecting to arbitrum inbox message broadcaster", "url", bc.websocketUrl, "requestedSeqNum", nextSeqNum)
 	timeoutDialer := ws.Dialer{
 		Timeout: 10 * time.Second,
 		TLSConfig: &tls.Config{
 			MinVersion: tls.VersionTLS12,
 		},
 	}
+	if bc.enableCompression {
+		timeoutDialer.Extensions = []httphead.Option{wsflate.DefaultParameters.Option()}
+	}
+	if nextSeqNum > 0 {
+		timeoutDialer.Header = ws.HandshakeHeaderHTTP(http.Header{
+			wsbroadcastserver.HTTPHeaderRequestedSequenceNumber: []string{strconv.FormatUint(uint64(nextSeqNum), 10)},
+		})
+	}
 
 	if bc.isShuttingDown() {
 		return
 	}
 
-	conn, br, _, err := timeoutDialer.Dial(ctx, bc.websocketUrl)
+	conn, br, hs, err := timeoutDialer.Dial(ctx, bc.websocketUrl)
 	if err != nil {
 		return nil, errors.Wrap(err, "broadcast client unable to connect")
 	}
@@ -159,11 +258,19 @@ func (bc *BroadcastClient) connect(ctx context.Context) (earlyFrameData io.Reade
 		earlyFrameData = io.LimitReader(br, int64(br.Buffered()))
 	}
 
+	// The dialer only accepts extensions named like one requested, so any extension is permessage-deflate,
+	// whatever parameters the server chose. The names can't be compared here, as hs.Extensions point into
+	// the dialer's pooled read buffer, which is reused once Dial returns.
+	compression := bc.enableCompression && len(hs.Extensions) > 0
+
 	bc.connMutex.Lock()
 	bc.conn = conn
+	bc.connected = true
+	bc.compression = compression
 	bc.connMutex.Unlock()
 
-	log.Info("Connected")
+	feedConnectsCounter.Inc(1)
+	log.Info("Connected", "compression", compression)
 
 	return
 }
@@ -177,11 +284,12 @@ func (bc *BroadcastClient) startBackgroundReader(earlyFrameData io.Reader) {
 			default:
 			}
 
-			msg, op, err := wsbroadcastserver.ReadData(ctx, bc.conn, earlyFrameData, bc.idleTimeout, ws.StateClientSide)
+			msg, op, err := wsbroadcastserver.ReadData(ctx, bc.conn, earlyFrameData, bc.idleTimeout, ws.StateClientSide, bc.isCompressed())
 			if err != nil {
 				if bc.isShuttingDown() {
 					return
 				}
+				feedReadErrorsCounter.Inc(1)
 				if strings.Contains(err.Error(), "i/o timeout") {
 					log.Error("Server connection timed out without receiving data", "url", bc.websocketUrl, "err", err)
 				} else if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
@@ -189,12 +297,13 @@ func (bc *BroadcastClient) startBackgroundReader(earlyFrameData io.Reader) {
 				} else {
 					log.Error("error calling readData", "url", bc.websocketUrl, "opcode", int(op), "err", err)
 				}
-				_ = bc.conn.Close()
+				bc.disconnected()
 				earlyFrameData = bc.retryConnect(ctx)
 				continue
 			}
 
 			if msg != nil {
+				feedReceivedBytesCounter.Inc(int64(len(msg)))
 				res := broadcaster.BroadcastMessage{}
 				err = json.Unmarshal(msg, &res)
 				if err != nil {
@@ -212,14 +321,28 @@ func (bc *BroadcastClient) startBackgroundReader(earlyFrameData io.Reader) {
 
 				if res.Version == 1 {
 					if len(res.Messages) > 0 {
-						messages := []arbstate.MessageWithMetadata{}
-						for _, message := range res.Messages {
-							messages = append(messages, message.Message)
+						if err := bc.verifyMessages(res.Messages); err != nil {
+							feedUnverifiedCounter.Inc(1)
+							log.Error("dropping unverified feed message, reconnecting", "url", bc.websocketUrl, "err", err)
+							bc.disconnected()
+							earlyFrameData = bc.retryConnect(ctx)
+							continue
 						}
-						if err := bc.txStreamer.AddBroadcastMessages(res.Messages[0].SequenceNumber, messages); err != nil {
+						if err := bc.txStreamer.AddBroadcastMessages(res.Messages); err != nil {
 							log.Error("Error adding message from Sequencer Feed", "err", err)
+						} else {
+							feedReceivedMessagesCounter.Inc(int64(len(res.Messages)))
+							bc.updateNextSeqNum(res.Messages[len(res.Messages)-1].SequenceNumber + 1)
 						}
 					}
+					if res.CatchupTooOldMessage != nil {
+						log.Warn(
+							"requested feed position no longer buffered by server, missing messages must be read from L1",
+							"url", bc.websocketUrl,
+							"requestedSeqNum", res.CatchupTooOldMessage.RequestedSequenceNumber,
+							"oldestSeqNum", res.CatchupTooOldMessage.OldestSequenceNumber,
+						)
+					}
 					if res.ConfirmedSequenceNumberMessage != nil && bc.ConfirmedSequenceNumberListener != nil {
 						bc.ConfirmedSequenceNumberListener <- res.ConfirmedSequenceNumberMessage.SequenceNumber
 					}
@@ -229,10 +352,63 @@ func (bc *BroadcastClient) startBackgroundReader(earlyFrameData io.Reader) {
 	})
 }
 
+// disconnected closes the current connection after it failed, before reconnecting
+func (bc *BroadcastClient) disconnected() {
+	bc.connMutex.Lock()
+	defer bc.connMutex.Unlock()
+	bc.connected = false
+	_ = bc.conn.Close()
+}
+
+// Connected returns whether the client is currently connected to the feed
+func (bc *BroadcastClient) Connected() bool {
+	bc.connMutex.Lock()
+	defer bc.connMutex.Unlock()
+	return bc.connected
+}
+
+func (bc *BroadcastClient) URL() string {
+	return bc.websocketUrl
+}
+
+// RequestCatchup reconnects to the server, asking it to resend messages starting at seqNum
+func (bc *BroadcastClient) RequestCatchup(seqNum arbutil.MessageIndex) {
+	atomic.StoreUint64(&bc.nextSeqNum, uint64(seqNum))
+	bc.connMutex.Lock()
+	defer bc.connMutex.Unlock()
+	if bc.conn != nil && !bc.shuttingDown {
+		// the background reader will notice the closed connection and reconnect
+		_ = bc.conn.Close()
+	}
+}
+
+func (bc *BroadcastClient) GetNextSeqNum() arbutil.MessageIndex {
+	return arbutil.MessageIndex(atomic.LoadUint64(&bc.nextSeqNum))
+}
+
+func (bc *BroadcastClient) updateNextSeqNum(nextSeqNum arbutil.MessageIndex) {
+	for {
+		current := atomic.LoadUint64(&bc.nextSeqNum)
+		if uint64(nextSeqNum) <= current {
+			return
+		}
+		if atomic.CompareAndSwapUint64(&bc.nextSeqNum, current, uint64(nextSeqNum)) {
+			bc.nextSeqNumGauge.Update(int64(nextSeqNum))
+			return
+		}
+	}
+}
+
 func (bc *BroadcastClient) GetRetryCount() int64 {
 	return atomic.LoadInt64(&bc.retryCount)
 }
 
+func (bc *BroadcastClient) isCompressed() bool {
+	bc.connMutex.Lock()
+	defer bc.connMutex.Unlock()
+	return bc.compression
+}
+
 func (bc *BroadcastClient) isShuttingDown() bool {
 	bc.connMutex.Lock()
 	defer bc.connMutex.Unlock()
@@ -274,6 +450,7 @@ func (bc *BroadcastClient) StopAndWait() {
 	defer bc.connMutex.Unlock()
 
 	bc.shuttingDown = true
+	bc.connected = false
 	if bc.conn != nil {
 		_ = bc.conn.Close()
 	}
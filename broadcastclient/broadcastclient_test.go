@@ -11,9 +11,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/tenderly/nitro/go-ethereum/crypto"
 	"github.com/tenderly/nitro/arbstate"
 	"github.com/tenderly/nitro/arbutil"
 	"github.com/tenderly/nitro/broadcaster"
+	"github.com/tenderly/nitro/util/testhelpers"
 	"github.com/tenderly/nitro/wsbroadcastserver"
 )
 
@@ -27,7 +29,7 @@ func TestReceiveMessages(t *testing.T) {
 	messageCount := 1000
 	clientCount := 2
 
-	b := broadcaster.NewBroadcaster(settings)
+	b := broadcaster.NewBroadcaster(settings, nil)
 
 	err := b.Start(ctx)
 	if err != nil {
@@ -42,7 +44,10 @@ func TestReceiveMessages(t *testing.T) {
 
 	go func() {
 		for i := 0; i < messageCount; i++ {
-			b.BroadcastSingle(arbstate.MessageWithMetadata{}, arbutil.MessageIndex(i))
+			if err := b.BroadcastSingle(arbstate.MessageWithMetadata{}, arbutil.MessageIndex(i)); err != nil {
+				t.Error(err)
+				return
+			}
 		}
 	}()
 
@@ -50,6 +55,178 @@ func TestReceiveMessages(t *testing.T) {
 
 }
 
+func TestReceiveSignedMessages(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	signer := func(hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, privateKey)
+	}
+	otherKey, err := crypto.GenerateKey()
+	Require(t, err)
+
+	b := broadcaster.NewBroadcaster(wsbroadcastserver.DefaultTestBroadcasterConfig, signer)
+	Require(t, b.Start(ctx))
+	defer b.StopAndWait()
+
+	config := DefaultBroadcastClientConfig
+	config.Verify.Enable = true
+	config.Verify.AllowedAddresses = []string{crypto.PubkeyToAddress(privateKey.PublicKey).Hex()}
+	ts := NewDummyTransactionStreamer()
+	broadcastClient := newTestBroadcastClientWithConfig(t, config, b.ListenerAddr(), ts)
+	broadcastClient.Start(ctx)
+	defer broadcastClient.StopAndWait()
+
+	config.Verify.AllowedAddresses = []string{crypto.PubkeyToAddress(otherKey.PublicKey).Hex()}
+	rejectingTs := NewDummyTransactionStreamer()
+	rejectingClient := newTestBroadcastClientWithConfig(t, config, b.ListenerAddr(), rejectingTs)
+	rejectingClient.Start(ctx)
+	defer rejectingClient.StopAndWait()
+
+	Require(t, b.BroadcastSingle(arbstate.MessageWithMetadata{}, 0))
+
+	timer := time.NewTimer(5 * time.Second)
+	defer timer.Stop()
+	select {
+	case receivedMsg := <-ts.messageReceiver:
+		if len(receivedMsg.Signature) == 0 {
+			Fail(t, "received message without signature")
+		}
+	case <-timer.C:
+		Fail(t, "client did not receive signed message")
+	}
+
+	rejectTimer := time.NewTimer(2 * time.Second)
+	defer rejectTimer.Stop()
+	select {
+	case receivedMsg := <-rejectingTs.messageReceiver:
+		Fail(t, "client accepted message signed by unexpected address", receivedMsg)
+	case <-rejectTimer.C:
+	}
+}
+
+func TestClientRequestsCatchupSequenceNumber(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := broadcaster.NewBroadcaster(wsbroadcastserver.DefaultTestBroadcasterConfig, nil)
+	Require(t, b.Start(ctx))
+	defer b.StopAndWait()
+
+	for i := 0; i < 5; i++ {
+		Require(t, b.BroadcastSingle(arbstate.MessageWithMetadata{}, arbutil.MessageIndex(i)))
+	}
+	b.Confirm(1)
+
+	expectFirstMessage := func(nextSeqNum arbutil.MessageIndex, expected arbutil.MessageIndex) {
+		t.Helper()
+		ts := NewDummyTransactionStreamer()
+		port := b.ListenerAddr().(*net.TCPAddr).Port
+		client, err := NewBroadcastClient(DefaultBroadcastClientConfig, fmt.Sprintf("ws://127.0.0.1:%d/", port), nextSeqNum, ts)
+		Require(t, err)
+		client.Start(ctx)
+
+		timer := time.NewTimer(5 * time.Second)
+		defer timer.Stop()
+		select {
+		case receivedMsg := <-ts.messageReceiver:
+			if receivedMsg.SequenceNumber != expected {
+				Fail(t, "requested", nextSeqNum, "expected first message", expected, "got", receivedMsg.SequenceNumber)
+			}
+		case <-timer.C:
+			Fail(t, "client requesting", nextSeqNum, "did not receive catch-up messages")
+		}
+
+		// drain the remaining catch-up messages so the client can shut down
+		stopped := make(chan struct{})
+		go func() {
+			for {
+				select {
+				case <-ts.messageReceiver:
+				case <-stopped:
+					return
+				}
+			}
+		}()
+		client.StopAndWait()
+		close(stopped)
+	}
+
+	// Messages 0 and 1 have been confirmed and evicted from the buffer
+	expectFirstMessage(0, 2)
+	expectFirstMessage(1, 2)
+	expectFirstMessage(3, 3)
+	expectFirstMessage(4, 4)
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	t.Parallel()
+	for _, serverCompression := range []bool{false, true} {
+		for _, clientCompression := range []bool{false, true} {
+			testCompressionNegotiation(t, serverCompression, clientCompression)
+		}
+	}
+}
+
+func testCompressionNegotiation(t *testing.T, serverCompression bool, clientCompression bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	settings := wsbroadcastserver.DefaultTestBroadcasterConfig
+	settings.EnableCompression = serverCompression
+	b := broadcaster.NewBroadcaster(settings, nil)
+	Require(t, b.Start(ctx))
+	defer b.StopAndWait()
+
+	// one message sent as catch-up on connect, and the rest broadcast afterwards
+	Require(t, b.BroadcastSingle(arbstate.MessageWithMetadata{}, 0))
+
+	config := DefaultBroadcastClientConfig
+	config.EnableCompression = clientCompression
+	ts := NewDummyTransactionStreamer()
+	broadcastClient := newTestBroadcastClientWithConfig(t, config, b.ListenerAddr(), ts)
+	broadcastClient.Start(ctx)
+	defer broadcastClient.StopAndWait()
+
+	messageCount := 5
+	for i := 0; i < messageCount; i++ {
+		timer := time.NewTimer(5 * time.Second)
+		select {
+		case receivedMsg := <-ts.messageReceiver:
+			if receivedMsg.SequenceNumber != arbutil.MessageIndex(i) {
+				Fail(t, "server compression", serverCompression, "client compression", clientCompression, "unexpected sequence number", receivedMsg.SequenceNumber)
+			}
+		case <-timer.C:
+			Fail(t, "server compression", serverCompression, "client compression", clientCompression, "did not receive message", i)
+		}
+		timer.Stop()
+		if i == 0 {
+			if broadcastClient.isCompressed() != (serverCompression && clientCompression) {
+				Fail(t, "server compression", serverCompression, "client compression", clientCompression, "negotiated", broadcastClient.isCompressed())
+			}
+		}
+		if i+1 < messageCount {
+			Require(t, b.BroadcastSingle(arbstate.MessageWithMetadata{}, arbutil.MessageIndex(i+1)))
+		}
+	}
+}
+
+func TestVerifyRequiresAllowedAddresses(t *testing.T) {
+	config := DefaultBroadcastClientConfig
+	config.Verify.Enable = true
+	if _, err := NewBroadcastClient(config, "ws://127.0.0.1:1/", 0, nil); err == nil {
+		Fail(t, "expected error creating verifying client without allowed addresses")
+	}
+	config.Verify.AllowedAddresses = []string{"not an address"}
+	if _, err := NewBroadcastClient(config, "ws://127.0.0.1:1/", 0, nil); err == nil {
+		Fail(t, "expected error creating verifying client with invalid address")
+	}
+}
+
 type dummyTransactionStreamer struct {
 	messageReceiver chan broadcaster.BroadcastFeedMessage
 }
@@ -60,24 +237,30 @@ func NewDummyTransactionStreamer() *dummyTransactionStreamer {
 	}
 }
 
-func (ts *dummyTransactionStreamer) AddBroadcastMessages(pos arbutil.MessageIndex, messages []arbstate.MessageWithMetadata) error {
-	for i, message := range messages {
-		ts.messageReceiver <- broadcaster.BroadcastFeedMessage{
-			SequenceNumber: pos + arbutil.MessageIndex(i),
-			Message:        message,
-		}
+func (ts *dummyTransactionStreamer) AddBroadcastMessages(feedMessages []*broadcaster.BroadcastFeedMessage) error {
+	for _, feedMessage := range feedMessages {
+		ts.messageReceiver <- *feedMessage
 	}
 	return nil
 }
 
-func newTestBroadcastClient(listenerAddress net.Addr, idleTimeout time.Duration, txStreamer TransactionStreamerInterface) *BroadcastClient {
+func newTestBroadcastClient(t *testing.T, listenerAddress net.Addr, idleTimeout time.Duration, txStreamer TransactionStreamerInterface) *BroadcastClient {
+	config := DefaultBroadcastClientConfig
+	config.Timeout = idleTimeout
+	return newTestBroadcastClientWithConfig(t, config, listenerAddress, txStreamer)
+}
+
+func newTestBroadcastClientWithConfig(t *testing.T, config BroadcastClientConfig, listenerAddress net.Addr, txStreamer TransactionStreamerInterface) *BroadcastClient {
+	t.Helper()
 	port := listenerAddress.(*net.TCPAddr).Port
-	return NewBroadcastClient(fmt.Sprintf("ws://127.0.0.1:%d/", port), nil, idleTimeout, txStreamer)
+	client, err := NewBroadcastClient(config, fmt.Sprintf("ws://127.0.0.1:%d/", port), 0, txStreamer)
+	Require(t, err)
+	return client
 }
 
 func startMakeBroadcastClient(ctx context.Context, t *testing.T, addr net.Addr, index int, expectedCount int, wg *sync.WaitGroup) {
 	ts := NewDummyTransactionStreamer()
-	broadcastClient := newTestBroadcastClient(addr, 20*time.Second, ts)
+	broadcastClient := newTestBroadcastClient(t, addr, 20*time.Second, ts)
 	broadcastClient.Start(ctx)
 	messageCount := 0
 
@@ -117,7 +300,7 @@ func TestServerClientDisconnect(t *testing.T) {
 	settings := wsbroadcastserver.DefaultTestBroadcasterConfig
 	settings.Ping = 1 * time.Second
 
-	b := broadcaster.NewBroadcaster(settings)
+	b := broadcaster.NewBroadcaster(settings, nil)
 
 	err := b.Start(ctx)
 	if err != nil {
@@ -126,10 +309,10 @@ func TestServerClientDisconnect(t *testing.T) {
 	defer b.StopAndWait()
 
 	ts := NewDummyTransactionStreamer()
-	broadcastClient := newTestBroadcastClient(b.ListenerAddr(), 20*time.Second, ts)
+	broadcastClient := newTestBroadcastClient(t, b.ListenerAddr(), 20*time.Second, ts)
 	broadcastClient.Start(ctx)
 
-	b.BroadcastSingle(arbstate.MessageWithMetadata{}, 0)
+	Require(t, b.BroadcastSingle(arbstate.MessageWithMetadata{}, 0))
 
 	// Wait for client to receive batch to ensure it is connected
 	timer := time.NewTimer(5 * time.Second)
@@ -168,7 +351,7 @@ func TestBroadcastClientReconnectsOnServerDisconnect(t *testing.T) {
 	settings.Ping = 50 * time.Second
 	settings.ClientTimeout = 150 * time.Second
 
-	b1 := broadcaster.NewBroadcaster(settings)
+	b1 := broadcaster.NewBroadcaster(settings, nil)
 
 	err := b1.Start(ctx)
 	if err != nil {
@@ -176,7 +359,7 @@ func TestBroadcastClientReconnectsOnServerDisconnect(t *testing.T) {
 	}
 	defer b1.StopAndWait()
 
-	broadcastClient := newTestBroadcastClient(b1.ListenerAddr(), 2*time.Second, nil)
+	broadcastClient := newTestBroadcastClient(t, b1.ListenerAddr(), 2*time.Second, nil)
 
 	broadcastClient.Start(ctx)
 
@@ -200,7 +383,7 @@ func TestBroadcasterSendsCachedMessagesOnClientConnect(t *testing.T) {
 	defer cancel()
 	settings := wsbroadcastserver.DefaultTestBroadcasterConfig
 
-	b := broadcaster.NewBroadcaster(settings)
+	b := broadcaster.NewBroadcaster(settings, nil)
 
 	err := b.Start(ctx)
 	if err != nil {
@@ -208,8 +391,8 @@ func TestBroadcasterSendsCachedMessagesOnClientConnect(t *testing.T) {
 	}
 	defer b.StopAndWait()
 
-	b.BroadcastSingle(arbstate.MessageWithMetadata{}, 0)
-	b.BroadcastSingle(arbstate.MessageWithMetadata{}, 1)
+	Require(t, b.BroadcastSingle(arbstate.MessageWithMetadata{}, 0))
+	Require(t, b.BroadcastSingle(arbstate.MessageWithMetadata{}, 1))
 
 	var wg sync.WaitGroup
 	for i := 0; i < 2; i++ {
@@ -261,7 +444,7 @@ func TestBroadcasterSendsCachedMessagesOnClientConnect(t *testing.T) {
 
 func connectAndGetCachedMessages(ctx context.Context, addr net.Addr, t *testing.T, clientIndex int, wg *sync.WaitGroup) {
 	ts := NewDummyTransactionStreamer()
-	broadcastClient := newTestBroadcastClient(addr, 60*time.Second, ts)
+	broadcastClient := newTestBroadcastClient(t, addr, 60*time.Second, ts)
 	broadcastClient.Start(ctx)
 
 	go func() {
@@ -302,3 +485,13 @@ func connectAndGetCachedMessages(ctx context.Context, addr net.Addr, t *testing.
 
 	}()
 }
+
+func Require(t *testing.T, err error, printables ...interface{}) {
+	t.Helper()
+	testhelpers.RequireImpl(t, err, printables...)
+}
+
+func Fail(t *testing.T, printables ...interface{}) {
+	t.Helper()
+	testhelpers.FailImpl(t, printables...)
+}
@@ -5,10 +5,14 @@ package broadcaster
 
 import (
 	"context"
+	"encoding/binary"
+	"errors"
 	"net"
 	"sync/atomic"
 	"time"
 
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/crypto"
 	"github.com/tenderly/nitro/go-ethereum/log"
 
 	"github.com/tenderly/nitro/arbstate"
@@ -19,8 +23,11 @@ import (
 type Broadcaster struct {
 	server        *wsbroadcastserver.WSBroadcastServer
 	catchupBuffer *SequenceNumberCatchupBuffer
+	signer        FeedSigner
 }
 
+type FeedSigner func([]byte) ([]byte, error) // takes 32-byte array (hash of data) and produces signature bytes (and/or error)
+
 /*
  * The base message type for messages to send over the network.
  *
@@ -42,17 +49,99 @@ type BroadcastMessage struct {
 	// TODO better name than messages since there are different types of messages
 	Messages                       []*BroadcastFeedMessage         `json:"messages,omitempty"`
 	ConfirmedSequenceNumberMessage *ConfirmedSequenceNumberMessage `json:"confirmedSequenceNumberMessage,omitempty"`
+	CatchupTooOldMessage           *CatchupTooOldMessage           `json:"catchupTooOldMessage,omitempty"`
 }
 
 type BroadcastFeedMessage struct {
 	SequenceNumber arbutil.MessageIndex         `json:"sequenceNumber"`
 	Message        arbstate.MessageWithMetadata `json:"message"`
+	Signature      []byte                       `json:"signature,omitempty"`
+}
+
+var feedSignaturePrefix = []byte("Arbitrum Nitro Feed Message:")
+
+// SignatureHash is the hash signed by the sequencer, committing to the sequence number and the full message
+func (m *BroadcastFeedMessage) SignatureHash() (common.Hash, error) {
+	data := append([]byte{}, feedSignaturePrefix...)
+	data = appendUint64(data, uint64(m.SequenceNumber))
+	data = appendUint64(data, m.Message.DelayedMessagesRead)
+	msg := m.Message.Message
+	if msg == nil {
+		return crypto.Keccak256Hash(data), nil
+	}
+	// Sequenced messages have no request id, so this can't use L1IncomingMessage.Serialize
+	if msg.Header != nil {
+		header := msg.Header
+		data = append(data, header.Kind)
+		data = append(data, header.Poster.Bytes()...)
+		data = appendUint64(data, header.BlockNumber)
+		data = appendUint64(data, header.Timestamp)
+		if header.RequestId != nil {
+			data = append(data, 1)
+			data = append(data, header.RequestId.Bytes()...)
+		} else {
+			data = append(data, 0)
+		}
+		if header.L1BaseFee != nil {
+			if header.L1BaseFee.Sign() < 0 {
+				return common.Hash{}, errors.New("cannot sign message with negative L1 base fee")
+			}
+			data = append(data, common.BigToHash(header.L1BaseFee).Bytes()...)
+		} else {
+			data = append(data, make([]byte, 32)...)
+		}
+	}
+	return crypto.Keccak256Hash(data, crypto.Keccak256(msg.L2msg)), nil
+}
+
+func appendUint64(data []byte, value uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], value)
+	return append(data, buf[:]...)
+}
+
+func (m *BroadcastFeedMessage) Sign(signer FeedSigner) error {
+	hash, err := m.SignatureHash()
+	if err != nil {
+		return err
+	}
+	sig, err := signer(hash.Bytes())
+	if err != nil {
+		return err
+	}
+	m.Signature = sig
+	return nil
+}
+
+var ErrMissingFeedSignature = errors.New("feed message has no signature")
+
+// RecoverSigner returns the address which signed the message
+func (m *BroadcastFeedMessage) RecoverSigner() (common.Address, error) {
+	if len(m.Signature) == 0 {
+		return common.Address{}, ErrMissingFeedSignature
+	}
+	hash, err := m.SignatureHash()
+	if err != nil {
+		return common.Address{}, err
+	}
+	pubkey, err := crypto.SigToPub(hash.Bytes(), m.Signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubkey), nil
 }
 
 type ConfirmedSequenceNumberMessage struct {
 	SequenceNumber arbutil.MessageIndex `json:"sequenceNumber"`
 }
 
+// CatchupTooOldMessage is sent to a client which requested catch-up from a sequence number
+// that's no longer buffered. The client must read the missing messages from L1.
+type CatchupTooOldMessage struct {
+	RequestedSequenceNumber arbutil.MessageIndex `json:"requestedSequenceNumber"`
+	OldestSequenceNumber    arbutil.MessageIndex `json:"oldestSequenceNumber"`
+}
+
 type SequenceNumberCatchupBuffer struct {
 	messages     []*BroadcastFeedMessage
 	messageCount int32
@@ -62,23 +151,56 @@ func NewSequenceNumberCatchupBuffer() *SequenceNumberCatchupBuffer {
 	return &SequenceNumberCatchupBuffer{}
 }
 
-func (b *SequenceNumberCatchupBuffer) OnRegisterClient(ctx context.Context, clientConnection *wsbroadcastserver.ClientConnection) error {
-	start := time.Now()
-	if len(b.messages) > 0 {
-		// send the newly connected client all the messages we've got...
-		bm := BroadcastMessage{
-			Version:  1,
-			Messages: b.messages,
+// getCatchupMessages returns the buffered messages starting at requestedSeqNum.
+// A requestedSeqNum of zero means the client wants everything buffered.
+func (b *SequenceNumberCatchupBuffer) getCatchupMessages(requestedSeqNum arbutil.MessageIndex) *BroadcastMessage {
+	if len(b.messages) == 0 {
+		return nil
+	}
+	firstSeqNum := b.messages[0].SequenceNumber
+	bm := BroadcastMessage{
+		Version: 1,
+	}
+	if requestedSeqNum < firstSeqNum {
+		if requestedSeqNum != 0 {
+			bm.CatchupTooOldMessage = &CatchupTooOldMessage{
+				RequestedSequenceNumber: requestedSeqNum,
+				OldestSequenceNumber:    firstSeqNum,
+			}
 		}
+		bm.Messages = b.messages
+	} else {
+		startIndex := uint64(requestedSeqNum - firstSeqNum)
+		if startIndex >= uint64(len(b.messages)) {
+			// client is already up to date
+			return nil
+		}
+		bm.Messages = b.messages[startIndex:]
+	}
+	return &bm
+}
 
+func (b *SequenceNumberCatchupBuffer) OnRegisterClient(ctx context.Context, clientConnection *wsbroadcastserver.ClientConnection) error {
+	start := time.Now()
+	requestedSeqNum := clientConnection.RequestedSeqNum()
+	bm := b.getCatchupMessages(requestedSeqNum)
+	if bm != nil {
+		// send the newly connected client the messages it's missing
 		err := clientConnection.Write(bm)
 		if err != nil {
-			log.Error("error sending client cached messages", err, "client", clientConnection.Name, "elapsed", time.Since(start))
+			log.Error("error sending client cached messages", "err", err, "client", clientConnection.Name, "elapsed", time.Since(start))
 			return err
 		}
+		if bm.CatchupTooOldMessage != nil {
+			log.Info("client requested catch-up older than buffer", "client", clientConnection.Name, "requestedSeqNum", requestedSeqNum, "oldestSeqNum", bm.CatchupTooOldMessage.OldestSequenceNumber)
+		}
 	}
 
-	log.Info("client registered", "client", clientConnection.Name, "elapsed", time.Since(start))
+	sent := 0
+	if bm != nil {
+		sent = len(bm.Messages)
+	}
+	log.Info("client registered", "client", clientConnection.Name, "requestedSeqNum", requestedSeqNum, "sent", sent, "elapsed", time.Since(start))
 
 	return nil
 }
@@ -146,23 +268,32 @@ func (b *SequenceNumberCatchupBuffer) GetMessageCount() int {
 	return int(atomic.LoadInt32(&b.messageCount))
 }
 
-func NewBroadcaster(settings wsbroadcastserver.BroadcasterConfig) *Broadcaster {
+// If signer is non-nil, messages passed to BroadcastSingle are signed with it.
+func NewBroadcaster(settings wsbroadcastserver.BroadcasterConfig, signer FeedSigner) *Broadcaster {
 	catchupBuffer := NewSequenceNumberCatchupBuffer()
 	return &Broadcaster{
 		server:        wsbroadcastserver.NewWSBroadcastServer(settings, catchupBuffer),
 		catchupBuffer: catchupBuffer,
+		signer:        signer,
 	}
 }
 
-func (b *Broadcaster) BroadcastSingle(msg arbstate.MessageWithMetadata, seq arbutil.MessageIndex) {
-	var broadcastMessages []*BroadcastFeedMessage
-
+func (b *Broadcaster) BroadcastSingle(msg arbstate.MessageWithMetadata, seq arbutil.MessageIndex) error {
 	bfm := BroadcastFeedMessage{SequenceNumber: seq, Message: msg}
-	broadcastMessages = append(broadcastMessages, &bfm)
+	if b.signer != nil {
+		if err := bfm.Sign(b.signer); err != nil {
+			return err
+		}
+	}
+	b.BroadcastFeedMessages([]*BroadcastFeedMessage{&bfm})
+	return nil
+}
 
+// BroadcastFeedMessages broadcasts already formed feed messages as-is, keeping any signatures
+func (b *Broadcaster) BroadcastFeedMessages(messages []*BroadcastFeedMessage) {
 	bm := BroadcastMessage{
 		Version:  1,
-		Messages: broadcastMessages,
+		Messages: messages,
 	}
 
 	b.server.Broadcast(bm)
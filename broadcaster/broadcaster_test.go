@@ -5,11 +5,17 @@ package broadcaster
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/big"
 	"testing"
 	"time"
 
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/crypto"
+	"github.com/tenderly/nitro/arbos"
 	"github.com/tenderly/nitro/arbstate"
+	"github.com/tenderly/nitro/arbutil"
 	"github.com/tenderly/nitro/util/testhelpers"
 	"github.com/tenderly/nitro/wsbroadcastserver"
 )
@@ -57,7 +63,7 @@ func TestBroadcasterMessagesRemovedOnConfirmation(t *testing.T) {
 
 	broadcasterSettings := wsbroadcastserver.DefaultTestBroadcasterConfig
 
-	b := NewBroadcaster(broadcasterSettings)
+	b := NewBroadcaster(broadcasterSettings, nil)
 	Require(t, b.Start(ctx))
 	defer b.StopAndWait()
 
@@ -67,13 +73,13 @@ func TestBroadcasterMessagesRemovedOnConfirmation(t *testing.T) {
 	}
 
 	// Normal broadcasting and confirming
-	b.BroadcastSingle(dummyMessage, 1)
+	Require(t, b.BroadcastSingle(dummyMessage, 1))
 	waitUntilUpdated(t, expectMessageCount(1, "after 1 message"))
-	b.BroadcastSingle(dummyMessage, 2)
+	Require(t, b.BroadcastSingle(dummyMessage, 2))
 	waitUntilUpdated(t, expectMessageCount(2, "after 2 messages"))
-	b.BroadcastSingle(dummyMessage, 3)
+	Require(t, b.BroadcastSingle(dummyMessage, 3))
 	waitUntilUpdated(t, expectMessageCount(3, "after 3 messages"))
-	b.BroadcastSingle(dummyMessage, 4)
+	Require(t, b.BroadcastSingle(dummyMessage, 4))
 	waitUntilUpdated(t, expectMessageCount(4, "after 4 messages"))
 
 	b.Confirm(1)
@@ -84,7 +90,7 @@ func TestBroadcasterMessagesRemovedOnConfirmation(t *testing.T) {
 	waitUntilUpdated(t, expectMessageCount(1,
 		"after 4 messages, 3 cleared"))
 
-	b.BroadcastSingle(dummyMessage, 5)
+	Require(t, b.BroadcastSingle(dummyMessage, 5))
 	waitUntilUpdated(t, expectMessageCount(2,
 		"after 5 messages, 3 cleared"))
 
@@ -93,10 +99,10 @@ func TestBroadcasterMessagesRemovedOnConfirmation(t *testing.T) {
 	waitUntilUpdated(t, expectMessageCount(0,
 		"clear all messages after confirmed 1 beyond latest"))
 
-	b.BroadcastSingle(dummyMessage, 3)
-	b.BroadcastSingle(dummyMessage, 4)
-	b.BroadcastSingle(dummyMessage, 5)
-	b.BroadcastSingle(dummyMessage, 6)
+	Require(t, b.BroadcastSingle(dummyMessage, 3))
+	Require(t, b.BroadcastSingle(dummyMessage, 4))
+	Require(t, b.BroadcastSingle(dummyMessage, 5))
+	Require(t, b.BroadcastSingle(dummyMessage, 6))
 	b.Confirm(2)
 	waitUntilUpdated(t, expectMessageCount(4,
 		"don't update count after confirming already confirmed messages"))
@@ -110,15 +116,105 @@ func TestBroadcasterMessagesRemovedOnConfirmation(t *testing.T) {
 		"clear all messages after confirmed 3 beyond latest"))
 
 	// Duplicates and messages already seen
-	b.BroadcastSingle(dummyMessage, 2)
-	b.BroadcastSingle(dummyMessage, 0)
-	b.BroadcastSingle(dummyMessage, 1)
-	b.BroadcastSingle(dummyMessage, 2)
+	Require(t, b.BroadcastSingle(dummyMessage, 2))
+	Require(t, b.BroadcastSingle(dummyMessage, 0))
+	Require(t, b.BroadcastSingle(dummyMessage, 1))
+	Require(t, b.BroadcastSingle(dummyMessage, 2))
 	waitUntilUpdated(t, expectMessageCount(1,
 		"1 message after duplicates and already seen messages"))
 
 }
 
+func TestCatchupBufferRequestedSequenceNumber(t *testing.T) {
+	buffer := NewSequenceNumberCatchupBuffer()
+	if buffer.getCatchupMessages(5) != nil {
+		Fail(t, "expected nothing from empty buffer")
+	}
+	var messages []*BroadcastFeedMessage
+	for i := arbutil.MessageIndex(10); i < 15; i++ {
+		messages = append(messages, &BroadcastFeedMessage{SequenceNumber: i})
+	}
+	Require(t, buffer.OnDoBroadcast(BroadcastMessage{Version: 1, Messages: messages}))
+
+	expectCatchup := func(requested arbutil.MessageIndex, expectedFirst arbutil.MessageIndex, expectedCount int, expectTooOld bool) {
+		t.Helper()
+		bm := buffer.getCatchupMessages(requested)
+		if expectedCount == 0 {
+			if bm != nil {
+				Fail(t, "requested", requested, "expected no catch-up but got", len(bm.Messages), "messages")
+			}
+			return
+		}
+		if bm == nil {
+			Fail(t, "requested", requested, "expected catch-up messages")
+		}
+		if len(bm.Messages) != expectedCount || bm.Messages[0].SequenceNumber != expectedFirst {
+			Fail(t, "requested", requested, "got", len(bm.Messages), "messages starting at", bm.Messages[0].SequenceNumber)
+		}
+		if (bm.CatchupTooOldMessage != nil) != expectTooOld {
+			Fail(t, "requested", requested, "unexpected too old message", bm.CatchupTooOldMessage)
+		}
+		if expectTooOld && bm.CatchupTooOldMessage.OldestSequenceNumber != 10 {
+			Fail(t, "unexpected oldest sequence number", bm.CatchupTooOldMessage.OldestSequenceNumber)
+		}
+	}
+	expectCatchup(0, 10, 5, false)
+	expectCatchup(3, 10, 5, true)
+	expectCatchup(10, 10, 5, false)
+	expectCatchup(13, 13, 2, false)
+	expectCatchup(15, 0, 0, false)
+	expectCatchup(100, 0, 0, false)
+}
+
+func TestBroadcastFeedMessageSignature(t *testing.T) {
+	privateKey, err := crypto.GenerateKey()
+	Require(t, err)
+	signer := func(hash []byte) ([]byte, error) {
+		return crypto.Sign(hash, privateKey)
+	}
+	expectedAddr := crypto.PubkeyToAddress(privateKey.PublicKey)
+
+	msg := &BroadcastFeedMessage{
+		SequenceNumber: 7,
+		Message: arbstate.MessageWithMetadata{
+			Message: &arbos.L1IncomingMessage{
+				Header: &arbos.L1IncomingMessageHeader{
+					Kind:        arbos.L1MessageType_L2Message,
+					Poster:      common.HexToAddress("0x1234"),
+					BlockNumber: 10,
+					Timestamp:   20,
+					L1BaseFee:   big.NewInt(0),
+				},
+				L2msg: []byte{1, 2, 3},
+			},
+			DelayedMessagesRead: 3,
+		},
+	}
+	if _, err := msg.RecoverSigner(); !errors.Is(err, ErrMissingFeedSignature) {
+		Fail(t, "expected missing signature error, got", err)
+	}
+
+	Require(t, msg.Sign(signer))
+	addr, err := msg.RecoverSigner()
+	Require(t, err)
+	if addr != expectedAddr {
+		Fail(t, "recovered", addr, "expected", expectedAddr)
+	}
+
+	msg.Message.Message.L2msg = []byte{1, 2, 4}
+	addr, err = msg.RecoverSigner()
+	if err == nil && addr == expectedAddr {
+		Fail(t, "signature still valid after modifying message")
+	}
+	msg.Message.Message.L2msg = []byte{1, 2, 3}
+
+	msg.SequenceNumber++
+	addr, err = msg.RecoverSigner()
+	if err == nil && addr == expectedAddr {
+		Fail(t, "signature still valid after modifying sequence number")
+	}
+}
+
 func Require(t *testing.T, err error, printables ...interface{}) {
 	t.Helper()
 	testhelpers.RequireImpl(t, err, printables...)
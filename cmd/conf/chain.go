@@ -6,6 +6,7 @@ package conf
 import (
 	"github.com/tenderly/nitro/arbnode"
 	"github.com/tenderly/nitro/cmd/genericconf"
+	"github.com/tenderly/nitro/util/l1client"
 	flag "github.com/spf13/pflag"
 )
 
@@ -14,6 +15,7 @@ type L1Config struct {
 	Rollup             arbnode.RollupAddressesConfig `koanf:"rollup"`
 	URL                string                        `koanf:"url"`
 	ConnectionAttempts int                           `koanf:"connection-attempts"`
+	Client             l1client.Config               `koanf:"client"`
 	Wallet             genericconf.WalletConfig      `koanf:"wallet"`
 }
 
@@ -22,6 +24,7 @@ var L1ConfigDefault = L1Config{
 	Rollup:             arbnode.RollupAddressesConfigDefault,
 	URL:                "",
 	ConnectionAttempts: 15,
+	Client:             l1client.DefaultConfig,
 	Wallet:             genericconf.WalletConfigDefault,
 }
 
@@ -30,6 +33,7 @@ func L1ConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.String(prefix+".url", L1ConfigDefault.URL, "layer 1 ethereum node RPC URL")
 	arbnode.RollupAddressesConfigAddOptions(prefix+".rollup", f)
 	f.Int(prefix+".connection-attempts", L1ConfigDefault.ConnectionAttempts, "layer 1 RPC connection attempts (spaced out at least 1 second per attempt, 0 to retry infinitely)")
+	l1client.ConfigAddOptions(prefix+".client", f)
 	genericconf.WalletConfigAddOptions(prefix+".wallet", f, "wallet")
 }
 
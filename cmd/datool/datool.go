@@ -7,6 +7,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -26,7 +27,7 @@ import (
 func main() {
 	args := os.Args
 	if len(args) < 2 {
-		panic("Usage: datool [client|keygen] ...")
+		panic("Usage: datool [client|keygen|storage] ...")
 	}
 
 	var err error
@@ -35,8 +36,10 @@ func main() {
 		err = startClient(args[2:])
 	case "keygen":
 		err = startKeyGen(args[2:])
+	case "storage":
+		err = startStorage(args[2:])
 	default:
-		panic(fmt.Sprintf("Unknown tool '%s' specified, valid tools are 'client', 'keygen'", args[1]))
+		panic(fmt.Sprintf("Unknown tool '%s' specified, valid tools are 'client', 'keygen', 'storage'", args[1]))
 	}
 	if err != nil {
 		panic(err)
@@ -76,6 +79,7 @@ type ClientStoreConfig struct {
 	URL                string        `koanf:"url"`
 	Message            string        `koanf:"message"`
 	DASRetentionPeriod time.Duration `koanf:"das-retention-period"`
+	MaxStoreChunkSize  int           `koanf:"max-store-chunk-size"`
 	// TODO ECDSA private key to sign message with
 	ConfConfig genericconf.ConfConfig `koanf:"conf"`
 }
@@ -85,6 +89,7 @@ func parseClientStoreConfig(args []string) (*ClientStoreConfig, error) {
 	f.String("url", "", "URL of DAS server to connect to.")
 	f.String("message", "", "Message to send.")
 	f.Duration("das-retention-period", 24*time.Hour, "The period which DASes are requested to retain the stored batches.")
+	f.Int("max-store-chunk-size", das.DefaultAggregatorConfig.MaxStoreChunkSize, "Messages larger than this many bytes are sent in chunks of this size (0 to disable chunking).")
 	genericconf.ConfConfigAddOptions("conf", f)
 
 	k, err := util.BeginCommonParse(f, args)
@@ -105,7 +110,7 @@ func startClientStore(args []string) error {
 		return err
 	}
 
-	client, err := dasrpc.NewDASRPCClient(config.URL)
+	client, err := dasrpc.NewDASRPCClient(config.URL, config.MaxStoreChunkSize)
 	if err != nil {
 		return err
 	}
@@ -155,7 +160,7 @@ func startRPCClientGetByHash(args []string) error {
 		return err
 	}
 
-	client, err := dasrpc.NewDASRPCClient(config.URL)
+	client, err := dasrpc.NewDASRPCClient(config.URL, 0)
 	if err != nil {
 		return err
 	}
@@ -280,3 +285,207 @@ func startKeyGen(args []string) error {
 	}
 	return nil
 }
+
+// datool storage ...
+
+func startStorage(args []string) error {
+	if len(args) == 0 {
+		return errors.New("datool storage requires an argument, valid arguments are 'migrate' and 'fsck'")
+	}
+	switch strings.ToLower(args[0]) {
+	case "migrate":
+		return startStorageMigrate(args[1:])
+	case "fsck":
+		return startStorageFsck(args[1:])
+	}
+	return fmt.Errorf("datool storage '%s' not supported, valid arguments are 'migrate' and 'fsck'", args[0])
+}
+
+// StorageBackendConfig selects one of the storage services a DAS can persist data to
+type StorageBackendConfig struct {
+	LocalDBStorageConfig   das.LocalDBStorageConfig   `koanf:"local-db-storage"`
+	LocalFileStorageConfig das.LocalFileStorageConfig `koanf:"local-file-storage"`
+	S3StorageServiceConfig das.S3StorageServiceConfig `koanf:"s3-storage"`
+	RedisConfig            das.RedisConfig            `koanf:"redis-storage"`
+}
+
+func storageBackendConfigAddOptions(prefix string, f *flag.FlagSet) {
+	das.LocalDBStorageConfigAddOptions(prefix+".local-db-storage", f)
+	das.LocalFileStorageConfigAddOptions(prefix+".local-file-storage", f)
+	das.S3ConfigAddOptions(prefix+".s3-storage", f)
+	das.RedisConfigAddOptions(prefix+".redis-storage", f)
+}
+
+// openStorageBackend opens the one enabled storage service.
+// Expiry is a dry run, so entries keep recording their timeouts but nothing is pruned while the tool runs.
+func openStorageBackend(ctx context.Context, config *StorageBackendConfig) (das.WalkableStorageService, error) {
+	var services []das.StorageService
+	if config.LocalDBStorageConfig.Enable {
+		s, err := das.NewDBStorageService(ctx, config.LocalDBStorageConfig.DataDir, config.LocalDBStorageConfig.DiscardAfterTimeout, config.LocalDBStorageConfig.ExpiryGracePeriod)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, s)
+	}
+	if config.LocalFileStorageConfig.Enable {
+		fileConfig := config.LocalFileStorageConfig
+		fileConfig.Expiry.DryRun = true
+		s, err := das.NewLocalFileStorageService(ctx, fileConfig)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, s)
+	}
+	if config.S3StorageServiceConfig.Enable {
+		s3Config := config.S3StorageServiceConfig
+		s3Config.Expiry.DryRun = true
+		s, err := das.NewS3StorageService(ctx, s3Config)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, s)
+	}
+	if config.RedisConfig.Enable {
+		s, err := das.NewRedisStorageService(config.RedisConfig, das.NewEmptyStorageService())
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, s)
+	}
+	if len(services) != 1 {
+		for _, s := range services {
+			_ = s.Close(ctx)
+		}
+		return nil, fmt.Errorf("exactly one storage backend must be enabled, got %d", len(services))
+	}
+	walkable, ok := services[0].(das.WalkableStorageService)
+	if !ok {
+		_ = services[0].Close(ctx)
+		return nil, fmt.Errorf("storage backend %v can't be walked", services[0])
+	}
+	return walkable, nil
+}
+
+// datool storage migrate
+
+type StorageMigrateConfig struct {
+	Src              StorageBackendConfig   `koanf:"src"`
+	Dst              StorageBackendConfig   `koanf:"dst"`
+	ResumeFile       string                 `koanf:"resume-file"`
+	DefaultRetention time.Duration          `koanf:"default-retention"`
+	ConfConfig       genericconf.ConfConfig `koanf:"conf"`
+}
+
+func parseStorageMigrateConfig(args []string) (*StorageMigrateConfig, error) {
+	f := flag.NewFlagSet("datool storage migrate", flag.ContinueOnError)
+	storageBackendConfigAddOptions("src", f)
+	storageBackendConfigAddOptions("dst", f)
+	f.String("resume-file", "", "file recording the last copied key, to resume an interrupted migration from (disabled if empty)")
+	f.Duration("default-retention", 15*24*time.Hour, "how long from now to keep entries that have no recorded expiry")
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := util.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var config StorageMigrateConfig
+	if err := util.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func startStorageMigrate(args []string) error {
+	config, err := parseStorageMigrateConfig(args)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	src, err := openStorageBackend(ctx, &config.Src)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	defer src.Close(ctx)
+	dst, err := openStorageBackend(ctx, &config.Dst)
+	if err != nil {
+		return fmt.Errorf("destination: %w", err)
+	}
+	defer dst.Close(ctx)
+
+	var after common.Hash
+	if config.ResumeFile != "" {
+		contents, err := os.ReadFile(config.ResumeFile)
+		if err == nil {
+			after, err = das.DecodeStorageServiceKey(strings.TrimSpace(string(contents)))
+			if err != nil {
+				return fmt.Errorf("invalid resume file %v: %w", config.ResumeFile, err)
+			}
+			fmt.Printf("Resuming after key %v\n", after)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	checkpoint := func(last common.Hash) error {
+		if config.ResumeFile == "" {
+			return nil
+		}
+		return os.WriteFile(config.ResumeFile, []byte(last.Hex()), 0600)
+	}
+
+	defaultTimeout := uint64(time.Now().Add(config.DefaultRetention).Unix())
+	result, err := das.MigrateStorage(ctx, src, dst, after, defaultTimeout, checkpoint)
+	fmt.Printf("Copied %d entries (%d bytes) from %v to %v, skipped %d corrupt entries, last key %v\n", result.Copied, result.Bytes, src, dst, result.Corrupt, result.LastKey)
+	return err
+}
+
+// datool storage fsck
+
+type StorageFsckConfig struct {
+	Storage          StorageBackendConfig   `koanf:"storage"`
+	Quarantine       bool                   `koanf:"quarantine"`
+	DefaultRetention time.Duration          `koanf:"default-retention"`
+	ConfConfig       genericconf.ConfConfig `koanf:"conf"`
+}
+
+func parseStorageFsckConfig(args []string) (*StorageFsckConfig, error) {
+	f := flag.NewFlagSet("datool storage fsck", flag.ContinueOnError)
+	storageBackendConfigAddOptions("storage", f)
+	f.Bool("quarantine", false, "quarantine corrupt entries, and legacy keyed entries after storing them again under their current key")
+	f.Duration("default-retention", 15*24*time.Hour, "how long from now to keep re-stored legacy entries that have no recorded expiry")
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := util.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var config StorageFsckConfig
+	if err := util.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func startStorageFsck(args []string) error {
+	config, err := parseStorageFsckConfig(args)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	s, err := openStorageBackend(ctx, &config.Storage)
+	if err != nil {
+		return err
+	}
+	defer s.Close(ctx)
+
+	defaultTimeout := uint64(time.Now().Add(config.DefaultRetention).Unix())
+	report := func(entry das.StorageEntry, problem das.StorageProblem) {
+		fmt.Printf("%v entry %v (%d bytes)\n", problem, entry.Key, entry.Size)
+	}
+	result, err := das.CheckStorage(ctx, s, config.Quarantine, defaultTimeout, report)
+	fmt.Printf("Checked %d entries of %v: %d corrupt, %d legacy, %d quarantined\n", result.Checked, s, result.Corrupt, result.Legacy, result.Quarantined)
+	return err
+}
@@ -71,10 +71,15 @@ func HTTPConfigAddOptions(prefix string, f *flag.FlagSet) {
 }
 
 func HTTPServerTimeoutConfigAddOptions(prefix string, f *flag.FlagSet) {
-	f.Duration(prefix+".read-timeout", HTTPServerTimeoutConfigDefault.ReadTimeout, "the maximum duration for reading the entire request (http.Server.ReadTimeout)")
-	f.Duration(prefix+".read-header-timeout", HTTPServerTimeoutConfigDefault.ReadHeaderTimeout, "the amount of time allowed to read the request headers (http.Server.ReadHeaderTimeout)")
-	f.Duration(prefix+".write-timeout", HTTPServerTimeoutConfigDefault.WriteTimeout, "the maximum duration before timing out writes of the response (http.Server.WriteTimeout)")
-	f.Duration(prefix+".idle-timeout", HTTPServerTimeoutConfigDefault.IdleTimeout, "the maximum amount of time to wait for the next request when keep-alives are enabled (http.Server.IdleTimeout)")
+	HTTPServerTimeoutConfigAddOptionsWithDefaults(prefix, f, HTTPServerTimeoutConfigDefault)
+}
+
+// HTTPServerTimeoutConfigAddOptionsWithDefaults is for servers whose requests need different timeouts than geth's
+func HTTPServerTimeoutConfigAddOptionsWithDefaults(prefix string, f *flag.FlagSet, defaults HTTPServerTimeoutConfig) {
+	f.Duration(prefix+".read-timeout", defaults.ReadTimeout, "the maximum duration for reading the entire request (http.Server.ReadTimeout)")
+	f.Duration(prefix+".read-header-timeout", defaults.ReadHeaderTimeout, "the amount of time allowed to read the request headers (http.Server.ReadHeaderTimeout)")
+	f.Duration(prefix+".write-timeout", defaults.WriteTimeout, "the maximum duration before timing out writes of the response (http.Server.WriteTimeout)")
+	f.Duration(prefix+".idle-timeout", defaults.IdleTimeout, "the maximum amount of time to wait for the next request when keep-alives are enabled (http.Server.IdleTimeout)")
 }
 
 type WSConfig struct {
@@ -29,7 +29,6 @@ import (
 	"github.com/tenderly/nitro/go-ethereum/accounts/keystore"
 	"github.com/tenderly/nitro/go-ethereum/common"
 	"github.com/tenderly/nitro/go-ethereum/core"
-	"github.com/tenderly/nitro/go-ethereum/ethclient"
 	"github.com/tenderly/nitro/go-ethereum/log"
 	"github.com/tenderly/nitro/go-ethereum/metrics"
 	"github.com/tenderly/nitro/go-ethereum/metrics/exp"
@@ -46,6 +45,7 @@ import (
 	"github.com/tenderly/nitro/cmd/conf"
 	"github.com/tenderly/nitro/cmd/genericconf"
 	"github.com/tenderly/nitro/cmd/util"
+	"github.com/tenderly/nitro/util/l1client"
 	"github.com/tenderly/nitro/statetransfer"
 
 	_ "github.com/tenderly/nitro/go-ethereum/eth/tracers/js"
@@ -201,7 +201,7 @@ func validateBlockChain(blockChain *core.BlockChain, expectedChainId *big.Int) e
 	return nil
 }
 
-func openInitializeChainDb(ctx context.Context, stack *node.Node, config *NodeConfig, chainId *big.Int, cacheConfig *core.CacheConfig) (ethdb.Database, *core.BlockChain, error) {
+func openInitializeChainDb(ctx context.Context, stack *node.Node, config *NodeConfig, chainId *big.Int, cacheConfig *core.CacheConfig, l1Client *l1client.Client, rollupAddrs *arbnode.RollupAddresses) (ethdb.Database, *core.BlockChain, error) {
 	if !config.Init.Force {
 		if readOnlyDb, err := stack.OpenDatabaseWithFreezer("l2chaindata", 0, 0, "", "", true); err == nil {
 			if chainConfig := arbnode.TryReadStoredChainConfig(readOnlyDb); chainConfig != nil {
@@ -245,6 +245,20 @@ func openInitializeChainDb(ctx context.Context, stack *node.Node, config *NodeCo
 		}
 	}
 
+	var snapshot *arbnode.SnapshotManifest
+	if config.Init.Snapshot != "" {
+		if initFile != "" || config.Init.ImportFile != "" || config.Init.Empty || config.Init.DevInit {
+			return nil, nil, errors.New("multiple init methods supplied")
+		}
+		if l1Client == nil {
+			return nil, nil, errors.New("importing a snapshot requires an L1 connection to verify it")
+		}
+		snapshot, err = arbnode.ImportSnapshot(ctx, stack, config.Init.Snapshot, "arbitrumdata", chainId, l1Client, rollupAddrs)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error importing snapshot: %w", err)
+		}
+	}
+
 	var initDataReader statetransfer.InitDataReader = nil
 
 	chainDb, err := stack.OpenDatabaseWithFreezer("l2chaindata", 0, 0, "", "", false)
@@ -304,6 +318,12 @@ func openInitializeChainDb(ctx context.Context, stack *node.Node, config *NodeCo
 			// The node will probably die later, but might as well not kill it here?
 			log.Error("database missing genesis block", "number", genesisBlockNr)
 		}
+		if snapshot != nil {
+			err = arbnode.RewindToSnapshot(l2BlockChain, snapshot)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
 	} else {
 		genesisBlockNr, err := initDataReader.GetNextBlockNumber()
 		if err != nil {
@@ -398,6 +418,10 @@ func main() {
 	var daSigner func([]byte) ([]byte, error)
 	if nodeConfig.Node.L1Reader.Enable {
 		log.Info("connected to l1 chain", "l1url", nodeConfig.L1.URL, "l1chainid", l1ChainId)
+		if l1Client != nil {
+			l1Client.Start(ctx)
+			defer l1Client.StopAndWait()
+		}
 
 		rollupAddrs, err = nodeConfig.L1.Rollup.ParseAddresses()
 		if err != nil {
@@ -422,8 +446,15 @@ func main() {
 	} else if l1Client != nil {
 		// Don't need l1Client anymore
 		log.Info("used chain id to get rollup parameters", "l1url", nodeConfig.L1.URL, "l1chainid", l1ChainId)
+		l1Client.Close()
 		l1Client = nil
 	}
+	if nodeConfig.Node.Feed.Output.Enable && nodeConfig.Node.Feed.Output.Signed && daSigner == nil {
+		daSigner, err = arbnode.GetSignerFromWallet(l1Wallet)
+		if err != nil {
+			panic(err)
+		}
+	}
 
 	if nodeConfig.Node.Validator.Enable {
 		if !nodeConfig.Node.L1Reader.Enable {
@@ -467,7 +498,7 @@ func main() {
 		}
 	}
 
-	chainDb, l2BlockChain, err := openInitializeChainDb(ctx, stack, nodeConfig, new(big.Int).SetUint64(nodeConfig.L2.ChainID), arbnode.DefaultCacheConfigFor(stack, nodeConfig.Node.Archive))
+	chainDb, l2BlockChain, err := openInitializeChainDb(ctx, stack, nodeConfig, new(big.Int).SetUint64(nodeConfig.L2.ChainID), arbnode.DefaultCacheConfigFor(stack, nodeConfig.Node.Archive), l1Client, &rollupAddrs)
 	if err != nil {
 		panic(err)
 	}
@@ -547,6 +578,7 @@ type InitConfig struct {
 	Empty           bool          `koanf:"empty"`
 	AccountsPerSync uint          `koanf:"accounts-per-sync"`
 	ImportFile      string        `koanf:"import-file"`
+	Snapshot        string        `koanf:"snapshot"`
 	ThenQuit        bool          `koanf:"then-quit"`
 }
 
@@ -559,6 +591,7 @@ var InitConfigDefault = InitConfig{
 	DevInitAddr:     "",
 	DevInitBlockNum: 0,
 	ImportFile:      "",
+	Snapshot:        "",
 	AccountsPerSync: 100000,
 	ThenQuit:        false,
 }
@@ -574,6 +607,7 @@ func InitConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".empty", InitConfigDefault.DevInit, "init with empty state")
 	f.Bool(prefix+".then-quit", InitConfigDefault.ThenQuit, "quit after init is done")
 	f.String(prefix+".import-file", InitConfigDefault.ImportFile, "path for json data to import")
+	f.String(prefix+".snapshot", InitConfigDefault.Snapshot, "path of a node snapshot archive (see snapshot-export) to import, verified against L1 before syncing from it")
 	f.Uint(prefix+".accounts-per-sync", InitConfigDefault.AccountsPerSync, "during init - sync database every X accounts. Lower value for low-memory systems. 0 disables.")
 }
 
@@ -634,7 +668,7 @@ func (c *NodeConfig) ResolveDirectoryNames() error {
 	return nil
 }
 
-func ParseNode(ctx context.Context, args []string) (*NodeConfig, *genericconf.WalletConfig, *genericconf.WalletConfig, *ethclient.Client, *big.Int, error) {
+func ParseNode(ctx context.Context, args []string) (*NodeConfig, *genericconf.WalletConfig, *genericconf.WalletConfig, *l1client.Client, *big.Int, error) {
 	f := flag.NewFlagSet("", flag.ContinueOnError)
 
 	NodeConfigAddOptions(f)
@@ -645,16 +679,20 @@ func ParseNode(ctx context.Context, args []string) (*NodeConfig, *genericconf.Wa
 	}
 
 	var l1ChainId *big.Int
-	var l1Client *ethclient.Client
+	var l1Client *l1client.Client
 	l1URL := k.String("l1.url")
 	configChainId := uint64(k.Int64("l1.chain-id"))
 	if l1URL != "" {
+		l1ClientConfig := l1client.DefaultConfig
+		if err := k.Unmarshal("l1.client", &l1ClientConfig); err != nil {
+			return nil, nil, nil, nil, nil, err
+		}
 		maxConnectionAttempts := k.Int("l1.connection-attempts")
 		if maxConnectionAttempts <= 0 {
 			maxConnectionAttempts = math.MaxInt
 		}
 		for i := 1; i <= maxConnectionAttempts; i++ {
-			l1Client, err = ethclient.DialContext(ctx, l1URL)
+			l1Client, err = l1client.New(ctx, l1URL, &l1ClientConfig)
 			if err == nil {
 				l1ChainId, err = l1Client.ChainID(ctx)
 				if err == nil {
@@ -65,19 +65,22 @@ func startup() error {
 	log.Info("Running Arbitrum nitro relay", "revision", vcsRevision, "vcs.time", vcsTime)
 
 	serverConf := wsbroadcastserver.BroadcasterConfig{
-		Addr:          relayConfig.Node.Feed.Output.Addr,
-		IOTimeout:     relayConfig.Node.Feed.Output.IOTimeout,
-		Port:          relayConfig.Node.Feed.Output.Port,
-		Ping:          relayConfig.Node.Feed.Output.Ping,
-		ClientTimeout: relayConfig.Node.Feed.Output.ClientTimeout,
-		Queue:         relayConfig.Node.Feed.Output.Queue,
-		Workers:       relayConfig.Node.Feed.Output.Workers,
-		MaxSendQueue:  relayConfig.Node.Feed.Output.MaxSendQueue,
+		Addr:              relayConfig.Node.Feed.Output.Addr,
+		IOTimeout:         relayConfig.Node.Feed.Output.IOTimeout,
+		Port:              relayConfig.Node.Feed.Output.Port,
+		Ping:              relayConfig.Node.Feed.Output.Ping,
+		ClientTimeout:     relayConfig.Node.Feed.Output.ClientTimeout,
+		Queue:             relayConfig.Node.Feed.Output.Queue,
+		Workers:           relayConfig.Node.Feed.Output.Workers,
+		MaxSendQueue:      relayConfig.Node.Feed.Output.MaxSendQueue,
+		EnableCompression: relayConfig.Node.Feed.Output.EnableCompression,
 	}
 
 	clientConf := broadcastclient.BroadcastClientConfig{
-		Timeout: relayConfig.Node.Feed.Input.Timeout,
-		URLs:    relayConfig.Node.Feed.Input.URLs,
+		Timeout:           relayConfig.Node.Feed.Input.Timeout,
+		URLs:              relayConfig.Node.Feed.Input.URLs,
+		Verify:            relayConfig.Node.Feed.Input.Verify,
+		EnableCompression: relayConfig.Node.Feed.Input.EnableCompression,
 	}
 
 	defer log.Info("Cleanly shutting down relay")
@@ -86,7 +89,10 @@ func startup() error {
 	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
 
 	// Start up an arbitrum sequencer relay
-	newRelay := relay.NewRelay(serverConf, clientConf)
+	newRelay, err := relay.NewRelay(serverConf, clientConf, relayConfig.Node.Gap)
+	if err != nil {
+		return err
+	}
 	err = newRelay.Start(ctx)
 	if err != nil {
 		return err
@@ -119,14 +125,17 @@ func RelayConfigAddOptions(f *flag.FlagSet) {
 
 type RelayNodeConfig struct {
 	Feed broadcastclient.FeedConfig `koanf:"feed"`
+	Gap  relay.GapConfig            `koanf:"gap"`
 }
 
 var RelayNodeConfigDefault = RelayNodeConfig{
 	Feed: broadcastclient.FeedConfigDefault,
+	Gap:  relay.DefaultGapConfig,
 }
 
 func RelayNodeConfigAddOptions(prefix string, f *flag.FlagSet) {
 	broadcastclient.FeedConfigAddOptions(prefix+".feed", f, true, true)
+	relay.GapConfigAddOptions(prefix+".gap", f)
 }
 
 func ParseRelay(_ context.Context, args []string) (*RelayConfig, error) {
@@ -15,17 +15,21 @@ import (
 )
 
 func main() {
-	if len(os.Args) != 4 {
-		fmt.Fprintf(os.Stderr, "Usage: seq-coordinator-invalidate [redis url] [signing key] [msg index]\n")
+	if len(os.Args) != 4 && len(os.Args) != 5 {
+		fmt.Fprintf(os.Stderr, "Usage: seq-coordinator-invalidate [redis url | raft://peer,...] [signing key] [msg index] [raft jwt secret]\n")
 		os.Exit(1)
 	}
-	redisUrl := os.Args[1]
+	backendUrl := os.Args[1]
 	signingKey := os.Args[2]
 	msgIndex, err := strconv.ParseUint(os.Args[3], 10, 64)
 	if err != nil {
 		panic("Failed to parse msg index: " + err.Error())
 	}
-	err = arbnode.StandaloneSeqCoordinatorInvalidateMsgIndex(context.Background(), redisUrl, signingKey, arbutil.MessageIndex(msgIndex))
+	raftJWTSecret := ""
+	if len(os.Args) == 5 {
+		raftJWTSecret = os.Args[4]
+	}
+	err = arbnode.StandaloneSeqCoordinatorInvalidateMsgIndex(context.Background(), backendUrl, raftJWTSecret, signingKey, arbutil.MessageIndex(msgIndex))
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,117 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/arbnode"
+	"github.com/tenderly/nitro/arbutil"
+	"github.com/tenderly/nitro/cmd/conf"
+	"github.com/tenderly/nitro/cmd/genericconf"
+	"github.com/tenderly/nitro/cmd/util"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/node"
+)
+
+type SnapshotExportConfig struct {
+	Persistent   conf.PersistentConfig `koanf:"persistent"`
+	Instance     string                `koanf:"instance"`
+	Output       string                `koanf:"output"`
+	MessageCount uint64                `koanf:"message-count"`
+	LogLevel     int                   `koanf:"log-level"`
+
+	ConfConfig genericconf.ConfConfig `koanf:"conf"`
+}
+
+func parseSnapshotExportConfig(args []string) (*SnapshotExportConfig, error) {
+	f := flag.NewFlagSet("snapshot-export", flag.ContinueOnError)
+	conf.PersistentConfigAddOptions("persistent", f)
+	f.String("instance", "nitro", "name of the node's directory inside the chain directory")
+	f.String("output", "", "path to write the snapshot archive to")
+	f.Uint64("message-count", 0, "snapshot at this many messages (0 for the chain head); unless the node is an archive node, only its latest blocks have the state needed")
+	f.Int("log-level", int(log.LvlInfo), "log level; 1: ERROR, 2: WARN, 3: INFO, 4: DEBUG, 5: TRACE")
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := util.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var config SnapshotExportConfig
+	if err := util.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	if config.Output == "" {
+		return nil, errors.New("--output must be specified")
+	}
+	if err := config.Persistent.ResolveDirectoryNames(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+func main() {
+	if err := export(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting snapshot: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func export(args []string) error {
+	config, err := parseSnapshotExportConfig(args)
+	if err != nil {
+		return err
+	}
+
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(config.LogLevel))
+	log.Root().SetHandler(glogger)
+
+	// Creating the stack locks the node's directory, so this fails if the node is still running
+	stackConf := node.DefaultConfig
+	stackConf.DataDir = config.Persistent.Chain
+	stackConf.Name = config.Instance
+	stackConf.P2P.ListenAddr = ""
+	stackConf.P2P.NoDial = true
+	stackConf.P2P.NoDiscovery = true
+	stack, err := node.New(&stackConf)
+	if err != nil {
+		return err
+	}
+	defer stack.Close()
+
+	chainDb, err := stack.OpenDatabaseWithFreezer("l2chaindata", 0, 0, "", "", true)
+	if err != nil {
+		return err
+	}
+	arbDb, err := stack.OpenDatabase("arbitrumdata", 0, 0, "", true)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(config.Output)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	manifest, err := arbnode.ExportSnapshot(stack.ResolvePath("l2chaindata"), chainDb, arbDb, arbutil.MessageIndex(config.MessageCount), writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(config.Output)
+		return err
+	}
+	fmt.Printf("Exported snapshot of %v messages (block %v, %v batches) to %v\n", manifest.MessageCount, manifest.BlockNumber, manifest.BatchCount, config.Output)
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/log"
+
+	"github.com/tenderly/nitro/cmd/genericconf"
+	"github.com/tenderly/nitro/cmd/util"
+	"github.com/tenderly/nitro/validator"
+)
+
+type ValidationReplayConfig struct {
+	Bundle       string `koanf:"bundle"`
+	WasmRootPath string `koanf:"wasm-root-path"`
+	ModuleRoot   string `koanf:"module-root"`
+	LogLevel     int    `koanf:"log-level"`
+
+	ConfConfig genericconf.ConfConfig `koanf:"conf"`
+}
+
+func parseValidationReplayConfig(args []string) (*ValidationReplayConfig, error) {
+	f := flag.NewFlagSet("validation-replay", flag.ContinueOnError)
+	f.String("bundle", "", "validation bundle to replay, either a directory or a JSON file as returned by arb_exportValidationBundle")
+	f.String("wasm-root-path", "", "path to machine folders, each containing wasm files (machine.wavm.br, replay.wasm)")
+	f.String("module-root", "", "wasm module root to replay with instead of the one in the bundle")
+	f.Int("log-level", int(log.LvlWarn), "log level; 1: ERROR, 2: WARN, 3: INFO, 4: DEBUG, 5: TRACE")
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := util.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var config ValidationReplayConfig
+	if err := util.EndCommonParse(k, &config); err != nil {
+		return nil, err
+	}
+	if config.Bundle == "" {
+		return nil, errors.New("--bundle must be specified")
+	}
+	return &config, nil
+}
+
+func main() {
+	matched, err := replay(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error replaying validation bundle: %v\n", err)
+		os.Exit(2)
+	}
+	if !matched {
+		os.Exit(1)
+	}
+}
+
+func replay(args []string) (bool, error) {
+	config, err := parseValidationReplayConfig(args)
+	if err != nil {
+		return false, err
+	}
+
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(config.LogLevel))
+	log.Root().SetHandler(glogger)
+
+	exported, err := validator.ReadValidationBundle(config.Bundle)
+	if err != nil {
+		return false, err
+	}
+	bundle, err := exported.Bundle()
+	if err != nil {
+		return false, err
+	}
+	if config.ModuleRoot != "" {
+		bundle.Input.ModuleRoot = common.HexToHash(config.ModuleRoot)
+	}
+
+	machineConfig := validator.DefaultNitroMachineConfig
+	if config.WasmRootPath != "" {
+		machineConfig.RootPath = config.WasmRootPath
+	} else {
+		execfile, err := os.Executable()
+		if err != nil {
+			return false, err
+		}
+		machineConfig.RootPath = filepath.Join(filepath.Dir(filepath.Dir(execfile)), "machines")
+	}
+
+	fmt.Printf("Replaying block %d (%v) with module root %v\n", bundle.Input.BlockNumber, bundle.BlockHash, bundle.Input.ModuleRoot)
+	gsEnd, err := validator.ReplayValidationBundle(context.Background(), validator.NewNitroMachineLoader(machineConfig), bundle)
+	if err != nil {
+		return false, err
+	}
+	if gsEnd != bundle.ExpectedEnd {
+		fmt.Printf("MISMATCH\n  got:      %+v\n  expected: %+v\n", gsEnd, bundle.ExpectedEnd)
+		return false, nil
+	}
+	fmt.Printf("MATCH %+v\n", gsEnd)
+	return true, nil
+}
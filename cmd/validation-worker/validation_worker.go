@@ -0,0 +1,159 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+
+	koanfjson "github.com/knadh/koanf/parsers/json"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
+	"github.com/tenderly/nitro/go-ethereum/metrics/exp"
+
+	"github.com/tenderly/nitro/cmd/genericconf"
+	"github.com/tenderly/nitro/cmd/util"
+	"github.com/tenderly/nitro/validator"
+)
+
+type ValidationWorkerConfig struct {
+	RPCAddr           string                              `koanf:"rpc-addr"`
+	RPCPort           uint64                              `koanf:"rpc-port"`
+	RPCServerTimeouts genericconf.HTTPServerTimeoutConfig `koanf:"rpc-server-timeouts"`
+
+	ConcurrentRunsLimit int    `koanf:"concurrent-runs-limit"`
+	WasmRootPath        string `koanf:"wasm-root-path"`
+
+	ConfConfig genericconf.ConfConfig `koanf:"conf"`
+	LogLevel   int                    `koanf:"log-level"`
+
+	Metrics       bool                            `koanf:"metrics"`
+	MetricsServer genericconf.MetricsServerConfig `koanf:"metrics-server"`
+}
+
+var DefaultValidationWorkerConfig = ValidationWorkerConfig{
+	RPCAddr:             "localhost",
+	RPCPort:             9880,
+	RPCServerTimeouts:   validator.ValidationWorkerServerTimeoutsDefault,
+	ConcurrentRunsLimit: 0,
+	WasmRootPath:        "",
+	ConfConfig:          genericconf.ConfConfigDefault,
+	Metrics:             false,
+	MetricsServer:       genericconf.MetricsServerConfigDefault,
+	LogLevel:            3,
+}
+
+func main() {
+	if err := startup(); err != nil {
+		log.Error("Error running validation worker", "err", err)
+	}
+}
+
+func printSampleUsage() {
+	progname := os.Args[0]
+	fmt.Printf("\n")
+	fmt.Printf("Sample usage:                  %s --help \n", progname)
+}
+
+func parseValidationWorker(args []string) (*ValidationWorkerConfig, error) {
+	f := flag.NewFlagSet("validation-worker", flag.ContinueOnError)
+	f.String("rpc-addr", DefaultValidationWorkerConfig.RPCAddr, "HTTP-RPC server listening interface")
+	f.Uint64("rpc-port", DefaultValidationWorkerConfig.RPCPort, "HTTP-RPC server listening port")
+	genericconf.HTTPServerTimeoutConfigAddOptionsWithDefaults("rpc-server-timeouts", f, DefaultValidationWorkerConfig.RPCServerTimeouts)
+
+	f.Int("concurrent-runs-limit", DefaultValidationWorkerConfig.ConcurrentRunsLimit, "maximum validations to run at once (0 for the number of CPUs)")
+	f.String("wasm-root-path", DefaultValidationWorkerConfig.WasmRootPath, "path to machine folders, each containing wasm files (machine.wavm.br, replay.wasm)")
+
+	f.Bool("metrics", DefaultValidationWorkerConfig.Metrics, "enable metrics")
+	genericconf.MetricsServerAddOptions("metrics-server", f)
+
+	f.Int("log-level", int(log.LvlInfo), "log level; 1: ERROR, 2: WARN, 3: INFO, 4: DEBUG, 5: TRACE")
+	genericconf.ConfConfigAddOptions("conf", f)
+
+	k, err := util.BeginCommonParse(f, args)
+	if err != nil {
+		return nil, err
+	}
+
+	var workerConfig ValidationWorkerConfig
+	if err := util.EndCommonParse(k, &workerConfig); err != nil {
+		return nil, err
+	}
+	if workerConfig.ConfConfig.Dump {
+		c, err := k.Marshal(koanfjson.Parser())
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal config file to JSON: %w", err)
+		}
+
+		fmt.Println(string(c))
+		os.Exit(0)
+	}
+
+	return &workerConfig, nil
+}
+
+func startup() error {
+	vcsRevision, vcsTime := genericconf.GetVersion()
+	workerConfig, err := parseValidationWorker(os.Args[1:])
+	if err != nil {
+		fmt.Printf("\nrevision: %v, vcs.time: %v\n", vcsRevision, vcsTime)
+		printSampleUsage()
+		if !strings.Contains(err.Error(), "help requested") {
+			fmt.Printf("%s\n", err.Error())
+		}
+		return nil
+	}
+
+	glogger := log.NewGlogHandler(log.StreamHandler(os.Stderr, log.TerminalFormat(false)))
+	glogger.Verbosity(log.Lvl(workerConfig.LogLevel))
+	log.Root().SetHandler(glogger)
+
+	if workerConfig.Metrics {
+		go metrics.CollectProcessMetrics(workerConfig.MetricsServer.UpdateInterval)
+
+		if workerConfig.MetricsServer.Addr != "" {
+			address := fmt.Sprintf("%v:%v", workerConfig.MetricsServer.Addr, workerConfig.MetricsServer.Port)
+			exp.Setup(address)
+		}
+	}
+
+	machineConfig := validator.DefaultNitroMachineConfig
+	if workerConfig.WasmRootPath != "" {
+		machineConfig.RootPath = workerConfig.WasmRootPath
+	} else {
+		execfile, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		machineConfig.RootPath = filepath.Join(filepath.Dir(filepath.Dir(execfile)), "machines")
+	}
+	concurrent := workerConfig.ConcurrentRunsLimit
+	if concurrent == 0 {
+		concurrent = runtime.NumCPU()
+	}
+	worker := validator.NewValidationWorker(validator.NewNitroMachineLoader(machineConfig), concurrent)
+
+	sigint := make(chan os.Signal, 1)
+	signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	log.Info("Starting validation worker", "addr", workerConfig.RPCAddr, "port", workerConfig.RPCPort, "concurrent", concurrent)
+	rpcServer, err := validator.StartValidationWorkerServer(ctx, workerConfig.RPCAddr, workerConfig.RPCPort, workerConfig.RPCServerTimeouts, worker)
+	if err != nil {
+		return err
+	}
+
+	<-sigint
+	return rpcServer.Shutdown(ctx)
+}
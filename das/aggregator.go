@@ -10,12 +10,16 @@ import (
 	"fmt"
 	"math/bits"
 	"os"
+	"sort"
+	"sync"
 	"time"
 
+	"github.com/tenderly/nitro/go-ethereum/accounts/abi/bind"
 	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
 	"github.com/tenderly/nitro/arbutil"
 	"github.com/tenderly/nitro/das/dastree"
 	"github.com/tenderly/nitro/solgen/go/bridgegen"
+	"github.com/tenderly/nitro/util/l1client"
 	"github.com/tenderly/nitro/util/pretty"
 
 	"github.com/tenderly/nitro/go-ethereum/common"
@@ -30,32 +34,116 @@ type AggregatorConfig struct {
 	Enable        bool   `koanf:"enable"`
 	AssumedHonest int    `koanf:"assumed-honest"`
 	Backends      string `koanf:"backends"`
+	Keysets       string `koanf:"keysets"`
 	DumpKeyset    bool   `koanf:"dump-keyset"`
+	// Stores larger than this are sent to RPC backends in chunks of this size
+	MaxStoreChunkSize int `koanf:"max-store-chunk-size"`
 }
 
 var DefaultAggregatorConfig = AggregatorConfig{
-	AssumedHonest: 0,
-	Backends:      "",
-	DumpKeyset:    false,
+	AssumedHonest:     0,
+	Backends:          "",
+	Keysets:           "",
+	DumpKeyset:        false,
+	MaxStoreChunkSize: 1024 * 1024,
 }
 
 func AggregatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultAggregatorConfig.Enable, "enable storage/retrieval of sequencer batch data from a list of RPC endpoints; this should only be used by the batch poster and not in combination with other DAS storage types")
 	f.Int(prefix+".assumed-honest", DefaultAggregatorConfig.AssumedHonest, "Number of assumed honest backends (H). If there are N backends, K=N+1-H valid responses are required to consider an Store request to be successful.")
 	f.String(prefix+".backends", DefaultAggregatorConfig.Backends, "JSON RPC backend configuration")
+	f.String(prefix+".keysets", DefaultAggregatorConfig.Keysets, "JSON list of additional keysets, each with its own assumed-honest, backends, and optional valid-from and valid-until unix times; the newest keyset valid on L1 is used for each store")
 	f.Bool(prefix+".dump-keyset", DefaultAggregatorConfig.DumpKeyset, "Dump the keyset encoded in hexadecimal for the backends string")
+	f.Int(prefix+".max-store-chunk-size", DefaultAggregatorConfig.MaxStoreChunkSize, "stores larger than this many bytes are uploaded to the backends in chunks of this size (0 to always send the whole batch in one request)")
 }
 
 type Aggregator struct {
 	config   AggregatorConfig
-	services []ServiceDetails
+	services []ServiceDetails // the distinct services of all keysets, used for retrieval
+	keysets  []*aggregatorKeyset
+	// services with a different key in some keysets, which have to be told which key to sign with
+	multiKeyServices map[DataAvailabilityService]bool
+
+	seqInboxCaller *bridgegen.SequencerInboxCaller
+	bpVerifier     *BatchPosterVerifier
+	l1Client       *l1client.Client // if connected to by NewMultiKeysetAggregator, stopped by Close
+
+	keysetValidityMutex sync.Mutex
+	keysetValidity      map[[32]byte]keysetValidity
+}
+
+// AggregatorKeyset is a committee the Aggregator can store to.
+// While rotating committee members, several keysets may be configured,
+// and each store uses the newest keyset that's valid on L1.
+type AggregatorKeyset struct {
+	Services      []ServiceDetails
+	AssumedHonest int
+	// The keyset is only used between these times, which are ignored if zero
+	ValidFrom  time.Time
+	ValidUntil time.Time
+}
+
+type aggregatorKeyset struct {
+	AggregatorKeyset
 
 	// calculated fields
 	requiredServicesForStore       int
 	maxAllowedServiceStoreFailures int
 	keysetHash                     [32]byte
 	keysetBytes                    []byte
-	bpVerifier                     *BatchPosterVerifier
+}
+
+type keysetValidity struct {
+	valid   bool
+	checked time.Time
+}
+
+// How long the L1 validity of a keyset is cached for
+var keysetValidityLifetime = time.Minute
+
+func newAggregatorKeyset(ks AggregatorKeyset) (*aggregatorKeyset, error) {
+	var aggSignersMask uint64
+	pubKeys := []blsSignatures.PublicKey{}
+	for _, d := range ks.Services {
+		if bits.OnesCount64(d.signersMask) != 1 {
+			return nil, fmt.Errorf("Tried to configure backend DAS %v with invalid signersMask %X", d.service, d.signersMask)
+		}
+		aggSignersMask |= d.signersMask
+		pubKeys = append(pubKeys, d.pubKey)
+	}
+	if bits.OnesCount64(aggSignersMask) != len(ks.Services) {
+		return nil, errors.New("At least two signers share a mask")
+	}
+
+	keyset := &arbstate.DataAvailabilityKeyset{
+		AssumedHonest: uint64(ks.AssumedHonest),
+		PubKeys:       pubKeys,
+	}
+	ksBuf := bytes.NewBuffer([]byte{})
+	if err := keyset.Serialize(ksBuf); err != nil {
+		return nil, err
+	}
+	keysetHash, err := keyset.Hash()
+	if err != nil {
+		return nil, err
+	}
+	return &aggregatorKeyset{
+		AggregatorKeyset:               ks,
+		requiredServicesForStore:       len(ks.Services) + 1 - ks.AssumedHonest,
+		maxAllowedServiceStoreFailures: ks.AssumedHonest - 1,
+		keysetHash:                     keysetHash,
+		keysetBytes:                    ksBuf.Bytes(),
+	}, nil
+}
+
+func (ks *aggregatorKeyset) activeAt(t time.Time) bool {
+	if !ks.ValidFrom.IsZero() && t.Before(ks.ValidFrom) {
+		return false
+	}
+	if !ks.ValidUntil.IsZero() && !t.Before(ks.ValidUntil) {
+		return false
+	}
+	return true
 }
 
 type ServiceDetails struct {
@@ -79,22 +167,36 @@ func NewServiceDetails(service DataAvailabilityService, pubKey blsSignatures.Pub
 	}, nil
 }
 
+func singleKeyset(config AggregatorConfig, services []ServiceDetails) []AggregatorKeyset {
+	return []AggregatorKeyset{{Services: services, AssumedHonest: config.AssumedHonest}}
+}
+
 func NewAggregator(ctx context.Context, config DataAvailabilityConfig, services []ServiceDetails) (*Aggregator, error) {
+	return NewMultiKeysetAggregator(ctx, config, singleKeyset(config.AggregatorConfig, services))
+}
+
+func NewMultiKeysetAggregator(ctx context.Context, config DataAvailabilityConfig, keysets []AggregatorKeyset) (*Aggregator, error) {
 	if config.L1NodeURL == "none" {
-		return NewAggregatorWithSeqInboxCaller(config.AggregatorConfig, services, nil)
+		return NewMultiKeysetAggregatorWithSeqInboxCaller(config.AggregatorConfig, keysets, nil)
 	}
-	l1client, err := GetL1Client(ctx, config.L1ConnectionAttempts, config.L1NodeURL)
+	seqInboxAddress, err := OptionalAddressFromString(config.SequencerInboxAddress)
 	if err != nil {
 		return nil, err
 	}
-	seqInboxAddress, err := OptionalAddressFromString(config.SequencerInboxAddress)
+	if seqInboxAddress == nil {
+		return NewMultiKeysetAggregatorWithSeqInboxCaller(config.AggregatorConfig, keysets, nil)
+	}
+	l1client, err := GetL1Client(ctx, config.L1ConnectionAttempts, config.L1NodeURL, &config.L1Client)
 	if err != nil {
 		return nil, err
 	}
-	if seqInboxAddress == nil {
-		return NewAggregatorWithSeqInboxCaller(config.AggregatorConfig, services, nil)
+	aggregator, err := NewMultiKeysetAggregatorWithL1Info(config.AggregatorConfig, keysets, l1client, *seqInboxAddress)
+	if err != nil {
+		l1client.StopAndWait()
+		return nil, err
 	}
-	return NewAggregatorWithL1Info(config.AggregatorConfig, services, l1client, *seqInboxAddress)
+	aggregator.l1Client = l1client
+	return aggregator, nil
 }
 
 func NewAggregatorWithL1Info(
@@ -102,12 +204,21 @@ func NewAggregatorWithL1Info(
 	services []ServiceDetails,
 	l1client arbutil.L1Interface,
 	seqInboxAddress common.Address,
+) (*Aggregator, error) {
+	return NewMultiKeysetAggregatorWithL1Info(config, singleKeyset(config, services), l1client, seqInboxAddress)
+}
+
+func NewMultiKeysetAggregatorWithL1Info(
+	config AggregatorConfig,
+	keysets []AggregatorKeyset,
+	l1client arbutil.L1Interface,
+	seqInboxAddress common.Address,
 ) (*Aggregator, error) {
 	seqInboxCaller, err := bridgegen.NewSequencerInboxCaller(seqInboxAddress, l1client)
 	if err != nil {
 		return nil, err
 	}
-	return NewAggregatorWithSeqInboxCaller(config, services, seqInboxCaller)
+	return NewMultiKeysetAggregatorWithSeqInboxCaller(config, keysets, seqInboxCaller)
 }
 
 func NewAggregatorWithSeqInboxCaller(
@@ -115,34 +226,47 @@ func NewAggregatorWithSeqInboxCaller(
 	services []ServiceDetails,
 	seqInboxCaller *bridgegen.SequencerInboxCaller,
 ) (*Aggregator, error) {
-	var aggSignersMask uint64
-	pubKeys := []blsSignatures.PublicKey{}
-	for _, d := range services {
-		if bits.OnesCount64(d.signersMask) != 1 {
-			return nil, fmt.Errorf("Tried to configure backend DAS %v with invalid signersMask %X", d.service, d.signersMask)
-		}
-		aggSignersMask |= d.signersMask
-		pubKeys = append(pubKeys, d.pubKey)
-	}
-	if bits.OnesCount64(aggSignersMask) != len(services) {
-		return nil, errors.New("At least two signers share a mask")
-	}
+	return NewMultiKeysetAggregatorWithSeqInboxCaller(config, singleKeyset(config, services), seqInboxCaller)
+}
 
-	keyset := &arbstate.DataAvailabilityKeyset{
-		AssumedHonest: uint64(config.AssumedHonest),
-		PubKeys:       pubKeys,
-	}
-	ksBuf := bytes.NewBuffer([]byte{})
-	if err := keyset.Serialize(ksBuf); err != nil {
-		return nil, err
+func NewMultiKeysetAggregatorWithSeqInboxCaller(
+	config AggregatorConfig,
+	keysets []AggregatorKeyset,
+	seqInboxCaller *bridgegen.SequencerInboxCaller,
+) (*Aggregator, error) {
+	if len(keysets) == 0 {
+		return nil, errors.New("no DAS keysets configured")
 	}
-	keysetHash, err := keyset.Hash()
-	if err != nil {
-		return nil, err
+	var services []ServiceDetails
+	var aggKeysets []*aggregatorKeyset
+	serviceKeys := make(map[DataAvailabilityService][]byte)
+	multiKeyServices := make(map[DataAvailabilityService]bool)
+	for _, ks := range keysets {
+		aggKeyset, err := newAggregatorKeyset(ks)
+		if err != nil {
+			return nil, err
+		}
+		aggKeysets = append(aggKeysets, aggKeyset)
+		for _, d := range ks.Services {
+			pubKey := blsSignatures.PublicKeyToBytes(d.pubKey)
+			if key, ok := serviceKeys[d.service]; !ok {
+				serviceKeys[d.service] = pubKey
+				services = append(services, d)
+			} else if !bytes.Equal(key, pubKey) {
+				multiKeyServices[d.service] = true
+			}
+		}
 	}
+	// prefer newer keysets
+	sort.SliceStable(aggKeysets, func(i, j int) bool {
+		return aggKeysets[i].ValidFrom.After(aggKeysets[j].ValidFrom)
+	})
+
 	if config.DumpKeyset {
-		fmt.Printf("Keyset: %s\n", hexutil.Encode(ksBuf.Bytes()))
-		fmt.Printf("KeysetHash: %s\n", hexutil.Encode(keysetHash[:]))
+		for _, ks := range aggKeysets {
+			fmt.Printf("Keyset: %s\n", hexutil.Encode(ks.keysetBytes))
+			fmt.Printf("KeysetHash: %s\n", hexutil.Encode(ks.keysetHash[:]))
+		}
 		os.Exit(0)
 	}
 
@@ -152,16 +276,65 @@ func NewAggregatorWithSeqInboxCaller(
 	}
 
 	return &Aggregator{
-		config:                         config,
-		services:                       services,
-		requiredServicesForStore:       len(services) + 1 - config.AssumedHonest,
-		maxAllowedServiceStoreFailures: config.AssumedHonest - 1,
-		keysetHash:                     keysetHash,
-		keysetBytes:                    ksBuf.Bytes(),
-		bpVerifier:                     bpVerifier,
+		config:           config,
+		services:         services,
+		keysets:          aggKeysets,
+		multiKeyServices: multiKeyServices,
+		seqInboxCaller:   seqInboxCaller,
+		bpVerifier:       bpVerifier,
+		keysetValidity:   make(map[[32]byte]keysetValidity),
 	}, nil
 }
 
+func (a *Aggregator) isValidKeysetOnL1(ctx context.Context, keysetHash [32]byte) (bool, error) {
+	a.keysetValidityMutex.Lock()
+	cached, ok := a.keysetValidity[keysetHash]
+	a.keysetValidityMutex.Unlock()
+	if ok && time.Since(cached.checked) < keysetValidityLifetime {
+		return cached.valid, nil
+	}
+	valid, err := a.seqInboxCaller.IsValidKeysetHash(&bind.CallOpts{Context: ctx}, keysetHash)
+	if err != nil {
+		return false, err
+	}
+	a.keysetValidityMutex.Lock()
+	a.keysetValidity[keysetHash] = keysetValidity{valid: valid, checked: time.Now()}
+	a.keysetValidityMutex.Unlock()
+	return valid, nil
+}
+
+// currentKeyset returns the newest keyset within its validity window which the L1 SequencerInbox accepts.
+// If L1 doesn't accept any of them, or isn't known, the newest keyset within its validity window is used.
+func (a *Aggregator) currentKeyset(ctx context.Context) (*aggregatorKeyset, error) {
+	now := time.Now()
+	var fallback *aggregatorKeyset
+	for _, ks := range a.keysets {
+		if !ks.activeAt(now) {
+			continue
+		}
+		if a.seqInboxCaller == nil {
+			return ks, nil
+		}
+		if fallback == nil {
+			fallback = ks
+		}
+		valid, err := a.isValidKeysetOnL1(ctx, ks.keysetHash)
+		if err != nil {
+			return nil, err
+		}
+		if valid {
+			return ks, nil
+		}
+	}
+	if fallback == nil {
+		return nil, errors.New("no DAS keyset is currently within its validity window")
+	}
+	if len(a.keysets) > 1 {
+		log.Warn("no configured DAS keyset is valid on L1, using the newest one", "keysetHash", hexutil.Encode(fallback.keysetHash[:]))
+	}
+	return fallback, nil
+}
+
 func (a *Aggregator) GetByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
 	// Query all services, even those that didn't sign.
 	// They may have been late in returning a response after storing the data,
@@ -209,6 +382,10 @@ type storeResponse struct {
 	err     error
 }
 
+func (a *Aggregator) AuthorizeChunkedStore(ctx context.Context, chunkHashes []common.Hash, totalSize uint64, timeout uint64, sig []byte) (common.Address, error) {
+	return authorizeChunkedStore(ctx, a.bpVerifier, chunkHashes, totalSize, timeout, sig)
+}
+
 // Store calls Store on each backend DAS in parallel and collects responses.
 // If there were at least K responses then it aggregates the signatures and
 // signersMasks from each DAS together into the DataAvailabilityCertificate
@@ -243,12 +420,22 @@ func (a *Aggregator) Store(ctx context.Context, message []byte, timeout uint64,
 		}
 	}
 
-	responses := make(chan storeResponse, len(a.services))
+	keyset, err := a.currentKeyset(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make(chan storeResponse, len(keyset.Services))
 
 	expectedHash := dastree.Hash(message)
-	for _, d := range a.services {
+	for _, d := range keyset.Services {
 		go func(ctx context.Context, d ServiceDetails) {
-			cert, err := d.service.Store(ctx, message, timeout, sig)
+			// members rotating their keys hold more than one, so ask for the key in this keyset
+			storeCtx := ctx
+			if a.multiKeyServices[d.service] {
+				storeCtx = WithSigningPubKey(ctx, d.pubKey)
+			}
+			cert, err := d.service.Store(storeCtx, message, timeout, sig)
 			if err != nil {
 				responses <- storeResponse{d, nil, err}
 				return
@@ -287,7 +474,7 @@ func (a *Aggregator) Store(ctx context.Context, message []byte, timeout uint64,
 	var aggSignersMask uint64
 	var storeFailures, successfullyStoredCount int
 	var errs []error
-	for i := 0; i < len(a.services) && storeFailures <= a.maxAllowedServiceStoreFailures && successfullyStoredCount < a.requiredServicesForStore; i++ {
+	for i := 0; i < len(keyset.Services) && storeFailures <= keyset.maxAllowedServiceStoreFailures && successfullyStoredCount < keyset.requiredServicesForStore; i++ {
 		select {
 		case <-ctx.Done():
 			break
@@ -305,8 +492,8 @@ func (a *Aggregator) Store(ctx context.Context, message []byte, timeout uint64,
 		}
 	}
 
-	if successfullyStoredCount < a.requiredServicesForStore {
-		return nil, fmt.Errorf("Aggregator failed to store message to at least %d out of %d DASes (assuming %d are honest), errors received %d, %v", a.requiredServicesForStore, len(a.services), a.config.AssumedHonest, storeFailures, errs)
+	if successfullyStoredCount < keyset.requiredServicesForStore {
+		return nil, fmt.Errorf("Aggregator failed to store message to at least %d out of %d DASes (assuming %d are honest), errors received %d, %v", keyset.requiredServicesForStore, len(keyset.Services), keyset.AssumedHonest, storeFailures, errs)
 	}
 
 	aggCert.Sig = blsSignatures.AggregateSignatures(sigs)
@@ -314,7 +501,7 @@ func (a *Aggregator) Store(ctx context.Context, message []byte, timeout uint64,
 	aggCert.SignersMask = aggSignersMask
 	aggCert.DataHash = expectedHash
 	aggCert.Timeout = timeout
-	aggCert.KeysetHash = a.keysetHash
+	aggCert.KeysetHash = keyset.keysetHash
 	aggCert.Version = 1
 
 	verified, err := blsSignatures.VerifySignature(aggCert.Sig, aggCert.SerializeSignableFields(), aggPubKey)
@@ -327,6 +514,14 @@ func (a *Aggregator) Store(ctx context.Context, message []byte, timeout uint64,
 	return &aggCert, nil
 }
 
+// Close stops the L1 client connected to by NewMultiKeysetAggregator, if any
+func (a *Aggregator) Close(ctx context.Context) error {
+	if a.l1Client != nil {
+		a.l1Client.StopAndWait()
+	}
+	return nil
+}
+
 func (a *Aggregator) String() string {
 	var b bytes.Buffer
 	b.WriteString("das.Aggregator{")
@@ -6,6 +6,7 @@ package das
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -18,6 +19,7 @@ import (
 	"github.com/tenderly/nitro/go-ethereum/common"
 	"github.com/tenderly/nitro/go-ethereum/log"
 	"github.com/tenderly/nitro/arbstate"
+	"github.com/tenderly/nitro/blsSignatures"
 )
 
 func TestDAS_BasicAggregationLocal(t *testing.T) {
@@ -394,3 +396,87 @@ func TestDAS_RetrieveFailureFromAllDASes(t *testing.T) {
 		testConfigurableRetrieveFailures(t, true)
 	}
 }
+
+func TestDAS_MultiKeysetRotation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// member 0 is rotating its key, and holds both the old and new one
+	newPubKey, newPrivKey, err := blsSignatures.GenerateKeys()
+	Require(t, err)
+	newPrivKeyEncoded := base64.StdEncoding.EncodeToString(blsSignatures.PrivateKeyToBytes(newPrivKey))
+
+	var members []ServiceDetails
+	for i := 0; i < 3; i++ {
+		dbPath := t.TempDir()
+		pubKey, _, err := GenerateAndStoreKeys(dbPath)
+		Require(t, err)
+		config := DataAvailabilityConfig{
+			Enable: true,
+			KeyConfig: KeyConfig{
+				KeyDir: dbPath,
+			},
+			LocalFileStorageConfig: LocalFileStorageConfig{
+				Enable:  true,
+				DataDir: dbPath,
+			},
+			L1NodeURL: "none",
+		}
+		if i == 0 {
+			config.KeyConfig.ExtraPrivKeys = []string{newPrivKeyEncoded}
+		}
+		storageService, lifecycleManager, err := CreatePersistentStorageService(ctx, &config)
+		Require(t, err)
+		defer lifecycleManager.StopAndWaitUntil(time.Second)
+		das, err := NewSignAfterStoreDAS(ctx, config, storageService)
+		Require(t, err)
+		members = append(members, ServiceDetails{service: das, pubKey: *pubKey})
+	}
+	member := func(i int, mask uint64) ServiceDetails {
+		d := members[i]
+		d.signersMask = mask
+		return d
+	}
+	rotated := member(0, 1)
+	rotated.pubKey = newPubKey
+
+	oldKeyset := AggregatorKeyset{Services: []ServiceDetails{member(0, 1), member(1, 2)}, AssumedHonest: 1}
+	newKeyset := AggregatorKeyset{Services: []ServiceDetails{rotated, member(2, 2)}, AssumedHonest: 1, ValidFrom: time.Now().Add(-time.Hour)}
+	futureKeyset := AggregatorKeyset{Services: []ServiceDetails{member(1, 1), member(2, 2)}, AssumedHonest: 1, ValidFrom: time.Now().Add(time.Hour)}
+
+	storeWith := func(keysets []AggregatorKeyset, expected AggregatorKeyset) {
+		t.Helper()
+		aggregator, err := NewMultiKeysetAggregator(ctx, DataAvailabilityConfig{L1NodeURL: "none"}, keysets)
+		Require(t, err)
+		// only the rotated member is told which key to sign with
+		if len(aggregator.services) != len(members) || len(aggregator.multiKeyServices) != 1 || !aggregator.multiKeyServices[members[0].service] {
+			Fail(t, "unexpected services", len(aggregator.services), len(aggregator.multiKeyServices))
+		}
+		rawMsg := []byte("Rotating the committee, one key at a time.")
+		cert, err := aggregator.Store(ctx, rawMsg, 0, []byte{})
+		Require(t, err)
+		expectedKeyset, err := newAggregatorKeyset(expected)
+		Require(t, err)
+		if cert.KeysetHash != expectedKeyset.keysetHash {
+			Fail(t, "certificate signed by the wrong keyset")
+		}
+		messageRetrieved, err := aggregator.GetByHash(ctx, cert.DataHash)
+		Require(t, err)
+		if !bytes.Equal(rawMsg, messageRetrieved) {
+			Fail(t, "Retrieved message is not the same as stored one.")
+		}
+	}
+
+	// the newest keyset in its validity window is used, with member 0 signing with its new key
+	storeWith([]AggregatorKeyset{oldKeyset, newKeyset, futureKeyset}, newKeyset)
+
+	// once the new keyset expires, the old one is used again
+	newKeyset.ValidUntil = time.Now().Add(-time.Minute)
+	storeWith([]AggregatorKeyset{oldKeyset, newKeyset, futureKeyset}, oldKeyset)
+
+	// a member can't sign with a key it doesn't have
+	_, err = members[1].service.Store(WithSigningPubKey(ctx, newPubKey), []byte("message"), 0, []byte{})
+	if err == nil {
+		Fail(t, "store signed with a key the DAS doesn't have")
+	}
+}
@@ -64,6 +64,10 @@ func (a *CacheStorageToDASAdapter) Store(
 	return cert, nil
 }
 
+func (a *CacheStorageToDASAdapter) AuthorizeChunkedStore(ctx context.Context, chunkHashes []common.Hash, totalSize uint64, timeout uint64, sig []byte) (common.Address, error) {
+	return AuthorizeChunkedStore(ctx, a.DataAvailabilityService, chunkHashes, totalSize, timeout, sig)
+}
+
 func (a *CacheStorageToDASAdapter) String() string {
 	return fmt.Sprintf("CacheStorageToDASAdapter{inner: %v, cache: %v}", a.DataAvailabilityService, a.cache)
 }
@@ -91,6 +91,10 @@ func (this *ChainFetchDAS) GetByHash(ctx context.Context, hash common.Hash) ([]b
 	return chainFetchGetByHash(ctx, this.DataAvailabilityService, &this.keysetCache, this.seqInboxCaller, this.seqInboxFilterer, hash)
 }
 
+func (this *ChainFetchDAS) AuthorizeChunkedStore(ctx context.Context, chunkHashes []common.Hash, totalSize uint64, timeout uint64, sig []byte) (common.Address, error) {
+	return AuthorizeChunkedStore(ctx, this.DataAvailabilityService, chunkHashes, totalSize, timeout, sig)
+}
+
 func (this *ChainFetchReader) GetByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
 	log.Trace("das.ChainFetchReader.GetByHash", "hash", pretty.PrettyHash(hash))
 	return chainFetchGetByHash(ctx, this.DataAvailabilityReader, &this.keysetCache, this.seqInboxCaller, this.seqInboxFilterer, hash)
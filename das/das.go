@@ -12,12 +12,12 @@ import (
 	"time"
 
 	"github.com/tenderly/nitro/go-ethereum/common"
-	"github.com/tenderly/nitro/go-ethereum/ethclient"
 	"github.com/tenderly/nitro/go-ethereum/log"
 	flag "github.com/spf13/pflag"
 
 	"github.com/tenderly/nitro/arbstate"
 	"github.com/tenderly/nitro/blsSignatures"
+	"github.com/tenderly/nitro/util/l1client"
 )
 
 type DataAvailabilityServiceWriter interface {
@@ -48,9 +48,10 @@ type DataAvailabilityConfig struct {
 	AggregatorConfig              AggregatorConfig              `koanf:"rpc-aggregator"`
 	RestfulClientAggregatorConfig RestfulClientAggregatorConfig `koanf:"rest-aggregator"`
 
-	L1NodeURL             string `koanf:"l1-node-url"`
-	L1ConnectionAttempts  int    `koanf:"l1-connection-attempts"`
-	SequencerInboxAddress string `koanf:"sequencer-inbox-address"`
+	L1NodeURL             string          `koanf:"l1-node-url"`
+	L1ConnectionAttempts  int             `koanf:"l1-connection-attempts"`
+	L1Client              l1client.Config `koanf:"l1-client"`
+	SequencerInboxAddress string          `koanf:"sequencer-inbox-address"`
 
 	PanicOnError             bool `koanf:"panic-on-error"`
 	DisableSignatureChecking bool `koanf:"disable-signature-checking"`
@@ -61,6 +62,7 @@ var DefaultDataAvailabilityConfig = DataAvailabilityConfig{
 	Enable:                        false,
 	RestfulClientAggregatorConfig: DefaultRestfulClientAggregatorConfig,
 	L1ConnectionAttempts:          15,
+	L1Client:                      l1client.DefaultConfig,
 	PanicOnError:                  false,
 }
 
@@ -103,6 +105,7 @@ func DataAvailabilityConfigAddOptions(prefix string, f *flag.FlagSet) {
 
 	f.String(prefix+".l1-node-url", DefaultDataAvailabilityConfig.L1NodeURL, "URL for L1 node, only used in standalone daserver; when running as part of a node that node's L1 configuration is used")
 	f.Int(prefix+".l1-connection-attempts", DefaultDataAvailabilityConfig.L1ConnectionAttempts, "layer 1 RPC connection attempts (spaced out at least 1 second per attempt, 0 to retry infinitely), only used in standalone daserver; when running as part of a node that node's L1 configuration is used")
+	l1client.ConfigAddOptions(prefix+".l1-client", f)
 	f.String(prefix+".sequencer-inbox-address", DefaultDataAvailabilityConfig.SequencerInboxAddress, "L1 address of SequencerInbox contract")
 }
 
@@ -125,15 +128,18 @@ func Serialize(c *arbstate.DataAvailabilityCertificate) []byte {
 	return append(buf, blsSignatures.SignatureToBytes(c.Sig)...)
 }
 
-func GetL1Client(ctx context.Context, maxConnectionAttempts int, l1URL string) (*ethclient.Client, error) {
+// GetL1Client connects to the L1 node and its fallbacks, checking their health in the background until the context is done.
+// The caller owns the client, and has to StopAndWait it once done with it.
+func GetL1Client(ctx context.Context, maxConnectionAttempts int, l1URL string, clientConfig *l1client.Config) (*l1client.Client, error) {
 	if maxConnectionAttempts <= 0 {
 		maxConnectionAttempts = math.MaxInt
 	}
-	var l1Client *ethclient.Client
+	var l1Client *l1client.Client
 	var err error
 	for i := 1; i <= maxConnectionAttempts; i++ {
-		l1Client, err = ethclient.DialContext(ctx, l1URL)
+		l1Client, err = l1client.New(ctx, l1URL, clientConfig)
 		if err == nil {
+			l1Client.Start(ctx)
 			return l1Client, nil
 		}
 		log.Warn("error connecting to L1", "err", err)
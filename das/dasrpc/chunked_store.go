@@ -0,0 +1,206 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package dasrpc
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/crypto"
+
+	"github.com/tenderly/nitro/util/arbmath"
+)
+
+const (
+	// Uploads that haven't received a chunk for this long are discarded
+	chunkedStoreExpiry        = 10 * time.Minute
+	chunkedStorePruneInterval = time.Minute
+	// Limits on the memory held by unfinished uploads
+	maxPendingChunkedStores          = 16
+	maxPendingChunkedStoresPerSigner = 4
+	maxChunkedStoreSize              = 64 * 1024 * 1024
+	maxChunksPerStore                = 4096
+)
+
+// chunkedStore is an upload in progress, which is stored once all its chunks are received
+type chunkedStore struct {
+	chunkHashes   []common.Hash
+	chunks        [][]byte
+	missingChunks int
+	receivedBytes uint64
+	totalSize     uint64
+	timeout       uint64
+	sig           []byte
+	signingPubKey []byte         // empty unless the client asked for a specific signing key
+	signer        common.Address // who signed the start of the upload
+	lastUpdated   time.Time
+}
+
+type chunkedStores struct {
+	mutex  sync.Mutex
+	stores map[common.Hash]*chunkedStore
+}
+
+func newChunkedStores() *chunkedStores {
+	return &chunkedStores{stores: make(map[common.Hash]*chunkedStore)}
+}
+
+// chunkedStoreId derives the batch id from the upload parameters,
+// so restarting the same upload resumes it instead of starting over.
+func chunkedStoreId(chunkHashes []common.Hash, totalSize uint64, timeout uint64, sig []byte, signingPubKey []byte) common.Hash {
+	var buf []byte
+	for _, hash := range chunkHashes {
+		buf = append(buf, hash[:]...)
+	}
+	return crypto.Keccak256Hash(buf, arbmath.UintToBytes(totalSize), arbmath.UintToBytes(timeout), sig, signingPubKey)
+}
+
+// checkChunkedStoreSize rejects uploads larger than the limits before their signature is checked
+func checkChunkedStoreSize(chunkHashes []common.Hash, totalSize uint64) error {
+	if len(chunkHashes) == 0 || len(chunkHashes) > maxChunksPerStore {
+		return fmt.Errorf("chunked store must have between 1 and %d chunks, got %d", maxChunksPerStore, len(chunkHashes))
+	}
+	if totalSize > maxChunkedStoreSize {
+		return fmt.Errorf("chunked store size %d exceeds the maximum of %d", totalSize, maxChunkedStoreSize)
+	}
+	return nil
+}
+
+// start begins an upload, whose signature was already checked and recovered to signer
+func (s *chunkedStores) start(chunkHashes []common.Hash, totalSize uint64, timeout uint64, sig []byte, signingPubKey []byte, signer common.Address) (common.Hash, error) {
+	if err := checkChunkedStoreSize(chunkHashes, totalSize); err != nil {
+		return common.Hash{}, err
+	}
+	id := chunkedStoreId(chunkHashes, totalSize, timeout, sig, signingPubKey)
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pruneLocked(now)
+	if store, ok := s.stores[id]; ok {
+		store.lastUpdated = now
+		return id, nil
+	}
+	if len(s.stores) >= maxPendingChunkedStores {
+		return common.Hash{}, errors.New("too many chunked stores in progress")
+	}
+	signerStores := 0
+	for _, store := range s.stores {
+		if store.signer == signer {
+			signerStores++
+		}
+	}
+	if signerStores >= maxPendingChunkedStoresPerSigner {
+		return common.Hash{}, fmt.Errorf("too many chunked stores in progress from %v", signer)
+	}
+	s.stores[id] = &chunkedStore{
+		chunkHashes:   chunkHashes,
+		chunks:        make([][]byte, len(chunkHashes)),
+		missingChunks: len(chunkHashes),
+		totalSize:     totalSize,
+		timeout:       timeout,
+		sig:           sig,
+		signingPubKey: signingPubKey,
+		signer:        signer,
+		lastUpdated:   now,
+	}
+	return id, nil
+}
+
+// prune discards uploads that haven't received a chunk recently
+func (s *chunkedStores) prune() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.pruneLocked(time.Now())
+}
+
+func (s *chunkedStores) pruneLocked(now time.Time) {
+	for id, store := range s.stores {
+		if now.Sub(store.lastUpdated) > chunkedStoreExpiry {
+			delete(s.stores, id)
+		}
+	}
+}
+
+func (s *chunkedStores) get(id common.Hash) (*chunkedStore, error) {
+	store, ok := s.stores[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown or expired chunked store %v", id)
+	}
+	return store, nil
+}
+
+func (s *chunkedStores) addChunk(id common.Hash, index uint64, chunk []byte) error {
+	hash := crypto.Keccak256Hash(chunk)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	store, err := s.get(id)
+	if err != nil {
+		return err
+	}
+	if index >= uint64(len(store.chunkHashes)) {
+		return fmt.Errorf("chunk index %d out of range, chunked store has %d chunks", index, len(store.chunkHashes))
+	}
+	if hash != store.chunkHashes[index] {
+		return fmt.Errorf("chunk %d has hash %v, expected %v", index, hash, store.chunkHashes[index])
+	}
+	store.lastUpdated = time.Now()
+	if store.chunks[index] != nil {
+		// already received, the client is probably retrying
+		return nil
+	}
+	if store.receivedBytes+uint64(len(chunk)) > store.totalSize {
+		return fmt.Errorf("chunks exceed the chunked store size of %d", store.totalSize)
+	}
+	store.chunks[index] = chunk
+	store.receivedBytes += uint64(len(chunk))
+	store.missingChunks--
+	return nil
+}
+
+func (s *chunkedStores) missing(id common.Hash) ([]uint64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	store, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	missing := []uint64{}
+	for i, chunk := range store.chunks {
+		if chunk == nil {
+			missing = append(missing, uint64(i))
+		}
+	}
+	return missing, nil
+}
+
+// assemble returns the full message once all chunks are received
+func (s *chunkedStores) assemble(id common.Hash) ([]byte, *chunkedStore, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	store, err := s.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if store.missingChunks > 0 {
+		return nil, nil, fmt.Errorf("chunked store is missing %d of %d chunks", store.missingChunks, len(store.chunks))
+	}
+	if store.receivedBytes != store.totalSize {
+		return nil, nil, fmt.Errorf("chunked store received %d bytes, expected %d", store.receivedBytes, store.totalSize)
+	}
+	message := make([]byte, 0, store.totalSize)
+	for _, chunk := range store.chunks {
+		message = append(message, chunk...)
+	}
+	return message, store, nil
+}
+
+func (s *chunkedStores) remove(id common.Hash) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.stores, id)
+}
@@ -5,33 +5,46 @@ package dasrpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/tenderly/nitro/go-ethereum/common"
 	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
+	"github.com/tenderly/nitro/go-ethereum/crypto"
 	"github.com/tenderly/nitro/go-ethereum/log"
 
 	"github.com/tenderly/nitro/go-ethereum/rpc"
 	"github.com/tenderly/nitro/arbstate"
 	"github.com/tenderly/nitro/blsSignatures"
+	"github.com/tenderly/nitro/das"
 	"github.com/tenderly/nitro/das/dastree"
 	"github.com/tenderly/nitro/util/pretty"
 )
 
 type DASRPCClient struct { // implements DataAvailabilityService
-	clnt *rpc.Client
-	url  string
+	clnt              *rpc.Client
+	url               string
+	maxStoreChunkSize int // messages larger than this are stored in chunks, 0 disables chunking
+	// unix nanoseconds when the server last rejected a signing public key, as it predates signing key selection,
+	// or zero if it hasn't, accessed atomically
+	signingPubKeyUnsupportedAt int64
 }
 
-func NewDASRPCClient(target string) (*DASRPCClient, error) {
+// Number of times sending a chunk is attempted before the store fails
+const sendChunkAttempts = 3
+
+func NewDASRPCClient(target string, maxStoreChunkSize int) (*DASRPCClient, error) {
 	clnt, err := rpc.Dial(target)
 	if err != nil {
 		return nil, err
 	}
 	return &DASRPCClient{
-		clnt: clnt,
-		url:  target,
+		clnt:              clnt,
+		url:               target,
+		maxStoreChunkSize: maxStoreChunkSize,
 	}, nil
 }
 
@@ -51,10 +64,115 @@ func (c *DASRPCClient) GetByHash(ctx context.Context, hash common.Hash) ([]byte,
 
 func (c *DASRPCClient) Store(ctx context.Context, message []byte, timeout uint64, reqSig []byte) (*arbstate.DataAvailabilityCertificate, error) {
 	log.Trace("das.DASRPCClient.Store(...)", "message", pretty.FirstFewBytes(message), "timeout", time.Unix(int64(timeout), 0), "sig", pretty.FirstFewBytes(reqSig), "this", *c)
+	if c.maxStoreChunkSize > 0 && len(message) > c.maxStoreChunkSize {
+		cert, err := c.chunkedStore(ctx, message, timeout, reqSig)
+		var rpcErr rpc.Error
+		if !errors.As(err, &rpcErr) || (rpcErr.ErrorCode() != methodNotFoundCode && rpcErr.ErrorCode() != chunkedStoreNotSignedCode) {
+			return cert, err
+		}
+		log.Warn("DAS server didn't accept a chunked store, sending the whole message", "url", c.url, "err", err)
+	}
 	var ret StoreResult
-	if err := c.clnt.CallContext(ctx, &ret, "das_store", hexutil.Bytes(message), hexutil.Uint64(timeout), hexutil.Bytes(reqSig)); err != nil {
+	if err := c.callWithSigningPubKey(ctx, &ret, "das_store", hexutil.Bytes(message), hexutil.Uint64(timeout), hexutil.Bytes(reqSig)); err != nil {
 		return nil, err
 	}
+	return storeResultToCert(&ret)
+}
+
+const (
+	methodNotFoundCode        = -32601
+	invalidParamsCode         = -32602
+	chunkedStoreNotSignedCode = -32010
+)
+
+// How long the signing public key isn't sent to a server that rejected it, before trying again in case it was upgraded
+var signingPubKeyReprobeInterval = 10 * time.Minute
+
+// isExtraParamRejected is whether the server rejected a call for having more params than the method takes,
+// rather than for an invalid value of one it does take
+func isExtraParamRejected(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr) && rpcErr.ErrorCode() == invalidParamsCode && strings.Contains(err.Error(), "too many arguments")
+}
+
+// callWithSigningPubKey makes a store call, passing the signing public key requested with das.WithSigningPubKey if there is one.
+// Servers that predate signing key selection reject the extra param, in which case the call is retried without it,
+// and the key isn't sent to the server again until the reprobe interval has passed.
+func (c *DASRPCClient) callWithSigningPubKey(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	pubKey, ok := das.SigningPubKeyFromContext(ctx)
+	unsupportedAt := atomic.LoadInt64(&c.signingPubKeyUnsupportedAt)
+	if !ok || (unsupportedAt != 0 && time.Since(time.Unix(0, unsupportedAt)) < signingPubKeyReprobeInterval) {
+		return c.clnt.CallContext(ctx, result, method, args...)
+	}
+	err := c.clnt.CallContext(ctx, result, method, append(args, hexutil.Bytes(blsSignatures.PublicKeyToBytes(pubKey)))...)
+	if !isExtraParamRejected(err) {
+		if err == nil && unsupportedAt != 0 {
+			atomic.CompareAndSwapInt64(&c.signingPubKeyUnsupportedAt, unsupportedAt, 0)
+		}
+		return err
+	}
+	if atomic.SwapInt64(&c.signingPubKeyUnsupportedAt, time.Now().UnixNano()) == 0 {
+		log.Warn("DAS server doesn't support choosing the signing key, storing without it", "url", c.url, "err", err)
+	}
+	return c.clnt.CallContext(ctx, result, method, args...)
+}
+
+// chunkedStore uploads the message in chunks, skipping any chunks the server already has from an earlier attempt
+func (c *DASRPCClient) chunkedStore(ctx context.Context, message []byte, timeout uint64, reqSig []byte) (*arbstate.DataAvailabilityCertificate, error) {
+	var chunks [][]byte
+	var chunkHashes []common.Hash
+	for start := 0; start < len(message); start += c.maxStoreChunkSize {
+		end := start + c.maxStoreChunkSize
+		if end > len(message) {
+			end = len(message)
+		}
+		chunks = append(chunks, message[start:end])
+		chunkHashes = append(chunkHashes, crypto.Keccak256Hash(message[start:end]))
+	}
+
+	// without the batch poster's signer, the server only accepts the upload if it doesn't check signatures
+	var startSig []byte
+	if signer, ok := das.StoreSignerFromContext(ctx); ok {
+		var err error
+		startSig, err = das.ApplyDasChunkedStoreSigner(signer, chunkHashes, uint64(len(message)), timeout)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var started StartChunkedStoreResult
+	err := c.callWithSigningPubKey(ctx, &started, "das_startChunkedStore", chunkHashes, hexutil.Uint64(len(message)), hexutil.Uint64(timeout), hexutil.Bytes(reqSig), hexutil.Bytes(startSig))
+	if err != nil {
+		return nil, err
+	}
+	var missing []hexutil.Uint64
+	if err := c.clnt.CallContext(ctx, &missing, "das_chunkedStoreStatus", started.BatchId); err != nil {
+		return nil, err
+	}
+	log.Trace("das.DASRPCClient.chunkedStore", "batchId", started.BatchId, "chunks", len(chunks), "missing", len(missing))
+	for _, index := range missing {
+		if uint64(index) >= uint64(len(chunks)) {
+			return nil, fmt.Errorf("DAS server requested chunk %d of %d", index, len(chunks))
+		}
+		for attempt := 1; ; attempt++ {
+			err := c.clnt.CallContext(ctx, nil, "das_sendChunk", started.BatchId, index, hexutil.Bytes(chunks[index]))
+			if err == nil {
+				break
+			}
+			if attempt >= sendChunkAttempts || ctx.Err() != nil {
+				return nil, fmt.Errorf("failed to send chunk %d of %d: %w", index, len(chunks), err)
+			}
+			log.Warn("failed to send chunk to DAS server, retrying", "url", c.url, "chunk", index, "err", err)
+		}
+	}
+
+	var ret StoreResult
+	if err := c.clnt.CallContext(ctx, &ret, "das_commitChunkedStore", started.BatchId); err != nil {
+		return nil, err
+	}
+	return storeResultToCert(&ret)
+}
+
+func storeResultToCert(ret *StoreResult) (*arbstate.DataAvailabilityCertificate, error) {
 	respSig, err := blsSignatures.SignatureFromBytes(ret.Sig)
 	if err != nil {
 		return nil, err
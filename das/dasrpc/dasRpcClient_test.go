@@ -0,0 +1,80 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package dasrpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
+	"github.com/tenderly/nitro/go-ethereum/rpc"
+
+	"github.com/tenderly/nitro/blsSignatures"
+	"github.com/tenderly/nitro/das"
+	"github.com/tenderly/nitro/util/testhelpers"
+)
+
+// legacyStoreAPI is a DAS server predating signing key selection
+type legacyStoreAPI struct {
+	calls int32
+}
+
+func (a *legacyStoreAPI) Store(message hexutil.Bytes, timeout hexutil.Uint64, sig hexutil.Bytes) (*StoreResult, error) {
+	atomic.AddInt32(&a.calls, 1)
+	return &StoreResult{}, nil
+}
+
+// mistypedStoreAPI takes a fourth param, but rejects the signing key's value
+type mistypedStoreAPI struct{}
+
+func (a *mistypedStoreAPI) Store(message hexutil.Bytes, timeout hexutil.Uint64, sig hexutil.Bytes, other *hexutil.Uint64) (*StoreResult, error) {
+	return &StoreResult{}, nil
+}
+
+func startTestStoreServer(t *testing.T, api interface{}) *DASRPCClient {
+	server := rpc.NewServer()
+	testhelpers.RequireImpl(t, server.RegisterName("das", api))
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	client, err := NewDASRPCClient(httpServer.URL, 0)
+	testhelpers.RequireImpl(t, err)
+	return client
+}
+
+func TestSigningPubKeyUnsupported(t *testing.T) {
+	defer func(interval time.Duration) { signingPubKeyReprobeInterval = interval }(signingPubKeyReprobeInterval)
+	pubKey, _, err := blsSignatures.GenerateKeys()
+	testhelpers.RequireImpl(t, err)
+	ctx := das.WithSigningPubKey(context.Background(), pubKey)
+	store := func(client *DASRPCClient) error {
+		var result StoreResult
+		return client.callWithSigningPubKey(ctx, &result, "das_store", hexutil.Bytes("message"), hexutil.Uint64(0), hexutil.Bytes{})
+	}
+
+	legacy := &legacyStoreAPI{}
+	client := startTestStoreServer(t, legacy)
+	testhelpers.RequireImpl(t, store(client))
+	if atomic.LoadInt32(&legacy.calls) != 1 || atomic.LoadInt64(&client.signingPubKeyUnsupportedAt) == 0 {
+		testhelpers.FailImpl(t, "store wasn't retried without the signing key")
+	}
+	// the key isn't sent again until the reprobe interval passes
+	testhelpers.RequireImpl(t, store(client))
+	signingPubKeyReprobeInterval = 0
+	testhelpers.RequireImpl(t, store(client))
+	if atomic.LoadInt32(&legacy.calls) != 3 {
+		testhelpers.FailImpl(t, "unexpected stores", legacy.calls)
+	}
+
+	// other invalid params aren't taken as the signing key being unsupported
+	client = startTestStoreServer(t, &mistypedStoreAPI{})
+	if err := store(client); err == nil {
+		testhelpers.FailImpl(t, "expected the invalid signing key param to be rejected")
+	}
+	if atomic.LoadInt64(&client.signingPubKeyUnsupportedAt) != 0 {
+		testhelpers.FailImpl(t, "signing key marked unsupported for an invalid param")
+	}
+}
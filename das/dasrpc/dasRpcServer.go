@@ -5,6 +5,7 @@ package dasrpc
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -34,6 +35,9 @@ var (
 	rpcStoreFailureGauge     = metrics.NewRegisteredGauge("arb/das/rpc/store/failure", nil)
 	rpcStoreStoredBytesGauge = metrics.NewRegisteredGauge("arb/das/rpc/store/bytes", nil)
 
+	rpcSendChunkRequestGauge = metrics.NewRegisteredGauge("arb/das/rpc/sendchunk/requests", nil)
+	rpcSendChunkFailureGauge = metrics.NewRegisteredGauge("arb/das/rpc/sendchunk/failure", nil)
+
 	// This histogram is set with the default parameters of go-ethereum/metrics/Timer.
 	// If requests are infrequent, then the reservoir size parameter can be adjusted
 	// downwards to make a smaller window of samples that are included. The alpha parameter
@@ -45,7 +49,8 @@ var (
 )
 
 type DASRPCServer struct {
-	localDAS das.DataAvailabilityService
+	localDAS      das.DataAvailabilityService
+	chunkedStores *chunkedStores
 }
 
 func StartDASRPCServer(ctx context.Context, addr string, portNum uint64, rpcServerTimeouts genericconf.HTTPServerTimeoutConfig, localDAS das.DataAvailabilityService) (*http.Server, error) {
@@ -58,7 +63,8 @@ func StartDASRPCServer(ctx context.Context, addr string, portNum uint64, rpcServ
 
 func StartDASRPCServerOnListener(ctx context.Context, listener net.Listener, rpcServerTimeouts genericconf.HTTPServerTimeoutConfig, localDAS das.DataAvailabilityService) (*http.Server, error) {
 	rpcServer := rpc.NewServer()
-	err := rpcServer.RegisterName("das", &DASRPCServer{localDAS: localDAS})
+	chunkedStores := newChunkedStores()
+	err := rpcServer.RegisterName("das", &DASRPCServer{localDAS: localDAS, chunkedStores: chunkedStores})
 	if err != nil {
 		return nil, err
 	}
@@ -81,6 +87,18 @@ func StartDASRPCServerOnListener(ctx context.Context, listener net.Listener, rpc
 		<-ctx.Done()
 		_ = srv.Shutdown(context.Background())
 	}()
+	go func() {
+		ticker := time.NewTicker(chunkedStorePruneInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				chunkedStores.prune()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
 	return srv, nil
 }
 
@@ -93,8 +111,18 @@ type StoreResult struct {
 	Version     hexutil.Uint64 `json:"version,omitempty"`
 }
 
-func (serv *DASRPCServer) Store(ctx context.Context, message hexutil.Bytes, timeout hexutil.Uint64, sig hexutil.Bytes) (*StoreResult, error) {
+// Store stores the message and returns the signed certificate.
+// If signingPubKey is given, the certificate is signed with the matching key, for DASes holding more than one.
+func (serv *DASRPCServer) Store(ctx context.Context, message hexutil.Bytes, timeout hexutil.Uint64, sig hexutil.Bytes, signingPubKey *hexutil.Bytes) (*StoreResult, error) {
 	log.Trace("dasRpc.DASRPCServer.Store", "message", pretty.FirstFewBytes(message), "message length", len(message), "timeout", time.Unix(int64(timeout), 0), "sig", pretty.FirstFewBytes(sig), "this", serv)
+	var pubKeyBytes []byte
+	if signingPubKey != nil {
+		pubKeyBytes = *signingPubKey
+	}
+	return serv.store(ctx, message, uint64(timeout), sig, pubKeyBytes)
+}
+
+func (serv *DASRPCServer) store(ctx context.Context, message []byte, timeout uint64, sig []byte, signingPubKey []byte) (*StoreResult, error) {
 	rpcStoreRequestGauge.Inc(1)
 	start := time.Now()
 	success := false
@@ -107,7 +135,14 @@ func (serv *DASRPCServer) Store(ctx context.Context, message hexutil.Bytes, time
 		rpcStoreDurationHistogram.Update(time.Since(start).Nanoseconds())
 	}()
 
-	cert, err := serv.localDAS.Store(ctx, message, uint64(timeout), sig)
+	if len(signingPubKey) > 0 {
+		pubKey, err := blsSignatures.PublicKeyFromBytes(signingPubKey, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing public key: %w", err)
+		}
+		ctx = das.WithSigningPubKey(ctx, pubKey)
+	}
+	cert, err := serv.localDAS.Store(ctx, message, timeout, sig)
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +158,84 @@ func (serv *DASRPCServer) Store(ctx context.Context, message hexutil.Bytes, time
 	}, nil
 }
 
+type StartChunkedStoreResult struct {
+	BatchId common.Hash `json:"batchId"`
+}
+
+// chunkedStoreNotSignedError is returned when the start of a chunked store isn't signed by a batch poster,
+// with its own error code so that clients without the batch poster's key can store the whole message instead.
+type chunkedStoreNotSignedError struct {
+	error
+}
+
+func (e chunkedStoreNotSignedError) ErrorCode() int {
+	return chunkedStoreNotSignedCode
+}
+
+// StartChunkedStore begins a store whose message is sent in chunks with SendChunk, and stored by CommitChunkedStore.
+// startSig signs the chunk hashes, size and timeout, so the upload is only accepted from batch posters;
+// sig signs the whole message, and is checked when the store is committed.
+// Starting a store with the same parameters as one in progress returns the same batch id, so uploads can be resumed.
+func (serv *DASRPCServer) StartChunkedStore(ctx context.Context, chunkHashes []common.Hash, totalSize hexutil.Uint64, timeout hexutil.Uint64, sig hexutil.Bytes, startSig hexutil.Bytes, signingPubKey *hexutil.Bytes) (*StartChunkedStoreResult, error) {
+	log.Trace("dasRpc.DASRPCServer.StartChunkedStore", "chunks", len(chunkHashes), "totalSize", totalSize, "timeout", time.Unix(int64(timeout), 0), "sig", pretty.FirstFewBytes(sig), "startSig", pretty.FirstFewBytes(startSig))
+	if err := checkChunkedStoreSize(chunkHashes, uint64(totalSize)); err != nil {
+		return nil, err
+	}
+	signer, err := das.AuthorizeChunkedStore(ctx, serv.localDAS, chunkHashes, uint64(totalSize), uint64(timeout), startSig)
+	if errors.Is(err, das.ErrChunkedStoreNotSigned) {
+		return nil, chunkedStoreNotSignedError{err}
+	}
+	if err != nil {
+		return nil, err
+	}
+	var pubKeyBytes []byte
+	if signingPubKey != nil {
+		pubKeyBytes = *signingPubKey
+	}
+	id, err := serv.chunkedStores.start(chunkHashes, uint64(totalSize), uint64(timeout), sig, pubKeyBytes, signer)
+	if err != nil {
+		return nil, err
+	}
+	return &StartChunkedStoreResult{BatchId: id}, nil
+}
+
+func (serv *DASRPCServer) SendChunk(ctx context.Context, batchId common.Hash, chunkIndex hexutil.Uint64, chunk hexutil.Bytes) error {
+	rpcSendChunkRequestGauge.Inc(1)
+	err := serv.chunkedStores.addChunk(batchId, uint64(chunkIndex), chunk)
+	if err != nil {
+		rpcSendChunkFailureGauge.Inc(1)
+	}
+	return err
+}
+
+// ChunkedStoreStatus returns the indexes of the chunks that haven't been received yet
+func (serv *DASRPCServer) ChunkedStoreStatus(ctx context.Context, batchId common.Hash) ([]hexutil.Uint64, error) {
+	missing, err := serv.chunkedStores.missing(batchId)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]hexutil.Uint64, 0, len(missing))
+	for _, index := range missing {
+		result = append(result, hexutil.Uint64(index))
+	}
+	return result, nil
+}
+
+func (serv *DASRPCServer) CommitChunkedStore(ctx context.Context, batchId common.Hash) (*StoreResult, error) {
+	message, store, err := serv.chunkedStores.assemble(batchId)
+	if err != nil {
+		return nil, err
+	}
+	log.Trace("dasRpc.DASRPCServer.CommitChunkedStore", "message", pretty.FirstFewBytes(message), "message length", len(message), "timeout", time.Unix(int64(store.timeout), 0), "sig", pretty.FirstFewBytes(store.sig), "this", serv)
+	result, err := serv.store(ctx, message, store.timeout, store.sig, store.signingPubKey)
+	if err != nil {
+		// keep the chunks, so the commit can be retried
+		return nil, err
+	}
+	serv.chunkedStores.remove(batchId)
+	return result, nil
+}
+
 func (serv *DASRPCServer) GetByHash(ctx context.Context, certBytes hexutil.Bytes) (hexutil.Bytes, error) {
 	rpcGetByHashRequestGauge.Inc(1)
 	start := time.Now()
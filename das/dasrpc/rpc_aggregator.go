@@ -6,6 +6,8 @@ package dasrpc
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/tenderly/nitro/solgen/go/bridgegen"
 
@@ -21,47 +23,94 @@ type BackendConfig struct {
 	SignerMask          uint64 `json:"signermask"`
 }
 
+// KeysetConfig is an entry of the aggregator's keysets option, used while rotating the committee
+type KeysetConfig struct {
+	AssumedHonest int             `json:"assumed-honest"`
+	Backends      []BackendConfig `json:"backends"`
+	// unix times bounding when the keyset is used, ignored if zero
+	ValidFrom  int64 `json:"valid-from"`
+	ValidUntil int64 `json:"valid-until"`
+}
+
 func NewRPCAggregator(ctx context.Context, config das.DataAvailabilityConfig) (*das.Aggregator, error) {
-	services, err := setUpServices(config.AggregatorConfig)
+	keysets, err := setUpKeysets(config.AggregatorConfig)
 	if err != nil {
 		return nil, err
 	}
-	return das.NewAggregator(ctx, config, services)
+	return das.NewMultiKeysetAggregator(ctx, config, keysets)
 }
 
 func NewRPCAggregatorWithL1Info(config das.AggregatorConfig, l1client arbutil.L1Interface, seqInboxAddress common.Address) (*das.Aggregator, error) {
-	services, err := setUpServices(config)
+	keysets, err := setUpKeysets(config)
 	if err != nil {
 		return nil, err
 	}
-	return das.NewAggregatorWithL1Info(config, services, l1client, seqInboxAddress)
+	return das.NewMultiKeysetAggregatorWithL1Info(config, keysets, l1client, seqInboxAddress)
 }
 
 func NewRPCAggregatorWithSeqInboxCaller(config das.AggregatorConfig, seqInboxCaller *bridgegen.SequencerInboxCaller) (*das.Aggregator, error) {
-	services, err := setUpServices(config)
+	keysets, err := setUpKeysets(config)
 	if err != nil {
 		return nil, err
 	}
-	return das.NewAggregatorWithSeqInboxCaller(config, services, seqInboxCaller)
+	return das.NewMultiKeysetAggregatorWithSeqInboxCaller(config, keysets, seqInboxCaller)
 }
 
-func setUpServices(config das.AggregatorConfig) ([]das.ServiceDetails, error) {
-	var cs []BackendConfig
-	err := json.Unmarshal([]byte(config.Backends), &cs)
-	if err != nil {
-		return nil, err
+// setUpKeysets returns the keyset of the backends option, if set, followed by those of the keysets option.
+// Backends in several keysets share one client.
+func setUpKeysets(config das.AggregatorConfig) ([]das.AggregatorKeyset, error) {
+	var keysets []das.AggregatorKeyset
+	clients := make(map[string]das.DataAvailabilityService)
+	if config.Backends != "" {
+		var cs []BackendConfig
+		err := json.Unmarshal([]byte(config.Backends), &cs)
+		if err != nil {
+			return nil, err
+		}
+		services, err := setUpServices(config, cs, clients)
+		if err != nil {
+			return nil, err
+		}
+		keysets = append(keysets, das.AggregatorKeyset{Services: services, AssumedHonest: config.AssumedHonest})
+	}
+	if config.Keysets != "" {
+		var kcs []KeysetConfig
+		err := json.Unmarshal([]byte(config.Keysets), &kcs)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keysets: %w", err)
+		}
+		for _, kc := range kcs {
+			services, err := setUpServices(config, kc.Backends, clients)
+			if err != nil {
+				return nil, err
+			}
+			keyset := das.AggregatorKeyset{Services: services, AssumedHonest: kc.AssumedHonest}
+			if kc.ValidFrom != 0 {
+				keyset.ValidFrom = time.Unix(kc.ValidFrom, 0)
+			}
+			if kc.ValidUntil != 0 {
+				keyset.ValidUntil = time.Unix(kc.ValidUntil, 0)
+			}
+			keysets = append(keysets, keyset)
+		}
 	}
+	return keysets, nil
+}
 
+func setUpServices(config das.AggregatorConfig, cs []BackendConfig, clients map[string]das.DataAvailabilityService) ([]das.ServiceDetails, error) {
 	var services []das.ServiceDetails
 
 	for _, b := range cs {
-		service, err := NewDASRPCClient(b.URL)
-		if err != nil {
-			return nil, err
+		serviceWithRetryWrapper, ok := clients[b.URL]
+		if !ok {
+			service, err := NewDASRPCClient(b.URL, config.MaxStoreChunkSize)
+			if err != nil {
+				return nil, err
+			}
+			serviceWithRetryWrapper = das.NewRetryWrapper(service)
+			clients[b.URL] = serviceWithRetryWrapper
 		}
 
-		serviceWithRetryWrapper := das.NewRetryWrapper(service)
-
 		pubKey, err := das.DecodeBase64BLSPublicKey([]byte(b.PubKeyBase64Encoded))
 		if err != nil {
 			return nil, err
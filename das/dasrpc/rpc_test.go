@@ -3,15 +3,21 @@ package dasrpc
 import (
 	"bytes"
 	"context"
+	"crypto/ecdsa"
 	"encoding/base64"
 	"encoding/json"
 	"net"
 	"testing"
 	"time"
 
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
+	"github.com/tenderly/nitro/go-ethereum/crypto"
+
 	"github.com/tenderly/nitro/blsSignatures"
 	"github.com/tenderly/nitro/cmd/genericconf"
 	"github.com/tenderly/nitro/das"
+	"github.com/tenderly/nitro/das/dastree"
 	"github.com/tenderly/nitro/util/testhelpers"
 )
 
@@ -22,8 +28,7 @@ func blsPubToBase64(pubkey *blsSignatures.PublicKey) string {
 	return string(encodedPubkey)
 }
 
-func TestRPC(t *testing.T) {
-	ctx := context.Background()
+func startTestDASRPCServer(t *testing.T, ctx context.Context) (net.Listener, *blsSignatures.PublicKey, func()) {
 	lis, err := net.Listen("tcp", "localhost:0")
 	testhelpers.RequireImpl(t, err)
 	keyDir := t.TempDir()
@@ -46,16 +51,22 @@ func TestRPC(t *testing.T) {
 
 	storageService, lifecycleManager, err := das.CreatePersistentStorageService(ctx, &config)
 	testhelpers.RequireImpl(t, err)
-	defer lifecycleManager.StopAndWaitUntil(time.Second)
 	localDas, err := das.NewSignAfterStoreDASWithSeqInboxCaller(ctx, config.KeyConfig, nil, storageService)
 	testhelpers.RequireImpl(t, err)
 	dasServer, err := StartDASRPCServerOnListener(ctx, lis, genericconf.HTTPServerTimeoutConfigDefault, localDas)
-	defer func() {
+	testhelpers.RequireImpl(t, err)
+	return lis, pubkey, func() {
 		if err := dasServer.Shutdown(ctx); err != nil {
 			panic(err)
 		}
-	}()
-	testhelpers.RequireImpl(t, err)
+		lifecycleManager.StopAndWaitUntil(time.Second)
+	}
+}
+
+func TestRPC(t *testing.T) {
+	ctx := context.Background()
+	lis, pubkey, cleanup := startTestDASRPCServer(t, ctx)
+	defer cleanup()
 	beConfig := BackendConfig{
 		URL:                 "http://" + lis.Addr().String(),
 		PubKeyBase64Encoded: blsPubToBase64(pubkey),
@@ -65,8 +76,9 @@ func TestRPC(t *testing.T) {
 	backendsJsonByte, err := json.Marshal([]BackendConfig{beConfig})
 	testhelpers.RequireImpl(t, err)
 	aggConf := das.AggregatorConfig{
-		AssumedHonest: 1,
-		Backends:      string(backendsJsonByte),
+		AssumedHonest:     1,
+		Backends:          string(backendsJsonByte),
+		MaxStoreChunkSize: 64,
 	}
 	rpcAgg, err := NewRPCAggregatorWithSeqInboxCaller(aggConf, nil)
 	testhelpers.RequireImpl(t, err)
@@ -89,3 +101,106 @@ func TestRPC(t *testing.T) {
 		testhelpers.FailImpl(t, "failed to getByHash correct message")
 	}
 }
+
+func TestRPCChunkedStore(t *testing.T) {
+	ctx := context.Background()
+	lis, _, cleanup := startTestDASRPCServer(t, ctx)
+	defer cleanup()
+	url := "http://" + lis.Addr().String()
+	chunkSize := 100
+	client, err := NewDASRPCClient(url, chunkSize)
+	testhelpers.RequireImpl(t, err)
+
+	msg := testhelpers.RandomizeSlice(make([]byte, chunkSize*5+7))
+	var chunkHashes []common.Hash
+	for start := 0; start < len(msg); start += chunkSize {
+		end := start + chunkSize
+		if end > len(msg) {
+			end = len(msg)
+		}
+		chunkHashes = append(chunkHashes, crypto.Keccak256Hash(msg[start:end]))
+	}
+
+	// begin an upload which is interrupted after a couple of chunks
+	var started StartChunkedStoreResult
+	err = client.clnt.CallContext(ctx, &started, "das_startChunkedStore", chunkHashes, hexutil.Uint64(len(msg)), hexutil.Uint64(0), hexutil.Bytes{}, hexutil.Bytes{})
+	testhelpers.RequireImpl(t, err)
+	for _, index := range []int{0, 3} {
+		err = client.clnt.CallContext(ctx, nil, "das_sendChunk", started.BatchId, hexutil.Uint64(index), hexutil.Bytes(msg[index*chunkSize:(index+1)*chunkSize]))
+		testhelpers.RequireImpl(t, err)
+	}
+	err = client.clnt.CallContext(ctx, nil, "das_sendChunk", started.BatchId, hexutil.Uint64(1), hexutil.Bytes(msg[:chunkSize]))
+	if err == nil {
+		testhelpers.FailImpl(t, "chunk with the wrong hash was accepted")
+	}
+	var missing []hexutil.Uint64
+	err = client.clnt.CallContext(ctx, &missing, "das_chunkedStoreStatus", started.BatchId)
+	testhelpers.RequireImpl(t, err)
+	if len(missing) != len(chunkHashes)-2 {
+		testhelpers.FailImpl(t, "unexpected missing chunks", missing)
+	}
+	var result StoreResult
+	err = client.clnt.CallContext(ctx, &result, "das_commitChunkedStore", started.BatchId)
+	if err == nil {
+		testhelpers.FailImpl(t, "incomplete chunked store was committed")
+	}
+
+	// storing the same message resumes the upload
+	cert, err := client.Store(ctx, msg, 0, nil)
+	testhelpers.RequireImpl(t, err)
+	if cert.DataHash != dastree.Hash(msg) {
+		testhelpers.FailImpl(t, "unexpected data hash", cert.DataHash)
+	}
+	retrievedMessage, err := client.GetByHash(ctx, cert.DataHash)
+	testhelpers.RequireImpl(t, err)
+	if !bytes.Equal(msg, retrievedMessage) {
+		testhelpers.FailImpl(t, "failed to retrieve chunked message")
+	}
+	err = client.clnt.CallContext(ctx, &missing, "das_chunkedStoreStatus", started.BatchId)
+	if err == nil {
+		testhelpers.FailImpl(t, "chunked store wasn't removed after committing")
+	}
+}
+
+func TestRPCChunkedStoreLimits(t *testing.T) {
+	ctx := context.Background()
+	lis, _, cleanup := startTestDASRPCServer(t, ctx)
+	defer cleanup()
+	client, err := NewDASRPCClient("http://"+lis.Addr().String(), 100)
+	testhelpers.RequireImpl(t, err)
+
+	startSigned := func(key *ecdsa.PrivateKey, timeout uint64) error {
+		chunkHashes := []common.Hash{crypto.Keccak256Hash([]byte{byte(timeout)})}
+		startSig, err := das.ApplyDasChunkedStoreSigner(das.DasSignerFromPrivateKey(key), chunkHashes, 1, timeout)
+		testhelpers.RequireImpl(t, err)
+		var started StartChunkedStoreResult
+		return client.clnt.CallContext(ctx, &started, "das_startChunkedStore", chunkHashes, hexutil.Uint64(1), hexutil.Uint64(timeout), hexutil.Bytes{}, hexutil.Bytes(startSig))
+	}
+	keyA, err := crypto.GenerateKey()
+	testhelpers.RequireImpl(t, err)
+	keyB, err := crypto.GenerateKey()
+	testhelpers.RequireImpl(t, err)
+	for i := uint64(0); i < maxPendingChunkedStoresPerSigner; i++ {
+		testhelpers.RequireImpl(t, startSigned(keyA, i))
+	}
+	if err := startSigned(keyA, maxPendingChunkedStoresPerSigner); err == nil {
+		testhelpers.FailImpl(t, "signer exceeded its limit of chunked stores")
+	}
+	testhelpers.RequireImpl(t, startSigned(keyB, 0))
+}
+
+func TestChunkedStoreExpiry(t *testing.T) {
+	stores := newChunkedStores()
+	chunkHashes := []common.Hash{crypto.Keccak256Hash([]byte{1})}
+	id, err := stores.start(chunkHashes, 1, 0, nil, nil, common.Address{})
+	testhelpers.RequireImpl(t, err)
+	stores.prune()
+	_, err = stores.missing(id)
+	testhelpers.RequireImpl(t, err)
+
+	stores.stores[id].lastUpdated = time.Now().Add(-chunkedStoreExpiry - time.Second)
+	stores.prune()
+	if _, err := stores.missing(id); err == nil {
+		testhelpers.FailImpl(t, "idle chunked store wasn't discarded")
+	}
+}
@@ -20,27 +20,32 @@ import (
 )
 
 type LocalDBStorageConfig struct {
-	Enable              bool   `koanf:"enable"`
-	DataDir             string `koanf:"data-dir"`
-	DiscardAfterTimeout bool   `koanf:"discard-after-timeout"`
+	Enable              bool          `koanf:"enable"`
+	DataDir             string        `koanf:"data-dir"`
+	DiscardAfterTimeout bool          `koanf:"discard-after-timeout"`
+	ExpiryGracePeriod   time.Duration `koanf:"expiry-grace-period"`
 }
 
-var DefaultLocalDBStorageConfig = LocalDBStorageConfig{}
+var DefaultLocalDBStorageConfig = LocalDBStorageConfig{
+	ExpiryGracePeriod: DefaultExpiryConfig.GracePeriod,
+}
 
 func LocalDBStorageConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultLocalDBStorageConfig.Enable, "enable storage/retrieval of sequencer batch data from a database on the local filesystem")
 	f.String(prefix+".data-dir", DefaultLocalDBStorageConfig.DataDir, "directory in which to store the database")
 	f.Bool(prefix+".discard-after-timeout", DefaultLocalDBStorageConfig.DiscardAfterTimeout, "discard data after its expiry timeout")
+	f.Duration(prefix+".expiry-grace-period", DefaultLocalDBStorageConfig.ExpiryGracePeriod, "how long to keep data after its expiry timeout before the database discards it")
 }
 
 type DBStorageService struct {
 	db                  *badger.DB
 	discardAfterTimeout bool
+	expiryGracePeriod   time.Duration
 	dirPath             string
 	stopWaiter          stopwaiter.StopWaiterSafe
 }
 
-func NewDBStorageService(ctx context.Context, dirPath string, discardAfterTimeout bool) (StorageService, error) {
+func NewDBStorageService(ctx context.Context, dirPath string, discardAfterTimeout bool, expiryGracePeriod time.Duration) (StorageService, error) {
 	db, err := badger.Open(badger.DefaultOptions(dirPath))
 	if err != nil {
 		return nil, err
@@ -49,6 +54,7 @@ func NewDBStorageService(ctx context.Context, dirPath string, discardAfterTimeou
 	ret := &DBStorageService{
 		db:                  db,
 		discardAfterTimeout: discardAfterTimeout,
+		expiryGracePeriod:   expiryGracePeriod,
 		dirPath:             dirPath,
 	}
 	if err := ret.stopWaiter.Start(ctx); err != nil {
@@ -108,12 +114,69 @@ func (dbs *DBStorageService) Put(ctx context.Context, data []byte, timeout uint6
 	return dbs.db.Update(func(txn *badger.Txn) error {
 		e := badger.NewEntry(dastree.HashBytes(data), data)
 		if dbs.discardAfterTimeout {
-			e = e.WithTTL(time.Until(time.Unix(int64(timeout), 0)))
+			// badger records the expiry, and discards the entry once it passes
+			e = e.WithTTL(time.Until(time.Unix(int64(timeout), 0)) + dbs.expiryGracePeriod)
 		}
 		return txn.SetEntry(e)
 	})
 }
 
+// Quarantined entries are moved to keys with this prefix
+var dbQuarantinePrefix = []byte("quarantine/")
+
+func (dbs *DBStorageService) Walk(ctx context.Context, after common.Hash, visit func(StorageEntry) error) error {
+	return dbs.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.IteratorOptions{})
+		defer it.Close()
+		for it.Seek(after.Bytes()); it.Valid(); it.Next() {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			item := it.Item()
+			key := item.Key()
+			// skip quarantined entries
+			if len(key) != common.HashLength || bytes.Equal(key, after.Bytes()) {
+				continue
+			}
+			entry := StorageEntry{
+				Key:  common.BytesToHash(key),
+				Size: uint64(item.ValueSize()),
+			}
+			if expiresAt := item.ExpiresAt(); expiresAt > 0 {
+				// the expiry recorded by badger includes the grace period
+				entry.Timeout = expiresAt - uint64(dbs.expiryGracePeriod/time.Second)
+			}
+			if err := visit(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (dbs *DBStorageService) Read(ctx context.Context, entry StorageEntry) ([]byte, error) {
+	return dbs.GetByHash(ctx, entry.Key)
+}
+
+// Quarantine moves the entry to a key with the quarantine prefix, which is kept until deleted manually
+func (dbs *DBStorageService) Quarantine(ctx context.Context, entry StorageEntry) error {
+	return dbs.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(entry.Key.Bytes())
+		if err != nil {
+			return err
+		}
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		quarantineKey := append(append([]byte{}, dbQuarantinePrefix...), entry.Key.Bytes()...)
+		if err := txn.Set(quarantineKey, value); err != nil {
+			return err
+		}
+		return txn.Delete(entry.Key.Bytes())
+	})
+}
+
 func (dbs *DBStorageService) Sync(ctx context.Context) error {
 	return dbs.db.Sync()
 }
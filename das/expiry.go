@@ -0,0 +1,76 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"context"
+	"time"
+
+	"github.com/tenderly/nitro/go-ethereum/log"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/arbstate"
+	"github.com/tenderly/nitro/util/stopwaiter"
+)
+
+type ExpiryConfig struct {
+	GracePeriod   time.Duration `koanf:"grace-period"`
+	PruneInterval time.Duration `koanf:"prune-interval"`
+	DryRun        bool          `koanf:"dry-run"`
+}
+
+var DefaultExpiryConfig = ExpiryConfig{
+	GracePeriod:   time.Hour,
+	PruneInterval: 10 * time.Minute,
+}
+
+func ExpiryConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Duration(prefix+".grace-period", DefaultExpiryConfig.GracePeriod, "how long to keep data after its expiry timeout before discarding it")
+	f.Duration(prefix+".prune-interval", DefaultExpiryConfig.PruneInterval, "how often to check for and discard expired data")
+	f.Bool(prefix+".dry-run", DefaultExpiryConfig.DryRun, "only log the data that would be discarded after its expiry timeout, without deleting it")
+}
+
+// PruneResult summarizes a pass over a storage service discarding expired data
+type PruneResult struct {
+	Checked int    // entries with a known expiry
+	Expired int    // entries past their expiry and grace period
+	Deleted int    // entries deleted, which is zero in dry-run mode
+	Bytes   uint64 // size of the expired entries
+}
+
+// expiringStorageService is a StorageService that records expiry metadata for its data,
+// so it can discard expired data.
+type expiringStorageService interface {
+	StorageService
+	// Prune discards data whose expiry plus grace period is before now
+	Prune(ctx context.Context, now time.Time) (*PruneResult, error)
+}
+
+// expiryDeletes returns whether pruning should delete data, or only report it.
+// Data is only deleted if the service reports it as discarded after its timeout.
+func expiryDeletes(ctx context.Context, s StorageService) (bool, error) {
+	policy, err := s.ExpirationPolicy(ctx)
+	if err != nil {
+		return false, err
+	}
+	return policy == arbstate.DiscardAfterDataTimeout, nil
+}
+
+// expiredAt returns whether data with the given timeout is past its grace period at now
+func (c *ExpiryConfig) expiredAt(timeout uint64, now time.Time) bool {
+	return time.Unix(int64(timeout), 0).Add(c.GracePeriod).Before(now)
+}
+
+// launchPruner periodically prunes the service until the stop waiter is stopped
+func launchPruner(stopWaiter *stopwaiter.StopWaiterSafe, config *ExpiryConfig, s expiringStorageService) error {
+	return stopWaiter.CallIteratively(func(ctx context.Context) time.Duration {
+		result, err := s.Prune(ctx, time.Now())
+		if err != nil {
+			log.Warn("failed to discard expired DAS data", "service", s, "err", err)
+		} else if result.Expired > 0 {
+			log.Info("discarded expired DAS data", "service", s, "checked", result.Checked, "expired", result.Expired, "deleted", result.Deleted, "bytes", result.Bytes, "dryRun", config.DryRun)
+		}
+		return config.PruneInterval
+	})
+}
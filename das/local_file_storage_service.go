@@ -7,71 +7,248 @@ import (
 	"bytes"
 	"context"
 	"encoding/base32"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/crypto"
 	"github.com/tenderly/nitro/go-ethereum/log"
 	"github.com/tenderly/nitro/arbstate"
 	"github.com/tenderly/nitro/das/dastree"
 	"github.com/tenderly/nitro/util/pretty"
+	"github.com/tenderly/nitro/util/stopwaiter"
 	flag "github.com/spf13/pflag"
 	"golang.org/x/sys/unix"
 )
 
 type LocalFileStorageConfig struct {
-	Enable  bool   `koanf:"enable"`
-	DataDir string `koanf:"data-dir"`
+	Enable              bool         `koanf:"enable"`
+	DataDir             string       `koanf:"data-dir"`
+	DiscardAfterTimeout bool         `koanf:"discard-after-timeout"`
+	Expiry              ExpiryConfig `koanf:"expiry"`
+	Sharded             bool         `koanf:"sharded"`
+	ExtraDataDirs       []string     `koanf:"extra-data-dirs"`
 }
 
 var DefaultLocalFileStorageConfig = LocalFileStorageConfig{
 	DataDir: "",
+	Expiry:  DefaultExpiryConfig,
 }
 
 func LocalFileStorageConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultLocalFileStorageConfig.Enable, "enable storage/retrieval of sequencer batch data from a directory of files, one per batch")
 	f.String(prefix+".data-dir", DefaultLocalFileStorageConfig.DataDir, "local data directory")
+	f.Bool(prefix+".discard-after-timeout", DefaultLocalFileStorageConfig.DiscardAfterTimeout, "discard data after its expiry timeout")
+	ExpiryConfigAddOptions(prefix+".expiry", f)
+	f.Bool(prefix+".sharded", DefaultLocalFileStorageConfig.Sharded, "store files in subdirectories named by the start of their hash, moving files stored in the flat layout in the background")
+	f.StringSlice(prefix+".extra-data-dirs", DefaultLocalFileStorageConfig.ExtraDataDirs, "additional data directories to spread files across when sharded, for example on other disks; adding a directory only changes the placement of new files, and existing files are still found")
 }
 
+// The expiry timeout of each file is recorded in a file of the same name with this suffix
+const expiryFileSuffix = ".expiry"
+
+// Quarantined files are moved to this subdirectory
+const quarantineDir = "quarantine"
+
+// Files in the sharded layout are stored at <data dir>/<hash[0:2]>/<hash[2:4]>/<hash>
+const (
+	shardLevels     = 2
+	shardNameLength = 2
+)
+
 type LocalFileStorageService struct {
-	dataDir string
+	dataDir             string
+	dataDirs            []string // the data dir followed by the extra data dirs
+	sharded             bool
+	discardAfterTimeout bool
+	expiry              ExpiryConfig
+	stopWaiter          stopwaiter.StopWaiterSafe
+
+	// held while storing, moving and pruning a file, so a file being stored again isn't deleted
+	fileLocks hashLocks
+
+	// held for reading by stores in progress, which Close waits for, so no files are written after it returns
+	closeMutex sync.RWMutex
+	closed     bool
+}
+
+var ErrLocalFileStorageClosed = errors.New("LocalFileStorageService is closed")
+
+// hashLocks locks the files of each hash separately
+type hashLocks struct {
+	mutex sync.Mutex
+	locks map[common.Hash]*hashLock
+}
+
+type hashLock struct {
+	sync.Mutex
+	users int
+}
+
+// lock locks the files of key, and returns the function unlocking them
+func (l *hashLocks) lock(key common.Hash) func() {
+	l.mutex.Lock()
+	if l.locks == nil {
+		l.locks = make(map[common.Hash]*hashLock)
+	}
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &hashLock{}
+		l.locks[key] = lock
+	}
+	lock.users++
+	l.mutex.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		lock.users--
+		if lock.users == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
+
+func NewLocalFileStorageService(ctx context.Context, config LocalFileStorageConfig) (StorageService, error) {
+	if len(config.ExtraDataDirs) > 0 && !config.Sharded {
+		return nil, errors.New("LocalFileStorageService extra data directories require the sharded layout")
+	}
+	dataDirs := append([]string{config.DataDir}, config.ExtraDataDirs...)
+	for _, dir := range dataDirs {
+		if unix.Access(dir, unix.W_OK|unix.R_OK) != nil {
+			return nil, fmt.Errorf("Couldn't start LocalFileStorageService, directory '%s' must be readable and writeable", dir)
+		}
+	}
+	s := newLocalFileStorageService(config)
+	if err := s.stopWaiter.Start(ctx); err != nil {
+		return nil, err
+	}
+	if config.DiscardAfterTimeout {
+		if err := launchPruner(&s.stopWaiter, &s.expiry, s); err != nil {
+			return nil, err
+		}
+	}
+	if config.Sharded {
+		err := s.stopWaiter.LaunchThread(func(ctx context.Context) {
+			if err := s.migrateToSharded(ctx); err != nil && ctx.Err() == nil {
+				log.Error("failed to move DAS files to the sharded layout", "err", err)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func newLocalFileStorageService(config LocalFileStorageConfig) *LocalFileStorageService {
+	return &LocalFileStorageService{
+		dataDir:             config.DataDir,
+		dataDirs:            append([]string{config.DataDir}, config.ExtraDataDirs...),
+		sharded:             config.Sharded,
+		discardAfterTimeout: config.DiscardAfterTimeout,
+		expiry:              config.Expiry,
+	}
+}
+
+func shardedPath(dir string, key common.Hash) string {
+	name := EncodeStorageServiceKey(key)
+	parts := []string{dir}
+	for i := 0; i < shardLevels; i++ {
+		parts = append(parts, name[i*shardNameLength:(i+1)*shardNameLength])
+	}
+	return filepath.Join(append(parts, name)...)
+}
+
+// placementDir chooses the data dir of a key by rendezvous hashing,
+// so adding a data dir only moves the keys that now prefer it.
+func (s *LocalFileStorageService) placementDir(key common.Hash) string {
+	var best string
+	var bestScore common.Hash
+	for _, dir := range s.dataDirs {
+		score := crypto.Keccak256Hash(key[:], []byte(dir))
+		if best == "" || bytes.Compare(score[:], bestScore[:]) > 0 {
+			best = dir
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// pathFor returns where a key is stored in the configured layout
+func (s *LocalFileStorageService) pathFor(key common.Hash) string {
+	if s.sharded {
+		return shardedPath(s.placementDir(key), key)
+	}
+	return filepath.Join(s.dataDir, EncodeStorageServiceKey(key))
 }
 
-func NewLocalFileStorageService(dataDir string) (StorageService, error) {
-	if unix.Access(dataDir, unix.W_OK|unix.R_OK) != nil {
-		return nil, fmt.Errorf("Couldn't start LocalFileStorageService, directory '%s' must be readable and writeable", dataDir)
+// candidatePaths returns where a key might be found, starting with where it's stored in the configured layout.
+// Files may still be in the flat layout, or a sharded layout with other data dirs, or have a legacy base32 name.
+func (s *LocalFileStorageService) candidatePaths(key common.Hash) []string {
+	preferred := s.pathFor(key)
+	paths := []string{preferred}
+	for _, dir := range s.dataDirs {
+		if path := shardedPath(dir, key); path != preferred {
+			paths = append(paths, path)
+		}
+	}
+	if flat := filepath.Join(s.dataDir, EncodeStorageServiceKey(key)); flat != preferred {
+		paths = append(paths, flat)
 	}
-	return &LocalFileStorageService{dataDir: dataDir}, nil
+	// Just for backward compatability.
+	return append(paths, filepath.Join(s.dataDir, base32.StdEncoding.EncodeToString(key.Bytes())))
 }
 
 func (s *LocalFileStorageService) GetByHash(ctx context.Context, key common.Hash) ([]byte, error) {
 	log.Trace("das.LocalFileStorageService.GetByHash", "key", pretty.PrettyHash(key), "this", s)
-	pathname := s.dataDir + "/" + EncodeStorageServiceKey(key)
-	data, err := os.ReadFile(pathname)
-	if err != nil {
-		// Just for backward compatability.
-		pathname = s.dataDir + "/" + base32.StdEncoding.EncodeToString(key.Bytes())
-		data, err = os.ReadFile(pathname)
-		if err != nil {
-			if errors.Is(err, os.ErrNotExist) {
-				return nil, ErrNotFound
-			}
+	for _, pathname := range s.candidatePaths(key) {
+		data, err := os.ReadFile(pathname)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
 			return nil, err
 		}
-		return data, nil
 	}
-	return data, nil
+	return nil, ErrNotFound
 }
 
 func (s *LocalFileStorageService) Put(ctx context.Context, data []byte, timeout uint64) error {
 	logPut("das.LocalFileStorageService.Store", data, timeout, s)
-	fileName := EncodeStorageServiceKey(dastree.Hash(data))
-	finalPath := s.dataDir + "/" + fileName
+	s.closeMutex.RLock()
+	defer s.closeMutex.RUnlock()
+	if s.closed {
+		return ErrLocalFileStorageClosed
+	}
+	key := dastree.Hash(data)
+	pathname := s.pathFor(key)
+	defer s.fileLocks.lock(key)()
+	if err := os.MkdirAll(filepath.Dir(pathname), 0700); err != nil {
+		return err
+	}
+	if err := writeFileAtomically(pathname, data); err != nil {
+		return err
+	}
+	if !s.discardAfterTimeout {
+		// the expiry is only recorded to discard the data
+		return nil
+	}
+	return s.extendExpiry(pathname, timeout)
+}
 
-	// Use a temp file and rename to achieve atomic writes.
-	f, err := os.CreateTemp(s.dataDir, fileName)
+// writeFileAtomically uses a temp file and rename to achieve atomic writes
+func writeFileAtomically(pathname string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(pathname), filepath.Base(pathname))
 	if err != nil {
 		return err
 	}
@@ -87,9 +264,278 @@ func (s *LocalFileStorageService) Put(ctx context.Context, data []byte, timeout
 	if err != nil {
 		return err
 	}
+	return os.Rename(f.Name(), pathname)
+}
+
+// moveFile renames a file, copying it if it's moving to another filesystem
+func moveFile(from string, to string) error {
+	err := os.Rename(from, to)
+	if !errors.Is(err, unix.EXDEV) {
+		return err
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomically(to, data); err != nil {
+		return err
+	}
+	return os.Remove(from)
+}
+
+func readExpiry(pathname string) (uint64, error) {
+	data, err := os.ReadFile(pathname + expiryFileSuffix)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// extendExpiry records the timeout of a file, unless it's already being kept for longer.
+// The file must be locked.
+func (s *LocalFileStorageService) extendExpiry(pathname string, timeout uint64) error {
+	existing, err := readExpiry(pathname)
+	if err == nil && existing >= timeout {
+		return nil
+	}
+	return writeFileAtomically(pathname+expiryFileSuffix, []byte(strconv.FormatUint(timeout, 10)))
+}
+
+func isShardName(name string) bool {
+	if len(name) != shardNameLength {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// forEachFile calls visit with each file in the top level of the data dirs, and in their shard subdirectories
+func (s *LocalFileStorageService) forEachFile(ctx context.Context, visit func(pathname string, name string) error) error {
+	var walkDir func(dir string, level int) error
+	walkDir = func(dir string, level int) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			pathname := filepath.Join(dir, entry.Name())
+			if !entry.IsDir() {
+				if err := visit(pathname, entry.Name()); err != nil {
+					return err
+				}
+			} else if level < shardLevels && isShardName(entry.Name()) {
+				if err := walkDir(pathname, level+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	for _, dir := range s.dataDirs {
+		if err := walkDir(dir, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Prune deletes the files past their expiry timeout and grace period.
+// Files stored before expiry metadata was recorded have no expiry, and are kept.
+func (s *LocalFileStorageService) Prune(ctx context.Context, now time.Time) (*PruneResult, error) {
+	deleteExpired, err := expiryDeletes(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	result := &PruneResult{}
+	err = s.forEachFile(ctx, func(pathname string, name string) error {
+		if !strings.HasSuffix(name, expiryFileSuffix) {
+			return nil
+		}
+		dataPath := strings.TrimSuffix(pathname, expiryFileSuffix)
+		key, _, ok := decodeFileName(filepath.Base(dataPath))
+		if !ok {
+			return nil
+		}
+		pruned, size, err := s.pruneFile(key, dataPath, now, deleteExpired)
+		if err != nil {
+			log.Warn("failed to check expiry of DAS file", "file", dataPath, "err", err)
+			return nil
+		}
+		result.Checked++
+		if pruned {
+			result.Expired++
+			result.Bytes += size
+			if deleteExpired {
+				result.Deleted++
+			} else {
+				log.Info("DAS file past its expiry would be discarded", "file", dataPath, "bytes", size)
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// pruneFile returns whether the file is expired and its size, deleting it if deleteExpired is set
+func (s *LocalFileStorageService) pruneFile(key common.Hash, pathname string, now time.Time, deleteExpired bool) (bool, uint64, error) {
+	defer s.fileLocks.lock(key)()
+	timeout, err := readExpiry(pathname)
+	if err != nil {
+		return false, 0, err
+	}
+	if !s.expiry.expiredAt(timeout, now) {
+		return false, 0, nil
+	}
+	var size uint64
+	info, err := os.Stat(pathname)
+	if err == nil {
+		size = uint64(info.Size())
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, 0, err
+	}
+	if !deleteExpired {
+		return true, size, nil
+	}
+	// remove the data before its expiry, so an interrupted prune is retried
+	if err := os.Remove(pathname); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, 0, err
+	}
+	if err := os.Remove(pathname + expiryFileSuffix); err != nil {
+		return false, 0, err
+	}
+	return true, size, nil
+}
+
+// decodeFileName returns the key of a file named by its hex key or legacy base32 key
+func decodeFileName(name string) (key common.Hash, legacy bool, ok bool) {
+	if len(name) == 2*common.HashLength {
+		key, err := DecodeStorageServiceKey(name)
+		return key, false, err == nil
+	}
+	legacyKey, err := base32.StdEncoding.DecodeString(name)
+	if err != nil || len(legacyKey) != common.HashLength {
+		// expiry and temporary files
+		return common.Hash{}, false, false
+	}
+	return common.BytesToHash(legacyKey), true, true
+}
+
+// migrateToSharded moves the files at the top level of the data dirs to their place in the sharded layout
+func (s *LocalFileStorageService) migrateToSharded(ctx context.Context) error {
+	var toMove []string
+	for _, dir := range s.dataDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if _, _, ok := decodeFileName(entry.Name()); ok && !entry.IsDir() {
+				toMove = append(toMove, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	if len(toMove) == 0 {
+		return nil
+	}
+	log.Info("moving DAS files to the sharded layout", "files", len(toMove))
+	for i, pathname := range toMove {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := s.moveToShard(pathname); err != nil {
+			return err
+		}
+		if (i+1)%10000 == 0 {
+			log.Info("moving DAS files to the sharded layout", "moved", i+1, "files", len(toMove))
+		}
+	}
+	log.Info("finished moving DAS files to the sharded layout", "files", len(toMove))
+	return nil
+}
+
+func (s *LocalFileStorageService) moveToShard(pathname string) error {
+	key, _, _ := decodeFileName(filepath.Base(pathname))
+	target := s.pathFor(key)
+	defer s.fileLocks.lock(key)()
+	if _, err := os.Stat(target); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return err
+		}
+		if err := moveFile(pathname, target); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	} else if err := os.Remove(pathname); err != nil {
+		// the file was stored again in the sharded layout
+		return err
+	}
+	timeout, err := readExpiry(pathname)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := s.extendExpiry(target, timeout); err != nil {
+		return err
+	}
+	return os.Remove(pathname + expiryFileSuffix)
+}
 
-	return os.Rename(f.Name(), finalPath)
+// Walk visits the files named by their key, and those with legacy base32 names
+func (s *LocalFileStorageService) Walk(ctx context.Context, after common.Hash, visit func(StorageEntry) error) error {
+	list := func(add func(StorageEntry)) error {
+		return s.forEachFile(ctx, func(pathname string, name string) error {
+			key, legacy, ok := decodeFileName(name)
+			if ok {
+				add(StorageEntry{Key: key, Legacy: legacy, name: pathname})
+			}
+			return nil
+		})
+	}
+	return walkInKeyOrder(ctx, after, list, func(entry StorageEntry) error {
+		info, err := os.Stat(entry.name)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		entry.Size = uint64(info.Size())
+		if !entry.Legacy {
+			timeout, err := readExpiry(entry.name)
+			if err == nil {
+				entry.Timeout = timeout
+			}
+		}
+		return visit(entry)
+	})
+}
 
+func (s *LocalFileStorageService) Read(ctx context.Context, entry StorageEntry) ([]byte, error) {
+	return os.ReadFile(entry.name)
+}
+
+// Quarantine moves the file and its expiry into the quarantine subdirectory of the data dir
+func (s *LocalFileStorageService) Quarantine(ctx context.Context, entry StorageEntry) error {
+	defer s.fileLocks.lock(entry.Key)()
+	dir := filepath.Join(s.dataDir, quarantineDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	target := filepath.Join(dir, filepath.Base(entry.name))
+	if err := moveFile(entry.name, target); err != nil {
+		return err
+	}
+	err := moveFile(entry.name+expiryFileSuffix, target+expiryFileSuffix)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
 }
 
 func (s *LocalFileStorageService) Sync(ctx context.Context) error {
@@ -97,15 +543,22 @@ func (s *LocalFileStorageService) Sync(ctx context.Context) error {
 }
 
 func (s *LocalFileStorageService) Close(ctx context.Context) error {
+	s.stopWaiter.StopAndWait()
+	s.closeMutex.Lock()
+	defer s.closeMutex.Unlock()
+	s.closed = true
 	return nil
 }
 
 func (s *LocalFileStorageService) ExpirationPolicy(ctx context.Context) (arbstate.ExpirationPolicy, error) {
+	if s.discardAfterTimeout && !s.expiry.DryRun {
+		return arbstate.DiscardAfterDataTimeout, nil
+	}
 	return arbstate.KeepForever, nil
 }
 
 func (s *LocalFileStorageService) String() string {
-	return "LocalFileStorageService(" + s.dataDir + ")"
+	return "LocalFileStorageService(" + strings.Join(s.dataDirs, ",") + ")"
 }
 
 func (s *LocalFileStorageService) HealthCheck(ctx context.Context) error {
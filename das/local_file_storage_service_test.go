@@ -0,0 +1,187 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+
+	"github.com/tenderly/nitro/das/dastree"
+)
+
+func TestLocalFileStorageServiceExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := DefaultLocalFileStorageConfig
+	config.DataDir = t.TempDir()
+	config.DiscardAfterTimeout = true
+	config.Expiry.DryRun = true
+	// without the background pruner, which would race with the changes to the config below
+	s := newLocalFileStorageService(config)
+
+	now := time.Now()
+	expired := []byte("expired")
+	kept := []byte("kept")
+	Require(t, s.Put(ctx, expired, uint64(now.Add(-2*config.Expiry.GracePeriod).Unix())))
+	Require(t, s.Put(ctx, kept, uint64(now.Add(-2*config.Expiry.GracePeriod).Unix())))
+	// storing data again extends its expiry
+	Require(t, s.Put(ctx, kept, uint64(now.Add(time.Hour).Unix())))
+	Require(t, s.Put(ctx, kept, uint64(now.Add(-time.Hour).Unix())))
+
+	// a dry run only reports the expired data
+	result, err := s.Prune(ctx, now)
+	Require(t, err)
+	if result.Checked != 2 || result.Expired != 1 || result.Deleted != 0 || result.Bytes != uint64(len(expired)) {
+		Fail(t, "unexpected dry run result", result)
+	}
+	_, err = s.GetByHash(ctx, dastree.Hash(expired))
+	Require(t, err)
+
+	s.expiry.DryRun = false
+	result, err = s.Prune(ctx, now)
+	Require(t, err)
+	if result.Checked != 2 || result.Deleted != 1 {
+		Fail(t, "unexpected prune result", result)
+	}
+	_, err = s.GetByHash(ctx, dastree.Hash(expired))
+	if !errors.Is(err, ErrNotFound) {
+		Fail(t, "expired data not discarded", err)
+	}
+	_, err = s.GetByHash(ctx, dastree.Hash(kept))
+	Require(t, err)
+
+	// data isn't discarded unless the expiration policy says so
+	s.discardAfterTimeout = false
+	result, err = s.Prune(ctx, now.Add(24*time.Hour))
+	Require(t, err)
+	if result.Expired != 1 || result.Deleted != 0 {
+		Fail(t, "data discarded despite the KeepForever policy", result)
+	}
+	// nor is the expiry recorded
+	unrecorded := []byte("unrecorded")
+	Require(t, s.Put(ctx, unrecorded, uint64(now.Unix())))
+	if _, err := readExpiry(s.pathFor(dastree.Hash(unrecorded))); !errors.Is(err, os.ErrNotExist) {
+		Fail(t, "expiry recorded without discarding data after its timeout", err)
+	}
+}
+
+func TestLocalFileStorageServiceSharded(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := DefaultLocalFileStorageConfig
+	config.DataDir = t.TempDir()
+	config.DiscardAfterTimeout = true
+	config.Expiry.DryRun = true
+	flat, err := NewLocalFileStorageService(ctx, config)
+	Require(t, err)
+	timeout := uint64(time.Now().Add(time.Hour).Unix())
+	var values [][]byte
+	for i := 0; i < 20; i++ {
+		values = append(values, []byte(fmt.Sprint("value ", i)))
+		Require(t, flat.Put(ctx, values[i], timeout))
+	}
+	Require(t, flat.Close(ctx))
+
+	config.Sharded = true
+	config.ExtraDataDirs = []string{t.TempDir()}
+	service, err := NewLocalFileStorageService(ctx, config)
+	Require(t, err)
+	defer service.Close(ctx)
+	s := service.(*LocalFileStorageService)
+
+	// files are readable while the migrator moves them into the sharded layout
+	for _, value := range values {
+		_, err := s.GetByHash(ctx, dastree.Hash(value))
+		Require(t, err)
+	}
+	for attempt := 0; ; attempt++ {
+		entries, err := os.ReadDir(config.DataDir)
+		Require(t, err)
+		remaining := 0
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				remaining++
+			}
+		}
+		if remaining == 0 {
+			break
+		}
+		if attempt > 100 {
+			Fail(t, "files not moved to the sharded layout", remaining)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	values = append(values, []byte("stored sharded"))
+	Require(t, s.Put(ctx, values[len(values)-1], timeout))
+	usedDirs := make(map[string]bool)
+	for _, value := range values {
+		key := dastree.Hash(value)
+		pathname := s.pathFor(key)
+		usedDirs[s.placementDir(key)] = true
+		data, err := os.ReadFile(pathname)
+		Require(t, err)
+		if !bytes.Equal(data, value) {
+			Fail(t, "unexpected data at", pathname)
+		}
+		expiry, err := readExpiry(pathname)
+		Require(t, err)
+		if expiry != timeout {
+			Fail(t, "expiry not moved with the file", pathname, expiry)
+		}
+	}
+	if len(usedDirs) != 2 {
+		Fail(t, "files weren't spread across the data directories", usedDirs)
+	}
+
+	visited := 0
+	Require(t, s.Walk(ctx, common.Hash{}, func(entry StorageEntry) error {
+		visited++
+		return nil
+	}))
+	if visited != len(values) {
+		Fail(t, "walk visited", visited, "files, expected", len(values))
+	}
+}
+
+func TestLocalFileStorageServiceClose(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := DefaultLocalFileStorageConfig
+	config.DataDir = t.TempDir()
+	config.Sharded = true
+	service, err := NewLocalFileStorageService(ctx, config)
+	Require(t, err)
+
+	// stores racing with Close either finish before it returns or are rejected, so the data dir can be removed
+	results := make(chan error, 10)
+	for i := 0; i < cap(results); i++ {
+		go func(i int) {
+			results <- service.Put(ctx, []byte(fmt.Sprint("data ", i)), uint64(time.Now().Unix()))
+		}(i)
+	}
+	Require(t, service.Close(ctx))
+	Require(t, os.RemoveAll(config.DataDir))
+	for i := 0; i < cap(results); i++ {
+		if err := <-results; err != nil && !errors.Is(err, ErrLocalFileStorageClosed) {
+			Fail(t, "unexpected store error", err)
+		}
+	}
+	if _, err := os.Stat(config.DataDir); !errors.Is(err, os.ErrNotExist) {
+		Fail(t, "files were written after the service was closed", err)
+	}
+	if err := service.Put(ctx, []byte("late"), 0); !errors.Is(err, ErrLocalFileStorageClosed) {
+		Fail(t, "store after close wasn't rejected", err)
+	}
+}
@@ -134,6 +134,41 @@ func (rs *RedisStorageService) Put(ctx context.Context, value []byte, timeout ui
 	return err
 }
 
+// Walk visits the entries cached in redis, without those only in the base storage service
+func (rs *RedisStorageService) Walk(ctx context.Context, after common.Hash, visit func(StorageEntry) error) error {
+	list := func(add func(StorageEntry)) error {
+		iter := rs.client.Scan(ctx, 0, "", 0).Iterator()
+		for iter.Next(ctx) {
+			// skip quarantined and unrelated keys
+			key := iter.Val()
+			if len(key) == common.HashLength {
+				add(StorageEntry{Key: common.BytesToHash([]byte(key))})
+			}
+		}
+		return iter.Err()
+	}
+	return walkInKeyOrder(ctx, after, list, visit)
+}
+
+// Read returns the cached data, which is returned with its HMAC if that doesn't match so it fails hash checks
+func (rs *RedisStorageService) Read(ctx context.Context, entry StorageEntry) ([]byte, error) {
+	data, err := rs.client.Get(ctx, string(entry.Key.Bytes())).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	message, err := rs.verifyMessageSignature(data)
+	if err != nil {
+		return data, nil
+	}
+	return message, nil
+}
+
+// Quarantine renames the key with the quarantine prefix
+func (rs *RedisStorageService) Quarantine(ctx context.Context, entry StorageEntry) error {
+	key := string(entry.Key.Bytes())
+	return rs.client.Rename(ctx, key, quarantineDir+":"+key).Err()
+}
+
 func (rs *RedisStorageService) Sync(ctx context.Context) error {
 	return rs.baseStorageService.Sync(ctx)
 }
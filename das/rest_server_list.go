@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/tenderly/nitro/go-ethereum/log"
 )
 
 const initialMaxRecurseDepth uint16 = 8
@@ -58,6 +60,7 @@ func restfulServerURLsFromList(
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 	scanner := bufio.NewScanner(resp.Body)
 	scanner.Split(bufio.ScanWords)
 	for scanner.Scan() {
@@ -84,13 +87,14 @@ const maxListFetchTime = time.Minute
 func StartRestfulServerListFetchDaemon(ctx context.Context, listUrl string, updatePeriod time.Duration) <-chan []string {
 	updateChan := make(chan []string)
 
-	downloadAndSend := func() bool { // download and send once, return true iff success
+	downloadAndSend := func() bool { // download and send once, return false iff ctx is done
 		subCtx, subCtxCancel := context.WithTimeout(ctx, maxListFetchTime)
 		defer subCtxCancel()
 
 		urls, err := RestfulServerURLsFromList(subCtx, listUrl)
 		if err != nil {
-			return false
+			log.Warn("Failed to fetch REST DAS server list, will retry", "url", listUrl, "err", err)
+			return ctx.Err() == nil
 		}
 		select {
 		case updateChan <- urls:
@@ -8,12 +8,15 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+	"sync/atomic"
 
 	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/log"
 	"github.com/tenderly/nitro/arbstate"
 	"github.com/tenderly/nitro/das/dastree"
 )
@@ -21,8 +24,13 @@ import (
 // Implements DataAvailabilityReader
 type RestfulDasClient struct {
 	url string
+	// set once the server is found not to support the binary endpoint, so the JSON one is used
+	jsonOnly int32
 }
 
+// How many times an interrupted binary download is resumed with a Range request
+const restfulClientMaxResumes = 3
+
 func NewRestfulDasClient(protocol string, host string, port int) *RestfulDasClient {
 	return &RestfulDasClient{
 		url: fmt.Sprintf("%s://%s:%d", protocol, host, port),
@@ -40,10 +48,74 @@ func NewRestfulDasClientFromURL(url string) (*RestfulDasClient, error) {
 }
 
 func (c *RestfulDasClient) GetByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
+	if atomic.LoadInt32(&c.jsonOnly) == 0 {
+		data, err := c.getByHashBinary(ctx, hash)
+		if !errors.Is(err, errBinaryUnsupported) {
+			return data, err
+		}
+		log.Info("REST DAS server doesn't support the binary endpoint, falling back to JSON", "url", c.url)
+		atomic.StoreInt32(&c.jsonOnly, 1)
+	}
+	return c.getByHashJSON(ctx, hash)
+}
+
+var errBinaryUnsupported = errors.New("binary endpoint not supported")
+
+// getByHashBinary fetches the raw data, resuming the download with a Range request if it's interrupted
+func (c *RestfulDasClient) getByHashBinary(ctx context.Context, hash common.Hash) ([]byte, error) {
+	url := c.url + getByHashBinaryRequestPath + EncodeStorageServiceKey(hash)
+	var data []byte
+	var etag string
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", len(data)))
+			req.Header.Set("If-Range", etag)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case res.StatusCode == http.StatusOK:
+			// a full response, either first time or because the server ignored the range
+			data = data[:0]
+			etag = res.Header.Get("ETag")
+		case res.StatusCode == http.StatusPartialContent && len(data) > 0:
+		case res.StatusCode == http.StatusBadRequest && len(data) == 0:
+			// older servers reject unknown paths
+			res.Body.Close()
+			return nil, errBinaryUnsupported
+		default:
+			res.Body.Close()
+			return nil, fmt.Errorf("HTTP error with status %d returned by server: %s", res.StatusCode, http.StatusText(res.StatusCode))
+		}
+		body, err := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		data = append(data, body...)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil || attempt >= restfulClientMaxResumes || etag == "" {
+			return nil, err
+		}
+		log.Warn("REST DAS download interrupted, resuming", "url", url, "received", len(data), "err", err)
+	}
+	if !dastree.ValidHash(hash, data) {
+		return nil, arbstate.ErrHashMismatch
+	}
+	return data, nil
+}
+
+func (c *RestfulDasClient) getByHashJSON(ctx context.Context, hash common.Hash) ([]byte, error) {
 	res, err := http.Get(c.url + getByHashRequestPath + EncodeStorageServiceKey(hash))
 	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("HTTP error with status %d returned by server: %s", res.StatusCode, http.StatusText(res.StatusCode))
 	}
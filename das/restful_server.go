@@ -4,6 +4,7 @@
 package das
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -88,6 +89,9 @@ const healthRequestPath = "/health"
 const expirationPolicyRequestPath = "/expiration-policy/"
 const getByHashRequestPath = "/get-by-hash/"
 
+// Returns the raw data rather than base64 in JSON, with an ETag of its hash, and supports Range requests
+const getByHashBinaryRequestPath = "/get-by-hash-binary/"
+
 func (rds *RestfulDasServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	requestPath := path.Clean(r.URL.Path)
 	log.Debug("Got request", "requestPath", requestPath)
@@ -98,6 +102,8 @@ func (rds *RestfulDasServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		rds.ExpirationPolicyHandler(w, r, requestPath)
 	case strings.HasPrefix(requestPath, getByHashRequestPath):
 		rds.GetByHashHandler(w, r, requestPath)
+	case strings.HasPrefix(requestPath, getByHashBinaryRequestPath):
+		rds.GetByHashBinaryHandler(w, r, requestPath)
 	default:
 		log.Warn("Unknown requestPath", "requestPath", requestPath)
 		w.WriteHeader(http.StatusBadRequest)
@@ -152,39 +158,83 @@ func (rds *RestfulDasServer) GetByHashHandler(w http.ResponseWriter, r *http.Req
 		restGetByHashDurationHistogram.Update(time.Since(start).Nanoseconds())
 	}()
 
-	hashBytes, err := DecodeStorageServiceKey(strings.TrimPrefix(requestPath, "/get-by-hash/"))
+	_, responseData, ok := rds.getByHash(w, r, requestPath, getByHashRequestPath)
+	if !ok {
+		return
+	}
+
+	encodedResponseData := make([]byte, base64.StdEncoding.EncodedLen(len(responseData)))
+	base64.StdEncoding.Encode(encodedResponseData, responseData)
+	var response RestfulDasServerResponse
+	response.Data = string(encodedResponseData)
+	restGetByHashReturnedBytesGauge.Inc(int64(len(response.Data)))
+
+	// headers must be set before the body is written
+	w.Header()[cacheControlKey] = []string{cacheControlValue}
+	err := json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Warn("Failed encoding and writing response", "path", requestPath, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	success = true
+}
+
+// getByHash looks up the data for the hash in the request path, writing an error response if it can't be found
+func (rds *RestfulDasServer) getByHash(w http.ResponseWriter, r *http.Request, requestPath string, prefix string) (common.Hash, []byte, bool) {
+	hashBytes, err := DecodeStorageServiceKey(strings.TrimPrefix(requestPath, prefix))
 	if err != nil {
 		log.Warn("Failed to decode hex-encoded hash", "path", requestPath, "err", err)
 		w.WriteHeader(http.StatusBadRequest)
-		return
+		return common.Hash{}, nil, false
 	}
 	if len(hashBytes) < 32 {
 		log.Warn("Decoded hash was too short", "path", requestPath, "len(hashBytes)", len(hashBytes))
 		w.WriteHeader(http.StatusBadRequest)
-		return
+		return common.Hash{}, nil, false
 	}
+	hash := common.BytesToHash(hashBytes[:32])
 
-	responseData, err := rds.storage.GetByHash(r.Context(), common.BytesToHash(hashBytes[:32]))
+	responseData, err := rds.storage.GetByHash(r.Context(), hash)
 	if err != nil {
 		log.Warn("Unable to find data", "path", requestPath, "err", err)
 		w.WriteHeader(http.StatusNotFound)
-		return
+		return common.Hash{}, nil, false
 	}
 	log.Trace("RestfulDasServer.ServeHTTP returning", "message", pretty.FirstFewBytes(responseData), "message length", len(responseData))
+	return hash, responseData, true
+}
 
-	encodedResponseData := make([]byte, base64.StdEncoding.EncodedLen(len(responseData)))
-	base64.StdEncoding.Encode(encodedResponseData, responseData)
-	var response RestfulDasServerResponse
-	response.Data = string(encodedResponseData)
-	restGetByHashReturnedBytesGauge.Inc(int64(len(response.Data)))
+// hashETag is a strong ETag for data addressed by its hash, which never changes
+func hashETag(hash common.Hash) string {
+	return `"` + EncodeStorageServiceKey(hash) + `"`
+}
 
-	err = json.NewEncoder(w).Encode(response)
-	if err != nil {
-		log.Warn("Failed encoding and writing response", "path", requestPath, "err", err)
-		w.WriteHeader(http.StatusInternalServerError)
+// GetByHashBinaryHandler returns the raw data, and as it's addressed by its hash
+// it has a strong ETag and can be cached forever. Conditional and Range requests are supported.
+func (rds *RestfulDasServer) GetByHashBinaryHandler(w http.ResponseWriter, r *http.Request, requestPath string) {
+	restGetByHashRequestGauge.Inc(1)
+	start := time.Now()
+	success := false
+	defer func() {
+		if success {
+			restGetByHashSuccessGauge.Inc(1)
+		} else {
+			restGetByHashFailureGauge.Inc(1)
+		}
+		restGetByHashDurationHistogram.Update(time.Since(start).Nanoseconds())
+	}()
+
+	hash, responseData, ok := rds.getByHash(w, r, requestPath, getByHashBinaryRequestPath)
+	if !ok {
 		return
 	}
+	restGetByHashReturnedBytesGauge.Inc(int64(len(responseData)))
+
 	w.Header()[cacheControlKey] = []string{cacheControlValue}
+	w.Header().Set("ETag", hashETag(hash))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(responseData))
 	success = true
 }
 
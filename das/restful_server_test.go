@@ -8,8 +8,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -69,3 +73,90 @@ func TestRestfulClientServer(t *testing.T) {
 	err = server.Shutdown()
 	Require(t, err)
 }
+
+func TestRestfulBinaryEndpoint(t *testing.T) {
+	initTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := NewMemoryBackedStorageService(ctx)
+	data := []byte("Testing the binary endpoint of a restful server.")
+	dataHash := dastree.Hash(data)
+	err := storage.Put(ctx, data, uint64(time.Now().Add(time.Hour).Unix()))
+	Require(t, err)
+
+	server, port, err := NewRestfulDasServerOnRandomPort(LocalServerAddressForTest, storage)
+	Require(t, err)
+	defer func() {
+		Require(t, server.Shutdown())
+	}()
+	url := fmt.Sprintf("http://%s:%d%s%s", LocalServerAddressForTest, port, getByHashBinaryRequestPath, EncodeStorageServiceKey(dataHash))
+
+	get := func(header map[string]string) (*http.Response, []byte) {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		Require(t, err)
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		res, err := http.DefaultClient.Do(req)
+		Require(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		Require(t, err)
+		return res, body
+	}
+
+	res, body := get(nil)
+	if res.StatusCode != http.StatusOK || !bytes.Equal(body, data) {
+		Fail(t, "unexpected full response", res.StatusCode, string(body))
+	}
+	etag := res.Header.Get("ETag")
+	if etag != hashETag(dataHash) {
+		Fail(t, "unexpected ETag", etag)
+	}
+	if res.Header.Get(cacheControlKey) != cacheControlValue {
+		Fail(t, "unexpected Cache-Control", res.Header.Get(cacheControlKey))
+	}
+
+	res, _ = get(map[string]string{"If-None-Match": etag})
+	if res.StatusCode != http.StatusNotModified {
+		Fail(t, "expected not modified, got", res.StatusCode)
+	}
+
+	res, body = get(map[string]string{"Range": "bytes=8-15"})
+	if res.StatusCode != http.StatusPartialContent || !bytes.Equal(body, data[8:16]) {
+		Fail(t, "unexpected range response", res.StatusCode, string(body))
+	}
+
+	client := NewRestfulDasClient("http", LocalServerAddressForTest, port)
+	returnedData, err := client.GetByHash(ctx, dataHash)
+	Require(t, err)
+	if !bytes.Equal(data, returnedData) {
+		Fail(t, fmt.Sprintf("Returned data '%s' does not match expected '%s'", returnedData, data))
+	}
+	if atomic.LoadInt32(&client.jsonOnly) != 0 {
+		Fail(t, "client fell back to JSON with a server supporting the binary endpoint")
+	}
+
+	// a server without the binary endpoint rejects its path, and the client falls back to JSON
+	oldServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, getByHashBinaryRequestPath) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		server.ServeHTTP(w, r)
+	}))
+	defer oldServer.Close()
+	oldClient, err := NewRestfulDasClientFromURL(oldServer.URL)
+	Require(t, err)
+	returnedData, err = oldClient.GetByHash(ctx, dataHash)
+	Require(t, err)
+	if !bytes.Equal(data, returnedData) {
+		Fail(t, fmt.Sprintf("Returned data '%s' does not match expected '%s'", returnedData, data))
+	}
+	if atomic.LoadInt32(&oldClient.jsonOnly) == 0 {
+		Fail(t, "client didn't fall back to JSON")
+	}
+}
@@ -6,17 +6,24 @@ package das
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/tenderly/nitro/arbstate"
 	"github.com/tenderly/nitro/das/dastree"
 	"github.com/tenderly/nitro/util/pretty"
+	"github.com/tenderly/nitro/util/stopwaiter"
 
 	"github.com/tenderly/nitro/go-ethereum/common"
 	"github.com/tenderly/nitro/go-ethereum/log"
@@ -32,17 +39,40 @@ type S3Downloader interface {
 	Download(ctx context.Context, w io.WriterAt, input *s3.GetObjectInput, options ...func(*manager.Downloader)) (n int64, err error)
 }
 
+// S3ObjectClient is the part of the S3 client used to walk objects and set up their expiry
+type S3ObjectClient interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	GetBucketLifecycleConfiguration(ctx context.Context, params *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error)
+	PutBucketLifecycleConfiguration(ctx context.Context, params *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error)
+}
+
 type S3StorageServiceConfig struct {
-	Enable              bool   `koanf:"enable"`
-	AccessKey           string `koanf:"access-key"`
-	Bucket              string `koanf:"bucket"`
-	ObjectPrefix        string `koanf:"object-prefix"`
-	Region              string `koanf:"region"`
-	SecretKey           string `koanf:"secret-key"`
-	DiscardAfterTimeout bool   `koanf:"discard-after-timeout"`
+	Enable              bool         `koanf:"enable"`
+	AccessKey           string       `koanf:"access-key"`
+	Bucket              string       `koanf:"bucket"`
+	ObjectPrefix        string       `koanf:"object-prefix"`
+	Region              string       `koanf:"region"`
+	SecretKey           string       `koanf:"secret-key"`
+	DiscardAfterTimeout bool         `koanf:"discard-after-timeout"`
+	Expiry              ExpiryConfig `koanf:"expiry"`
+}
+
+var DefaultS3StorageServiceConfig = S3StorageServiceConfig{
+	Expiry: DefaultExpiryConfig,
 }
 
-var DefaultS3StorageServiceConfig = S3StorageServiceConfig{}
+// The expiry timeout of each object is recorded in this user metadata field
+const s3ExpiryMetadataKey = "expiry"
+
+// Objects are tagged with the number of days they're kept for, including the grace period,
+// and S3 deletes them with a bucket lifecycle rule for each number of days.
+const s3RetentionTagKey = "das-retention-days"
+
+// The numbers of days objects are tagged with, rounding up how long they're kept for, so the bucket only needs
+// a fixed set of lifecycle rules. Objects kept for longer than the last aren't tagged, and are kept forever.
+var s3RetentionDays = []int32{1, 2, 3, 7, 14, 21, 30, 60, 90, 180, 365}
 
 func S3ConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultS3StorageServiceConfig.Enable, "enable storage/retrieval of sequencer batch data from an AWS S3 bucket")
@@ -52,6 +82,10 @@ func S3ConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.String(prefix+".region", DefaultS3StorageServiceConfig.Region, "S3 region")
 	f.String(prefix+".secret-key", DefaultS3StorageServiceConfig.SecretKey, "S3 secret key")
 	f.Bool(prefix+".discard-after-timeout", DefaultS3StorageServiceConfig.DiscardAfterTimeout, "discard data after its expiry timeout")
+	// objects are discarded by lifecycle rules, so the prune interval is only how often they're checked
+	f.Duration(prefix+".expiry.grace-period", DefaultS3StorageServiceConfig.Expiry.GracePeriod, "how long to keep data after its expiry timeout before discarding it, rounded up to whole days")
+	f.Duration(prefix+".expiry.prune-interval", DefaultS3StorageServiceConfig.Expiry.PruneInterval, "how often to check the bucket's lifecycle rules discarding expired data are still in place")
+	f.Bool(prefix+".expiry.dry-run", DefaultS3StorageServiceConfig.Expiry.DryRun, "only tag objects with how long they're kept for, without adding the bucket lifecycle rules deleting them")
 }
 
 type S3StorageService struct {
@@ -60,10 +94,13 @@ type S3StorageService struct {
 	objectPrefix        string
 	uploader            S3Uploader
 	downloader          S3Downloader
+	objects             S3ObjectClient
 	discardAfterTimeout bool
+	expiry              ExpiryConfig
+	stopWaiter          stopwaiter.StopWaiterSafe
 }
 
-func NewS3StorageService(config S3StorageServiceConfig) (StorageService, error) {
+func NewS3StorageService(ctx context.Context, config S3StorageServiceConfig) (StorageService, error) {
 	credCache := aws.NewCredentialsCache(
 		credentials.NewStaticCredentialsProvider(config.AccessKey, config.SecretKey, ""),
 	)
@@ -71,14 +108,38 @@ func NewS3StorageService(config S3StorageServiceConfig) (StorageService, error)
 		Region:      config.Region,
 		Credentials: credCache,
 	})
-	return &S3StorageService{
+	s3s := &S3StorageService{
 		client:              client,
 		bucket:              config.Bucket,
 		objectPrefix:        config.ObjectPrefix,
 		uploader:            manager.NewUploader(client),
 		downloader:          manager.NewDownloader(client),
+		objects:             client,
 		discardAfterTimeout: config.DiscardAfterTimeout,
-	}, nil
+		expiry:              config.Expiry,
+	}
+	if config.DiscardAfterTimeout && config.Expiry.DryRun {
+		log.Info("DAS S3 expiry is a dry run, objects are tagged with their retention but no lifecycle rules are added to delete them", "bucket", config.Bucket)
+	}
+	if config.DiscardAfterTimeout && !config.Expiry.DryRun {
+		// the rules are set up before anything is stored, and restored if they're removed
+		if err := s3s.ensureLifecycleRules(ctx); err != nil {
+			return nil, fmt.Errorf("unable to add the lifecycle rules discarding expired data to S3 bucket %v: %w", config.Bucket, err)
+		}
+		if err := s3s.stopWaiter.Start(ctx); err != nil {
+			return nil, err
+		}
+		err := s3s.stopWaiter.CallIteratively(func(ctx context.Context) time.Duration {
+			if err := s3s.ensureLifecycleRules(ctx); err != nil {
+				log.Warn("failed to check DAS S3 lifecycle rules", "bucket", s3s.bucket, "err", err)
+			}
+			return config.Expiry.PruneInterval
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s3s, nil
 }
 
 func (s3s *S3StorageService) GetByHash(ctx context.Context, key common.Hash) ([]byte, error) {
@@ -97,8 +158,21 @@ func (s3s *S3StorageService) Put(ctx context.Context, value []byte, timeout uint
 	putObjectInput := s3.PutObjectInput{
 		Bucket: aws.String(s3s.bucket),
 		Key:    aws.String(s3s.objectPrefix + EncodeStorageServiceKey(dastree.Hash(value))),
-		Body:   bytes.NewReader(value)}
-	if !s3s.discardAfterTimeout {
+		Body:   bytes.NewReader(value),
+	}
+	if s3s.discardAfterTimeout {
+		// the expiry is only recorded to discard the data
+		expiry, err := s3s.extendExpiry(ctx, putObjectInput.Key, timeout)
+		if err != nil {
+			return err
+		}
+		putObjectInput.Metadata = map[string]string{
+			s3ExpiryMetadataKey: strconv.FormatUint(expiry, 10),
+		}
+		if days, ok := s3s.retentionDays(expiry, time.Now()); ok {
+			putObjectInput.Tagging = aws.String(url.Values{s3RetentionTagKey: {strconv.Itoa(int(days))}}.Encode())
+		}
+	} else {
 		expires := time.Unix(int64(timeout), 0)
 		putObjectInput.Expires = &expires
 	}
@@ -109,16 +183,197 @@ func (s3s *S3StorageService) Put(ctx context.Context, value []byte, timeout uint
 	return err
 }
 
+// extendExpiry returns the later of timeout and the expiry of the object if it's already stored,
+// so storing data again never shortens how long it's kept.
+func (s3s *S3StorageService) extendExpiry(ctx context.Context, key *string, timeout uint64) (uint64, error) {
+	head, err := s3s.objects.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s3s.bucket),
+		Key:    key,
+	})
+	var notFound *types.NotFound
+	if errors.As(err, &notFound) {
+		return timeout, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	existing, err := strconv.ParseUint(head.Metadata[s3ExpiryMetadataKey], 10, 64)
+	if err == nil && existing > timeout {
+		return existing, nil
+	}
+	return timeout, nil
+}
+
+// retentionDays returns the number of days from now an object is kept for, until its expiry and grace period have passed,
+// rounded up to one of the retention days with a lifecycle rule, or false if it's kept forever
+func (s3s *S3StorageService) retentionDays(expiry uint64, now time.Time) (int32, bool) {
+	if expiry > math.MaxInt64 {
+		return 0, false
+	}
+	const day = 24 * time.Hour
+	retention := time.Unix(int64(expiry), 0).Add(s3s.expiry.GracePeriod).Sub(now)
+	days := (retention + day - 1) / day
+	for _, retentionDays := range s3RetentionDays {
+		if days <= time.Duration(retentionDays) {
+			return retentionDays, true
+		}
+	}
+	return 0, false
+}
+
+func (s3s *S3StorageService) lifecycleRuleId(days int32) string {
+	return fmt.Sprintf("das-expiry-%vd-%v", days, s3s.objectPrefix)
+}
+
+// ensureLifecycleRules adds any missing rule to the bucket's lifecycle configuration deleting the objects under the
+// object prefix tagged to be kept for each of the retention days. Other rules in the configuration are kept,
+// and the configuration is only written if a rule is missing.
+func (s3s *S3StorageService) ensureLifecycleRules(ctx context.Context) error {
+	var rules []types.LifecycleRule
+	current, err := s3s.objects.GetBucketLifecycleConfiguration(ctx, &s3.GetBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s3s.bucket),
+	})
+	var apiErr interface{ ErrorCode() string }
+	if err == nil {
+		rules = current.Rules
+	} else if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "NoSuchLifecycleConfiguration" {
+		return err
+	}
+	existing := make(map[string]bool)
+	for _, rule := range rules {
+		existing[aws.ToString(rule.ID)] = true
+	}
+	var added []string
+	for _, days := range s3RetentionDays {
+		id := s3s.lifecycleRuleId(days)
+		if existing[id] {
+			continue
+		}
+		tag := types.Tag{Key: aws.String(s3RetentionTagKey), Value: aws.String(strconv.Itoa(int(days)))}
+		var filter types.LifecycleRuleFilter = &types.LifecycleRuleFilterMemberTag{Value: tag}
+		if s3s.objectPrefix != "" {
+			filter = &types.LifecycleRuleFilterMemberAnd{Value: types.LifecycleRuleAndOperator{
+				Prefix: aws.String(s3s.objectPrefix),
+				Tags:   []types.Tag{tag},
+			}}
+		}
+		rules = append(rules, types.LifecycleRule{
+			ID:         aws.String(id),
+			Status:     types.ExpirationStatusEnabled,
+			Filter:     filter,
+			Expiration: &types.LifecycleExpiration{Days: days},
+		})
+		added = append(added, id)
+	}
+	if len(added) == 0 {
+		return nil
+	}
+	_, err = s3s.objects.PutBucketLifecycleConfiguration(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket:                 aws.String(s3s.bucket),
+		LifecycleConfiguration: &types.BucketLifecycleConfiguration{Rules: rules},
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("added DAS S3 lifecycle rules", "bucket", s3s.bucket, "rules", added)
+	return nil
+}
+
 func (s3s *S3StorageService) Sync(ctx context.Context) error {
 	return nil
 }
 
+// Walk lists the objects named by their key under the object prefix, reading their expiry from their metadata
+func (s3s *S3StorageService) Walk(ctx context.Context, after common.Hash, visit func(StorageEntry) error) error {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s3s.bucket),
+		Prefix: aws.String(s3s.objectPrefix),
+	}
+	if after != (common.Hash{}) {
+		input.StartAfter = aws.String(s3s.objectPrefix + EncodeStorageServiceKey(after))
+	}
+	paginator := s3.NewListObjectsV2Paginator(s3s.objects, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, object := range page.Contents {
+			name := aws.ToString(object.Key)
+			encodedKey := strings.TrimPrefix(name, s3s.objectPrefix)
+			if len(encodedKey) != 2*common.HashLength {
+				// quarantined and unrelated objects
+				continue
+			}
+			key, err := DecodeStorageServiceKey(encodedKey)
+			if err != nil || bytes.Compare(key[:], after[:]) <= 0 {
+				continue
+			}
+			entry := StorageEntry{
+				Key:  key,
+				Size: uint64(object.Size),
+				name: name,
+			}
+			head, err := s3s.objects.HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(s3s.bucket),
+				Key:    object.Key,
+			})
+			if err != nil {
+				return err
+			}
+			if encodedTimeout, ok := head.Metadata[s3ExpiryMetadataKey]; ok {
+				timeout, err := strconv.ParseUint(encodedTimeout, 10, 64)
+				if err != nil {
+					log.Warn("invalid expiry on DAS S3 object", "key", name, "expiry", encodedTimeout)
+				} else {
+					entry.Timeout = timeout
+				}
+			}
+			if err := visit(entry); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s3s *S3StorageService) Read(ctx context.Context, entry StorageEntry) ([]byte, error) {
+	buf := manager.NewWriteAtBuffer([]byte{})
+	_, err := s3s.downloader.Download(ctx, buf, &s3.GetObjectInput{
+		Bucket: aws.String(s3s.bucket),
+		Key:    aws.String(entry.name),
+	})
+	return buf.Bytes(), err
+}
+
+// Quarantine moves the object under the quarantine prefix, within the object prefix
+func (s3s *S3StorageService) Quarantine(ctx context.Context, entry StorageEntry) error {
+	data, err := s3s.Read(ctx, entry)
+	if err != nil {
+		return err
+	}
+	_, err = s3s.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s3s.bucket),
+		Key:    aws.String(s3s.objectPrefix + quarantineDir + "/" + EncodeStorageServiceKey(entry.Key)),
+		Body:   bytes.NewReader(data),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s3s.objects.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s3s.bucket),
+		Key:    aws.String(entry.name),
+	})
+	return err
+}
+
 func (s3s *S3StorageService) Close(ctx context.Context) error {
+	s3s.stopWaiter.StopAndWait()
 	return nil
 }
 
 func (s3s *S3StorageService) ExpirationPolicy(ctx context.Context) (arbstate.ExpirationPolicy, error) {
-	if s3s.discardAfterTimeout {
+	if s3s.discardAfterTimeout && !s3s.expiry.DryRun {
 		return arbstate.DiscardAfterDataTimeout, nil
 	} else {
 		return arbstate.KeepForever, nil
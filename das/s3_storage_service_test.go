@@ -7,12 +7,20 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 
 	"github.com/tenderly/nitro/cmd/genericconf"
 	"github.com/tenderly/nitro/das/dastree"
@@ -90,3 +98,187 @@ func TestS3StorageService(t *testing.T) {
 		t.Fatal(val, val1)
 	}
 }
+
+type mockS3Object struct {
+	data     []byte
+	metadata map[string]string
+	tagging  string
+}
+
+// mockS3Objects is an in memory bucket that keeps object metadata and lifecycle rules, for testing expiry
+type mockS3Objects struct {
+	mutex          sync.Mutex
+	objects        map[string]mockS3Object
+	lifecycleRules []types.LifecycleRule
+	lifecyclePuts  int
+}
+
+func (m *mockS3Objects) Upload(ctx context.Context, input *s3.PutObjectInput, opts ...func(*manager.Uploader)) (*manager.UploadOutput, error) {
+	data, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.objects[*input.Key] = mockS3Object{data, input.Metadata, aws.ToString(input.Tagging)}
+	return &manager.UploadOutput{}, nil
+}
+
+func (m *mockS3Objects) Download(ctx context.Context, w io.WriterAt, input *s3.GetObjectInput, options ...func(*manager.Downloader)) (n int64, err error) {
+	m.mutex.Lock()
+	object, ok := m.objects[*input.Key]
+	m.mutex.Unlock()
+	if !ok {
+		return 0, ErrNotFound
+	}
+	ret, err := w.WriteAt(object.data, 0)
+	return int64(ret), err
+}
+
+func (m *mockS3Objects) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	var keys []string
+	for key := range m.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	output := &s3.ListObjectsV2Output{}
+	for _, key := range keys {
+		output.Contents = append(output.Contents, types.Object{Key: aws.String(key), Size: int64(len(m.objects[key].data))})
+	}
+	return output, nil
+}
+
+func (m *mockS3Objects) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	object, ok := m.objects[*params.Key]
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	return &s3.HeadObjectOutput{ContentLength: int64(len(object.data)), Metadata: object.metadata}, nil
+}
+
+func (m *mockS3Objects) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.objects, *params.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+type mockNoSuchLifecycleConfiguration struct{}
+
+func (mockNoSuchLifecycleConfiguration) Error() string {
+	return "The lifecycle configuration does not exist"
+}
+
+func (mockNoSuchLifecycleConfiguration) ErrorCode() string {
+	return "NoSuchLifecycleConfiguration"
+}
+
+func (m *mockS3Objects) GetBucketLifecycleConfiguration(ctx context.Context, params *s3.GetBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.GetBucketLifecycleConfigurationOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if len(m.lifecycleRules) == 0 {
+		return nil, mockNoSuchLifecycleConfiguration{}
+	}
+	return &s3.GetBucketLifecycleConfigurationOutput{Rules: append([]types.LifecycleRule{}, m.lifecycleRules...)}, nil
+}
+
+func (m *mockS3Objects) PutBucketLifecycleConfiguration(ctx context.Context, params *s3.PutBucketLifecycleConfigurationInput, optFns ...func(*s3.Options)) (*s3.PutBucketLifecycleConfigurationOutput, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lifecycleRules = params.LifecycleConfiguration.Rules
+	m.lifecyclePuts++
+	return &s3.PutBucketLifecycleConfigurationOutput{}, nil
+}
+
+func TestS3StorageServiceExpiry(t *testing.T) {
+	ctx := context.Background()
+	objects := &mockS3Objects{objects: make(map[string]mockS3Object)}
+	// rules added by the bucket's owner are kept
+	objects.lifecycleRules = []types.LifecycleRule{{ID: aws.String("other"), Status: types.ExpirationStatusEnabled}}
+	s3Service := &S3StorageService{
+		objectPrefix:        "das/",
+		uploader:            objects,
+		downloader:          objects,
+		objects:             objects,
+		discardAfterTimeout: true,
+		expiry:              DefaultExpiryConfig,
+	}
+	object := func(data []byte) mockS3Object {
+		return objects.objects["das/"+EncodeStorageServiceKey(dastree.Hash(data))]
+	}
+
+	now := time.Now()
+	short := []byte("short")
+	long := []byte("long")
+	Require(t, s3Service.Put(ctx, short, uint64(now.Add(time.Hour).Unix())))
+	longTimeout := uint64(now.Add(10 * 24 * time.Hour).Unix())
+	Require(t, s3Service.Put(ctx, long, longTimeout))
+	// storing data again doesn't shorten its expiry
+	Require(t, s3Service.Put(ctx, long, uint64(now.Add(time.Hour).Unix())))
+
+	if tagging := object(short).tagging; tagging != s3RetentionTagKey+"=1" {
+		Fail(t, "unexpected tagging", tagging)
+	}
+	// 10 days and the grace period round up to 14
+	if tagging := object(long).tagging; tagging != s3RetentionTagKey+"=14" {
+		Fail(t, "unexpected tagging of data stored again", tagging)
+	}
+	if expiry := object(long).metadata[s3ExpiryMetadataKey]; expiry != strconv.FormatUint(longTimeout, 10) {
+		Fail(t, "expiry of data stored again was shortened", expiry)
+	}
+	if objects.lifecyclePuts != 0 {
+		Fail(t, "bucket lifecycle configuration changed when storing data")
+	}
+
+	// the fixed rules are only written when one is missing
+	Require(t, s3Service.ensureLifecycleRules(ctx))
+	Require(t, s3Service.ensureLifecycleRules(ctx))
+	ruleIds := func() string {
+		var ids []string
+		for _, rule := range objects.lifecycleRules {
+			ids = append(ids, aws.ToString(rule.ID))
+		}
+		return strings.Join(ids, ",")
+	}
+	expectedIds := "other"
+	for _, days := range s3RetentionDays {
+		expectedIds += fmt.Sprintf(",das-expiry-%vd-das/", days)
+	}
+	if ruleIds() != expectedIds || objects.lifecyclePuts != 1 {
+		Fail(t, "unexpected lifecycle rules", ruleIds(), objects.lifecyclePuts)
+	}
+	filter, ok := objects.lifecycleRules[5].Filter.(*types.LifecycleRuleFilterMemberAnd)
+	if !ok || aws.ToString(filter.Value.Prefix) != "das/" || aws.ToString(filter.Value.Tags[0].Value) != "14" || objects.lifecycleRules[5].Expiration.Days != 14 {
+		Fail(t, "unexpected lifecycle rule", objects.lifecycleRules[5])
+	}
+	// a removed rule is restored
+	objects.lifecycleRules = append(objects.lifecycleRules[:5], objects.lifecycleRules[6:]...)
+	Require(t, s3Service.ensureLifecycleRules(ctx))
+	if !strings.Contains(ruleIds(), "das-expiry-14d-das/") || objects.lifecyclePuts != 2 {
+		Fail(t, "removed lifecycle rule wasn't restored", ruleIds())
+	}
+
+	// data kept forever isn't tagged
+	forever := []byte("forever")
+	Require(t, s3Service.Put(ctx, forever, math.MaxUint64))
+	if tagging := object(forever).tagging; tagging != "" {
+		Fail(t, "data kept forever was tagged", tagging)
+	}
+	// nor is data kept for longer than the longest lifecycle rule
+	twoYears := []byte("two years")
+	Require(t, s3Service.Put(ctx, twoYears, uint64(now.Add(2*365*24*time.Hour).Unix())))
+	if tagging := object(twoYears).tagging; tagging != "" {
+		Fail(t, "data kept longer than the longest rule was tagged", tagging)
+	}
+	// nor is data when it isn't discarded after its timeout
+	s3Service.discardAfterTimeout = false
+	unrecorded := []byte("unrecorded")
+	Require(t, s3Service.Put(ctx, unrecorded, uint64(now.Unix())))
+	if object(unrecorded).tagging != "" || len(object(unrecorded).metadata) != 0 {
+		Fail(t, "expiry recorded without discarding data after its timeout", object(unrecorded))
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/tenderly/nitro/blsSignatures"
 	"github.com/tenderly/nitro/das/dastree"
 	"github.com/tenderly/nitro/solgen/go/bridgegen"
+	"github.com/tenderly/nitro/util/l1client"
 	"github.com/tenderly/nitro/util/pretty"
 
 	flag "github.com/spf13/pflag"
@@ -26,8 +27,9 @@ import (
 var ErrDasKeysetNotFound = errors.New("no such keyset")
 
 type KeyConfig struct {
-	KeyDir  string `koanf:"key-dir"`
-	PrivKey string `koanf:"priv-key"`
+	KeyDir        string   `koanf:"key-dir"`
+	PrivKey       string   `koanf:"priv-key"`
+	ExtraPrivKeys []string `koanf:"extra-priv-keys"`
 }
 
 var DefaultKeyConfig = KeyConfig{}
@@ -35,6 +37,21 @@ var DefaultKeyConfig = KeyConfig{}
 func KeyConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.String(prefix+".key-dir", DefaultKeyConfig.KeyDir, fmt.Sprintf("the directory to read the bls keypair ('%s' and '%s') from; if using any of the DAS storage types exactly one of key-dir or priv-key must be specified", DefaultPubKeyFilename, DefaultPrivKeyFilename))
 	f.String(prefix+".priv-key", DefaultKeyConfig.PrivKey, "the base64 BLS private key to use for signing DAS certificates; if using any of the DAS storage types exactly one of key-dir or priv-key must be specified")
+	f.StringSlice(prefix+".extra-priv-keys", DefaultKeyConfig.ExtraPrivKeys, "additional base64 BLS private keys to sign with when a store asks for their public key, to serve both an old and a new keyset while rotating keys")
+}
+
+type signingPubKeyContextKey struct{}
+
+// WithSigningPubKey requests the certificate for a store be signed with the private key of pubKey,
+// for committee members holding more than one key while keysets are rotated.
+func WithSigningPubKey(ctx context.Context, pubKey blsSignatures.PublicKey) context.Context {
+	return context.WithValue(ctx, signingPubKeyContextKey{}, pubKey)
+}
+
+// SigningPubKeyFromContext returns the public key requested by WithSigningPubKey, if any
+func SigningPubKeyFromContext(ctx context.Context) (blsSignatures.PublicKey, bool) {
+	pubKey, ok := ctx.Value(signingPubKeyContextKey{}).(blsSignatures.PublicKey)
+	return pubKey, ok
 }
 
 // Provides DAS signature functionality over a StorageService by adapting
@@ -43,6 +60,7 @@ func KeyConfigAddOptions(prefix string, f *flag.FlagSet) {
 //
 // 1) SignAfterStoreDAS.Store(...) assembles the returned hash into a
 // DataAvailabilityCertificate and signs it with its BLS private key.
+// If it has extra keys, the key is chosen by the public key requested with WithSigningPubKey.
 //
 // 2) If Sequencer Inbox contract details are provided when a SignAfterStoreDAS is
 // constructed, calls to Store(...) will try to verify the passed-in data's signature
@@ -50,21 +68,49 @@ func KeyConfigAddOptions(prefix string, f *flag.FlagSet) {
 // signature is not checked, which is useful for testing.
 type SignAfterStoreDAS struct {
 	config         KeyConfig
-	privKey        *blsSignatures.PrivateKey
-	keysetHash     [32]byte
-	keysetBytes    []byte
+	keys           []signingKey // the primary key comes first
 	storageService StorageService
 	bpVerifier     *BatchPosterVerifier
+	l1Client       *l1client.Client // if connected to by NewSignAfterStoreDAS, stopped by Close
 }
 
-func NewSignAfterStoreDAS(ctx context.Context, config DataAvailabilityConfig, storageService StorageService) (*SignAfterStoreDAS, error) {
-	if config.L1NodeURL == "none" {
-		return NewSignAfterStoreDASWithSeqInboxCaller(ctx, config.KeyConfig, nil, storageService)
+type signingKey struct {
+	privKey     *blsSignatures.PrivateKey
+	pubKey      blsSignatures.PublicKey
+	keysetHash  [32]byte
+	keysetBytes []byte
+}
+
+func newSigningKey(privKey *blsSignatures.PrivateKey) (*signingKey, error) {
+	publicKey, err := blsSignatures.PublicKeyFromPrivateKey(*privKey)
+	if err != nil {
+		return nil, err
+	}
+
+	keyset := &arbstate.DataAvailabilityKeyset{
+		AssumedHonest: 1,
+		PubKeys:       []blsSignatures.PublicKey{publicKey},
+	}
+	ksBuf := bytes.NewBuffer([]byte{})
+	if err := keyset.Serialize(ksBuf); err != nil {
+		return nil, err
 	}
-	l1client, err := GetL1Client(ctx, config.L1ConnectionAttempts, config.L1NodeURL)
+	ksHash, err := keyset.Hash()
 	if err != nil {
 		return nil, err
 	}
+	return &signingKey{
+		privKey:     privKey,
+		pubKey:      publicKey,
+		keysetHash:  ksHash,
+		keysetBytes: ksBuf.Bytes(),
+	}, nil
+}
+
+func NewSignAfterStoreDAS(ctx context.Context, config DataAvailabilityConfig, storageService StorageService) (*SignAfterStoreDAS, error) {
+	if config.L1NodeURL == "none" {
+		return NewSignAfterStoreDASWithSeqInboxCaller(ctx, config.KeyConfig, nil, storageService)
+	}
 	seqInboxAddress, err := OptionalAddressFromString(config.SequencerInboxAddress)
 	if err != nil {
 		return nil, err
@@ -72,12 +118,23 @@ func NewSignAfterStoreDAS(ctx context.Context, config DataAvailabilityConfig, st
 	if seqInboxAddress == nil {
 		return NewSignAfterStoreDASWithSeqInboxCaller(ctx, config.KeyConfig, nil, storageService)
 	}
+	l1client, err := GetL1Client(ctx, config.L1ConnectionAttempts, config.L1NodeURL, &config.L1Client)
+	if err != nil {
+		return nil, err
+	}
 
 	seqInboxCaller, err := bridgegen.NewSequencerInboxCaller(*seqInboxAddress, l1client)
 	if err != nil {
+		l1client.StopAndWait()
+		return nil, err
+	}
+	das, err := NewSignAfterStoreDASWithSeqInboxCaller(ctx, config.KeyConfig, seqInboxCaller, storageService)
+	if err != nil {
+		l1client.StopAndWait()
 		return nil, err
 	}
-	return NewSignAfterStoreDASWithSeqInboxCaller(ctx, config.KeyConfig, seqInboxCaller, storageService)
+	das.l1Client = l1client
+	return das, nil
 }
 
 func NewSignAfterStoreDASWithSeqInboxCaller(
@@ -104,22 +161,21 @@ func NewSignAfterStoreDASWithSeqInboxCaller(
 		}
 	}
 
-	publicKey, err := blsSignatures.PublicKeyFromPrivateKey(*privKey)
+	key, err := newSigningKey(privKey)
 	if err != nil {
 		return nil, err
 	}
-
-	keyset := &arbstate.DataAvailabilityKeyset{
-		AssumedHonest: 1,
-		PubKeys:       []blsSignatures.PublicKey{publicKey},
-	}
-	ksBuf := bytes.NewBuffer([]byte{})
-	if err := keyset.Serialize(ksBuf); err != nil {
-		return nil, err
-	}
-	ksHash, err := keyset.Hash()
-	if err != nil {
-		return nil, err
+	keys := []signingKey{*key}
+	for i, encoded := range config.ExtraPrivKeys {
+		extraPrivKey, err := DecodeBase64BLSPrivateKey([]byte(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("'extra-priv-keys' entry %d was invalid: %w", i, err)
+		}
+		extraKey, err := newSigningKey(extraPrivKey)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *extraKey)
 	}
 
 	var bpVerifier *BatchPosterVerifier
@@ -129,14 +185,16 @@ func NewSignAfterStoreDASWithSeqInboxCaller(
 
 	return &SignAfterStoreDAS{
 		config:         config,
-		privKey:        privKey,
-		keysetHash:     ksHash,
-		keysetBytes:    ksBuf.Bytes(),
+		keys:           keys,
 		storageService: storageService,
 		bpVerifier:     bpVerifier,
 	}, nil
 }
 
+func (d *SignAfterStoreDAS) AuthorizeChunkedStore(ctx context.Context, chunkHashes []common.Hash, totalSize uint64, timeout uint64, sig []byte) (common.Address, error) {
+	return authorizeChunkedStore(ctx, d.bpVerifier, chunkHashes, totalSize, timeout, sig)
+}
+
 func (d *SignAfterStoreDAS) Store(
 	ctx context.Context, message []byte, timeout uint64, sig []byte,
 ) (c *arbstate.DataAvailabilityCertificate, err error) {
@@ -155,6 +213,11 @@ func (d *SignAfterStoreDAS) Store(
 		}
 	}
 
+	key, err := d.signingKeyFor(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	c = &arbstate.DataAvailabilityCertificate{
 		Timeout:     timeout,
 		DataHash:    dastree.Hash(message),
@@ -163,7 +226,7 @@ func (d *SignAfterStoreDAS) Store(
 	}
 
 	fields := c.SerializeSignableFields()
-	c.Sig, err = blsSignatures.SignMessage(*d.privKey, fields)
+	c.Sig, err = blsSignatures.SignMessage(*key.privKey, fields)
 	if err != nil {
 		return nil, err
 	}
@@ -177,15 +240,38 @@ func (d *SignAfterStoreDAS) Store(
 		return nil, err
 	}
 
-	c.KeysetHash = d.keysetHash
+	c.KeysetHash = key.keysetHash
 
 	return c, nil
 }
 
+// signingKeyFor returns the key requested with WithSigningPubKey, or the primary key if none was requested
+func (d *SignAfterStoreDAS) signingKeyFor(ctx context.Context) (*signingKey, error) {
+	pubKey, ok := SigningPubKeyFromContext(ctx)
+	if !ok {
+		return &d.keys[0], nil
+	}
+	requested := blsSignatures.PublicKeyToBytes(pubKey)
+	for i := range d.keys {
+		if bytes.Equal(blsSignatures.PublicKeyToBytes(d.keys[i].pubKey), requested) {
+			return &d.keys[i], nil
+		}
+	}
+	return nil, errors.New("store requested a signature from a BLS key this DAS doesn't have")
+}
+
 func (d *SignAfterStoreDAS) GetByHash(ctx context.Context, hash common.Hash) ([]byte, error) {
 	return d.storageService.GetByHash(ctx, hash)
 }
 
+// Close stops the L1 client connected to by NewSignAfterStoreDAS, if any. It doesn't close the storage service.
+func (d *SignAfterStoreDAS) Close(ctx context.Context) error {
+	if d.l1Client != nil {
+		d.l1Client.StopAndWait()
+	}
+	return nil
+}
+
 func (d *SignAfterStoreDAS) String() string {
 	return fmt.Sprintf("SignAfterStoreDAS{config:%v}", d.config)
 }
@@ -33,6 +33,8 @@ type RestfulClientAggregatorConfig struct {
 	StrategyUpdateInterval             time.Duration                      `koanf:"strategy-update-interval"`
 	WaitBeforeTryNext                  time.Duration                      `koanf:"wait-before-try-next"`
 	MaxPerEndpointStats                int                                `koanf:"max-per-endpoint-stats"`
+	StatsFile                          string                             `koanf:"stats-file"`
+	BlacklistDuration                  time.Duration                      `koanf:"blacklist-duration"`
 	SimpleExploreExploitStrategyConfig SimpleExploreExploitStrategyConfig `koanf:"simple-explore-exploit-strategy"`
 	SyncToStorageConfig                SyncToStorageConfig                `koanf:"sync-to-storage"`
 }
@@ -45,6 +47,8 @@ var DefaultRestfulClientAggregatorConfig = RestfulClientAggregatorConfig{
 	StrategyUpdateInterval:             10 * time.Second,
 	WaitBeforeTryNext:                  2 * time.Second,
 	MaxPerEndpointStats:                20,
+	StatsFile:                          "",
+	BlacklistDuration:                  24 * time.Hour,
 	SimpleExploreExploitStrategyConfig: DefaultSimpleExploreExploitStrategyConfig,
 	SyncToStorageConfig:                DefaultSyncToStorageConfig,
 }
@@ -62,12 +66,14 @@ var DefaultSimpleExploreExploitStrategyConfig = SimpleExploreExploitStrategyConf
 func RestfulClientAggregatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultRestfulClientAggregatorConfig.Enable, "enable retrieval of sequencer batch data from a list of remote REST endpoints; if other DAS storage types are enabled, this mode is used as a fallback")
 	f.StringSlice(prefix+".urls", DefaultRestfulClientAggregatorConfig.Urls, "list of URLs including 'http://' or 'https://' prefixes and port numbers to REST DAS endpoints; additive with the online-url-list option")
-	f.String(prefix+".online-url-list", DefaultRestfulClientAggregatorConfig.OnlineUrlList, "a URL to a list of URLs of REST das endpoints that is checked at startup; additive with the url option")
+	f.String(prefix+".online-url-list", DefaultRestfulClientAggregatorConfig.OnlineUrlList, "a URL to a list of URLs of REST das endpoints that is checked at startup and then periodically; additive with the url option")
 	f.Duration(prefix+".online-url-list-fetch-interval", DefaultRestfulClientAggregatorConfig.OnlineUrlListFetchInterval, "time interval to periodically fetch url list from online-url-list")
 	f.String(prefix+".strategy", DefaultRestfulClientAggregatorConfig.Strategy, "strategy to use to determine order and parallelism of calling REST endpoint URLs; valid options are 'simple-explore-exploit'")
 	f.Duration(prefix+".strategy-update-interval", DefaultRestfulClientAggregatorConfig.StrategyUpdateInterval, "how frequently to update the strategy with endpoint latency and error rate data")
 	f.Duration(prefix+".wait-before-try-next", DefaultRestfulClientAggregatorConfig.WaitBeforeTryNext, "time to wait until trying the next set of REST endpoints while waiting for a response; the next set of REST endpoints is determined by the strategy selected")
 	f.Int(prefix+".max-per-endpoint-stats", DefaultRestfulClientAggregatorConfig.MaxPerEndpointStats, "number of stats entries (latency and success rate) to keep for each REST endpoint; controls whether strategy is faster or slower to respond to changing conditions")
+	f.String(prefix+".stats-file", DefaultRestfulClientAggregatorConfig.StatsFile, "file to persist the REST endpoint stats and blacklist to, so they are kept across restarts; not persisted if empty")
+	f.Duration(prefix+".blacklist-duration", DefaultRestfulClientAggregatorConfig.BlacklistDuration, "how long to stop using a REST endpoint after it returned data not matching the requested hash; 0 to never blacklist")
 	SimpleExploreExploitStrategyConfigAddOptions(prefix+".simple-explore-exploit-strategy", f)
 	SyncToStorageConfigAddOptions(prefix+".sync-to-storage", f)
 }
@@ -79,8 +85,11 @@ func SimpleExploreExploitStrategyConfigAddOptions(prefix string, f *flag.FlagSet
 
 func NewRestfulClientAggregator(ctx context.Context, config *RestfulClientAggregatorConfig) (*SimpleDASReaderAggregator, error) {
 	a := SimpleDASReaderAggregator{
-		config: config,
-		stats:  make(map[arbstate.DataAvailabilityReader]readerStats),
+		config:    config,
+		stats:     make(map[arbstate.DataAvailabilityReader]readerStats),
+		urls:      make(map[arbstate.DataAvailabilityReader]string),
+		blacklist: make(map[string]time.Time),
+		metrics:   make(map[string]*endpointMetrics),
 	}
 
 	combinedUrls := make(map[string]bool)
@@ -107,15 +116,24 @@ func NewRestfulClientAggregator(ctx context.Context, config *RestfulClientAggreg
 
 	log.Info("REST Aggregator URLs", "urls", urls)
 
+	saved, err := loadEndpointStats(config.StatsFile)
+	if err != nil {
+		log.Warn("Failed to load REST aggregator stats, starting without them", "file", config.StatsFile, "err", err)
+	}
+	now := time.Now()
+	for url, endpoint := range saved {
+		if endpoint.BlacklistedUntil > now.Unix() {
+			a.blacklist[url] = time.Unix(endpoint.BlacklistedUntil, 0)
+		}
+	}
 	for _, url := range urls {
 		reader, err := NewRestfulDasClientFromURL(url)
 		if err != nil {
 			return nil, err
 		}
-		a.readers = append(a.readers, reader)
-		a.stats[reader] = make([]readerStat, 0, config.MaxPerEndpointStats)
+		a.addReader(url, reader, saved[url].readerStats(config.MaxPerEndpointStats))
 	}
-	a.statMessages = make(chan readerStatMessage, len(config.Urls)*2)
+	a.statMessages = make(chan readerStatMessage, len(urls)*2)
 
 	switch strings.ToLower(config.Strategy) {
 	case "simple-explore-exploit":
@@ -128,7 +146,7 @@ func NewRestfulClientAggregator(ctx context.Context, config *RestfulClientAggreg
 	default:
 		return nil, fmt.Errorf("Unknown RestfulClientAggregator strategy '%s', use --help to see available strategies.", config.Strategy)
 	}
-	a.strategy.update(a.readers, a.stats)
+	a.updateStrategy(now)
 	return &a, nil
 }
 
@@ -136,6 +154,15 @@ type readerStats []readerStat
 
 // Return the mean latency, weighted inversely by the ratio of successes : total attempts
 func (s *readerStats) successRatioWeightedMeanLatency() time.Duration {
+	successRatio, avgLatency := s.successRatioAndMeanLatency()
+	if successRatio == 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(float64(avgLatency) / successRatio)
+}
+
+// Return the ratio of successes : total attempts, and the mean latency of the successes
+func (s *readerStats) successRatioAndMeanLatency() (float64, time.Duration) {
 	successes, totalAttempts := 0.0, 0.0
 	var totalLatency time.Duration
 	for _, stat := range *s {
@@ -146,11 +173,9 @@ func (s *readerStats) successRatioWeightedMeanLatency() time.Duration {
 		totalAttempts++
 	}
 	if successes == 0 {
-		return time.Duration(math.MaxInt64)
+		return 0, 0
 	}
-	avgLatency := float64(totalLatency) / successes
-	successRatio := successes / totalAttempts
-	return time.Duration(avgLatency / successRatio)
+	return successes / totalAttempts, time.Duration(float64(totalLatency) / successes)
 }
 
 type readerStat struct {
@@ -160,7 +185,8 @@ type readerStat struct {
 
 type readerStatMessage struct {
 	readerStat
-	reader arbstate.DataAvailabilityReader
+	reader       arbstate.DataAvailabilityReader
+	hashMismatch bool
 }
 
 type SimpleDASReaderAggregator struct {
@@ -169,9 +195,14 @@ type SimpleDASReaderAggregator struct {
 	config *RestfulClientAggregatorConfig
 
 	readersMutex sync.RWMutex
-	// readers and stats are only to be updated by the stats goroutine
-	readers []arbstate.DataAvailabilityReader
-	stats   map[arbstate.DataAvailabilityReader]readerStats
+	// readers, stats, urls, blacklist and metrics are only to be updated by the stats goroutine
+	readers   []arbstate.DataAvailabilityReader
+	stats     map[arbstate.DataAvailabilityReader]readerStats
+	urls      map[arbstate.DataAvailabilityReader]string
+	blacklist map[string]time.Time // until when each URL that returned data not matching its hash isn't used
+	metrics   map[string]*endpointMetrics
+
+	statsDirty bool // whether stats have changed since they were last saved
 
 	strategy aggregatorStrategy
 
@@ -193,13 +224,21 @@ func (a *SimpleDASReaderAggregator) GetByHash(ctx context.Context, hash common.H
 	defer cancel()
 
 	go func() {
+		// The strategy may not return every reader, so results is closed once all the ones it returned are done
+		allWg := sync.WaitGroup{}
+		defer func() {
+			allWg.Wait()
+			close(results)
+		}()
 		si := a.strategy.newInstance()
 		for readers := si.nextReaders(); len(readers) != 0 && subCtx.Err() == nil; readers = si.nextReaders() {
 			wg := sync.WaitGroup{}
 			waitChan := make(chan interface{})
 			for _, reader := range readers {
 				wg.Add(1)
+				allWg.Add(1)
 				go func(reader arbstate.DataAvailabilityReader) {
+					defer allWg.Done()
 					defer wg.Done()
 					data, err := a.tryGetByHash(subCtx, hash, reader)
 					if err != nil && errors.Is(ctx.Err(), context.Canceled) {
@@ -226,11 +265,14 @@ func (a *SimpleDASReaderAggregator) GetByHash(ctx context.Context, hash common.H
 	}()
 
 	var errorCollection []error
-	for i := 0; i < len(a.readers); i++ {
+	for {
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
-		case result := <-results:
+		case result, ok := <-results:
+			if !ok {
+				return nil, fmt.Errorf("Data wasn't able to be retrieved from any DAS Reader: %v", errorCollection)
+			}
 			if result.err != nil {
 				errorCollection = append(errorCollection, result.err)
 			} else {
@@ -238,8 +280,6 @@ func (a *SimpleDASReaderAggregator) GetByHash(ctx context.Context, hash common.H
 			}
 		}
 	}
-
-	return nil, fmt.Errorf("Data wasn't able to be retrieved from any DAS Reader: %v", errorCollection)
 }
 
 func (a *SimpleDASReaderAggregator) tryGetByHash(
@@ -254,10 +294,11 @@ func (a *SimpleDASReaderAggregator) tryGetByHash(
 		if dastree.ValidHash(hash, result) {
 			stat.success = true
 		} else {
-			err = fmt.Errorf("SimpleDASReaderAggregator got result from reader(%v) not matching hash", reader)
+			err = fmt.Errorf("SimpleDASReaderAggregator got result from reader(%v) not matching hash: %w", reader, arbstate.ErrHashMismatch)
 		}
 	}
 	stat.latency = time.Since(start)
+	stat.hashMismatch = errors.Is(err, arbstate.ErrHashMismatch)
 
 	select {
 	case a.statMessages <- stat:
@@ -271,37 +312,50 @@ func (a *SimpleDASReaderAggregator) tryGetByHash(
 
 func (a *SimpleDASReaderAggregator) Start(ctx context.Context) {
 	a.StopWaiter.Start(ctx)
-	onlineUrlsChan := StartRestfulServerListFetchDaemon(a.StopWaiter.GetContext(), a.config.OnlineUrlList, a.config.OnlineUrlListFetchInterval)
+	var onlineUrlsChan <-chan []string
+	if a.config.OnlineUrlList != "" {
+		onlineUrlsChan = StartRestfulServerListFetchDaemon(a.StopWaiter.GetContext(), a.config.OnlineUrlList, a.config.OnlineUrlListFetchInterval)
+	}
 
 	updateRestfulDasClients := func(urls []string) {
 		a.readersMutex.Lock()
 		defer a.readersMutex.Unlock()
-		combinedUrls := a.config.Urls
-		combinedUrls = append(combinedUrls, urls...)
-		combinedReaders := make(map[arbstate.DataAvailabilityReader]bool)
-		for _, url := range combinedUrls {
-			reader, err := NewRestfulDasClientFromURL(url)
-			if err != nil {
-				return
-			}
-			combinedReaders[reader] = true
+		combinedUrls := make(map[string]bool)
+		for _, url := range append(append([]string{}, a.config.Urls...), urls...) {
+			combinedUrls[url] = true
+		}
+		// Keep the existing readers, and their stats, for URLs still listed
+		existing := make(map[string]arbstate.DataAvailabilityReader)
+		for reader, url := range a.urls {
+			existing[url] = reader
 		}
+		oldReaders := a.readers
 		a.readers = make([]arbstate.DataAvailabilityReader, 0, len(combinedUrls))
-		// Update reader and add newly added stats
-		for reader := range combinedReaders {
-			a.readers = append(a.readers, reader)
-			if _, ok := a.stats[reader]; ok {
+		for url := range combinedUrls {
+			if reader, ok := existing[url]; ok {
+				a.readers = append(a.readers, reader)
+				continue
+			}
+			reader, err := NewRestfulDasClientFromURL(url)
+			if err != nil {
+				log.Warn("Ignoring invalid REST DAS URL from online list", "url", url, "err", err)
 				continue
 			}
-			a.stats[reader] = make([]readerStat, 0, a.config.MaxPerEndpointStats)
+			log.Info("Adding REST DAS endpoint from online list", "url", url)
+			a.addReader(url, reader, nil)
 		}
-		// Delete stats for removed reader
-		for reader := range a.stats {
-			if combinedReaders[reader] {
+		// Delete stats for removed readers
+		for _, reader := range oldReaders {
+			if combinedUrls[a.urls[reader]] {
 				continue
 			}
+			log.Info("Removing REST DAS endpoint no longer in online list", "url", a.urls[reader])
+			a.removeMetrics(a.urls[reader])
 			delete(a.stats, reader)
+			delete(a.urls, reader)
 		}
+		a.statsDirty = true
+		a.updateStrategy(time.Now())
 	}
 
 	a.StopWaiter.LaunchThread(func(innerCtx context.Context) {
@@ -310,18 +364,30 @@ func (a *SimpleDASReaderAggregator) Start(ctx context.Context) {
 		for {
 			select {
 			case <-innerCtx.Done():
-				return
-			case stat := <-a.statMessages:
-				a.stats[stat.reader] = append(a.stats[stat.reader], stat.readerStat)
-				statsLen := len(a.stats[stat.reader])
-				if statsLen > a.config.MaxPerEndpointStats {
-					a.stats[stat.reader] = a.stats[stat.reader][statsLen-a.config.MaxPerEndpointStats:]
+				// record the stats already queued before saving them
+				for {
+					select {
+					case stat := <-a.statMessages:
+						a.recordStat(stat)
+					default:
+						a.saveStats()
+						return
+					}
 				}
+			case stat := <-a.statMessages:
+				a.recordStat(stat)
 			case <-updateStrategyTicker.C:
 				// Strategy update happens in same goroutine as updates to the stats
 				// to avoid needing extra synchronization.
-				a.strategy.update(a.readers, a.stats)
-			case onlineUrls := <-onlineUrlsChan:
+				now := time.Now()
+				a.updateStrategy(now)
+				a.updateMetrics(now)
+				a.saveStats()
+			case onlineUrls, ok := <-onlineUrlsChan:
+				if !ok {
+					onlineUrlsChan = nil
+					continue
+				}
 				updateRestfulDasClients(onlineUrls)
 			}
 		}
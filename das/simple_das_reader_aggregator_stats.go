@@ -0,0 +1,202 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
+
+	"github.com/tenderly/nitro/arbstate"
+)
+
+// persistedEndpointStats is what's kept of a REST endpoint's stats across restarts
+type persistedEndpointStats struct {
+	Stats            []persistedReaderStat `json:"stats"`
+	BlacklistedUntil int64                 `json:"blacklistedUntil,omitempty"` // unix time, or zero if not blacklisted
+}
+
+type persistedReaderStat struct {
+	Latency time.Duration `json:"latency"`
+	Success bool          `json:"success"`
+}
+
+func (p *persistedEndpointStats) readerStats(maxStats int) readerStats {
+	stats := make(readerStats, 0, maxStats)
+	if p == nil {
+		return stats
+	}
+	persisted := p.Stats
+	if len(persisted) > maxStats {
+		persisted = persisted[len(persisted)-maxStats:]
+	}
+	for _, stat := range persisted {
+		stats = append(stats, readerStat{latency: stat.Latency, success: stat.Success})
+	}
+	return stats
+}
+
+// loadEndpointStats reads the stats saved by a previous run, keyed by endpoint URL
+func loadEndpointStats(statsFile string) (map[string]*persistedEndpointStats, error) {
+	if statsFile == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(statsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var saved map[string]*persistedEndpointStats
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+// saveStats writes the current stats and blacklist to the stats file, if they've changed
+func (a *SimpleDASReaderAggregator) saveStats() {
+	if a.config.StatsFile == "" || !a.statsDirty {
+		return
+	}
+	saved := make(map[string]*persistedEndpointStats)
+	for reader, url := range a.urls {
+		endpoint := &persistedEndpointStats{}
+		for _, stat := range a.stats[reader] {
+			endpoint.Stats = append(endpoint.Stats, persistedReaderStat{Latency: stat.latency, Success: stat.success})
+		}
+		saved[url] = endpoint
+	}
+	// blacklisted endpoints stay blacklisted if they reappear in the online list
+	for url, until := range a.blacklist {
+		if saved[url] == nil {
+			saved[url] = &persistedEndpointStats{}
+		}
+		saved[url].BlacklistedUntil = until.Unix()
+	}
+	data, err := json.Marshal(saved)
+	if err != nil {
+		log.Warn("Failed to encode REST aggregator stats", "err", err)
+		return
+	}
+	if err := writeFileAtomically(a.config.StatsFile, data); err != nil {
+		log.Warn("Failed to save REST aggregator stats", "file", a.config.StatsFile, "err", err)
+		return
+	}
+	a.statsDirty = false
+}
+
+func (a *SimpleDASReaderAggregator) addReader(url string, reader arbstate.DataAvailabilityReader, stats readerStats) {
+	if stats == nil {
+		stats = make(readerStats, 0, a.config.MaxPerEndpointStats)
+	}
+	a.readers = append(a.readers, reader)
+	a.stats[reader] = stats
+	a.urls[reader] = url
+}
+
+func (a *SimpleDASReaderAggregator) recordStat(stat readerStatMessage) {
+	url, ok := a.urls[stat.reader]
+	if !ok {
+		// the reader was removed since it was used
+		return
+	}
+	a.stats[stat.reader] = append(a.stats[stat.reader], stat.readerStat)
+	statsLen := len(a.stats[stat.reader])
+	if statsLen > a.config.MaxPerEndpointStats {
+		a.stats[stat.reader] = a.stats[stat.reader][statsLen-a.config.MaxPerEndpointStats:]
+	}
+	a.statsDirty = true
+
+	if stat.hashMismatch && a.config.BlacklistDuration > 0 {
+		now := time.Now()
+		a.blacklist[url] = now.Add(a.config.BlacklistDuration)
+		log.Warn("Blacklisting REST DAS endpoint that returned data not matching its hash", "url", url, "until", a.blacklist[url])
+		a.updateStrategy(now)
+	}
+}
+
+func (a *SimpleDASReaderAggregator) blacklisted(url string, now time.Time) bool {
+	until, ok := a.blacklist[url]
+	return ok && now.Before(until)
+}
+
+// updateStrategy gives the strategy the readers that aren't blacklisted, or all of them if every one is
+func (a *SimpleDASReaderAggregator) updateStrategy(now time.Time) {
+	for url, until := range a.blacklist {
+		if !now.Before(until) {
+			log.Info("REST DAS endpoint blacklist expired", "url", url)
+			delete(a.blacklist, url)
+			a.statsDirty = true
+		}
+	}
+	readers := make([]arbstate.DataAvailabilityReader, 0, len(a.readers))
+	for _, reader := range a.readers {
+		if !a.blacklisted(a.urls[reader], now) {
+			readers = append(readers, reader)
+		}
+	}
+	if len(readers) == 0 {
+		readers = a.readers
+	}
+	a.strategy.update(readers, a.stats)
+}
+
+type endpointMetrics struct {
+	prefix      string
+	successRate metrics.Gauge // percentage of recent requests that succeeded
+	latency     metrics.Gauge // mean latency of recent successful requests, in milliseconds
+	blacklisted metrics.Gauge
+}
+
+var nonMetricNameChars = regexp.MustCompile("[^a-zA-Z0-9]+")
+
+func endpointMetricsPrefix(url string) string {
+	name := strings.TrimPrefix(strings.TrimPrefix(url, "http://"), "https://")
+	return "arb/das/rest/aggregator/endpoint/" + strings.Trim(nonMetricNameChars.ReplaceAllString(name, "_"), "_") + "/"
+}
+
+// updateMetrics publishes the stats of each endpoint
+func (a *SimpleDASReaderAggregator) updateMetrics(now time.Time) {
+	for reader, url := range a.urls {
+		m, ok := a.metrics[url]
+		if !ok {
+			prefix := endpointMetricsPrefix(url)
+			m = &endpointMetrics{
+				prefix:      prefix,
+				successRate: metrics.GetOrRegisterGauge(prefix+"successrate", nil),
+				latency:     metrics.GetOrRegisterGauge(prefix+"latency", nil),
+				blacklisted: metrics.GetOrRegisterGauge(prefix+"blacklisted", nil),
+			}
+			a.metrics[url] = m
+		}
+		stats := a.stats[reader]
+		successRatio, meanLatency := stats.successRatioAndMeanLatency()
+		m.successRate.Update(int64(successRatio * 100))
+		m.latency.Update(meanLatency.Milliseconds())
+		if a.blacklisted(url, now) {
+			m.blacklisted.Update(1)
+		} else {
+			m.blacklisted.Update(0)
+		}
+	}
+}
+
+func (a *SimpleDASReaderAggregator) removeMetrics(url string) {
+	m, ok := a.metrics[url]
+	if !ok {
+		return
+	}
+	metrics.Unregister(m.prefix + "successrate")
+	metrics.Unregister(m.prefix + "latency")
+	metrics.Unregister(m.prefix + "blacklisted")
+	delete(a.metrics, url)
+}
@@ -7,6 +7,9 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
@@ -84,3 +87,78 @@ func TestSimpleDASReaderAggregator(t *testing.T) { //nolint
 	Require(t, err)
 
 }
+
+func TestSimpleDASReaderAggregatorPersistentStats(t *testing.T) {
+	initTest(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	storage := NewMemoryBackedStorageService(ctx)
+	data := []byte("Testing data that one REST endpoint returns corrupted.")
+	dataHash := dastree.Hash(data)
+	err := storage.Put(ctx, data, uint64(time.Now().Add(time.Hour).Unix()))
+	Require(t, err)
+
+	goodServer, goodPort, err := NewRestfulDasServerOnRandomPort(LocalServerAddressForTest, storage)
+	Require(t, err)
+	defer func() {
+		Require(t, goodServer.Shutdown())
+	}()
+	badServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("corrupted data"))
+	}))
+	defer badServer.Close()
+	goodUrl := "http://localhost:" + strconv.Itoa(goodPort)
+
+	config := RestfulClientAggregatorConfig{
+		Urls:                   []string{badServer.URL, goodUrl},
+		Strategy:               "testing-sequential",
+		StrategyUpdateInterval: time.Hour,
+		WaitBeforeTryNext:      500 * time.Millisecond,
+		MaxPerEndpointStats:    10,
+		StatsFile:              filepath.Join(t.TempDir(), "stats.json"),
+		BlacklistDuration:      time.Hour,
+	}
+
+	agg, err := NewRestfulClientAggregator(ctx, &config)
+	Require(t, err)
+	agg.Start(ctx)
+	for _, reader := range agg.readers {
+		_, err := agg.tryGetByHash(ctx, dataHash, reader)
+		if (err == nil) != (agg.urls[reader] == goodUrl) {
+			Fail(t, "unexpected result from", agg.urls[reader], err)
+		}
+	}
+	Require(t, agg.Close(ctx))
+
+	saved, err := loadEndpointStats(config.StatsFile)
+	Require(t, err)
+	if saved[badServer.URL] == nil || saved[badServer.URL].BlacklistedUntil == 0 {
+		Fail(t, "endpoint returning corrupted data wasn't blacklisted", saved[badServer.URL])
+	}
+	if saved[goodUrl] == nil || len(saved[goodUrl].Stats) != 1 || !saved[goodUrl].Stats[0].Success {
+		Fail(t, "unexpected saved stats", saved[goodUrl])
+	}
+
+	// the stats and blacklist are restored on restart, so the corrupt endpoint isn't used
+	agg, err = NewRestfulClientAggregator(ctx, &config)
+	Require(t, err)
+	if !agg.blacklisted(badServer.URL, time.Now()) {
+		Fail(t, "blacklist wasn't restored")
+	}
+	for reader, url := range agg.urls {
+		if url == goodUrl && len(agg.stats[reader]) != 1 {
+			Fail(t, "stats weren't restored", agg.stats[reader])
+		}
+	}
+	strategy, ok := agg.strategy.(*testingSequentialStrategy)
+	if !ok || len(strategy.readers) != 1 || agg.urls[strategy.readers[0]] != goodUrl {
+		Fail(t, "blacklisted endpoint is still used")
+	}
+	returnedData, err := agg.GetByHash(ctx, dataHash)
+	Require(t, err)
+	if !bytes.Equal(data, returnedData) {
+		Fail(t, fmt.Sprintf("Returned data '%s' does not match expected '%s'", returnedData, data))
+	}
+}
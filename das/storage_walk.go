@@ -0,0 +1,251 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"sort"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/crypto"
+	"github.com/tenderly/nitro/go-ethereum/log"
+
+	"github.com/tenderly/nitro/das/dastree"
+)
+
+// StorageEntry is an entry found by walking a storage service
+type StorageEntry struct {
+	Key     common.Hash
+	Timeout uint64 // the expiry timeout, or zero if it isn't recorded
+	Size    uint64
+	Legacy  bool   // stored under a legacy name format
+	name    string // where the backend stores the entry
+}
+
+// WalkableStorageService is a StorageService whose entries can be listed, for migrating and checking storage
+type WalkableStorageService interface {
+	StorageService
+	// Walk calls visit for each entry with a key after the given one, in ascending key order
+	Walk(ctx context.Context, after common.Hash, visit func(StorageEntry) error) error
+	// Read returns the stored data of an entry, without checking its hash
+	Read(ctx context.Context, entry StorageEntry) ([]byte, error)
+	// Quarantine removes an entry, keeping its data somewhere it won't be served from
+	Quarantine(ctx context.Context, entry StorageEntry) error
+}
+
+// The most entries walkInKeyOrder holds in memory
+var walkBatchSize = 100000
+
+// entryMaxHeap is a heap of entries with the highest key at the top
+type entryMaxHeap []StorageEntry
+
+func (h entryMaxHeap) Len() int           { return len(h) }
+func (h entryMaxHeap) Less(i, j int) bool { return bytes.Compare(h[i].Key[:], h[j].Key[:]) > 0 }
+func (h entryMaxHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *entryMaxHeap) Push(x any)        { *h = append(*h, x.(StorageEntry)) }
+func (h *entryMaxHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// walkInKeyOrder calls visit with the entries listed by list after the given key, in ascending key order,
+// for backends which can only list their entries unordered.
+// Rather than sorting every entry in memory, each pass lists all the entries again,
+// keeping the walkBatchSize lowest ones after the last visited key.
+func walkInKeyOrder(
+	ctx context.Context,
+	after common.Hash,
+	list func(add func(StorageEntry)) error,
+	visit func(StorageEntry) error,
+) error {
+	for {
+		batch := &entryMaxHeap{}
+		err := list(func(entry StorageEntry) {
+			if bytes.Compare(entry.Key[:], after[:]) <= 0 {
+				return
+			}
+			if batch.Len() < walkBatchSize {
+				heap.Push(batch, entry)
+			} else if bytes.Compare(entry.Key[:], (*batch)[0].Key[:]) < 0 {
+				(*batch)[0] = entry
+				heap.Fix(batch, 0)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		entries := []StorageEntry(*batch)
+		sort.SliceStable(entries, func(i, j int) bool {
+			return bytes.Compare(entries[i].Key[:], entries[j].Key[:]) < 0
+		})
+		complete := len(entries) < walkBatchSize
+		if !complete {
+			// entries with the highest key, such as a legacy and a current file of the same data,
+			// may not all have fit, so they're left for the next pass
+			last := entries[len(entries)-1].Key
+			trimmed := len(entries)
+			for trimmed > 0 && entries[trimmed-1].Key == last {
+				trimmed--
+			}
+			if trimmed > 0 {
+				entries = entries[:trimmed]
+			}
+		}
+		for _, entry := range entries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err := visit(entry); err != nil {
+				return err
+			}
+		}
+		if complete {
+			return nil
+		}
+		after = entries[len(entries)-1].Key
+	}
+}
+
+// How many entries are migrated between calls to the checkpoint function
+const migrateCheckpointInterval = 100
+
+type MigrateResult struct {
+	Copied  int
+	Corrupt int // entries skipped as their data doesn't match their key
+	Bytes   uint64
+	LastKey common.Hash
+}
+
+// MigrateStorage copies the entries of src after the given key to dst, in key order.
+// Entries without a recorded expiry are stored with defaultTimeout.
+// checkpoint is called with the last copied key periodically and once done, so an interrupted migration can be resumed.
+func MigrateStorage(
+	ctx context.Context,
+	src WalkableStorageService,
+	dst StorageService,
+	after common.Hash,
+	defaultTimeout uint64,
+	checkpoint func(common.Hash) error,
+) (*MigrateResult, error) {
+	result := &MigrateResult{LastKey: after}
+	sinceCheckpoint := 0
+	err := src.Walk(ctx, after, func(entry StorageEntry) error {
+		data, err := src.Read(ctx, entry)
+		if err != nil {
+			return err
+		}
+		if !dastree.ValidHash(entry.Key, data) {
+			log.Warn("skipping DAS entry whose data doesn't match its key", "key", entry.Key, "source", src)
+			result.Corrupt++
+		} else {
+			timeout := entry.Timeout
+			if timeout == 0 {
+				timeout = defaultTimeout
+			}
+			if err := dst.Put(ctx, data, timeout); err != nil {
+				return err
+			}
+			result.Copied++
+			result.Bytes += uint64(len(data))
+		}
+		result.LastKey = entry.Key
+		sinceCheckpoint++
+		if sinceCheckpoint >= migrateCheckpointInterval {
+			sinceCheckpoint = 0
+			if err := dst.Sync(ctx); err != nil {
+				return err
+			}
+			return checkpoint(result.LastKey)
+		}
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	if err := dst.Sync(ctx); err != nil {
+		return result, err
+	}
+	return result, checkpoint(result.LastKey)
+}
+
+type StorageProblem int
+
+const (
+	StorageEntryCorrupt StorageProblem = iota // the data doesn't hash to its key
+	StorageEntryLegacy                        // the entry is stored under a legacy name or flat hash key
+)
+
+func (p StorageProblem) String() string {
+	if p == StorageEntryCorrupt {
+		return "corrupt"
+	}
+	return "legacy"
+}
+
+type CheckResult struct {
+	Checked     int
+	Corrupt     int
+	Legacy      int
+	Quarantined int
+}
+
+// CheckStorage re-hashes every entry of s, calling report for each corrupt or legacy keyed entry.
+// If quarantine is set, those entries are quarantined, with legacy entries first stored again under their current key,
+// with defaultTimeout if they have no recorded expiry.
+func CheckStorage(
+	ctx context.Context,
+	s WalkableStorageService,
+	quarantine bool,
+	defaultTimeout uint64,
+	report func(StorageEntry, StorageProblem),
+) (*CheckResult, error) {
+	result := &CheckResult{}
+	err := s.Walk(ctx, common.Hash{}, func(entry StorageEntry) error {
+		data, err := s.Read(ctx, entry)
+		if err != nil {
+			return err
+		}
+		result.Checked++
+		var problem StorageProblem
+		switch {
+		case entry.Key == dastree.Hash(data):
+			if !entry.Legacy {
+				return nil
+			}
+			problem = StorageEntryLegacy
+		case entry.Key == crypto.Keccak256Hash(data):
+			problem = StorageEntryLegacy
+		default:
+			problem = StorageEntryCorrupt
+		}
+		if problem == StorageEntryCorrupt {
+			result.Corrupt++
+		} else {
+			result.Legacy++
+		}
+		report(entry, problem)
+		if !quarantine {
+			return nil
+		}
+		if problem == StorageEntryLegacy {
+			timeout := entry.Timeout
+			if timeout == 0 {
+				timeout = defaultTimeout
+			}
+			if err := s.Put(ctx, data, timeout); err != nil {
+				return err
+			}
+		}
+		if err := s.Quarantine(ctx, entry); err != nil {
+			return err
+		}
+		result.Quarantined++
+		return nil
+	})
+	return result, err
+}
@@ -0,0 +1,176 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package das
+
+import (
+	"bytes"
+	"context"
+	"encoding/base32"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/crypto"
+
+	"github.com/tenderly/nitro/das/dastree"
+)
+
+func TestStorageMigrateAndCheck(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := DefaultLocalFileStorageConfig
+	config.DataDir = t.TempDir()
+	service, err := NewLocalFileStorageService(ctx, config)
+	Require(t, err)
+	defer service.Close(ctx)
+	src := service.(*LocalFileStorageService)
+
+	timeout := uint64(time.Now().Add(time.Hour).Unix())
+	var values [][]byte
+	for i := 0; i < 5; i++ {
+		value := []byte(fmt.Sprint("value ", i))
+		values = append(values, value)
+		Require(t, src.Put(ctx, value, timeout))
+	}
+	legacyValue := []byte("stored under a base32 name")
+	legacyName := base32.StdEncoding.EncodeToString(dastree.HashBytes(legacyValue))
+	Require(t, os.WriteFile(config.DataDir+"/"+legacyName, legacyValue, 0600))
+	flatValue := []byte("stored under a flat hash")
+	Require(t, os.WriteFile(config.DataDir+"/"+EncodeStorageServiceKey(crypto.Keccak256Hash(flatValue)), flatValue, 0600))
+	corruptKey := dastree.Hash([]byte("original"))
+	Require(t, os.WriteFile(config.DataDir+"/"+EncodeStorageServiceKey(corruptKey), []byte("corrupted"), 0600))
+
+	dstService, err := NewDBStorageService(ctx, t.TempDir(), false, 0)
+	Require(t, err)
+	defer dstService.Close(ctx)
+	dst := dstService.(*DBStorageService)
+
+	// migrate part way, then resume from the checkpoint
+	var checkpoint common.Hash
+	saveCheckpoint := func(last common.Hash) error {
+		checkpoint = last
+		return nil
+	}
+	stopAfter := 3
+	partial := &walkLimiter{WalkableStorageService: src, limit: stopAfter}
+	first, err := MigrateStorage(ctx, partial, dst, common.Hash{}, 0, saveCheckpoint)
+	Require(t, err)
+	second, err := MigrateStorage(ctx, src, dst, checkpoint, 0, saveCheckpoint)
+	Require(t, err)
+	if first.Copied+first.Corrupt != stopAfter || first.Copied+second.Copied != len(values)+2 || first.Corrupt+second.Corrupt != 1 {
+		Fail(t, "unexpected migration results", first, second)
+	}
+	for _, value := range append(values, legacyValue, flatValue) {
+		data, err := dst.GetByHash(ctx, dastree.Hash(value))
+		Require(t, err)
+		if !bytes.Equal(data, value) {
+			Fail(t, "migrated data doesn't match")
+		}
+	}
+	err = dst.Walk(ctx, common.Hash{}, func(entry StorageEntry) error {
+		if entry.Key == dastree.Hash(values[0]) && entry.Timeout != 0 {
+			Fail(t, "expiry recorded without discarding after timeout")
+		}
+		return nil
+	})
+	Require(t, err)
+
+	// the check finds the legacy and corrupt entries, and quarantining them leaves a clean store
+	var reported []StorageProblem
+	report := func(entry StorageEntry, problem StorageProblem) {
+		reported = append(reported, problem)
+	}
+	check, err := CheckStorage(ctx, src, true, timeout, report)
+	Require(t, err)
+	if check.Checked != len(values)+3 || check.Corrupt != 1 || check.Legacy != 2 || check.Quarantined != 3 || len(reported) != 3 {
+		Fail(t, "unexpected check result", check)
+	}
+	for _, value := range [][]byte{legacyValue, flatValue} {
+		_, err := src.GetByHash(ctx, dastree.Hash(value))
+		Require(t, err, "legacy entry not stored under its current key")
+	}
+	check, err = CheckStorage(ctx, src, false, timeout, report)
+	Require(t, err)
+	if check.Checked != len(values)+2 || check.Corrupt != 0 || check.Legacy != 0 {
+		Fail(t, "unexpected check result after quarantine", check)
+	}
+}
+
+// walkLimiter stops walking after a number of entries, like an interrupted migration
+type walkLimiter struct {
+	WalkableStorageService
+	limit int
+}
+
+func (w *walkLimiter) Walk(ctx context.Context, after common.Hash, visit func(StorageEntry) error) error {
+	count := 0
+	return w.WalkableStorageService.Walk(ctx, after, func(entry StorageEntry) error {
+		if count >= w.limit {
+			return nil
+		}
+		count++
+		return visit(entry)
+	})
+}
+
+func TestStorageMigrateRecordsExpiry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// walk in several passes
+	defer func(size int) { walkBatchSize = size }(walkBatchSize)
+	walkBatchSize = 2
+
+	// datool's no-prune mode, which records expiry without discarding anything
+	openFileStorage := func() *LocalFileStorageService {
+		config := DefaultLocalFileStorageConfig
+		config.DataDir = t.TempDir()
+		config.DiscardAfterTimeout = true
+		config.Expiry.DryRun = true
+		service, err := NewLocalFileStorageService(ctx, config)
+		Require(t, err)
+		t.Cleanup(func() { _ = service.Close(ctx) })
+		return service.(*LocalFileStorageService)
+	}
+	src := openFileStorage()
+	dst := openFileStorage()
+
+	timeout := uint64(time.Now().Add(time.Hour).Unix())
+	expected := make(map[common.Hash]uint64)
+	for i := 0; i < 5; i++ {
+		value := []byte(fmt.Sprint("value ", i))
+		Require(t, src.Put(ctx, value, timeout))
+		expected[dastree.Hash(value)] = timeout
+	}
+	defaultTimeout := uint64(time.Now().Add(2 * time.Hour).Unix())
+	noExpiryValue := []byte("stored without an expiry")
+	Require(t, os.WriteFile(src.dataDir+"/"+EncodeStorageServiceKey(dastree.Hash(noExpiryValue)), noExpiryValue, 0600))
+	expected[dastree.Hash(noExpiryValue)] = defaultTimeout
+
+	result, err := MigrateStorage(ctx, src, dst, common.Hash{}, defaultTimeout, func(common.Hash) error { return nil })
+	Require(t, err)
+	if result.Copied != len(expected) {
+		Fail(t, "unexpected migration result", result)
+	}
+	var last common.Hash
+	visited := 0
+	err = dst.Walk(ctx, common.Hash{}, func(entry StorageEntry) error {
+		if bytes.Compare(entry.Key[:], last[:]) <= 0 {
+			Fail(t, "walked out of order", entry.Key, last)
+		}
+		last = entry.Key
+		visited++
+		if entry.Timeout != expected[entry.Key] {
+			Fail(t, "migrated expiry", entry.Timeout, "doesn't match", expected[entry.Key])
+		}
+		return nil
+	})
+	Require(t, err)
+	if visited != len(expected) {
+		Fail(t, "walked", visited, "entries, expected", len(expected))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"encoding/binary"
+	"errors"
 	"time"
 
 	"github.com/tenderly/nitro/go-ethereum/common"
@@ -45,6 +46,86 @@ func dasStoreHash(data []byte, timeout uint64) []byte {
 	return dastree.HashBytes(uniquifyingPrefix, buf8[:], data)
 }
 
+var chunkedStorePrefix = []byte("Arbitrum Nitro DAS API Chunked Store:")
+
+// ApplyDasChunkedStoreSigner signs the start of a chunked store, which commits to the chunks before they're sent
+func ApplyDasChunkedStoreSigner(signer DasSigner, chunkHashes []common.Hash, totalSize uint64, timeout uint64) ([]byte, error) {
+	return signer(dasChunkedStoreHash(chunkHashes, totalSize, timeout))
+}
+
+func DasRecoverChunkedStoreSigner(chunkHashes []common.Hash, totalSize uint64, timeout uint64, sig []byte) (common.Address, error) {
+	pk, err := crypto.SigToPub(dasChunkedStoreHash(chunkHashes, totalSize, timeout), sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pk), nil
+}
+
+func dasChunkedStoreHash(chunkHashes []common.Hash, totalSize uint64, timeout uint64) []byte {
+	var sizeBuf, timeoutBuf [8]byte
+	binary.BigEndian.PutUint64(sizeBuf[:], totalSize)
+	binary.BigEndian.PutUint64(timeoutBuf[:], timeout)
+	preimage := [][]byte{chunkedStorePrefix, sizeBuf[:], timeoutBuf[:]}
+	for i := range chunkHashes {
+		preimage = append(preimage, chunkHashes[i][:])
+	}
+	return dastree.HashBytes(preimage...)
+}
+
+type storeSignerContextKey struct{}
+
+// WithStoreSigner makes the signer available to DAS clients below a StoreSigningDAS,
+// which sign the start of chunked stores with it.
+func WithStoreSigner(ctx context.Context, signer DasSigner) context.Context {
+	return context.WithValue(ctx, storeSignerContextKey{}, signer)
+}
+
+// StoreSignerFromContext returns the signer set by WithStoreSigner, if any
+func StoreSignerFromContext(ctx context.Context) (DasSigner, bool) {
+	signer, ok := ctx.Value(storeSignerContextKey{}).(DasSigner)
+	return signer, ok
+}
+
+var ErrChunkedStoreNotSigned = errors.New("chunked store request not properly signed")
+
+// ChunkedStoreAuthorizer is implemented by DASes that check store requests are signed by a batch poster,
+// so that chunked stores can be checked before their message arrives.
+type ChunkedStoreAuthorizer interface {
+	// AuthorizeChunkedStore returns the address which signed the start of the chunked store
+	AuthorizeChunkedStore(ctx context.Context, chunkHashes []common.Hash, totalSize uint64, timeout uint64, sig []byte) (common.Address, error)
+}
+
+// AuthorizeChunkedStore checks the start of a chunked store with das, if it checks who may store.
+// Otherwise the signer is only recovered, or is the zero address if the request isn't signed.
+func AuthorizeChunkedStore(ctx context.Context, das DataAvailabilityService, chunkHashes []common.Hash, totalSize uint64, timeout uint64, sig []byte) (common.Address, error) {
+	if authorizer, ok := das.(ChunkedStoreAuthorizer); ok {
+		return authorizer.AuthorizeChunkedStore(ctx, chunkHashes, totalSize, timeout, sig)
+	}
+	return authorizeChunkedStore(ctx, nil, chunkHashes, totalSize, timeout, sig)
+}
+
+func authorizeChunkedStore(ctx context.Context, bpVerifier *BatchPosterVerifier, chunkHashes []common.Hash, totalSize uint64, timeout uint64, sig []byte) (common.Address, error) {
+	signer, err := DasRecoverChunkedStoreSigner(chunkHashes, totalSize, timeout, sig)
+	if bpVerifier == nil {
+		if err != nil {
+			// signatures aren't checked, so unsigned stores share the zero address's limits
+			return common.Address{}, nil
+		}
+		return signer, nil
+	}
+	if err != nil {
+		return common.Address{}, ErrChunkedStoreNotSigned
+	}
+	isBatchPoster, err := bpVerifier.IsBatchPoster(ctx, signer)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if !isBatchPoster {
+		return common.Address{}, ErrChunkedStoreNotSigned
+	}
+	return signer, nil
+}
+
 type StoreSigningDAS struct {
 	DataAvailabilityService
 	signer DasSigner
@@ -69,7 +150,7 @@ func (s *StoreSigningDAS) Store(ctx context.Context, message []byte, timeout uin
 	if err != nil {
 		return nil, err
 	}
-	return s.DataAvailabilityService.Store(ctx, message, timeout, mySig)
+	return s.DataAvailabilityService.Store(WithStoreSigner(ctx, s.signer), message, timeout, mySig)
 }
 
 func (s *StoreSigningDAS) String() string {
@@ -6,10 +6,16 @@ package relay
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
+	"sync"
 	"time"
 
-	"github.com/tenderly/nitro/arbstate"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
 	"github.com/tenderly/nitro/arbutil"
 	"github.com/tenderly/nitro/broadcastclient"
 	"github.com/tenderly/nitro/broadcaster"
@@ -17,57 +23,162 @@ import (
 	"github.com/tenderly/nitro/wsbroadcastserver"
 )
 
+type GapConfig struct {
+	Timeout     time.Duration `koanf:"timeout"`
+	MaxWait     time.Duration `koanf:"max-wait"`
+	MaxBuffered int           `koanf:"max-buffered"`
+}
+
+func GapConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Duration(prefix+".timeout", DefaultGapConfig.Timeout, "how long to wait for a missing feed message before requesting it from another upstream")
+	f.Duration(prefix+".max-wait", DefaultGapConfig.MaxWait, "how long to wait for a missing feed message before skipping it")
+	f.Int(prefix+".max-buffered", DefaultGapConfig.MaxBuffered, "maximum number of out of order feed messages to buffer while waiting for a missing message")
+}
+
+var DefaultGapConfig = GapConfig{
+	Timeout:     time.Second,
+	MaxWait:     10 * time.Second,
+	MaxBuffered: 1024,
+}
+
+var TestGapConfig = GapConfig{
+	Timeout:     100 * time.Millisecond,
+	MaxWait:     time.Second,
+	MaxBuffered: 16,
+}
+
+// UpstreamStats describes what a relay has received from one of its upstream feeds
+type UpstreamStats struct {
+	URL                string
+	HighestSeqNum      arbutil.MessageIndex
+	LastMessage        time.Time
+	MessagesReceived   uint64
+	OutOfOrderMessages uint64
+	CatchupRequests    uint64
+}
+
+type upstreamMetrics struct {
+	lag         metrics.Gauge
+	idle        metrics.Gauge
+	received    metrics.Counter
+	outOfOrder  metrics.Counter
+	catchupReqs metrics.Counter
+}
+
+func newUpstreamMetrics(index int) upstreamMetrics {
+	prefix := fmt.Sprintf("arb/feed/relay/upstream/%d/", index)
+	return upstreamMetrics{
+		lag:         metrics.GetOrRegisterGauge(prefix+"lag", nil),
+		idle:        metrics.GetOrRegisterGauge(prefix+"idle", nil),
+		received:    metrics.GetOrRegisterCounter(prefix+"received", nil),
+		outOfOrder:  metrics.GetOrRegisterCounter(prefix+"outoforder", nil),
+		catchupReqs: metrics.GetOrRegisterCounter(prefix+"catchuprequests", nil),
+	}
+}
+
+var (
+	gapSkippedCounter  = metrics.NewRegisteredCounter("arb/feed/relay/gaps/skipped", nil)
+	pendingCountGauge  = metrics.NewRegisteredGauge("arb/feed/relay/pending", nil)
+	nextSeqNumGauge    = metrics.NewRegisteredGauge("arb/feed/relay/nextseqnum", nil)
+	duplicatesCounter  = metrics.NewRegisteredCounter("arb/feed/relay/duplicates", nil)
+	gapRequestsCounter = metrics.NewRegisteredCounter("arb/feed/relay/gaps/requests", nil)
+	resetsCounter      = metrics.NewRegisteredCounter("arb/feed/relay/resets", nil)
+)
+
 type Relay struct {
 	stopwaiter.StopWaiter
 	broadcastClients            []*broadcastclient.BroadcastClient
 	broadcaster                 *broadcaster.Broadcaster
 	confirmedSequenceNumberChan chan arbutil.MessageIndex
-	messageChan                 chan broadcastFeedMessage
+	messageChan                 chan upstreamMessage
+	gapConfig                   GapConfig
+
+	// Only accessed by the relay's main thread
+	initialized           bool
+	nextSeqNum            arbutil.MessageIndex
+	pending               map[arbutil.MessageIndex]*broadcaster.BroadcastFeedMessage
+	forwarded             map[arbutil.MessageIndex]common.Hash // hashes of the last forwarded messages, to tell duplicates from reorgs
+	reorgedOut            map[arbutil.MessageIndex]common.Hash // hashes of the messages replaced by the last reset, dropped if lagging upstreams still send them
+	staleSince            time.Time                            // when messages from before the forwarded ones started arriving
+	gapStart              time.Time
+	lastGapRequest        time.Time
+	lastRequestedUpstream int
+
+	statsMutex      sync.Mutex
+	upstreamStats   []UpstreamStats
+	upstreamMetrics []upstreamMetrics
 }
 
-type broadcastFeedMessage struct {
-	message        arbstate.MessageWithMetadata
-	sequenceNumber arbutil.MessageIndex
+type upstreamMessage struct {
+	upstream int
+	message  *broadcaster.BroadcastFeedMessage
 }
 
+// RelayMessageQueue passes the messages received from one upstream feed to the relay
 type RelayMessageQueue struct {
-	queue chan broadcastFeedMessage
+	upstream int
+	queue    chan upstreamMessage
 }
 
-func (q *RelayMessageQueue) AddBroadcastMessages(pos arbutil.MessageIndex, messages []arbstate.MessageWithMetadata) error {
-	for i, message := range messages {
-		q.queue <- broadcastFeedMessage{
-			sequenceNumber: pos + arbutil.MessageIndex(i),
-			message:        message,
+func (q *RelayMessageQueue) AddBroadcastMessages(feedMessages []*broadcaster.BroadcastFeedMessage) error {
+	for _, feedMessage := range feedMessages {
+		q.queue <- upstreamMessage{
+			upstream: q.upstream,
+			message:  feedMessage,
 		}
 	}
 
 	return nil
 }
 
-func NewRelay(serverConf wsbroadcastserver.BroadcasterConfig, clientConf broadcastclient.BroadcastClientConfig) *Relay {
+// NewRelay creates a relay which forwards feed messages, including their signatures, unmodified.
+// Messages from all upstreams are forwarded once each, in sequence number order.
+func NewRelay(serverConf wsbroadcastserver.BroadcasterConfig, clientConf broadcastclient.BroadcastClientConfig, gapConf GapConfig) (*Relay, error) {
+	if gapConf.MaxBuffered <= 0 {
+		return nil, errors.New("relay gap max buffered messages must be positive")
+	}
+	if gapConf.Timeout <= 0 || gapConf.MaxWait < gapConf.Timeout {
+		return nil, errors.New("relay gap max wait must be at least the gap timeout, which must be positive")
+	}
+
 	var broadcastClients []*broadcastclient.BroadcastClient
+	var upstreamStats []UpstreamStats
+	var upstreamMetricsList []upstreamMetrics
 
-	q := RelayMessageQueue{make(chan broadcastFeedMessage, 100)}
+	messageChan := make(chan upstreamMessage, 100)
 
 	confirmedSequenceNumberListener := make(chan arbutil.MessageIndex, 10)
 
 	for _, address := range clientConf.URLs {
-		client := broadcastclient.NewBroadcastClient(address, nil, clientConf.Timeout, &q)
+		if address == "" {
+			continue
+		}
+		q := &RelayMessageQueue{upstream: len(broadcastClients), queue: messageChan}
+		client, err := broadcastclient.NewBroadcastClient(clientConf, address, 0, q)
+		if err != nil {
+			return nil, err
+		}
 		client.ConfirmedSequenceNumberListener = confirmedSequenceNumberListener
+		upstreamMetricsList = append(upstreamMetricsList, newUpstreamMetrics(len(broadcastClients)))
 		broadcastClients = append(broadcastClients, client)
+		upstreamStats = append(upstreamStats, UpstreamStats{URL: address})
 	}
 
 	return &Relay{
-		broadcaster:                 broadcaster.NewBroadcaster(serverConf),
+		broadcaster:                 broadcaster.NewBroadcaster(serverConf, nil),
 		broadcastClients:            broadcastClients,
 		confirmedSequenceNumberChan: confirmedSequenceNumberListener,
-		messageChan:                 q.queue,
-	}
+		messageChan:                 messageChan,
+		gapConfig:                   gapConf,
+		pending:                     make(map[arbutil.MessageIndex]*broadcaster.BroadcastFeedMessage),
+		forwarded:                   make(map[arbutil.MessageIndex]common.Hash),
+		reorgedOut:                  make(map[arbutil.MessageIndex]common.Hash),
+		lastRequestedUpstream:       -1,
+		upstreamStats:               upstreamStats,
+		upstreamMetrics:             upstreamMetricsList,
+	}, nil
 }
 
-const RECENT_FEED_ITEM_TTL time.Duration = time.Second * 10
-
 func (r *Relay) Start(ctx context.Context) error {
 	r.StopWaiter.Start(ctx)
 	err := r.broadcaster.Start(ctx)
@@ -79,30 +190,20 @@ func (r *Relay) Start(ctx context.Context) error {
 		client.Start(ctx)
 	}
 
-	recentFeedItems := make(map[arbutil.MessageIndex]time.Time)
 	r.LaunchThread(func(ctx context.Context) {
-		recentFeedItemsCleanup := time.NewTicker(RECENT_FEED_ITEM_TTL)
-		defer recentFeedItemsCleanup.Stop()
+		gapCheck := time.NewTicker(r.gapConfig.Timeout / 2)
+		defer gapCheck.Stop()
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case msg := <-r.messageChan:
-				if recentFeedItems[msg.sequenceNumber] != (time.Time{}) {
-					continue
-				}
-				recentFeedItems[msg.sequenceNumber] = time.Now()
-				r.broadcaster.BroadcastSingle(msg.message, msg.sequenceNumber)
+				r.handleMessage(msg, time.Now())
 			case cs := <-r.confirmedSequenceNumberChan:
 				r.broadcaster.Confirm(cs)
-			case <-recentFeedItemsCleanup.C:
-				// Clear expired items from recentFeedItems
-				recentFeedItemExpiry := time.Now().Add(-RECENT_FEED_ITEM_TTL)
-				for acc, created := range recentFeedItems {
-					if created.Before(recentFeedItemExpiry) {
-						delete(recentFeedItems, acc)
-					}
-				}
+			case <-gapCheck.C:
+				r.checkGap(time.Now())
+				r.updateUpstreamMetrics(time.Now())
 			}
 		}
 	})
@@ -110,6 +211,300 @@ func (r *Relay) Start(ctx context.Context) error {
 	return nil
 }
 
+func (r *Relay) handleMessage(msg upstreamMessage, now time.Time) {
+	seqNum := msg.message.SequenceNumber
+	r.recordUpstreamMessage(msg.upstream, seqNum, now)
+
+	if !r.initialized {
+		r.nextSeqNum = seqNum
+		r.initialized = true
+	}
+	if seqNum < r.nextSeqNum {
+		r.handleOldMessage(msg, now)
+		return
+	}
+	if r.isReorgedOut(msg.message) {
+		duplicatesCounter.Inc(1)
+		return
+	}
+	if seqNum > r.nextSeqNum {
+		if _, ok := r.pending[seqNum]; !ok {
+			r.pending[seqNum] = msg.message
+			pendingCountGauge.Update(int64(len(r.pending)))
+		}
+		r.recordUpstreamOutOfOrder(msg.upstream)
+		if r.gapStart.IsZero() {
+			log.Debug("relay feed gap detected", "expectedSeqNum", r.nextSeqNum, "gotSeqNum", seqNum)
+			r.gapStart = now
+		}
+		if len(r.pending) > r.gapConfig.MaxBuffered {
+			r.skipGap(now)
+		}
+		return
+	}
+	r.forward(msg.message)
+	r.flushPending(now)
+}
+
+// isReorgedOut is whether the message was replaced by the last reset, so comes from an upstream still serving stale content
+func (r *Relay) isReorgedOut(msg *broadcaster.BroadcastFeedMessage) bool {
+	reorgedHash, ok := r.reorgedOut[msg.SequenceNumber]
+	if !ok {
+		return false
+	}
+	hash, err := msg.SignatureHash()
+	return err == nil && hash == reorgedHash
+}
+
+// handleOldMessage drops a message from before the next sequence number if it's a duplicate.
+// If its content differs from what was forwarded, the sequencer reorged, and the relay continues from it.
+// The relay does the same if no message it could forward has arrived for the gap max wait,
+// as the upstreams must have restarted from before messages it no longer remembers.
+// Only the leading upstream can reset the relay, so lagging upstreams can't take it back to stale content.
+func (r *Relay) handleOldMessage(upstreamMsg upstreamMessage, now time.Time) {
+	msg := upstreamMsg.message
+	hash, err := msg.SignatureHash()
+	if err != nil {
+		log.Warn("relay failed to hash feed message", "seqNum", msg.SequenceNumber, "err", err)
+		duplicatesCounter.Inc(1)
+		return
+	}
+	forwardedHash, known := r.forwarded[msg.SequenceNumber]
+	if known && forwardedHash == hash {
+		duplicatesCounter.Inc(1)
+		return
+	}
+	if !known {
+		if r.staleSince.IsZero() {
+			r.staleSince = now
+		}
+		if now.Sub(r.staleSince) < r.gapConfig.MaxWait {
+			duplicatesCounter.Inc(1)
+			return
+		}
+	}
+	if leader := r.leadingUpstream(now); leader >= 0 && upstreamMsg.upstream != leader {
+		duplicatesCounter.Inc(1)
+		return
+	}
+	log.Warn("relay resetting feed to an earlier message", "seqNum", msg.SequenceNumber, "expectedSeqNum", r.nextSeqNum, "reorg", known)
+	resetsCounter.Inc(1)
+	r.reorgedOut = make(map[arbutil.MessageIndex]common.Hash)
+	for seqNum, forwardedHash := range r.forwarded {
+		if seqNum >= msg.SequenceNumber {
+			delete(r.forwarded, seqNum)
+			r.reorgedOut[seqNum] = forwardedHash
+		}
+	}
+	r.lowerUpstreamSeqNums(msg.SequenceNumber)
+	r.pending = make(map[arbutil.MessageIndex]*broadcaster.BroadcastFeedMessage)
+	r.forward(msg)
+	r.flushPending(now)
+}
+
+func (r *Relay) forward(msg *broadcaster.BroadcastFeedMessage) {
+	r.broadcaster.BroadcastFeedMessages([]*broadcaster.BroadcastFeedMessage{msg})
+	r.nextSeqNum = msg.SequenceNumber + 1
+	nextSeqNumGauge.Update(int64(r.nextSeqNum))
+	r.staleSince = time.Time{}
+	hash, err := msg.SignatureHash()
+	if err != nil {
+		log.Warn("relay failed to hash feed message", "seqNum", msg.SequenceNumber, "err", err)
+		return
+	}
+	r.forwarded[msg.SequenceNumber] = hash
+	delete(r.reorgedOut, msg.SequenceNumber)
+	remembered := arbutil.MessageIndex(r.gapConfig.MaxBuffered)
+	if msg.SequenceNumber >= remembered {
+		delete(r.forwarded, msg.SequenceNumber-remembered)
+	}
+	if len(r.forwarded) > 2*r.gapConfig.MaxBuffered {
+		// skipped gaps leave older messages behind
+		for seqNum := range r.forwarded {
+			if seqNum+remembered < msg.SequenceNumber {
+				delete(r.forwarded, seqNum)
+			}
+		}
+	}
+}
+
+// flushPending forwards buffered messages which are now contiguous
+func (r *Relay) flushPending(now time.Time) {
+	progressed := false
+	for {
+		msg, ok := r.pending[r.nextSeqNum]
+		if !ok {
+			break
+		}
+		delete(r.pending, r.nextSeqNum)
+		r.forward(msg)
+		progressed = true
+	}
+	pendingCountGauge.Update(int64(len(r.pending)))
+	if len(r.pending) == 0 {
+		r.gapStart = time.Time{}
+		r.lastGapRequest = time.Time{}
+		r.lastRequestedUpstream = -1
+	} else if progressed {
+		// The previous gap was filled, but there's another one after it
+		r.gapStart = now
+		r.lastGapRequest = time.Time{}
+	}
+}
+
+// skipGap gives up on the missing messages and continues from the lowest buffered message
+func (r *Relay) skipGap(now time.Time) {
+	if len(r.pending) == 0 {
+		return
+	}
+	var lowest arbutil.MessageIndex
+	first := true
+	for seqNum := range r.pending {
+		if first || seqNum < lowest {
+			lowest = seqNum
+			first = false
+		}
+	}
+	log.Error("relay skipping missing feed messages", "from", r.nextSeqNum, "to", lowest, "buffered", len(r.pending), "waited", now.Sub(r.gapStart))
+	gapSkippedCounter.Inc(1)
+	r.nextSeqNum = lowest
+	r.flushPending(now)
+}
+
+func (r *Relay) checkGap(now time.Time) {
+	if r.gapStart.IsZero() {
+		return
+	}
+	waited := now.Sub(r.gapStart)
+	if waited >= r.gapConfig.MaxWait {
+		r.skipGap(now)
+		return
+	}
+	if waited >= r.gapConfig.Timeout && now.Sub(r.lastGapRequest) >= r.gapConfig.Timeout {
+		r.requestMissing(now)
+	}
+}
+
+// requestMissing asks the healthiest upstream, other than the last one asked, to resend from the gap
+func (r *Relay) requestMissing(now time.Time) {
+	upstream := r.pickUpstream(r.lastRequestedUpstream)
+	if upstream < 0 {
+		return
+	}
+	log.Warn("relay requesting missing feed messages", "from", r.nextSeqNum, "upstream", r.broadcastClients[upstream].URL())
+	r.lastGapRequest = now
+	r.lastRequestedUpstream = upstream
+	gapRequestsCounter.Inc(1)
+	r.statsMutex.Lock()
+	r.upstreamStats[upstream].CatchupRequests++
+	r.statsMutex.Unlock()
+	r.upstreamMetrics[upstream].catchupReqs.Inc(1)
+	r.broadcastClients[upstream].RequestCatchup(r.nextSeqNum)
+}
+
+// pickUpstream returns the upstream furthest ahead, preferring the most recently heard from, or -1 if there's none
+func (r *Relay) pickUpstream(exclude int) int {
+	r.statsMutex.Lock()
+	defer r.statsMutex.Unlock()
+	best := -1
+	for i, stats := range r.upstreamStats {
+		if i == exclude && len(r.upstreamStats) > 1 {
+			continue
+		}
+		if best < 0 {
+			best = i
+			continue
+		}
+		bestStats := r.upstreamStats[best]
+		if stats.HighestSeqNum > bestStats.HighestSeqNum ||
+			(stats.HighestSeqNum == bestStats.HighestSeqNum && stats.LastMessage.After(bestStats.LastMessage)) {
+			best = i
+		}
+	}
+	return best
+}
+
+// leadingUpstream returns the upstream furthest ahead among those heard from within the gap max wait,
+// or any upstream furthest ahead if none was, or -1 if there's none
+func (r *Relay) leadingUpstream(now time.Time) int {
+	r.statsMutex.Lock()
+	best := -1
+	for i, stats := range r.upstreamStats {
+		if now.Sub(stats.LastMessage) > r.gapConfig.MaxWait {
+			continue
+		}
+		if best < 0 || stats.HighestSeqNum > r.upstreamStats[best].HighestSeqNum {
+			best = i
+		}
+	}
+	r.statsMutex.Unlock()
+	if best < 0 {
+		return r.pickUpstream(-1)
+	}
+	return best
+}
+
+// lowerUpstreamSeqNums caps the upstreams' highest sequence numbers after a reset to an earlier message,
+// as what they sent after it was replaced
+func (r *Relay) lowerUpstreamSeqNums(seqNum arbutil.MessageIndex) {
+	r.statsMutex.Lock()
+	defer r.statsMutex.Unlock()
+	for i := range r.upstreamStats {
+		if r.upstreamStats[i].HighestSeqNum > seqNum {
+			r.upstreamStats[i].HighestSeqNum = seqNum
+		}
+	}
+}
+
+func (r *Relay) recordUpstreamMessage(upstream int, seqNum arbutil.MessageIndex, now time.Time) {
+	if upstream < 0 || upstream >= len(r.upstreamStats) {
+		return
+	}
+	r.statsMutex.Lock()
+	defer r.statsMutex.Unlock()
+	stats := &r.upstreamStats[upstream]
+	if seqNum > stats.HighestSeqNum {
+		stats.HighestSeqNum = seqNum
+	}
+	stats.LastMessage = now
+	stats.MessagesReceived++
+	r.upstreamMetrics[upstream].received.Inc(1)
+}
+
+func (r *Relay) recordUpstreamOutOfOrder(upstream int) {
+	if upstream < 0 || upstream >= len(r.upstreamStats) {
+		return
+	}
+	r.statsMutex.Lock()
+	defer r.statsMutex.Unlock()
+	r.upstreamStats[upstream].OutOfOrderMessages++
+	r.upstreamMetrics[upstream].outOfOrder.Inc(1)
+}
+
+func (r *Relay) updateUpstreamMetrics(now time.Time) {
+	r.statsMutex.Lock()
+	defer r.statsMutex.Unlock()
+	var highest arbutil.MessageIndex
+	for _, stats := range r.upstreamStats {
+		if stats.HighestSeqNum > highest {
+			highest = stats.HighestSeqNum
+		}
+	}
+	for i, stats := range r.upstreamStats {
+		r.upstreamMetrics[i].lag.Update(int64(highest - stats.HighestSeqNum))
+		if !stats.LastMessage.IsZero() {
+			r.upstreamMetrics[i].idle.Update(now.Sub(stats.LastMessage).Milliseconds())
+		}
+	}
+}
+
+// UpstreamStats returns a snapshot of what was received from each upstream feed
+func (r *Relay) UpstreamStats() []UpstreamStats {
+	r.statsMutex.Lock()
+	defer r.statsMutex.Unlock()
+	return append([]UpstreamStats{}, r.upstreamStats...)
+}
+
 func (r *Relay) GetListenerAddr() net.Addr {
 	return r.broadcaster.ListenerAddr()
 }
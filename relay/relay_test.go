@@ -0,0 +1,193 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/tenderly/nitro/arbstate"
+	"github.com/tenderly/nitro/arbutil"
+	"github.com/tenderly/nitro/broadcastclient"
+	"github.com/tenderly/nitro/broadcaster"
+	"github.com/tenderly/nitro/util/testhelpers"
+	"github.com/tenderly/nitro/wsbroadcastserver"
+)
+
+type messageReceiver struct {
+	messages chan *broadcaster.BroadcastFeedMessage
+}
+
+func (r *messageReceiver) AddBroadcastMessages(feedMessages []*broadcaster.BroadcastFeedMessage) error {
+	for _, feedMessage := range feedMessages {
+		r.messages <- feedMessage
+	}
+	return nil
+}
+
+func startTestRelay(ctx context.Context, t *testing.T, clientConf broadcastclient.BroadcastClientConfig) (*Relay, *messageReceiver) {
+	t.Helper()
+	r, err := NewRelay(wsbroadcastserver.DefaultTestBroadcasterConfig, clientConf, TestGapConfig)
+	Require(t, err)
+	return r, startTestDownstream(ctx, t, r)
+}
+
+// startTestDownstream starts the relay and connects a feed client to its output
+func startTestDownstream(ctx context.Context, t *testing.T, r *Relay) *messageReceiver {
+	t.Helper()
+	Require(t, r.Start(ctx))
+
+	receiver := &messageReceiver{messages: make(chan *broadcaster.BroadcastFeedMessage, 100)}
+	downstreamConf := broadcastclient.DefaultBroadcastClientConfig
+	port := r.GetListenerAddr().(*net.TCPAddr).Port
+	downstream, err := broadcastclient.NewBroadcastClient(downstreamConf, fmt.Sprintf("ws://127.0.0.1:%d/", port), 0, receiver)
+	Require(t, err)
+	downstream.Start(ctx)
+	t.Cleanup(downstream.StopAndWait)
+	t.Cleanup(r.StopAndWait)
+	return receiver
+}
+
+func expectMessages(t *testing.T, receiver *messageReceiver, expected ...arbutil.MessageIndex) {
+	t.Helper()
+	for _, seqNum := range expected {
+		timer := time.NewTimer(5 * time.Second)
+		select {
+		case msg := <-receiver.messages:
+			if msg.SequenceNumber != seqNum {
+				Fail(t, "expected sequence number", seqNum, "got", msg.SequenceNumber)
+			}
+		case <-timer.C:
+			Fail(t, "did not receive sequence number", seqNum)
+		}
+		timer.Stop()
+	}
+}
+
+func expectNoMessages(t *testing.T, receiver *messageReceiver, wait time.Duration) {
+	t.Helper()
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case msg := <-receiver.messages:
+		Fail(t, "unexpected message", msg.SequenceNumber)
+	case <-timer.C:
+	}
+}
+
+func TestRelayReordersAndDeduplicates(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, receiver := startTestRelay(ctx, t, broadcastclient.DefaultBroadcastClientConfig)
+
+	send := func(seqNum arbutil.MessageIndex) {
+		r.messageChan <- upstreamMessage{upstream: -1, message: &broadcaster.BroadcastFeedMessage{SequenceNumber: seqNum}}
+	}
+
+	send(0)
+	expectMessages(t, receiver, 0)
+	send(2)
+	send(3)
+	send(0)
+	expectNoMessages(t, receiver, 50*time.Millisecond)
+	send(1)
+	expectMessages(t, receiver, 1, 2, 3)
+	send(2)
+	send(3)
+
+	// Nobody can fill the gap at 4, so the relay skips it after the max wait
+	send(5)
+	expectMessages(t, receiver, 5)
+	send(6)
+	expectMessages(t, receiver, 6)
+}
+
+func TestRelayFollowsReorgs(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r, err := NewRelay(wsbroadcastserver.DefaultTestBroadcasterConfig, broadcastclient.DefaultBroadcastClientConfig, TestGapConfig)
+	Require(t, err)
+	// Two upstreams without connections, whose messages are injected directly
+	for i := 0; i < 2; i++ {
+		r.upstreamStats = append(r.upstreamStats, UpstreamStats{URL: fmt.Sprint("upstream", i)})
+		r.upstreamMetrics = append(r.upstreamMetrics, newUpstreamMetrics(i))
+	}
+	receiver := startTestDownstream(ctx, t, r)
+
+	send := func(upstream int, seqNum arbutil.MessageIndex, delayedRead uint64) {
+		r.messageChan <- upstreamMessage{upstream: upstream, message: &broadcaster.BroadcastFeedMessage{
+			SequenceNumber: seqNum,
+			Message:        arbstate.MessageWithMetadata{DelayedMessagesRead: delayedRead},
+		}}
+	}
+
+	send(0, 0, 0)
+	send(0, 1, 0)
+	send(0, 2, 0)
+	expectMessages(t, receiver, 0, 1, 2)
+	send(1, 1, 0)
+	expectNoMessages(t, receiver, 50*time.Millisecond)
+
+	// A lagging upstream can't reset the relay
+	send(1, 1, 1)
+	expectNoMessages(t, receiver, 50*time.Millisecond)
+
+	// The sequencer reorged from 1, so the relay forwards the new messages from the leading upstream
+	send(0, 1, 1)
+	expectMessages(t, receiver, 1)
+	if stats := r.UpstreamStats(); stats[0].HighestSeqNum != 1 {
+		Fail(t, "upstream highest sequence number not lowered after reset", stats[0].HighestSeqNum)
+	}
+	// and drops the replaced message from the lagging upstream
+	send(1, 2, 0)
+	expectNoMessages(t, receiver, 50*time.Millisecond)
+	send(0, 2, 1)
+	expectMessages(t, receiver, 2)
+	send(0, 1, 1)
+	expectNoMessages(t, receiver, 50*time.Millisecond)
+}
+
+func TestRelayRequestsMissingMessages(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	upstream := broadcaster.NewBroadcaster(wsbroadcastserver.DefaultTestBroadcasterConfig, nil)
+	Require(t, upstream.Start(ctx))
+	defer upstream.StopAndWait()
+	for i := 0; i < 5; i++ {
+		upstream.BroadcastFeedMessages([]*broadcaster.BroadcastFeedMessage{{SequenceNumber: arbutil.MessageIndex(i)}})
+	}
+
+	clientConf := broadcastclient.DefaultBroadcastClientConfig
+	clientConf.URLs = []string{fmt.Sprintf("ws://127.0.0.1:%d/", upstream.ListenerAddr().(*net.TCPAddr).Port)}
+	r, err := NewRelay(wsbroadcastserver.DefaultTestBroadcasterConfig, clientConf, TestGapConfig)
+	Require(t, err)
+	// The relay expects 0, but its upstream connection initially only asks for messages from 3 onwards
+	r.initialized = true
+	r.nextSeqNum = 0
+	r.broadcastClients[0].RequestCatchup(3)
+	receiver := startTestDownstream(ctx, t, r)
+
+	expectMessages(t, receiver, 0, 1, 2, 3, 4)
+	stats := r.UpstreamStats()
+	if stats[0].CatchupRequests == 0 {
+		Fail(t, "expected relay to request missing messages from upstream")
+	}
+	if stats[0].HighestSeqNum != 4 {
+		Fail(t, "unexpected upstream highest sequence number", stats[0].HighestSeqNum)
+	}
+}
+
+func Require(t *testing.T, err error, printables ...interface{}) {
+	t.Helper()
+	testhelpers.RequireImpl(t, err, printables...)
+}
+
+func Fail(t *testing.T, printables ...interface{}) {
+	t.Helper()
+	testhelpers.FailImpl(t, printables...)
+}
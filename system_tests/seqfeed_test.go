@@ -26,8 +26,9 @@ func newBroadcasterConfigTest(port string) *wsbroadcastserver.BroadcasterConfig
 
 func newBroadcastClientConfigTest(port int) *broadcastclient.BroadcastClientConfig {
 	return &broadcastclient.BroadcastClientConfig{
-		URLs:    []string{fmt.Sprintf("ws://localhost:%d/feed", port)},
-		Timeout: 20 * time.Second,
+		URLs:              []string{fmt.Sprintf("ws://localhost:%d/feed", port)},
+		Timeout:           20 * time.Second,
+		EnableCompression: true,
 	}
 }
 
@@ -80,8 +81,9 @@ func TestRelayedSequencerFeed(t *testing.T) {
 	port := nodeA.BroadcastServer.ListenerAddr().(*net.TCPAddr).Port
 	relayClientConf := *newBroadcastClientConfigTest(port)
 
-	relay := relay.NewRelay(relayServerConf, relayClientConf)
-	err := relay.Start(ctx)
+	relay, err := relay.NewRelay(relayServerConf, relayClientConf, relay.TestGapConfig)
+	Require(t, err)
+	err = relay.Start(ctx)
 	Require(t, err)
 	defer relay.StopAndWait()
 
@@ -0,0 +1,123 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package arbtest
+
+import (
+	"bufio"
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tenderly/nitro/arbnode"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/go-ethereum/ethclient"
+	"github.com/tenderly/nitro/statetransfer"
+)
+
+func sendL1BlocksForSync(t *testing.T, ctx context.Context, l1info info, l1client *ethclient.Client) {
+	for i := 0; i < 30; i++ {
+		SendWaitTestTransactions(t, ctx, l1client, []*types.Transaction{
+			l1info.PrepareTx("Faucet", "User", 30000, big.NewInt(1e12), nil),
+		})
+	}
+}
+
+func TestSnapshotExportImport(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	l2info, nodeA, l2clientA, l2stackA, l1info, _, l1client, l1stack := CreateTestNodeOnL1(t, ctx, true)
+	defer requireClose(t, l1stack)
+	defer requireClose(t, l2stackA)
+	chainConfig := nodeA.ArbInterface.BlockChain().Config()
+
+	nodeConfig := arbnode.ConfigDefaultL1NonSequencerTest()
+	nodeConfig.DataAvailability.Enable = false
+
+	// node B syncs from L1 without keeping the state of old blocks, like most nodes a snapshot is exported from
+	dirB := t.TempDir()
+	l2stackB, err := arbnode.CreateDefaultStackForTest(dirB)
+	Require(t, err)
+	chainDbB, err := l2stackB.OpenDatabase("chaindb", 0, 0, "", false)
+	Require(t, err)
+	arbDbB, err := l2stackB.OpenDatabase("arbdb", 0, 0, "", false)
+	Require(t, err)
+	initReader := statetransfer.NewMemoryInitDataReader(&l2info.ArbInitData)
+	blockchainB, err := arbnode.WriteOrTestBlockChain(chainDbB, arbnode.DefaultCacheConfigFor(l2stackB, false), initReader, chainConfig, arbnode.ConfigDefaultL2Test(), 0)
+	Require(t, err)
+	_, err = arbnode.CreateNode(ctx, l2stackB, chainDbB, arbDbB, nodeConfig, blockchainB, l1client, nodeA.DeployInfo, nil, nil)
+	Require(t, err)
+	Require(t, l2stackB.Start())
+	l2clientB := ClientForStack(t, l2stackB)
+
+	l2info.GenerateAccount("User2")
+	tx := l2info.PrepareTx("Owner", "User2", l2info.TransferGas, big.NewInt(1e12), nil)
+	Require(t, l2clientA.SendTransaction(ctx, tx))
+	_, err = EnsureTxSucceeded(ctx, l2clientA, tx)
+	Require(t, err)
+	sendL1BlocksForSync(t, ctx, l1info, l1client)
+	_, err = WaitForTx(ctx, l2clientB, tx.Hash(), time.Second*5)
+	Require(t, err)
+	requireClose(t, l2stackB)
+
+	exportStack, err := arbnode.CreateDefaultStackForTest(dirB)
+	Require(t, err)
+	exportChainDb, err := exportStack.OpenDatabase("chaindb", 0, 0, "", true)
+	Require(t, err)
+	exportArbDb, err := exportStack.OpenDatabase("arbdb", 0, 0, "", true)
+	Require(t, err)
+	archive := filepath.Join(t.TempDir(), "snapshot.tar")
+	file, err := os.Create(archive)
+	Require(t, err)
+	writer := bufio.NewWriter(file)
+	manifest, err := arbnode.ExportSnapshot(exportStack.ResolvePath("chaindb"), exportChainDb, exportArbDb, 0, writer)
+	Require(t, err)
+	Require(t, writer.Flush())
+	Require(t, file.Close())
+	requireClose(t, exportStack)
+	if manifest.BatchCount == 0 {
+		Fail(t, "snapshot has no batches")
+	}
+
+	// node C starts from the snapshot and keeps syncing from L1
+	l2stackC, err := arbnode.CreateDefaultStackForTest(t.TempDir())
+	Require(t, err)
+	defer requireClose(t, l2stackC)
+	imported, err := arbnode.ImportSnapshot(ctx, l2stackC, archive, "arbdb", chainConfig.ChainID, l1client, nodeA.DeployInfo)
+	Require(t, err)
+	if imported.BlockHash != manifest.BlockHash {
+		Fail(t, "imported snapshot of block", imported.BlockHash, "but exported", manifest.BlockHash)
+	}
+	chainDbC, err := l2stackC.OpenDatabase("l2chaindata", 0, 0, "", false)
+	Require(t, err)
+	arbDbC, err := l2stackC.OpenDatabase("arbdb", 0, 0, "", false)
+	Require(t, err)
+	blockchainC, err := arbnode.GetBlockChain(chainDbC, arbnode.DefaultCacheConfigFor(l2stackC, false), chainConfig, arbnode.ConfigDefaultL2Test())
+	Require(t, err)
+	Require(t, arbnode.RewindToSnapshot(blockchainC, imported))
+	_, err = arbnode.CreateNode(ctx, l2stackC, chainDbC, arbDbC, nodeConfig, blockchainC, l1client, nodeA.DeployInfo, nil, nil)
+	Require(t, err)
+	Require(t, l2stackC.Start())
+	l2clientC := ClientForStack(t, l2stackC)
+
+	_, err = l2clientC.TransactionReceipt(ctx, tx.Hash())
+	Require(t, err)
+	tx = l2info.PrepareTx("Owner", "User2", l2info.TransferGas, big.NewInt(1e12), nil)
+	Require(t, l2clientA.SendTransaction(ctx, tx))
+	_, err = EnsureTxSucceeded(ctx, l2clientA, tx)
+	Require(t, err)
+	sendL1BlocksForSync(t, ctx, l1info, l1client)
+	_, err = WaitForTx(ctx, l2clientC, tx.Hash(), time.Second*5)
+	Require(t, err)
+
+	l2balance, err := l2clientC.BalanceAt(ctx, l2info.GetAddress("User2"), nil)
+	Require(t, err)
+	if l2balance.Cmp(big.NewInt(2e12)) != 0 {
+		Fail(t, "Unexpected balance:", l2balance)
+	}
+}
@@ -28,6 +28,8 @@ type HeaderReader struct {
 	outChannelsBehind          map[chan<- *types.Header]struct{}
 	lastBroadcastHash          common.Hash
 	lastBroadcastHeader        *types.Header
+	lastBroadcastTime          time.Time
+	lastReadErr                error
 	lastPendingCallBlockNr     uint64
 	requiresPendingCallUpdates int
 }
@@ -134,7 +136,9 @@ func (s *HeaderReader) possiblyBroadcast(h *types.Header) {
 		broadcastThis = true
 		s.lastBroadcastHash = headerHash
 		s.lastBroadcastHeader = h
+		s.lastBroadcastTime = time.Now()
 	}
+	s.lastReadErr = nil
 
 	if s.requiresPendingCallUpdates > 0 {
 		pendingCallBlockNr, err := arbutil.GetPendingCallBlockNumber(s.GetContext(), s.client)
@@ -197,6 +201,7 @@ func (s *HeaderReader) broadcastLoop(ctx context.Context) {
 			h, err := s.client.HeaderByNumber(ctx, nil)
 			if err != nil {
 				log.Warn("failed reading header", "err", err)
+				s.setLastReadErr(err)
 			} else {
 				s.possiblyBroadcast(h)
 			}
@@ -260,6 +265,27 @@ func (s *HeaderReader) LastHeader(ctx context.Context) (*types.Header, error) {
 	return s.client.HeaderByNumber(ctx, nil)
 }
 
+func (s *HeaderReader) setLastReadErr(err error) {
+	s.chanMutex.Lock()
+	defer s.chanMutex.Unlock()
+	s.lastReadErr = err
+}
+
+// LastHeaderReceived returns the latest header read from L1 without querying it, and when it was received.
+// The header is nil if none has been read yet.
+func (s *HeaderReader) LastHeaderReceived() (*types.Header, time.Time) {
+	s.chanMutex.Lock()
+	defer s.chanMutex.Unlock()
+	return s.lastBroadcastHeader, s.lastBroadcastTime
+}
+
+// LastReadError returns the error of the latest failed attempt to read a header, or nil if a header was read since
+func (s *HeaderReader) LastReadError() error {
+	s.chanMutex.Lock()
+	defer s.chanMutex.Unlock()
+	return s.lastReadErr
+}
+
 func (s *HeaderReader) UpdatingPendingCallBlockNr() bool {
 	s.chanMutex.Lock()
 	defer s.chanMutex.Unlock()
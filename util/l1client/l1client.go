@@ -0,0 +1,614 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package l1client provides an arbutil.L1Interface spread over several L1 endpoints,
+// failing over between them and optionally requiring a quorum of them to agree on critical reads.
+package l1client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/arbutil"
+	"github.com/tenderly/nitro/go-ethereum"
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/go-ethereum/crypto"
+	"github.com/tenderly/nitro/go-ethereum/ethclient"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
+	"github.com/tenderly/nitro/go-ethereum/rpc"
+	"github.com/tenderly/nitro/util/stopwaiter"
+)
+
+type Config struct {
+	FallbackURLs        []string      `koanf:"fallback-urls"`
+	CallTimeout         time.Duration `koanf:"call-timeout"`
+	HealthCheckInterval time.Duration `koanf:"health-check-interval"`
+	MaxBlockLag         uint64        `koanf:"max-block-lag"`
+	Quorum              int           `koanf:"quorum"`
+}
+
+var DefaultConfig = Config{
+	FallbackURLs:        []string{},
+	CallTimeout:         time.Minute,
+	HealthCheckInterval: 10 * time.Second,
+	MaxBlockLag:         10,
+	Quorum:              0,
+}
+
+func ConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.StringSlice(prefix+".fallback-urls", DefaultConfig.FallbackURLs, "layer 1 ethereum node RPC URLs to fail over to, in order of preference, when the main one is unhealthy")
+	f.Duration(prefix+".call-timeout", DefaultConfig.CallTimeout, "timeout for each call to a layer 1 node before failing over to the next (0 for none)")
+	f.Duration(prefix+".health-check-interval", DefaultConfig.HealthCheckInterval, "interval between checks of the layer 1 nodes' health")
+	f.Uint64(prefix+".max-block-lag", DefaultConfig.MaxBlockLag, "a layer 1 node is unhealthy if its latest block is this many blocks behind the most advanced node (0 to ignore lag)")
+	f.Int(prefix+".quorum", DefaultConfig.Quorum, "if above 1, the number of layer 1 nodes that must agree on historical log queries and contract calls, waiting up to the call timeout for enough nodes to have recent blocks")
+}
+
+var (
+	healthyEndpointsGauge = metrics.NewRegisteredGauge("arb/l1client/endpoints/healthy", nil)
+	failoverCounter       = metrics.NewRegisteredCounter("arb/l1client/failovers", nil)
+	noQuorumCounter       = metrics.NewRegisteredCounter("arb/l1client/quorum/failures", nil)
+)
+
+var (
+	ErrNoEndpoints = errors.New("no layer 1 node available")
+	ErrNoQuorum    = errors.New("layer 1 nodes didn't reach quorum")
+)
+
+// backend is what's needed of the client for a single endpoint
+type backend interface {
+	arbutil.L1Interface
+	ChainID(ctx context.Context) (*big.Int, error)
+	Close()
+}
+
+type endpoint struct {
+	url string
+
+	// behind the client's mutex
+	backend     backend // nil until dialed
+	healthy     bool
+	blockNumber uint64 // as of the last successful health check
+}
+
+// Client is an arbutil.L1Interface calling the first healthy one of several endpoints.
+// Historical log queries and contract calls need the agreement of a quorum of endpoints if one is configured.
+type Client struct {
+	stopwaiter.StopWaiter
+	config *Config
+	dial   func(context.Context, string) (backend, error)
+
+	mutex     sync.Mutex
+	endpoints []*endpoint
+	head      uint64 // the most advanced endpoint's block number as of the last health check
+}
+
+var _ arbutil.L1Interface = (*Client)(nil)
+
+func dialEthClient(ctx context.Context, url string) (backend, error) {
+	client, err := ethclient.DialContext(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// New connects to the url and the configured fallback urls. It only fails if none can be connected to,
+// the others are retried by the health checks once the client is started.
+func New(ctx context.Context, url string, config *Config) (*Client, error) {
+	return newClient(ctx, append([]string{url}, config.FallbackURLs...), config, dialEthClient)
+}
+
+func newClient(ctx context.Context, urls []string, config *Config, dial func(context.Context, string) (backend, error)) (*Client, error) {
+	if config.Quorum > len(urls) {
+		return nil, fmt.Errorf("layer 1 quorum of %v is larger than the %v nodes configured", config.Quorum, len(urls))
+	}
+	c := &Client{
+		config: config,
+		dial:   dial,
+	}
+	var lastErr error
+	connected := 0
+	for _, url := range urls {
+		e := &endpoint{url: url}
+		e.backend, lastErr = dial(ctx, url)
+		if lastErr != nil {
+			log.Warn("error connecting to L1 node", "url", url, "err", lastErr)
+		} else {
+			e.healthy = true
+			connected++
+		}
+		c.endpoints = append(c.endpoints, e)
+	}
+	if connected == 0 {
+		return nil, lastErr
+	}
+	healthyEndpointsGauge.Update(int64(connected))
+	return c, nil
+}
+
+func (c *Client) Start(ctxIn context.Context) {
+	c.StopWaiter.Start(ctxIn)
+	c.CallIteratively(func(ctx context.Context) time.Duration {
+		c.checkHealth(ctx)
+		return c.config.HealthCheckInterval
+	})
+}
+
+func (c *Client) StopAndWait() {
+	c.StopWaiter.StopAndWait()
+	c.Close()
+}
+
+// Close disconnects from every endpoint, without needing the client to have been started
+func (c *Client) Close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, e := range c.endpoints {
+		if e.backend != nil {
+			e.backend.Close()
+		}
+	}
+}
+
+func (c *Client) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.config.CallTimeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.config.CallTimeout)
+}
+
+// checkHealth dials the endpoints not yet connected to, and marks endpoints unhealthy if they fail or lag behind
+func (c *Client) checkHealth(ctx context.Context) {
+	c.mutex.Lock()
+	endpoints := append([]*endpoint{}, c.endpoints...)
+	backends := make([]backend, len(endpoints))
+	for i, e := range endpoints {
+		backends[i] = e.backend
+	}
+	c.mutex.Unlock()
+
+	blockNumbers := make([]uint64, len(endpoints))
+	errs := make([]error, len(endpoints))
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		wg.Add(1)
+		go func(i int, e *endpoint) {
+			defer wg.Done()
+			callCtx, cancel := c.callContext(ctx)
+			defer cancel()
+			if backends[i] == nil {
+				backends[i], errs[i] = c.dial(callCtx, e.url)
+				if errs[i] != nil {
+					return
+				}
+			}
+			blockNumbers[i], errs[i] = backends[i].BlockNumber(callCtx)
+		}(i, e)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return
+	}
+
+	var highest uint64
+	for i := range endpoints {
+		if errs[i] == nil && blockNumbers[i] > highest {
+			highest = blockNumbers[i]
+		}
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if highest > c.head {
+		c.head = highest
+	}
+	healthyCount := 0
+	for i, e := range endpoints {
+		if e.backend == nil {
+			e.backend = backends[i]
+		}
+		err := errs[i]
+		if err == nil {
+			e.blockNumber = blockNumbers[i]
+		}
+		if err == nil && c.config.MaxBlockLag > 0 && highest-blockNumbers[i] > c.config.MaxBlockLag {
+			err = fmt.Errorf("block %v is %v blocks behind the most advanced node", blockNumbers[i], highest-blockNumbers[i])
+		}
+		if err == nil {
+			healthyCount++
+		}
+		if e.healthy && err != nil {
+			log.Warn("L1 node unhealthy", "url", e.url, "err", err)
+		} else if !e.healthy && err == nil {
+			log.Info("L1 node healthy again", "url", e.url, "blockNumber", blockNumbers[i])
+		}
+		e.healthy = err == nil
+	}
+	healthyEndpointsGauge.Update(int64(healthyCount))
+}
+
+func (c *Client) markUnhealthy(e *endpoint, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if e.healthy {
+		log.Warn("L1 node failed, failing over", "url", e.url, "err", err)
+		e.healthy = false
+		failoverCounter.Inc(1)
+	}
+}
+
+type candidate struct {
+	endpoint *endpoint
+	backend  backend
+}
+
+// candidates returns the connected endpoints to try in order: the healthy ones by preference, then the unhealthy ones
+func (c *Client) candidates() []candidate {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var healthy, unhealthy []candidate
+	for _, e := range c.endpoints {
+		if e.backend == nil {
+			continue
+		}
+		if e.healthy {
+			healthy = append(healthy, candidate{e, e.backend})
+		} else {
+			unhealthy = append(unhealthy, candidate{e, e.backend})
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// the JSON-RPC error code nodes answer with when rate limiting
+const limitExceededCode = -32005
+
+// isEndpointFailure is whether the error is the endpoint's fault, rather than an answer that every endpoint would give.
+// Besides failing to answer, that's the endpoint rate limiting us or not having the block yet.
+func isEndpointFailure(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, ethereum.NotFound) {
+		return false
+	}
+	var rpcErr rpc.Error
+	if !errors.As(err, &rpcErr) {
+		return true
+	}
+	return rpcErr.ErrorCode() == limitExceededCode || strings.Contains(err.Error(), "header not found")
+}
+
+// call tries the endpoints in order until one answers
+func call[T any](ctx context.Context, c *Client, timeout bool, f func(context.Context, backend) (T, error)) (T, error) {
+	var zero T
+	err := ErrNoEndpoints
+	for _, target := range c.candidates() {
+		callCtx, cancel := ctx, func() {}
+		if timeout {
+			callCtx, cancel = c.callContext(ctx)
+		}
+		var res T
+		res, err = f(callCtx, target.backend)
+		cancel()
+		if err == nil || !isEndpointFailure(ctx, err) {
+			return res, err
+		}
+		c.markUnhealthy(target.endpoint, err)
+	}
+	return zero, err
+}
+
+type quorumVote[T any] struct {
+	result T
+	err    error
+	key    string
+}
+
+// quorumCandidates returns the endpoints to ask for a quorum on a read of the block, which are those known to have it.
+// Without a block number, as for reads by block hash, every endpoint is asked.
+func (c *Client) quorumCandidates(blockNumber *big.Int) []candidate {
+	all := c.candidates()
+	if blockNumber == nil {
+		return all
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.head == 0 {
+		// not health checked yet
+		return all
+	}
+	var candidates []candidate
+	for _, target := range all {
+		if blockNumber.IsUint64() && target.endpoint.blockNumber >= blockNumber.Uint64() {
+			candidates = append(candidates, target)
+		}
+	}
+	return candidates
+}
+
+// isRecent is whether the block is within the max block lag of the head, so healthy endpoints may not have it yet
+func (c *Client) isRecent(blockNumber *big.Int) bool {
+	if blockNumber == nil || !blockNumber.IsUint64() {
+		return false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.head != 0 && blockNumber.Uint64()+c.config.MaxBlockLag > c.head
+}
+
+// how often the endpoints' block numbers are checked again while waiting for enough of them to have a recent block
+var quorumRetryInterval = time.Second
+
+// waitForQuorumCandidates returns the quorum candidates for the block.
+// If too few endpoints have a recent block yet, it checks them again until enough do or the call timeout passes.
+func (c *Client) waitForQuorumCandidates(ctx context.Context, blockNumber *big.Int) ([]candidate, error) {
+	waitCtx, cancel := c.callContext(ctx)
+	defer cancel()
+	for {
+		candidates := c.quorumCandidates(blockNumber)
+		if len(candidates) >= c.config.Quorum || !c.isRecent(blockNumber) {
+			return candidates, nil
+		}
+		select {
+		case <-waitCtx.Done():
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			return candidates, nil
+		case <-time.After(quorumRetryInterval):
+		}
+		c.checkHealth(waitCtx)
+	}
+}
+
+// quorumCall asks every endpoint having the block and returns the answer a quorum of them agree on.
+// Without a quorum configured it's the same as call.
+func quorumCall[T any](ctx context.Context, c *Client, blockNumber *big.Int, f func(context.Context, backend) (T, error), encode func(T) ([]byte, error)) (T, error) {
+	if c.config.Quorum <= 1 {
+		return call(ctx, c, true, f)
+	}
+	var zero T
+	candidates, err := c.waitForQuorumCandidates(ctx, blockNumber)
+	if err != nil {
+		return zero, err
+	}
+	if len(candidates) < c.config.Quorum {
+		noQuorumCounter.Inc(1)
+		return zero, fmt.Errorf("%w: %v nodes connected with the block, %v needed", ErrNoQuorum, len(candidates), c.config.Quorum)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	votesChan := make(chan quorumVote[T], len(candidates))
+	for _, target := range candidates {
+		go func(target candidate) {
+			callCtx, cancel := c.callContext(ctx)
+			defer cancel()
+			res, err := f(callCtx, target.backend)
+			if err != nil {
+				if isEndpointFailure(ctx, err) {
+					c.markUnhealthy(target.endpoint, err)
+					votesChan <- quorumVote[T]{err: err}
+				} else {
+					votesChan <- quorumVote[T]{err: err, key: "error: " + err.Error()}
+				}
+				return
+			}
+			encoded, err := encode(res)
+			if err != nil {
+				votesChan <- quorumVote[T]{err: err}
+				return
+			}
+			votesChan <- quorumVote[T]{result: res, key: crypto.Keccak256Hash(encoded).Hex()}
+		}(target)
+	}
+	counts := make(map[string]int)
+	for range candidates {
+		vote := <-votesChan
+		if vote.key == "" {
+			continue
+		}
+		counts[vote.key]++
+		if counts[vote.key] >= c.config.Quorum {
+			return vote.result, vote.err
+		}
+	}
+	if ctx.Err() != nil {
+		return zero, ctx.Err()
+	}
+	noQuorumCounter.Inc(1)
+	return zero, fmt.Errorf("%w: %v nodes needed, %v different answers from %v nodes", ErrNoQuorum, c.config.Quorum, len(counts), len(candidates))
+}
+
+// isHistorical is whether the block number refers to a specific block, so every synced endpoint must give the same answer
+func isHistorical(blockNumber *big.Int) bool {
+	return blockNumber != nil && blockNumber.Sign() >= 0
+}
+
+func (c *Client) ChainID(ctx context.Context) (*big.Int, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (*big.Int, error) {
+		return b.ChainID(ctx)
+	})
+}
+
+func (c *Client) CodeAt(ctx context.Context, contract common.Address, blockNumber *big.Int) ([]byte, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) ([]byte, error) {
+		return b.CodeAt(ctx, contract, blockNumber)
+	})
+}
+
+func (c *Client) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	f := func(ctx context.Context, b backend) ([]byte, error) {
+		return b.CallContract(ctx, msg, blockNumber)
+	}
+	if isHistorical(blockNumber) {
+		return quorumCall(ctx, c, blockNumber, f, func(res []byte) ([]byte, error) { return res, nil })
+	}
+	return call(ctx, c, true, f)
+}
+
+func (c *Client) PendingCallContract(ctx context.Context, msg ethereum.CallMsg) ([]byte, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) ([]byte, error) {
+		return b.PendingCallContract(ctx, msg)
+	})
+}
+
+func (c *Client) HeaderByHash(ctx context.Context, hash common.Hash) (*types.Header, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (*types.Header, error) {
+		return b.HeaderByHash(ctx, hash)
+	})
+}
+
+func (c *Client) HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (*types.Header, error) {
+		return b.HeaderByNumber(ctx, number)
+	})
+}
+
+func (c *Client) BlockByHash(ctx context.Context, hash common.Hash) (*types.Block, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (*types.Block, error) {
+		return b.BlockByHash(ctx, hash)
+	})
+}
+
+func (c *Client) BlockByNumber(ctx context.Context, number *big.Int) (*types.Block, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (*types.Block, error) {
+		return b.BlockByNumber(ctx, number)
+	})
+}
+
+func (c *Client) BlockNumber(ctx context.Context) (uint64, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (uint64, error) {
+		return b.BlockNumber(ctx)
+	})
+}
+
+func (c *Client) TransactionCount(ctx context.Context, blockHash common.Hash) (uint, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (uint, error) {
+		return b.TransactionCount(ctx, blockHash)
+	})
+}
+
+func (c *Client) TransactionInBlock(ctx context.Context, blockHash common.Hash, index uint) (*types.Transaction, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (*types.Transaction, error) {
+		return b.TransactionInBlock(ctx, blockHash, index)
+	})
+}
+
+func (c *Client) TransactionByHash(ctx context.Context, txHash common.Hash) (*types.Transaction, bool, error) {
+	type txAndPending struct {
+		tx        *types.Transaction
+		isPending bool
+	}
+	res, err := call(ctx, c, true, func(ctx context.Context, b backend) (txAndPending, error) {
+		tx, isPending, err := b.TransactionByHash(ctx, txHash)
+		return txAndPending{tx, isPending}, err
+	})
+	return res.tx, res.isPending, err
+}
+
+func (c *Client) TransactionReceipt(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (*types.Receipt, error) {
+		return b.TransactionReceipt(ctx, txHash)
+	})
+}
+
+func (c *Client) TransactionSender(ctx context.Context, tx *types.Transaction, block common.Hash, index uint) (common.Address, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (common.Address, error) {
+		return b.TransactionSender(ctx, tx, block, index)
+	})
+}
+
+func (c *Client) BalanceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (*big.Int, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (*big.Int, error) {
+		return b.BalanceAt(ctx, account, blockNumber)
+	})
+}
+
+func (c *Client) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) ([]byte, error) {
+		return b.StorageAt(ctx, account, key, blockNumber)
+	})
+}
+
+func (c *Client) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (uint64, error) {
+		return b.NonceAt(ctx, account, blockNumber)
+	})
+}
+
+func (c *Client) PendingCodeAt(ctx context.Context, account common.Address) ([]byte, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) ([]byte, error) {
+		return b.PendingCodeAt(ctx, account)
+	})
+}
+
+func (c *Client) PendingNonceAt(ctx context.Context, account common.Address) (uint64, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (uint64, error) {
+		return b.PendingNonceAt(ctx, account)
+	})
+}
+
+func (c *Client) SuggestGasPrice(ctx context.Context) (*big.Int, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (*big.Int, error) {
+		return b.SuggestGasPrice(ctx)
+	})
+}
+
+func (c *Client) SuggestGasTipCap(ctx context.Context) (*big.Int, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (*big.Int, error) {
+		return b.SuggestGasTipCap(ctx)
+	})
+}
+
+func (c *Client) EstimateGas(ctx context.Context, msg ethereum.CallMsg) (uint64, error) {
+	return call(ctx, c, true, func(ctx context.Context, b backend) (uint64, error) {
+		return b.EstimateGas(ctx, msg)
+	})
+}
+
+// SendTransaction sends the transaction to the first endpoint that accepts it.
+// Resending it to another endpoint after a failure is harmless, as it's the same signed transaction.
+func (c *Client) SendTransaction(ctx context.Context, tx *types.Transaction) error {
+	_, err := call(ctx, c, true, func(ctx context.Context, b backend) (struct{}, error) {
+		return struct{}{}, b.SendTransaction(ctx, tx)
+	})
+	return err
+}
+
+func (c *Client) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	f := func(ctx context.Context, b backend) ([]types.Log, error) {
+		return b.FilterLogs(ctx, query)
+	}
+	if query.BlockHash != nil {
+		return quorumCall(ctx, c, nil, f, func(logs []types.Log) ([]byte, error) { return json.Marshal(logs) })
+	}
+	if isHistorical(query.ToBlock) {
+		return quorumCall(ctx, c, query.ToBlock, f, func(logs []types.Log) ([]byte, error) { return json.Marshal(logs) })
+	}
+	return call(ctx, c, true, f)
+}
+
+// Subscriptions are made on the first healthy endpoint, without a timeout as they're long lived.
+// Subscribers resubscribe after an error, which picks the best endpoint again.
+
+func (c *Client) SubscribeFilterLogs(ctx context.Context, query ethereum.FilterQuery, ch chan<- types.Log) (ethereum.Subscription, error) {
+	return call(ctx, c, false, func(ctx context.Context, b backend) (ethereum.Subscription, error) {
+		return b.SubscribeFilterLogs(ctx, query, ch)
+	})
+}
+
+func (c *Client) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	return call(ctx, c, false, func(ctx context.Context, b backend) (ethereum.Subscription, error) {
+		return b.SubscribeNewHead(ctx, ch)
+	})
+}
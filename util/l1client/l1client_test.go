@@ -0,0 +1,261 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package l1client
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/go-ethereum"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+)
+
+type testRpcError struct{}
+
+func (testRpcError) Error() string {
+	return "execution reverted"
+}
+
+func (testRpcError) ErrorCode() int {
+	return 3
+}
+
+type testRpcCodeError struct {
+	code    int
+	message string
+}
+
+func (e testRpcCodeError) Error() string {
+	return e.message
+}
+
+func (e testRpcCodeError) ErrorCode() int {
+	return e.code
+}
+
+type testBackend struct {
+	backend // unimplemented methods panic
+
+	mutex       sync.Mutex
+	calls       int
+	err         error
+	blockNumber uint64
+	logs        []types.Log
+}
+
+func (b *testBackend) answer() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.calls++
+	return b.err
+}
+
+func (b *testBackend) callCount() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.calls
+}
+
+func (b *testBackend) BlockNumber(ctx context.Context) (uint64, error) {
+	if err := b.answer(); err != nil {
+		return 0, err
+	}
+	return b.blockNumber, nil
+}
+
+func (b *testBackend) FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error) {
+	if err := b.answer(); err != nil {
+		return nil, err
+	}
+	return b.logs, nil
+}
+
+func (b *testBackend) Close() {}
+
+func newTestClient(t *testing.T, config Config, backends ...*testBackend) *Client {
+	t.Helper()
+	var urls []string
+	byUrl := make(map[string]*testBackend)
+	for i, b := range backends {
+		url := string(rune('a' + i))
+		urls = append(urls, url)
+		byUrl[url] = b
+	}
+	client, err := newClient(context.Background(), urls, &config, func(ctx context.Context, url string) (backend, error) {
+		return byUrl[url], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestFailover(t *testing.T) {
+	ctx := context.Background()
+	first := &testBackend{err: errors.New("connection refused"), blockNumber: 1}
+	second := &testBackend{blockNumber: 2}
+	client := newTestClient(t, DefaultConfig, first, second)
+
+	blockNumber, err := client.BlockNumber(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blockNumber != 2 {
+		t.Fatal("got block number", blockNumber, "from failed node")
+	}
+	if _, err := client.BlockNumber(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if first.callCount() != 1 {
+		t.Error("unhealthy node was called first again")
+	}
+
+	// answers from the node itself aren't failed over
+	second.err = testRpcError{}
+	if _, err := client.BlockNumber(ctx); !errors.Is(err, testRpcError{}) {
+		t.Error("expected node's error, got", err)
+	}
+	if first.callCount() != 1 {
+		t.Error("failed over after an error every node would give")
+	}
+
+	// the health check brings the first node back once it answers
+	first.err = nil
+	first.blockNumber = 2
+	client.checkHealth(ctx)
+	second.err = nil
+	if _, err := client.BlockNumber(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if first.callCount() != 3 {
+		t.Error("recovered node wasn't called first")
+	}
+}
+
+func TestHealthCheckLag(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig
+	config.MaxBlockLag = 5
+	lagging := &testBackend{blockNumber: 100}
+	synced := &testBackend{blockNumber: 110}
+	client := newTestClient(t, config, lagging, synced)
+
+	client.checkHealth(ctx)
+	candidates := client.candidates()
+	if candidates[0].endpoint.url != "b" {
+		t.Error("lagging node is still preferred")
+	}
+	lagging.blockNumber = 108
+	client.checkHealth(ctx)
+	candidates = client.candidates()
+	if candidates[0].endpoint.url != "a" {
+		t.Error("caught up node isn't preferred again")
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	ctx := context.Background()
+	config := DefaultConfig
+	config.Quorum = 2
+	agreed := []types.Log{{BlockNumber: 10, Data: []byte{1}}}
+	different := []types.Log{{BlockNumber: 10, Data: []byte{2}}}
+	backends := []*testBackend{{logs: different}, {logs: agreed}, {logs: agreed}}
+	client := newTestClient(t, config, backends...)
+
+	historical := ethereum.FilterQuery{FromBlock: big.NewInt(0), ToBlock: big.NewInt(10)}
+	logs, err := client.FilterLogs(ctx, historical)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Data[0] != 1 {
+		t.Error("got logs the quorum didn't agree on", logs)
+	}
+
+	// queries up to the latest block aren't compared
+	logs, err = client.FilterLogs(ctx, ethereum.FilterQuery{FromBlock: big.NewInt(0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 || logs[0].Data[0] != 2 {
+		t.Error("latest logs didn't come from the first node", logs)
+	}
+
+	client = newTestClient(t, config,
+		&testBackend{logs: different},
+		&testBackend{logs: agreed},
+		&testBackend{err: errors.New("connection refused")},
+	)
+	if _, err := client.FilterLogs(ctx, historical); !errors.Is(err, ErrNoQuorum) {
+		t.Error("expected no quorum, got", err)
+	}
+}
+
+func TestQuorumNearHead(t *testing.T) {
+	ctx := context.Background()
+	defer func(interval time.Duration) { quorumRetryInterval = interval }(quorumRetryInterval)
+	quorumRetryInterval = time.Millisecond
+	config := DefaultConfig
+	config.Quorum = 2
+	config.MaxBlockLag = 5
+	config.CallTimeout = 50 * time.Millisecond
+	agreed := []types.Log{{BlockNumber: 100, Data: []byte{1}}}
+	lagging := &testBackend{blockNumber: 100}
+	synced := &testBackend{blockNumber: 110, logs: agreed}
+	client := newTestClient(t, config, lagging, synced)
+	client.checkHealth(ctx)
+	lagging.err = testRpcCodeError{-32000, "header not found"}
+
+	// recent blocks still need a quorum, rather than the answer of the one node having them
+	recent := ethereum.FilterQuery{FromBlock: big.NewInt(0), ToBlock: big.NewInt(108)}
+	if _, err := client.FilterLogs(ctx, recent); !errors.Is(err, ErrNoQuorum) {
+		t.Error("expected no quorum, got", err)
+	}
+
+	// older blocks need a quorum of the nodes having them without waiting
+	_, err := client.FilterLogs(ctx, ethereum.FilterQuery{FromBlock: big.NewInt(0), ToBlock: big.NewInt(104)})
+	if !errors.Is(err, ErrNoQuorum) {
+		t.Error("expected no quorum, got", err)
+	}
+
+	// a recent read waits for the lagging node to catch up
+	lagging.mutex.Lock()
+	lagging.err = nil
+	lagging.blockNumber = 108
+	lagging.logs = agreed
+	lagging.mutex.Unlock()
+	logs, err := client.FilterLogs(ctx, recent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logs) != 1 {
+		t.Error("got wrong logs", logs)
+	}
+	if _, err := client.FilterLogs(ctx, ethereum.FilterQuery{FromBlock: big.NewInt(0), ToBlock: big.NewInt(104)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsEndpointFailure(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		err     error
+		failure bool
+	}{
+		{errors.New("connection refused"), true},
+		{ethereum.NotFound, false},
+		{testRpcError{}, false},
+		{testRpcCodeError{-32000, "header not found"}, true},
+		{testRpcCodeError{limitExceededCode, "rate limit exceeded"}, true},
+	}
+	for _, c := range cases {
+		if isEndpointFailure(ctx, c.err) != c.failure {
+			t.Error("error", c.err, "should be an endpoint failure:", c.failure)
+		}
+	}
+}
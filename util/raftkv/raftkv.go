@@ -0,0 +1,290 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+// Package raftkv implements a small key-value store replicated with the Raft consensus algorithm.
+// It's intended for coordinating a handful of nodes, not for storing large amounts of data.
+package raftkv
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/ethdb"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/util/stopwaiter"
+)
+
+type Config struct {
+	MyAddr            string        `koanf:"my-addr"`
+	ListenAddr        string        `koanf:"listen-addr"`
+	Peers             []string      `koanf:"peers"`
+	JWTSecret         string        `koanf:"jwt-secret"`
+	ElectionTimeout   time.Duration `koanf:"election-timeout"`
+	RequestTimeout    time.Duration `koanf:"request-timeout"`
+	SnapshotInterval  uint64        `koanf:"snapshot-interval"`
+	MaxEntriesPerSend int           `koanf:"max-entries-per-send"`
+}
+
+func ConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.String(prefix+".my-addr", DefaultConfig.MyAddr, "host:port other raft peers reach this node at, which must be one of the peers")
+	f.String(prefix+".listen-addr", DefaultConfig.ListenAddr, "address to listen on for raft requests (defaults to my-addr)")
+	f.StringSlice(prefix+".peers", DefaultConfig.Peers, "host:port of every raft peer, including this node")
+	f.String(prefix+".jwt-secret", DefaultConfig.JWTSecret, "a 32-byte (64-character) hex string shared by all raft peers to authenticate each other, or a path to a file containing it")
+	f.Duration(prefix+".election-timeout", DefaultConfig.ElectionTimeout, "time without hearing from a leader before starting an election")
+	f.Duration(prefix+".request-timeout", DefaultConfig.RequestTimeout, "timeout of requests to other peers")
+	f.Uint64(prefix+".snapshot-interval", DefaultConfig.SnapshotInterval, "number of log entries after which the log is compacted into a snapshot")
+	f.Int(prefix+".max-entries-per-send", DefaultConfig.MaxEntriesPerSend, "maximum number of log entries sent to a peer in one request")
+}
+
+var DefaultConfig = Config{
+	MyAddr:            "",
+	ListenAddr:        "",
+	Peers:             []string{},
+	JWTSecret:         "",
+	ElectionTimeout:   time.Second,
+	RequestTimeout:    5 * time.Second,
+	SnapshotInterval:  10000,
+	MaxEntriesPerSend: 256,
+}
+
+var TestConfig = Config{
+	MyAddr:            "",
+	ListenAddr:        "",
+	Peers:             []string{},
+	JWTSecret:         "3a7c9d1e5b2f8a4c6e0d9b7a5c3e1f8d2b4a6c8e0f1d3b5a7c9e2f4d6b8a0c1e",
+	ElectionTimeout:   100 * time.Millisecond,
+	RequestTimeout:    time.Second,
+	SnapshotInterval:  100,
+	MaxEntriesPerSend: 16,
+}
+
+func (c *Config) Validate() error {
+	found := false
+	for _, peer := range c.Peers {
+		if peer == c.MyAddr {
+			found = true
+		}
+	}
+	if !found {
+		return errors.Errorf("raft my-addr %v is not one of the peers %v", c.MyAddr, c.Peers)
+	}
+	if c.JWTSecret == "" {
+		return errors.New("raft jwt-secret must be set to authenticate the peers")
+	}
+	if c.ElectionTimeout < 10*time.Millisecond {
+		return errors.New("raft election timeout must be at least 10ms")
+	}
+	if c.SnapshotInterval == 0 || c.MaxEntriesPerSend <= 0 || c.MaxEntriesPerSend > 1024 {
+		return errors.New("raft snapshot interval must be positive and max entries per send between 1 and 1024")
+	}
+	return nil
+}
+
+var ErrNoLeader = errors.New("raft leader unknown")
+var ErrLeadershipLost = errors.New("raft leadership lost before command was committed")
+
+var secretIsHexRegex = regexp.MustCompile("^(0x)?[a-fA-F0-9]{64}$")
+
+func loadJWTSecret(secretConfig string) ([]byte, error) {
+	secret := strings.TrimSpace(secretConfig)
+	if !secretIsHexRegex.MatchString(secret) {
+		contents, err := ioutil.ReadFile(secretConfig)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read raft jwt secret file")
+		}
+		secret = strings.TrimSpace(string(contents))
+		if !secretIsHexRegex.MatchString(secret) {
+			return nil, errors.New("raft jwt secret file contents are not 32 bytes of hex")
+		}
+	}
+	return common.FromHex(secret), nil
+}
+
+// logWriter passes raft's log lines on to the node's logger
+type logWriter struct{}
+
+func (logWriter) Write(p []byte) (int, error) {
+	log.Warn("raft", "msg", strings.TrimSpace(string(p)))
+	return len(p), nil
+}
+
+// Node is a member of a raft cluster replicating a key-value store.
+// All commands, including reads, go through the raft log, so results are linearizable.
+type Node struct {
+	stopwaiter.StopWaiter
+	config    *Config
+	secret    []byte
+	fsm       *fsm
+	logs      *logStore
+	snapshots *snapshotStore
+	transport *raft.NetworkTransport
+	raft      *raft.Raft
+}
+
+func NewNode(config *Config, db ethdb.Database) (*Node, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	secret, err := loadJWTSecret(config.JWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := newLogStore(db)
+	if err != nil {
+		return nil, err
+	}
+	return &Node{
+		config:    config,
+		secret:    secret,
+		fsm:       &fsm{state: newKvState()},
+		logs:      logs,
+		snapshots: &snapshotStore{db: db},
+	}, nil
+}
+
+func (n *Node) raftConfig(logger hclog.Logger) *raft.Config {
+	conf := raft.DefaultConfig()
+	conf.LocalID = raft.ServerID(n.config.MyAddr)
+	conf.HeartbeatTimeout = n.config.ElectionTimeout
+	conf.ElectionTimeout = n.config.ElectionTimeout
+	conf.LeaderLeaseTimeout = n.config.ElectionTimeout / 2
+	conf.CommitTimeout = n.config.ElectionTimeout / 20
+	conf.SnapshotThreshold = n.config.SnapshotInterval
+	conf.SnapshotInterval = 10 * n.config.ElectionTimeout
+	conf.TrailingLogs = n.config.SnapshotInterval
+	conf.MaxAppendEntries = n.config.MaxEntriesPerSend
+	conf.Logger = logger
+	return conf
+}
+
+func (n *Node) Start(ctxIn context.Context) error {
+	listenAddr := n.config.ListenAddr
+	if listenAddr == "" {
+		listenAddr = n.config.MyAddr
+	}
+	listener, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		return err
+	}
+	n.StopWaiter.Start(ctxIn)
+	logger := hclog.New(&hclog.LoggerOptions{
+		Name:   "raft",
+		Level:  hclog.Warn,
+		Output: logWriter{},
+	})
+	stream := newStreamLayer(n.GetContext(), listener, n.config, n.secret, n.apply)
+	n.transport = raft.NewNetworkTransportWithConfig(&raft.NetworkTransportConfig{
+		Stream:  stream,
+		MaxPool: 3,
+		Timeout: n.config.RequestTimeout,
+		Logger:  logger,
+	})
+	conf := n.raftConfig(logger)
+	hasState, err := raft.HasExistingState(n.logs, n.logs, n.snapshots)
+	if err != nil {
+		n.transport.Close()
+		return err
+	}
+	if !hasState {
+		var configuration raft.Configuration
+		for _, peer := range n.config.Peers {
+			configuration.Servers = append(configuration.Servers, raft.Server{
+				Suffrage: raft.Voter,
+				ID:       raft.ServerID(peer),
+				Address:  raft.ServerAddress(peer),
+			})
+		}
+		if err := raft.BootstrapCluster(conf, n.logs, n.logs, n.snapshots, n.transport, configuration); err != nil {
+			n.transport.Close()
+			return err
+		}
+	}
+	n.raft, err = raft.NewRaft(conf, n.fsm, n.logs, n.logs, n.snapshots, n.transport)
+	if err != nil {
+		n.transport.Close()
+		return err
+	}
+	return nil
+}
+
+func (n *Node) StopAndWait() {
+	n.StopWaiter.StopAndWait()
+	if n.raft != nil {
+		if err := n.raft.Shutdown().Error(); err != nil {
+			log.Warn("failed to shut down raft", "err", err)
+		}
+		if err := n.transport.Close(); err != nil {
+			log.Warn("failed to close raft transport", "err", err)
+		}
+	}
+}
+
+// IsLeader returns whether this node currently believes it's the leader
+func (n *Node) IsLeader() bool {
+	return n.raft.State() == raft.Leader
+}
+
+// Leader returns the address of the current leader, or "" if it's unknown
+func (n *Node) Leader() string {
+	addr, _ := n.raft.LeaderWithID()
+	return string(addr)
+}
+
+// Execute commits a command to the raft log and returns its result once applied.
+// If this node isn't the leader, the command is forwarded to the leader.
+func (n *Node) Execute(ctx context.Context, cmd *Command) (*Result, error) {
+	if n.raft.State() != raft.Leader {
+		leaderAddr := n.Leader()
+		if leaderAddr == "" {
+			return nil, ErrNoLeader
+		}
+		return executeOn(ctx, leaderAddr, n.secret, n.config.RequestTimeout, cmd)
+	}
+	return n.apply(ctx, cmd)
+}
+
+// apply commits a command if this node is the leader.
+// Commands forwarded by other peers aren't forwarded again, to avoid loops while the leader changes.
+func (n *Node) apply(ctx context.Context, cmd *Command) (*Result, error) {
+	data, err := json.Marshal(&logCommand{Time: time.Now().UnixMilli(), Command: cmd})
+	if err != nil {
+		return nil, err
+	}
+	timeout := n.config.RequestTimeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+		if timeout <= 0 {
+			return nil, context.DeadlineExceeded
+		}
+	}
+	future := n.raft.Apply(data, timeout)
+	if err := future.Error(); err != nil {
+		if errors.Is(err, raft.ErrNotLeader) || errors.Is(err, raft.ErrEnqueueTimeout) {
+			// the command certainly wasn't committed
+			return nil, ErrNoLeader
+		}
+		if errors.Is(err, raft.ErrLeadershipLost) {
+			return nil, ErrLeadershipLost
+		}
+		return nil, err
+	}
+	switch response := future.Response().(type) {
+	case *Result:
+		return response, nil
+	case error:
+		return nil, response
+	default:
+		return nil, errors.Errorf("unexpected raft response %v", response)
+	}
+}
@@ -0,0 +1,282 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package raftkv
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tenderly/nitro/go-ethereum/core/rawdb"
+	"github.com/tenderly/nitro/go-ethereum/ethdb"
+	"github.com/tenderly/nitro/util/testhelpers"
+)
+
+func Require(t *testing.T, err error, printables ...interface{}) {
+	t.Helper()
+	testhelpers.RequireImpl(t, err, printables...)
+}
+
+func Fail(t *testing.T, printables ...interface{}) {
+	t.Helper()
+	testhelpers.FailImpl(t, printables...)
+}
+
+func freeAddrs(t *testing.T, count int) []string {
+	var addrs []string
+	var listeners []net.Listener
+	for i := 0; i < count; i++ {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Require(t, err)
+		listeners = append(listeners, listener)
+		addrs = append(addrs, listener.Addr().String())
+	}
+	for _, listener := range listeners {
+		listener.Close()
+	}
+	return addrs
+}
+
+type testCluster struct {
+	t       *testing.T
+	ctx     context.Context
+	peers   []string
+	secrets []string
+	dbs     []ethdb.Database
+	nodes   []*Node
+}
+
+func newTestCluster(t *testing.T, ctx context.Context, size int) *testCluster {
+	c := &testCluster{t: t, ctx: ctx, peers: freeAddrs(t, size)}
+	for i := 0; i < size; i++ {
+		c.secrets = append(c.secrets, TestConfig.JWTSecret)
+		c.dbs = append(c.dbs, rawdb.NewMemoryDatabase())
+		c.nodes = append(c.nodes, nil)
+		c.start(i)
+	}
+	return c
+}
+
+func (c *testCluster) start(i int) {
+	config := TestConfig
+	config.MyAddr = c.peers[i]
+	config.Peers = c.peers
+	config.JWTSecret = c.secrets[i]
+	node, err := NewNode(&config, c.dbs[i])
+	Require(c.t, err)
+	Require(c.t, node.Start(c.ctx))
+	c.nodes[i] = node
+}
+
+func (c *testCluster) stop(i int) {
+	c.nodes[i].StopAndWait()
+	c.nodes[i] = nil
+}
+
+func (c *testCluster) stopAll() {
+	for i, node := range c.nodes {
+		if node != nil {
+			c.stop(i)
+		}
+	}
+}
+
+// value returns the value of a key in a node's replica, or nil if it's missing
+func (c *testCluster) value(i int, key string) []byte {
+	fsm := c.nodes[i].fsm
+	fsm.mutex.Lock()
+	defer fsm.mutex.Unlock()
+	return fsm.state.get(key, time.Now().UnixMilli()).Value
+}
+
+func (c *testCluster) waitForLeader() int {
+	for attempt := 0; attempt < 500; attempt++ {
+		for i, node := range c.nodes {
+			if node != nil && node.IsLeader() {
+				return i
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	Fail(c.t, "no raft leader elected")
+	return -1
+}
+
+func (c *testCluster) execute(i int, cmd *Command) *Result {
+	var err error
+	for attempt := 0; attempt < 100; attempt++ {
+		var result *Result
+		result, err = c.nodes[i].Execute(c.ctx, cmd)
+		if err == nil {
+			return result
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	Fail(c.t, "executing raft command failed", err)
+	return nil
+}
+
+func setCommand(key string, value string) *Command {
+	return &Command{Writes: []Write{{Key: key, Value: []byte(value)}}}
+}
+
+func readCommand(keys ...string) *Command {
+	return &Command{Reads: keys}
+}
+
+func TestRaftReplication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster := newTestCluster(t, ctx, 3)
+	defer cluster.stopAll()
+
+	leader := cluster.waitForLeader()
+	follower := (leader + 1) % 3
+	// commands sent to a follower are forwarded to the leader
+	cluster.execute(follower, setCommand("a", "1"))
+	result := cluster.execute(leader, readCommand("a", "b"))
+	if !result.Values[0].Exists || string(result.Values[0].Value) != "1" || result.Values[1].Exists {
+		Fail(t, "unexpected values", result.Values)
+	}
+
+	// conditional writes only apply if the version still matches
+	version := result.Values[0].Version
+	cluster.execute(leader, setCommand("a", "2"))
+	result = cluster.execute(follower, &Command{
+		Conditions: []Condition{{Key: "a", Exists: true, Version: version}},
+		Writes:     []Write{{Key: "a", Value: []byte("3")}},
+	})
+	if result.Applied {
+		Fail(t, "write applied despite a stale version")
+	}
+	result = cluster.execute(follower, readCommand("a"))
+	if string(result.Values[0].Value) != "2" {
+		Fail(t, "unexpected value", string(result.Values[0].Value))
+	}
+
+	// expired keys are treated as missing
+	cluster.execute(leader, &Command{Writes: []Write{{Key: "e", Value: []byte("x"), ExpireAt: time.Now().Add(50 * time.Millisecond).UnixMilli()}}})
+	time.Sleep(100 * time.Millisecond)
+	result = cluster.execute(leader, &Command{
+		Reads:      []string{"e"},
+		Conditions: []Condition{{Key: "e", Exists: false}},
+	})
+	if result.Values[0].Exists || !result.Applied {
+		Fail(t, "expired key still exists")
+	}
+}
+
+func TestRaftFailover(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster := newTestCluster(t, ctx, 3)
+	defer cluster.stopAll()
+
+	leader := cluster.waitForLeader()
+	cluster.execute(leader, setCommand("a", "1"))
+	cluster.stop(leader)
+
+	newLeader := cluster.waitForLeader()
+	if newLeader == leader {
+		Fail(t, "stopped node still leader")
+	}
+	result := cluster.execute(newLeader, readCommand("a"))
+	if string(result.Values[0].Value) != "1" {
+		Fail(t, "committed value lost after failover", result.Values)
+	}
+	cluster.execute(newLeader, setCommand("b", "2"))
+
+	// the old leader catches up when it restarts
+	cluster.start(leader)
+	cluster.stop(newLeader)
+	cluster.waitForLeader()
+	other := 3 - leader - newLeader
+	result = cluster.execute(other, readCommand("a", "b"))
+	if string(result.Values[0].Value) != "1" || string(result.Values[1].Value) != "2" {
+		Fail(t, "unexpected values after restart", result.Values)
+	}
+}
+
+func TestRaftSnapshot(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster := newTestCluster(t, ctx, 3)
+	defer cluster.stopAll()
+
+	leader := cluster.waitForLeader()
+	lagging := (leader + 1) % 3
+	cluster.stop(lagging)
+	entries := int(TestConfig.SnapshotInterval) * 3
+	for i := 0; i < entries; i++ {
+		cluster.execute(leader, setCommand(fmt.Sprint("key", i%10), fmt.Sprint(i)))
+	}
+
+	// the lagging node must receive a snapshot, as the log it's missing was compacted
+	Require(t, cluster.nodes[leader].raft.Snapshot().Error())
+	cluster.start(lagging)
+	for attempt := 0; ; attempt++ {
+		value := cluster.value(lagging, "key9")
+		if bytes.Equal(value, []byte(fmt.Sprint(entries-1))) {
+			snapshots, err := cluster.nodes[lagging].snapshots.List()
+			Require(t, err)
+			if len(snapshots) == 0 {
+				Fail(t, "lagging node caught up without a snapshot")
+			}
+			break
+		}
+		if attempt > 500 {
+			Fail(t, "lagging node didn't catch up", string(value))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// the snapshot is restored on restart
+	cluster.stop(lagging)
+	cluster.start(lagging)
+	if value := cluster.value(lagging, "key9"); !bytes.Equal(value, []byte(fmt.Sprint(entries-1))) {
+		Fail(t, "snapshot not restored", string(value))
+	}
+}
+
+func TestRaftAuthentication(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cluster := newTestCluster(t, ctx, 3)
+	defer cluster.stopAll()
+	leader := cluster.waitForLeader()
+	cluster.execute(leader, setCommand("a", "1"))
+
+	client, err := NewClient(cluster.peers, TestConfig.JWTSecret, TestConfig.RequestTimeout)
+	Require(t, err)
+	result, err := client.Execute(ctx, readCommand("a"))
+	Require(t, err)
+	if string(result.Values[0].Value) != "1" {
+		Fail(t, "unexpected value", string(result.Values[0].Value))
+	}
+
+	wrongSecret := strings.Repeat("ab", 32)
+	client, err = NewClient(cluster.peers, wrongSecret, TestConfig.RequestTimeout)
+	Require(t, err)
+	if _, err := client.Execute(ctx, setCommand("a", "2")); err == nil {
+		Fail(t, "command executed without the shared secret")
+	}
+
+	// a node with the wrong secret can't take part in the cluster
+	lagging := (leader + 1) % 3
+	cluster.stop(lagging)
+	cluster.secrets[lagging] = wrongSecret
+	cluster.start(lagging)
+	cluster.execute(leader, setCommand("b", "1"))
+	time.Sleep(10 * TestConfig.ElectionTimeout)
+	if value := cluster.value(lagging, "b"); value != nil {
+		Fail(t, "node with the wrong secret received a write")
+	}
+	if value := cluster.value(leader, "a"); string(value) != "1" {
+		Fail(t, "write with the wrong secret applied", string(value))
+	}
+}
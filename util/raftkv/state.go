@@ -0,0 +1,177 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package raftkv
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/hashicorp/raft"
+)
+
+// Command is a transaction against the replicated key-value store.
+// Reads are evaluated first. If all Conditions hold, the Writes are then applied in order.
+type Command struct {
+	Reads      []string    `json:"reads,omitempty"`
+	Conditions []Condition `json:"conditions,omitempty"`
+	Writes     []Write     `json:"writes,omitempty"`
+}
+
+// Condition requires a key to be missing, or to exist at a specific version.
+// Expired keys are considered missing.
+type Condition struct {
+	Key     string `json:"key"`
+	Exists  bool   `json:"exists"`
+	Version uint64 `json:"version,omitempty"`
+}
+
+type Write struct {
+	Key    string `json:"key"`
+	Value  []byte `json:"value,omitempty"`
+	Delete bool   `json:"delete,omitempty"`
+	// Unix milliseconds after which the key expires, or 0 if it never expires
+	ExpireAt int64 `json:"expireAt,omitempty"`
+}
+
+type Value struct {
+	Exists  bool   `json:"exists"`
+	Value   []byte `json:"value,omitempty"`
+	Version uint64 `json:"version,omitempty"`
+}
+
+type Result struct {
+	// false if a condition didn't hold, in which case no writes were applied
+	Applied bool    `json:"applied"`
+	Values  []Value `json:"values,omitempty"`
+}
+
+type kvEntry struct {
+	Value []byte `json:"value"`
+	// the log index which last modified the entry
+	Version  uint64 `json:"version"`
+	ExpireAt int64  `json:"expireAt,omitempty"`
+}
+
+func (e *kvEntry) expired(time int64) bool {
+	return e.ExpireAt != 0 && e.ExpireAt <= time
+}
+
+type kvState struct {
+	Entries map[string]*kvEntry `json:"entries"`
+	// Unix milliseconds of the latest applied command
+	Time int64 `json:"time"`
+}
+
+func newKvState() *kvState {
+	return &kvState{Entries: make(map[string]*kvEntry)}
+}
+
+func (s *kvState) get(key string, time int64) Value {
+	entry, ok := s.Entries[key]
+	if !ok || entry.expired(time) {
+		return Value{}
+	}
+	return Value{Exists: true, Value: entry.Value, Version: entry.Version}
+}
+
+// apply executes a command committed at the given log index, with the time the leader assigned to it.
+// It must be deterministic, as every node applies every command.
+func (s *kvState) apply(cmd *Command, index uint64, time int64) *Result {
+	// a new leader's clock may be behind the previous one's, but time must not go backwards
+	if time < s.Time {
+		time = s.Time
+	}
+	s.Time = time
+	result := &Result{}
+	for _, key := range cmd.Reads {
+		result.Values = append(result.Values, s.get(key, time))
+	}
+	for _, condition := range cmd.Conditions {
+		current := s.get(condition.Key, time)
+		if current.Exists != condition.Exists || current.Version != condition.Version {
+			return result
+		}
+	}
+	for _, write := range cmd.Writes {
+		if write.Delete {
+			delete(s.Entries, write.Key)
+			continue
+		}
+		s.Entries[write.Key] = &kvEntry{
+			Value:    write.Value,
+			Version:  index,
+			ExpireAt: write.ExpireAt,
+		}
+	}
+	result.Applied = true
+	return result
+}
+
+// purgeExpired removes expired keys. This doesn't change the result of any command, as expired keys are treated as missing.
+func (s *kvState) purgeExpired(time int64) {
+	for key, entry := range s.Entries {
+		if entry.expired(time) {
+			delete(s.Entries, key)
+		}
+	}
+}
+
+// logCommand is the data of a raft log entry
+type logCommand struct {
+	// Unix milliseconds assigned by the leader, used to expire keys
+	Time    int64    `json:"time"`
+	Command *Command `json:"command"`
+}
+
+// fsm applies committed raft log entries to the key-value state
+type fsm struct {
+	mutex sync.Mutex
+	state *kvState
+}
+
+func (f *fsm) Apply(entry *raft.Log) interface{} {
+	var cmd logCommand
+	if err := json.Unmarshal(entry.Data, &cmd); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.state.apply(cmd.Command, entry.Index, cmd.Time)
+}
+
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.state.purgeExpired(f.state.Time)
+	data, err := json.Marshal(f.state)
+	if err != nil {
+		return nil, err
+	}
+	return fsmSnapshot(data), nil
+}
+
+func (f *fsm) Restore(reader io.ReadCloser) error {
+	defer reader.Close()
+	state := newKvState()
+	if err := json.NewDecoder(reader).Decode(state); err != nil {
+		return err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.state = state
+	return nil
+}
+
+type fsmSnapshot []byte
+
+func (s fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	if _, err := sink.Write(s); err != nil {
+		_ = sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s fsmSnapshot) Release() {}
@@ -0,0 +1,282 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package raftkv
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/hashicorp/raft"
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/go-ethereum/ethdb"
+)
+
+var (
+	stablePrefix []byte = []byte("s")         // raft's current term and vote, by key
+	snapshotKey  []byte = []byte("_snapshot") // contains the latest snapshot
+	logPrefix    []byte = []byte("l")         // maps a log index to a log entry
+)
+
+// raft's stores report missing keys with this exact message
+var errKeyNotFound = errors.New("not found")
+
+func logKey(index uint64) []byte {
+	key := make([]byte, len(logPrefix)+8)
+	copy(key, logPrefix)
+	binary.BigEndian.PutUint64(key[len(logPrefix):], index)
+	return key
+}
+
+func readJSON(db ethdb.Database, key []byte, value interface{}) (bool, error) {
+	has, err := db.Has(key)
+	if err != nil || !has {
+		return false, err
+	}
+	data, err := db.Get(key)
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, value)
+}
+
+func writeJSON(db ethdb.KeyValueWriter, key []byte, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return db.Put(key, data)
+}
+
+// logStore keeps raft's log and stable state in the database.
+// It implements both raft.LogStore and raft.StableStore.
+type logStore struct {
+	db ethdb.Database
+
+	mutex      sync.Mutex
+	firstIndex uint64 // 0 if the log is empty
+	lastIndex  uint64
+}
+
+func newLogStore(db ethdb.Database) (*logStore, error) {
+	store := &logStore{db: db}
+	iter := db.NewIterator(logPrefix, nil)
+	defer iter.Release()
+	for iter.Next() {
+		index := binary.BigEndian.Uint64(iter.Key()[len(logPrefix):])
+		if store.firstIndex == 0 {
+			store.firstIndex = index
+		} else if index != store.lastIndex+1 {
+			return nil, errors.Errorf("raft log has a gap before index %v", index)
+		}
+		store.lastIndex = index
+	}
+	return store, iter.Error()
+}
+
+func (s *logStore) FirstIndex() (uint64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.firstIndex, nil
+}
+
+func (s *logStore) LastIndex() (uint64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lastIndex, nil
+}
+
+func (s *logStore) GetLog(index uint64, entry *raft.Log) error {
+	found, err := readJSON(s.db, logKey(index), entry)
+	if err != nil {
+		return errors.Wrap(err, "reading raft log")
+	}
+	if !found {
+		return raft.ErrLogNotFound
+	}
+	return nil
+}
+
+func (s *logStore) StoreLog(entry *raft.Log) error {
+	return s.StoreLogs([]*raft.Log{entry})
+}
+
+func (s *logStore) StoreLogs(entries []*raft.Log) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	batch := s.db.NewBatch()
+	for _, entry := range entries {
+		if err := writeJSON(batch, logKey(entry.Index), entry); err != nil {
+			return err
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	if s.firstIndex == 0 || entries[0].Index < s.firstIndex {
+		s.firstIndex = entries[0].Index
+	}
+	if last := entries[len(entries)-1].Index; last > s.lastIndex {
+		s.lastIndex = last
+	}
+	return nil
+}
+
+// DeleteRange deletes the log entries in [from, to]
+func (s *logStore) DeleteRange(from uint64, to uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if from < s.firstIndex {
+		from = s.firstIndex
+	}
+	if to > s.lastIndex {
+		to = s.lastIndex
+	}
+	if from > to {
+		return nil
+	}
+	batch := s.db.NewBatch()
+	for index := from; index <= to; index++ {
+		if err := batch.Delete(logKey(index)); err != nil {
+			return err
+		}
+		if batch.ValueSize() >= ethdb.IdealBatchSize {
+			if err := batch.Write(); err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return err
+	}
+	// raft only deletes a prefix of the log when compacting, or a suffix when it conflicts with the leader's
+	if from <= s.firstIndex {
+		s.firstIndex = to + 1
+	}
+	if to >= s.lastIndex {
+		s.lastIndex = from - 1
+	}
+	if s.firstIndex > s.lastIndex {
+		s.firstIndex = 0
+		s.lastIndex = 0
+	}
+	return nil
+}
+
+func stableKey(key []byte) []byte {
+	return append(append([]byte{}, stablePrefix...), key...)
+}
+
+func (s *logStore) Set(key []byte, value []byte) error {
+	return s.db.Put(stableKey(key), value)
+}
+
+func (s *logStore) Get(key []byte) ([]byte, error) {
+	has, err := s.db.Has(stableKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, errKeyNotFound
+	}
+	return s.db.Get(stableKey(key))
+}
+
+func (s *logStore) SetUint64(key []byte, value uint64) error {
+	var data [8]byte
+	binary.BigEndian.PutUint64(data[:], value)
+	return s.Set(key, data[:])
+}
+
+func (s *logStore) GetUint64(key []byte) (uint64, error) {
+	data, err := s.Get(key)
+	if errors.Is(err, errKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(data) != 8 {
+		return 0, errors.Errorf("raft stable value %v has length %v", string(key), len(data))
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+type storedSnapshot struct {
+	Meta raft.SnapshotMeta `json:"meta"`
+	Data []byte            `json:"data"`
+}
+
+// snapshotStore keeps only the latest snapshot, in the database.
+// The replicated state is small, so snapshots are held in memory while being written.
+type snapshotStore struct {
+	db ethdb.Database
+}
+
+func (s *snapshotStore) Create(version raft.SnapshotVersion, index, term uint64, configuration raft.Configuration, configurationIndex uint64, trans raft.Transport) (raft.SnapshotSink, error) {
+	return &snapshotSink{
+		store: s,
+		meta: raft.SnapshotMeta{
+			Version:            version,
+			ID:                 fmt.Sprintf("%v-%v", term, index),
+			Index:              index,
+			Term:               term,
+			Configuration:      configuration,
+			ConfigurationIndex: configurationIndex,
+		},
+	}, nil
+}
+
+func (s *snapshotStore) List() ([]*raft.SnapshotMeta, error) {
+	var snapshot storedSnapshot
+	found, err := readJSON(s.db, snapshotKey, &snapshot)
+	if err != nil || !found {
+		return nil, err
+	}
+	return []*raft.SnapshotMeta{&snapshot.Meta}, nil
+}
+
+func (s *snapshotStore) Open(id string) (*raft.SnapshotMeta, io.ReadCloser, error) {
+	var snapshot storedSnapshot
+	found, err := readJSON(s.db, snapshotKey, &snapshot)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !found || snapshot.Meta.ID != id {
+		return nil, nil, errors.Errorf("raft snapshot %v not found", id)
+	}
+	return &snapshot.Meta, io.NopCloser(bytes.NewReader(snapshot.Data)), nil
+}
+
+type snapshotSink struct {
+	store *snapshotStore
+	meta  raft.SnapshotMeta
+	data  bytes.Buffer
+}
+
+func (s *snapshotSink) Write(p []byte) (int, error) {
+	return s.data.Write(p)
+}
+
+func (s *snapshotSink) Close() error {
+	s.meta.Size = int64(s.data.Len())
+	return writeJSON(s.store.db, snapshotKey, storedSnapshot{Meta: s.meta, Data: s.data.Bytes()})
+}
+
+func (s *snapshotSink) ID() string {
+	return s.meta.ID
+}
+
+func (s *snapshotSink) Cancel() error {
+	s.data.Reset()
+	return nil
+}
@@ -0,0 +1,300 @@
+// Copyright 2021-2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package raftkv
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/hashicorp/raft"
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/go-ethereum/log"
+)
+
+// Every connection starts with a JWT signed with the shared secret, followed by the kind of stream
+const (
+	raftStream    byte = 'r'
+	executeStream byte = 'e'
+)
+
+const maxTokenSize = 1024
+
+// tokens are only accepted this long before or after they were issued, like the engine API's
+const maxTokenDrift = 5 * time.Second
+
+const maxRequestSize = 256 * 1024 * 1024
+
+var errTransportClosed = errors.New("raft transport closed")
+
+func writeHandshake(conn net.Conn, secret []byte, kind byte) error {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		IssuedAt: jwt.NewNumericDate(time.Now()),
+	}).SignedString(secret)
+	if err != nil {
+		return err
+	}
+	message := make([]byte, 2, 3+len(token))
+	binary.BigEndian.PutUint16(message, uint16(len(token)))
+	message = append(message, token...)
+	message = append(message, kind)
+	_, err = conn.Write(message)
+	return err
+}
+
+// readHandshake authenticates the peer, and returns the kind of stream it opened
+func readHandshake(conn net.Conn, secret []byte) (byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(conn, size[:]); err != nil {
+		return 0, err
+	}
+	if binary.BigEndian.Uint16(size[:]) > maxTokenSize {
+		return 0, errors.New("token too large")
+	}
+	message := make([]byte, binary.BigEndian.Uint16(size[:])+1)
+	if _, err := io.ReadFull(conn, message); err != nil {
+		return 0, err
+	}
+	var claims jwt.RegisteredClaims
+	token, err := jwt.ParseWithClaims(string(message[:len(message)-1]), &claims,
+		func(*jwt.Token) (interface{}, error) { return secret, nil },
+		jwt.WithValidMethods([]string{"HS256"}),
+		jwt.WithoutClaimsValidation())
+	switch {
+	case err != nil:
+		return 0, err
+	case !token.Valid:
+		return 0, errors.New("invalid token")
+	case claims.IssuedAt == nil:
+		return 0, errors.New("missing issued-at")
+	case time.Since(claims.IssuedAt.Time) > maxTokenDrift:
+		return 0, errors.New("stale token")
+	case time.Until(claims.IssuedAt.Time) > maxTokenDrift:
+		return 0, errors.New("future token")
+	}
+	return message[len(message)-1], nil
+}
+
+func dial(ctx context.Context, addr string, secret []byte, kind byte, timeout time.Duration) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeHandshake(conn, secret, kind); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+type advertisedAddr string
+
+func (a advertisedAddr) Network() string {
+	return "tcp"
+}
+
+func (a advertisedAddr) String() string {
+	return string(a)
+}
+
+// streamLayer authenticates connections between peers, and serves commands forwarded to this node on the same listener
+type streamLayer struct {
+	ctx      context.Context
+	listener net.Listener
+	addr     advertisedAddr
+	secret   []byte
+	timeout  time.Duration
+	execute  func(context.Context, *Command) (*Result, error)
+
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newStreamLayer(ctx context.Context, listener net.Listener, config *Config, secret []byte, execute func(context.Context, *Command) (*Result, error)) *streamLayer {
+	s := &streamLayer{
+		ctx:      ctx,
+		listener: listener,
+		addr:     advertisedAddr(config.MyAddr),
+		secret:   secret,
+		timeout:  config.RequestTimeout,
+		execute:  execute,
+		conns:    make(chan net.Conn),
+		closed:   make(chan struct{}),
+	}
+	go s.acceptLoop()
+	return s
+}
+
+func (s *streamLayer) acceptLoop() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.closed:
+				return
+			default:
+			}
+			log.Warn("failed to accept raft connection", "err", err)
+			time.Sleep(s.timeout / 10)
+			continue
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *streamLayer) handleConn(conn net.Conn) {
+	if err := conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+		conn.Close()
+		return
+	}
+	kind, err := readHandshake(conn, s.secret)
+	if err != nil {
+		log.Warn("rejected raft connection", "remote", conn.RemoteAddr(), "err", err)
+		conn.Close()
+		return
+	}
+	switch kind {
+	case raftStream:
+		if err := conn.SetDeadline(time.Time{}); err != nil {
+			conn.Close()
+			return
+		}
+		select {
+		case s.conns <- conn:
+		case <-s.closed:
+			conn.Close()
+		}
+	case executeStream:
+		defer conn.Close()
+		// the command may take a while to commit
+		if err := conn.SetDeadline(time.Now().Add(2 * s.timeout)); err != nil {
+			return
+		}
+		var cmd Command
+		if err := json.NewDecoder(io.LimitReader(conn, maxRequestSize)).Decode(&cmd); err != nil {
+			return
+		}
+		ctx, cancel := context.WithTimeout(s.ctx, s.timeout)
+		defer cancel()
+		var reply executeReply
+		reply.Result, err = s.execute(ctx, &cmd)
+		if err != nil {
+			reply.Error = err.Error()
+		}
+		_ = json.NewEncoder(conn).Encode(&reply)
+	default:
+		conn.Close()
+	}
+}
+
+func (s *streamLayer) Accept() (net.Conn, error) {
+	select {
+	case conn := <-s.conns:
+		return conn, nil
+	case <-s.closed:
+		return nil, errTransportClosed
+	}
+}
+
+func (s *streamLayer) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		err = s.listener.Close()
+	})
+	return err
+}
+
+func (s *streamLayer) Addr() net.Addr {
+	return s.addr
+}
+
+func (s *streamLayer) Dial(address raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
+	return dial(s.ctx, string(address), s.secret, raftStream, timeout)
+}
+
+type executeReply struct {
+	Result *Result `json:"result,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// executeOn sends a command to a peer, which executes it if it's the leader
+func executeOn(ctx context.Context, addr string, secret []byte, timeout time.Duration, cmd *Command) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*timeout)
+	defer cancel()
+	conn, err := dial(ctx, addr, secret, executeStream, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.NewEncoder(conn).Encode(cmd); err != nil {
+		return nil, err
+	}
+	var reply executeReply
+	if err := json.NewDecoder(io.LimitReader(conn, maxRequestSize)).Decode(&reply); err != nil {
+		return nil, errors.Wrapf(err, "raft execute request to %v failed", addr)
+	}
+	switch reply.Error {
+	case "":
+		if reply.Result == nil {
+			return nil, errors.Errorf("raft execute request to %v returned no result", addr)
+		}
+		return reply.Result, nil
+	case ErrNoLeader.Error():
+		return nil, ErrNoLeader
+	case ErrLeadershipLost.Error():
+		return nil, ErrLeadershipLost
+	default:
+		return nil, errors.Errorf("raft execute request to %v failed: %v", addr, reply.Error)
+	}
+}
+
+// Client executes commands against a raft cluster without being a member of it
+type Client struct {
+	peers   []string
+	secret  []byte
+	timeout time.Duration
+}
+
+// NewClient creates a client of the raft peers, authenticating with the peers' JWT secret
+func NewClient(peers []string, jwtSecret string, timeout time.Duration) (*Client, error) {
+	secret, err := loadJWTSecret(jwtSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{
+		peers:   peers,
+		secret:  secret,
+		timeout: timeout,
+	}, nil
+}
+
+// Execute tries each peer in turn until one of them accepts the command as the leader
+func (c *Client) Execute(ctx context.Context, cmd *Command) (*Result, error) {
+	var lastErr error = ErrNoLeader
+	for _, peer := range c.peers {
+		result, err := executeOn(ctx, peer, c.secret, c.timeout, cmd)
+		if err == nil {
+			return result, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
@@ -5,9 +5,7 @@ package validator
 
 import (
 	"context"
-	"encoding/binary"
 	"fmt"
-	"os"
 	"path/filepath"
 	"runtime"
 	"sync"
@@ -22,12 +20,21 @@ import (
 	"github.com/tenderly/nitro/go-ethereum/core/types"
 	"github.com/tenderly/nitro/go-ethereum/ethdb"
 	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
 	"github.com/tenderly/nitro/go-ethereum/rlp"
 	"github.com/tenderly/nitro/arbstate"
 	"github.com/tenderly/nitro/arbutil"
 	"github.com/tenderly/nitro/util/stopwaiter"
 )
 
+var (
+	validatorLastValidatedGauge = metrics.NewRegisteredGauge("arb/validator/lastvalidated", nil)
+	validatorLagGauge           = metrics.NewRegisteredGauge("arb/validator/lag", nil)
+	validatorRunningGauge       = metrics.NewRegisteredGauge("arb/validator/running", nil)
+	validatorFailedCounter      = metrics.NewRegisteredCounter("arb/validator/failed", nil)
+	validatorDurationHistogram  = metrics.NewRegisteredHistogram("arb/validator/duration", nil, metrics.NewExpDecaySample(1028, 0.015))
+)
+
 type BlockValidator struct {
 	stopwaiter.StopWaiter
 	*StatelessBlockValidator
@@ -54,6 +61,9 @@ type BlockValidator struct {
 	config                   *BlockValidatorConfig
 	atomicValidationsRunning int32
 	concurrentRunsLimit      int32
+	remoteValidation         *remoteValidationPool // nil if validations run in-process
+	localFallbackRuns        chan struct{}         // limits the validations falling back to running in-process
+	rangeValidator           *RangeValidator
 
 	sendValidationsChan chan struct{}
 	checkProgressChan   chan struct{}
@@ -61,21 +71,25 @@ type BlockValidator struct {
 }
 
 type BlockValidatorConfig struct {
-	Enable                   bool   `koanf:"enable"`
-	OutputPath               string `koanf:"output-path"`
-	ConcurrentRunsLimit      int    `koanf:"concurrent-runs-limit"`
-	CurrentModuleRoot        string `koanf:"current-module-root"`
-	PendingUpgradeModuleRoot string `koanf:"pending-upgrade-module-root"`
-	StorePreimages           bool   `koanf:"store-preimages"`
+	Enable                   bool                   `koanf:"enable"`
+	OutputPath               string                 `koanf:"output-path"`
+	ConcurrentRunsLimit      int                    `koanf:"concurrent-runs-limit"`
+	CurrentModuleRoot        string                 `koanf:"current-module-root"`
+	PendingUpgradeModuleRoot string                 `koanf:"pending-upgrade-module-root"`
+	StorePreimages           bool                   `koanf:"store-preimages"`
+	Remote                   RemoteValidationConfig `koanf:"remote"`
+	RangeValidation          RangeValidatorConfig   `koanf:"range-validation"`
 }
 
 func BlockValidatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".enable", DefaultBlockValidatorConfig.Enable, "enable block validator")
-	f.String(prefix+".output-path", DefaultBlockValidatorConfig.OutputPath, "")
+	f.String(prefix+".output-path", DefaultBlockValidatorConfig.OutputPath, "directory to write validation bundles of blocks that fail validation to, relative to the machines directory")
 	f.Int(prefix+".concurrent-runs-limit", DefaultBlockValidatorConfig.ConcurrentRunsLimit, "")
 	f.String(prefix+".current-module-root", DefaultBlockValidatorConfig.CurrentModuleRoot, "current wasm module root ('current' read from chain, 'latest' from machines/latest dir, or provide hash)")
 	f.String(prefix+".pending-upgrade-module-root", DefaultBlockValidatorConfig.PendingUpgradeModuleRoot, "pending upgrade wasm module root to additionally validate (hash, 'latest' or empty)")
 	f.Bool(prefix+".store-preimages", DefaultBlockValidatorConfig.StorePreimages, "store preimages of running machines (higher memory cost, better debugging, potentially better performance)")
+	RemoteValidationConfigAddOptions(prefix+".remote", f)
+	RangeValidatorConfigAddOptions(prefix+".range-validation", f)
 }
 
 var DefaultBlockValidatorConfig = BlockValidatorConfig{
@@ -85,6 +99,8 @@ var DefaultBlockValidatorConfig = BlockValidatorConfig{
 	CurrentModuleRoot:        "current",
 	PendingUpgradeModuleRoot: "latest",
 	StorePreimages:           false,
+	Remote:                   DefaultRemoteValidationConfig,
+	RangeValidation:          DefaultRangeValidatorConfig,
 }
 
 var TestBlockValidatorConfig = BlockValidatorConfig{
@@ -94,6 +110,8 @@ var TestBlockValidatorConfig = BlockValidatorConfig{
 	CurrentModuleRoot:        "latest",
 	PendingUpgradeModuleRoot: "latest",
 	StorePreimages:           false,
+	Remote:                   DefaultRemoteValidationConfig,
+	RangeValidation:          DefaultRangeValidatorConfig,
 }
 
 const validationStatusUnprepared uint32 = 0 // waiting for validationEntry to be populated
@@ -120,7 +138,11 @@ func NewBlockValidator(
 ) (*BlockValidator, error) {
 	concurrent := config.ConcurrentRunsLimit
 	if concurrent == 0 {
-		concurrent = runtime.NumCPU()
+		if len(config.Remote.Urls) > 0 {
+			concurrent = len(config.Remote.Urls) * config.Remote.MaxConcurrentPerWorker
+		} else {
+			concurrent = runtime.NumCPU()
+		}
 	}
 	statelessVal, err := NewStatelessBlockValidator(
 		machineLoader,
@@ -141,6 +163,18 @@ func NewBlockValidator(
 		progressChan:            make(chan uint64, 1),
 		concurrentRunsLimit:     int32(concurrent),
 		config:                  config,
+		rangeValidator:          NewRangeValidator(statelessVal, &config.RangeValidation),
+	}
+	if len(config.Remote.Urls) > 0 {
+		validator.remoteValidation, err = newRemoteValidationPool(&config.Remote)
+		if err != nil {
+			return nil, err
+		}
+		localRuns := config.Remote.LocalConcurrentRuns
+		if localRuns == 0 {
+			localRuns = runtime.NumCPU()
+		}
+		validator.localFallbackRuns = make(chan struct{}, localRuns)
 	}
 	err = validator.readLastBlockValidatedDbInfo(reorgingToBlock)
 	if err != nil {
@@ -233,7 +267,7 @@ func (v *BlockValidator) readLastBlockValidatedDbInfo(reorgingToBlock *types.Blo
 }
 
 func (v *BlockValidator) prepareBlock(ctx context.Context, header *types.Header, prevHeader *types.Header, msg arbstate.MessageWithMetadata, validationStatus *validationStatus) {
-	preimages, readBatchInfo, hasDelayedMessage, delayedMsgToRead, err := BlockDataForValidation(ctx, v.blockchain, v.inboxReader, header, prevHeader, msg, v.config.StorePreimages)
+	preimages, readBatchInfo, hasDelayedMessage, delayedMsgToRead, err := BlockDataForValidation(ctx, v.blockchain, v.inboxReader, header, prevHeader, msg, v.config.StorePreimages || v.remoteValidation != nil)
 	if err != nil {
 		log.Error("failed to set up validation", "err", err, "header", header, "prevHeader", prevHeader)
 		return
@@ -268,6 +302,7 @@ func (v *BlockValidator) GetModuleRootsToValidate() []common.Hash {
 func (v *BlockValidator) NewBlock(block *types.Block, prevHeader *types.Header, msg arbstate.MessageWithMetadata) {
 	v.blockMutex.Lock()
 	defer v.blockMutex.Unlock()
+	v.updateLagMetrics()
 	status := &validationStatus{
 		Status:      validationStatusUnprepared,
 		Entry:       nil,
@@ -286,102 +321,20 @@ func (v *BlockValidator) NewBlock(block *types.Block, prevHeader *types.Header,
 	v.LaunchUntrackedThread(func() { v.prepareBlock(context.Background(), block.Header(), prevHeader, msg, status) })
 }
 
-var launchTime = time.Now().Format("2006_01_02__15_04")
-
-//nolint:gosec
-func (v *BlockValidator) writeToFile(validationEntry *validationEntry, moduleRoot common.Hash, start, end GlobalStatePosition, preimages map[common.Hash][]byte, sequencerMsg, delayedMsg []byte) error {
-	machConf := v.MachineLoader.GetConfig()
-	outDirPath := filepath.Join(machConf.RootPath, v.config.OutputPath, launchTime, fmt.Sprintf("block_%d", validationEntry.BlockNumber))
-	err := os.MkdirAll(outDirPath, 0755)
-	if err != nil {
-		return err
-	}
-
-	cmdFile, err := os.OpenFile(filepath.Join(outDirPath, "run-prover.sh"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
-	if err != nil {
-		return err
-	}
-	defer cmdFile.Close()
-	_, err = cmdFile.WriteString("#!/bin/bash\n" +
-		fmt.Sprintf("# expected output: batch %d, postion %d, hash %s\n", end.BatchNumber, end.PosInBatch, validationEntry.BlockHash) +
-		"MACHPATH=\"" + machConf.getMachinePath(moduleRoot) + "\"\n" +
-		"if (( $# > 1 )); then\n" +
-		"	if [[ $1 == \"-m\" ]]; then\n" +
-		"		MACHPATH=$2\n" +
-		"		shift\n" +
-		"		shift\n" +
-		"	fi\n" +
-		"fi\n" +
-		"${ROOTPATH}/bin/prover ${MACHPATH}/" + machConf.ProverBinPath)
-	if err != nil {
-		return err
-	}
-
-	for _, module := range machConf.LibraryPaths {
-		_, err = cmdFile.WriteString(" -l " + "${ROOTPATH}/" + module)
-		if err != nil {
-			return err
-		}
-	}
-	_, err = cmdFile.WriteString(fmt.Sprintf(" --inbox-position %d --position-within-message %d --last-block-hash %s", start.BatchNumber, start.PosInBatch, validationEntry.PrevBlockHash))
-	if err != nil {
-		return err
-	}
-
-	sequencerFileName := fmt.Sprintf("sequencer_%d.bin", start.BatchNumber)
-	err = os.WriteFile(filepath.Join(outDirPath, sequencerFileName), sequencerMsg, 0644)
-	if err != nil {
-		return err
-	}
-	_, err = cmdFile.WriteString(" --inbox " + sequencerFileName)
-	if err != nil {
-		return err
-	}
-
-	preimageFile, err := os.Create(filepath.Join(outDirPath, "preimages.bin"))
-	if err != nil {
-		return err
-	}
-	defer preimageFile.Close()
-	for _, data := range preimages {
-		lenbytes := make([]byte, 8)
-		binary.LittleEndian.PutUint64(lenbytes, uint64(len(data)))
-		_, err := preimageFile.Write(lenbytes)
-		if err != nil {
-			return err
-		}
-		_, err = preimageFile.Write(data)
-		if err != nil {
-			return err
-		}
-	}
-
-	_, err = cmdFile.WriteString(" --preimages preimages.bin")
-	if err != nil {
-		return err
-	}
-
-	if validationEntry.HasDelayedMsg {
-		_, err = cmdFile.WriteString(fmt.Sprintf(" --delayed-inbox-position %d", validationEntry.DelayedMsgNr))
-		if err != nil {
-			return err
-		}
-		filename := fmt.Sprintf("delayed_%d.bin", validationEntry.DelayedMsgNr)
-		err = os.WriteFile(filepath.Join(outDirPath, filename), delayedMsg, 0644)
-		if err != nil {
-			return err
-		}
-		_, err = cmdFile.WriteString(fmt.Sprintf(" --delayed-inbox %s", filename))
-		if err != nil {
-			return err
-		}
+// writeToFile writes a validation bundle for the entry, recording the block again if its preimages weren't stored
+func (v *BlockValidator) writeToFile(ctx context.Context, entry *validationEntry, moduleRoot common.Hash) error {
+	var bundle *ValidationBundle
+	var err error
+	if entry.Preimages != nil {
+		bundle, err = v.validationBundleForEntry(ctx, entry, moduleRoot)
+	} else {
+		bundle, err = v.ValidationBundle(ctx, entry.BlockHeader, moduleRoot)
 	}
-
-	_, err = cmdFile.WriteString(" \"$@\"\n")
 	if err != nil {
 		return err
 	}
-	return nil
+	outDirPath := filepath.Join(v.MachineLoader.GetConfig().RootPath, v.config.OutputPath, fmt.Sprintf("block_%d_%v", entry.BlockNumber, moduleRoot))
+	return bundle.Export().WriteDir(outDirPath)
 }
 
 func (v *BlockValidator) SetCurrentWasmModuleRoot(hash common.Hash) error {
@@ -416,7 +369,7 @@ func (v *BlockValidator) validate(ctx context.Context, validationStatus *validat
 	}
 	entry := validationStatus.Entry
 	defer func() {
-		atomic.AddInt32(&v.atomicValidationsRunning, -1)
+		validatorRunningGauge.Update(int64(atomic.AddInt32(&v.atomicValidationsRunning, -1)))
 		select {
 		case v.sendValidationsChan <- struct{}{}:
 		default:
@@ -429,12 +382,14 @@ func (v *BlockValidator) validate(ctx context.Context, validationStatus *validat
 	log.Info("starting validation for block", "blockNr", entry.BlockNumber)
 	for _, moduleRoot := range validationStatus.ModuleRoots {
 		before := time.Now()
-		gsEnd, delayedMsg, err := v.executeBlock(ctx, entry, moduleRoot)
+		gsEnd, err := v.runValidation(ctx, entry, moduleRoot)
 		duration := time.Since(before)
+		validatorDurationHistogram.Update(duration.Nanoseconds())
 		if err != nil {
 			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
 				log.Info("Validation of block canceled", "blockNr", entry.BlockNumber, "blockHash", entry.BlockHash, "err", err)
 			} else {
+				validatorFailedCounter.Inc(1)
 				log.Error("Validation of block failed", "blockNr", entry.BlockNumber, "blockHash", entry.BlockHash, "moduleRoot", moduleRoot, "err", err)
 			}
 			return
@@ -442,19 +397,12 @@ func (v *BlockValidator) validate(ctx context.Context, validationStatus *validat
 		gsExpected := entry.expectedEnd()
 		resultValid := gsEnd == gsExpected
 
-		writeThisBlock := false
 		if !resultValid {
-			writeThisBlock = true
-		}
-
-		if writeThisBlock {
-			err = v.writeToFile(entry, moduleRoot, entry.StartPosition, entry.EndPosition, entry.Preimages, seqMsg, delayedMsg)
+			validatorFailedCounter.Inc(1)
+			err = v.writeToFile(ctx, entry, moduleRoot)
 			if err != nil {
-				log.Error("failed to write file", "err", err)
+				log.Error("failed to write validation bundle", "err", err)
 			}
-		}
-
-		if !resultValid {
 			log.Error("validation failed", "moduleRoot", moduleRoot, "got", gsEnd, "expected", gsExpected, "expHeader", entry.BlockHeader)
 			return
 		}
@@ -466,6 +414,31 @@ func (v *BlockValidator) validate(ctx context.Context, validationStatus *validat
 	v.checkProgressChan <- struct{}{}
 }
 
+// runValidation executes the block on a remote validation worker if any are configured,
+// falling back to running it in-process if the workers all fail and local fallback is enabled
+func (v *BlockValidator) runValidation(ctx context.Context, entry *validationEntry, moduleRoot common.Hash) (GoGlobalState, error) {
+	if v.remoteValidation != nil {
+		input, err := v.validationInput(ctx, entry, moduleRoot)
+		if err != nil {
+			return GoGlobalState{}, err
+		}
+		gsEnd, err := v.remoteValidation.Validate(ctx, input)
+		if err == nil || ctx.Err() != nil || !v.config.Remote.LocalFallback || isValidationFailure(err) {
+			return gsEnd, err
+		}
+		log.Warn("remote validation failed, validating in-process", "blockNr", entry.BlockNumber, "err", err)
+		// concurrentRunsLimit counts the workers' capacity, which this machine may not have
+		select {
+		case v.localFallbackRuns <- struct{}{}:
+		case <-ctx.Done():
+			return GoGlobalState{}, ctx.Err()
+		}
+		defer func() { <-v.localFallbackRuns }()
+	}
+	gsEnd, _, err := v.executeBlock(ctx, entry, moduleRoot)
+	return gsEnd, err
+}
+
 func (v *BlockValidator) sendValidations(ctx context.Context) {
 	v.reorgMutex.Lock()
 	defer v.reorgMutex.Unlock()
@@ -539,7 +512,7 @@ func (v *BlockValidator) sendValidations(ctx context.Context) {
 			log.Error("inconsistent pos mapping", "msg", nextMsg, "expected", v.globalPosNextSend, "found", startPos)
 			return
 		}
-		atomic.AddInt32(&v.atomicValidationsRunning, 1)
+		validatorRunningGauge.Update(int64(atomic.AddInt32(&v.atomicValidationsRunning, 1)))
 		validationStatus.Entry.StartPosition = startPos
 		validationStatus.Entry.EndPosition = endPos
 
@@ -622,6 +595,7 @@ func (v *BlockValidator) progressValidated() {
 		v.lastBlockValidatedMutex.Unlock()
 
 		v.validationEntries.Delete(checkingBlock)
+		v.updateLagMetrics()
 		select {
 		case v.progressChan <- checkingBlock:
 		default:
@@ -633,6 +607,21 @@ func (v *BlockValidator) progressValidated() {
 	}
 }
 
+func (v *BlockValidator) updateLagMetrics() {
+	validatorLastValidatedGauge.Update(int64(v.LastBlockValidated()))
+	validatorLagGauge.Update(int64(v.ValidationLag()))
+}
+
+// ValidationLag returns how many blocks the chain head is ahead of the last block validated
+func (v *BlockValidator) ValidationLag() uint64 {
+	lastValidated := atomic.LoadUint64(&v.lastBlockValidated)
+	latest := v.blockchain.CurrentBlock().NumberU64()
+	if latest <= lastValidated {
+		return 0
+	}
+	return latest - lastValidated
+}
+
 func (v *BlockValidator) LastBlockValidated() uint64 {
 	return atomic.LoadUint64(&v.lastBlockValidated)
 }
@@ -820,6 +809,7 @@ func (v *BlockValidator) Initialize() error {
 
 func (v *BlockValidator) Start(ctxIn context.Context) error {
 	v.StopWaiter.Start(ctxIn)
+	v.rangeValidator.Start(ctxIn)
 	v.LaunchThread(func(ctx context.Context) {
 		// `progressValidated` and `sendValidations` should both only do `concurrentRunsLimit` iterations of work,
 		// so they won't stomp on each other and prevent the other from running.
@@ -844,6 +834,19 @@ func (v *BlockValidator) Start(ctxIn context.Context) error {
 	return nil
 }
 
+func (v *BlockValidator) StopAndWait() {
+	v.StopWaiter.StopAndWait()
+	v.rangeValidator.StopAndWait()
+	if v.remoteValidation != nil {
+		v.remoteValidation.close()
+	}
+}
+
+// RangeValidator returns the validator of historical block ranges
+func (v *BlockValidator) RangeValidator() *RangeValidator {
+	return v.rangeValidator
+}
+
 // can only be used from One thread
 func (v *BlockValidator) WaitForBlock(blockNumber uint64, timeout time.Duration) bool {
 	timer := time.NewTimer(timeout)
@@ -0,0 +1,304 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/util/stopwaiter"
+)
+
+type RangeValidatorConfig struct {
+	ConcurrentRunsLimit int    `koanf:"concurrent-runs-limit"`
+	MaxRangeSize        uint64 `koanf:"max-range-size"`
+	MaxJobs             int    `koanf:"max-jobs"`
+}
+
+var DefaultRangeValidatorConfig = RangeValidatorConfig{
+	ConcurrentRunsLimit: 0,
+	MaxRangeSize:        1000000,
+	MaxJobs:             16,
+}
+
+func RangeValidatorConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.Int(prefix+".concurrent-runs-limit", DefaultRangeValidatorConfig.ConcurrentRunsLimit, "maximum validations to run at once across all block range validations (0 for half the number of CPUs)")
+	f.Uint64(prefix+".max-range-size", DefaultRangeValidatorConfig.MaxRangeSize, "maximum number of blocks in a block range validation")
+	f.Int(prefix+".max-jobs", DefaultRangeValidatorConfig.MaxJobs, "maximum number of block range validations to keep, the oldest finished ones are forgotten first")
+}
+
+// the most failures kept for each range validation, more are only counted
+const maxRangeValidationFailures = 100
+
+const (
+	RangeValidationRunning   = "running"
+	RangeValidationDone      = "done"
+	RangeValidationCancelled = "cancelled"
+)
+
+type RangeValidationFailure struct {
+	BlockNumber uint64      `json:"blockNumber"`
+	ModuleRoot  common.Hash `json:"moduleRoot"`
+	Error       string      `json:"error"`
+}
+
+// RangeValidationProgress is a snapshot of the state of a block range validation
+type RangeValidationProgress struct {
+	Id          uint64                   `json:"id"`
+	FromBlock   uint64                   `json:"fromBlock"`
+	ToBlock     uint64                   `json:"toBlock"`
+	ModuleRoots []common.Hash            `json:"moduleRoots"`
+	Status      string                   `json:"status"`
+	Validated   uint64                   `json:"validated"`
+	Failed      uint64                   `json:"failed"`
+	Failures    []RangeValidationFailure `json:"failures"`
+	Started     time.Time                `json:"started"`
+	Finished    *time.Time               `json:"finished,omitempty"`
+}
+
+type rangeValidationJob struct {
+	cancel func()
+
+	// behind the range validator's mutex
+	progress  RangeValidationProgress
+	nextBlock uint64
+}
+
+// RangeValidator validates ranges of historical blocks in the background, independently of the block validator's progress
+type RangeValidator struct {
+	stopwaiter.StopWaiter
+	config         *RangeValidatorConfig
+	headerByNumber func(uint64) *types.Header
+	validateBlock  func(context.Context, *types.Header, common.Hash) (bool, error)
+	runs           chan struct{} // limits the concurrent validation runs of all jobs
+
+	mutex  sync.Mutex
+	jobs   map[uint64]*rangeValidationJob
+	nextId uint64
+}
+
+func NewRangeValidator(validator *StatelessBlockValidator, config *RangeValidatorConfig) *RangeValidator {
+	return newRangeValidator(config, validator.blockchain.GetHeaderByNumber, validator.ValidateBlock)
+}
+
+func newRangeValidator(
+	config *RangeValidatorConfig,
+	headerByNumber func(uint64) *types.Header,
+	validateBlock func(context.Context, *types.Header, common.Hash) (bool, error),
+) *RangeValidator {
+	concurrent := config.ConcurrentRunsLimit
+	if concurrent == 0 {
+		concurrent = (runtime.NumCPU() + 1) / 2
+	}
+	return &RangeValidator{
+		config:         config,
+		headerByNumber: headerByNumber,
+		validateBlock:  validateBlock,
+		runs:           make(chan struct{}, concurrent),
+		jobs:           make(map[uint64]*rangeValidationJob),
+	}
+}
+
+// forgetFinishedLocked drops the oldest finished jobs until there's room for a new one
+func (r *RangeValidator) forgetFinishedLocked() error {
+	var finished []uint64
+	for id, job := range r.jobs {
+		if job.progress.Status != RangeValidationRunning {
+			finished = append(finished, id)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i] < finished[j] })
+	for len(r.jobs) >= r.config.MaxJobs {
+		if len(finished) == 0 {
+			return fmt.Errorf("too many block range validations running, at most %v allowed", r.config.MaxJobs)
+		}
+		delete(r.jobs, finished[0])
+		finished = finished[1:]
+	}
+	return nil
+}
+
+// ValidateRange starts validating the blocks from fromBlock to toBlock inclusive with each of the module roots,
+// returning the id to follow its progress with
+func (r *RangeValidator) ValidateRange(fromBlock, toBlock uint64, moduleRoots []common.Hash) (uint64, error) {
+	if fromBlock > toBlock {
+		return 0, fmt.Errorf("invalid block range: %v to %v", fromBlock, toBlock)
+	}
+	if toBlock-fromBlock >= r.config.MaxRangeSize {
+		return 0, fmt.Errorf("block range of %v blocks is larger than the maximum of %v", toBlock-fromBlock+1, r.config.MaxRangeSize)
+	}
+	if len(moduleRoots) == 0 {
+		return 0, errors.New("no module roots to validate with")
+	}
+	ctx, err := r.StopWaiterSafe.GetContext()
+	if err != nil {
+		return 0, err
+	}
+	if r.Stopped() {
+		return 0, errors.New("range validator stopped")
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if err := r.forgetFinishedLocked(); err != nil {
+		return 0, err
+	}
+	r.nextId++
+	id := r.nextId
+	ctx, cancel := context.WithCancel(ctx)
+	job := &rangeValidationJob{
+		cancel: cancel,
+		progress: RangeValidationProgress{
+			Id:          id,
+			FromBlock:   fromBlock,
+			ToBlock:     toBlock,
+			ModuleRoots: moduleRoots,
+			Status:      RangeValidationRunning,
+			Failures:    []RangeValidationFailure{},
+			Started:     time.Now(),
+		},
+		nextBlock: fromBlock,
+	}
+	r.jobs[id] = job
+	log.Info("starting block range validation", "id", id, "from", fromBlock, "to", toBlock, "moduleRoots", moduleRoots)
+
+	r.LaunchThread(func(context.Context) {
+		var wg sync.WaitGroup
+		for i := 0; i < cap(r.runs); i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				r.runJob(ctx, job)
+			}()
+		}
+		wg.Wait()
+		r.finishJob(ctx, job)
+	})
+	return id, nil
+}
+
+// runJob validates blocks of the job until it has none left or is cancelled
+func (r *RangeValidator) runJob(ctx context.Context, job *rangeValidationJob) {
+	for {
+		r.mutex.Lock()
+		blockNum := job.nextBlock
+		job.nextBlock++
+		r.mutex.Unlock()
+		if blockNum > job.progress.ToBlock {
+			return
+		}
+
+		select {
+		case r.runs <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+		failures := r.validateRangeBlock(ctx, blockNum, job.progress.ModuleRoots)
+		<-r.runs
+		if ctx.Err() != nil {
+			return
+		}
+
+		r.mutex.Lock()
+		if len(failures) > 0 {
+			job.progress.Failed++
+			for _, failure := range failures {
+				if len(job.progress.Failures) < maxRangeValidationFailures {
+					job.progress.Failures = append(job.progress.Failures, failure)
+				}
+			}
+		} else {
+			job.progress.Validated++
+		}
+		r.mutex.Unlock()
+	}
+}
+
+func (r *RangeValidator) validateRangeBlock(ctx context.Context, blockNum uint64, moduleRoots []common.Hash) []RangeValidationFailure {
+	var failures []RangeValidationFailure
+	header := r.headerByNumber(blockNum)
+	for _, moduleRoot := range moduleRoots {
+		valid, err := r.validateBlock(ctx, header, moduleRoot)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == nil && valid {
+			continue
+		}
+		if err == nil {
+			err = errors.New("end state mismatch")
+		}
+		log.Warn("block range validation failed", "blockNr", blockNum, "moduleRoot", moduleRoot, "err", err)
+		failures = append(failures, RangeValidationFailure{
+			BlockNumber: blockNum,
+			ModuleRoot:  moduleRoot,
+			Error:       err.Error(),
+		})
+	}
+	return failures
+}
+
+func (r *RangeValidator) finishJob(ctx context.Context, job *rangeValidationJob) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	now := time.Now()
+	job.progress.Finished = &now
+	if ctx.Err() != nil {
+		job.progress.Status = RangeValidationCancelled
+	} else {
+		job.progress.Status = RangeValidationDone
+	}
+	job.cancel()
+	log.Info("block range validation finished", "id", job.progress.Id, "status", job.progress.Status, "validated", job.progress.Validated, "failed", job.progress.Failed)
+}
+
+func (r *RangeValidator) progressLocked(job *rangeValidationJob) *RangeValidationProgress {
+	progress := job.progress
+	progress.Failures = append([]RangeValidationFailure{}, job.progress.Failures...)
+	return &progress
+}
+
+func (r *RangeValidator) Progress(id uint64) (*RangeValidationProgress, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown block range validation %v", id)
+	}
+	return r.progressLocked(job), nil
+}
+
+// AllProgress returns the progress of every block range validation kept, ordered by id
+func (r *RangeValidator) AllProgress() []*RangeValidationProgress {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	all := make([]*RangeValidationProgress, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		all = append(all, r.progressLocked(job))
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Id < all[j].Id })
+	return all
+}
+
+// Cancel stops a running block range validation; its progress is kept
+func (r *RangeValidator) Cancel(id uint64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	job, ok := r.jobs[id]
+	if !ok {
+		return fmt.Errorf("unknown block range validation %v", id)
+	}
+	job.cancel()
+	return nil
+}
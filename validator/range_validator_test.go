@@ -0,0 +1,131 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/core/types"
+)
+
+func testHeaderByNumber(number uint64) *types.Header {
+	return &types.Header{Number: new(big.Int).SetUint64(number)}
+}
+
+func waitForRangeValidation(t *testing.T, r *RangeValidator, id uint64) *RangeValidationProgress {
+	for i := 0; i < 1000; i++ {
+		progress, err := r.Progress(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if progress.Status != RangeValidationRunning {
+			return progress
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("block range validation didn't finish")
+	return nil
+}
+
+func TestRangeValidatorReportsFailures(t *testing.T) {
+	goodRoot := common.HexToHash("0x01")
+	badRoot := common.HexToHash("0x02")
+	validate := func(ctx context.Context, header *types.Header, moduleRoot common.Hash) (bool, error) {
+		number := header.Number.Uint64()
+		if moduleRoot == badRoot && number%10 == 0 {
+			return false, nil
+		}
+		if number == 15 {
+			return false, errors.New("machine failed")
+		}
+		return true, nil
+	}
+	config := DefaultRangeValidatorConfig
+	config.ConcurrentRunsLimit = 4
+	r := newRangeValidator(&config, testHeaderByNumber, validate)
+	r.Start(context.Background())
+	defer r.StopAndWait()
+
+	id, err := r.ValidateRange(1, 40, []common.Hash{goodRoot, badRoot})
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress := waitForRangeValidation(t, r, id)
+	if progress.Status != RangeValidationDone {
+		t.Fatal("unexpected status", progress.Status)
+	}
+	// blocks 10, 20, 30 and 40 mismatch with the bad root, block 15 fails with both roots
+	if progress.Failed != 5 || progress.Validated != 35 {
+		t.Fatal("unexpected counts", "failed", progress.Failed, "validated", progress.Validated)
+	}
+	if len(progress.Failures) != 6 {
+		t.Fatal("unexpected failures", progress.Failures)
+	}
+}
+
+func TestRangeValidatorCancel(t *testing.T) {
+	validate := func(ctx context.Context, header *types.Header, moduleRoot common.Hash) (bool, error) {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	config := DefaultRangeValidatorConfig
+	config.ConcurrentRunsLimit = 2
+	r := newRangeValidator(&config, testHeaderByNumber, validate)
+	r.Start(context.Background())
+	defer r.StopAndWait()
+
+	id, err := r.ValidateRange(1, 100, []common.Hash{{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Cancel(id); err != nil {
+		t.Fatal(err)
+	}
+	progress := waitForRangeValidation(t, r, id)
+	if progress.Status != RangeValidationCancelled || progress.Failed != 0 {
+		t.Fatal("unexpected progress after cancelling", progress)
+	}
+}
+
+func TestRangeValidatorLimits(t *testing.T) {
+	validate := func(ctx context.Context, header *types.Header, moduleRoot common.Hash) (bool, error) {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+	config := DefaultRangeValidatorConfig
+	config.MaxRangeSize = 10
+	config.MaxJobs = 2
+	r := newRangeValidator(&config, testHeaderByNumber, validate)
+	r.Start(context.Background())
+	defer r.StopAndWait()
+
+	if _, err := r.ValidateRange(1, 10, []common.Hash{{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ValidateRange(1, 11, []common.Hash{{}}); err == nil {
+		t.Fatal("expected range larger than the maximum to be rejected")
+	}
+	second, err := r.ValidateRange(5, 5, []common.Hash{{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ValidateRange(5, 5, []common.Hash{{}}); err == nil {
+		t.Fatal("expected validation beyond the maximum running to be rejected")
+	}
+	if err := r.Cancel(second); err != nil {
+		t.Fatal(err)
+	}
+	waitForRangeValidation(t, r, second)
+	if _, err := r.ValidateRange(5, 5, []common.Hash{{}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Progress(second); err == nil {
+		t.Fatal("expected finished validation to be forgotten")
+	}
+}
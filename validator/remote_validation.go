@@ -0,0 +1,191 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	flag "github.com/spf13/pflag"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/rpc"
+)
+
+type RemoteValidationConfig struct {
+	Urls                   []string      `koanf:"urls"`
+	MaxConcurrentPerWorker int           `koanf:"max-concurrent-per-worker"`
+	Retries                int           `koanf:"retries"`
+	Timeout                time.Duration `koanf:"timeout"`
+	FailureBackoff         time.Duration `koanf:"failure-backoff"`
+	LocalFallback          bool          `koanf:"local-fallback"`
+	LocalConcurrentRuns    int           `koanf:"local-concurrent-runs"`
+}
+
+var DefaultRemoteValidationConfig = RemoteValidationConfig{
+	Urls:                   []string{},
+	MaxConcurrentPerWorker: 2,
+	Retries:                2,
+	Timeout:                time.Hour,
+	FailureBackoff:         time.Minute,
+	LocalFallback:          true,
+	LocalConcurrentRuns:    0,
+}
+
+func RemoteValidationConfigAddOptions(prefix string, f *flag.FlagSet) {
+	f.StringSlice(prefix+".urls", DefaultRemoteValidationConfig.Urls, "URLs of validation workers to run validations on; validations run in-process if empty")
+	f.Int(prefix+".max-concurrent-per-worker", DefaultRemoteValidationConfig.MaxConcurrentPerWorker, "maximum validations to send to each worker at once; also sets the default concurrent-runs-limit")
+	f.Int(prefix+".retries", DefaultRemoteValidationConfig.Retries, "number of other workers to try a validation on after a worker fails")
+	f.Duration(prefix+".timeout", DefaultRemoteValidationConfig.Timeout, "timeout for a validation on a worker, which must be less than the workers' rpc-server-timeouts.write-timeout")
+	f.Duration(prefix+".failure-backoff", DefaultRemoteValidationConfig.FailureBackoff, "how long to stop sending validations to a worker after it fails")
+	f.Bool(prefix+".local-fallback", DefaultRemoteValidationConfig.LocalFallback, "run a validation in-process if no worker succeeds")
+	f.Int(prefix+".local-concurrent-runs", DefaultRemoteValidationConfig.LocalConcurrentRuns, "maximum validations to run in-process at once after workers fail (0 for the number of CPUs)")
+}
+
+// The preimages of a validation input are sent in chunks of about this size, to stay under the RPC request size limit
+const remoteValidationChunkSize = 2 * 1024 * 1024
+
+var errNoValidationWorkers = errors.New("no validation worker available")
+
+// isValidationFailure is whether a worker answered that running the validation failed, as it would on any worker,
+// rather than the worker or the connection to it failing
+func isValidationFailure(err error) bool {
+	var rpcErr rpc.Error
+	return errors.As(err, &rpcErr) && rpcErr.ErrorCode() == validationFailedCode
+}
+
+type remoteValidationWorker struct {
+	url    string
+	client *rpc.Client
+
+	// behind the pool's mutex
+	inFlight       int
+	unhealthyUntil time.Time
+}
+
+// remoteValidationPool sends validations to the least loaded healthy worker, trying other workers if one fails
+type remoteValidationPool struct {
+	config  *RemoteValidationConfig
+	mutex   sync.Mutex
+	workers []*remoteValidationWorker
+}
+
+func newRemoteValidationPool(config *RemoteValidationConfig) (*remoteValidationPool, error) {
+	pool := &remoteValidationPool{config: config}
+	for _, url := range config.Urls {
+		client, err := rpc.Dial(url)
+		if err != nil {
+			return nil, err
+		}
+		pool.workers = append(pool.workers, &remoteValidationWorker{url: url, client: client})
+	}
+	return pool, nil
+}
+
+// acquire picks the healthy worker with the fewest validations in flight that hasn't been tried, if any has capacity
+func (p *remoteValidationPool) acquire(tried map[*remoteValidationWorker]bool) *remoteValidationWorker {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	now := time.Now()
+	var best *remoteValidationWorker
+	for _, worker := range p.workers {
+		if tried[worker] || now.Before(worker.unhealthyUntil) || worker.inFlight >= p.config.MaxConcurrentPerWorker {
+			continue
+		}
+		if best == nil || worker.inFlight < best.inFlight {
+			best = worker
+		}
+	}
+	if best != nil {
+		best.inFlight++
+	}
+	return best
+}
+
+func (p *remoteValidationPool) release(worker *remoteValidationWorker, failed bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	worker.inFlight--
+	if failed {
+		worker.unhealthyUntil = time.Now().Add(p.config.FailureBackoff)
+	}
+}
+
+// Validate runs the validation on a worker, retrying on others if the worker fails, and returns the end state of the machine
+func (p *remoteValidationPool) Validate(ctx context.Context, input *ValidationInput) (GoGlobalState, error) {
+	tried := make(map[*remoteValidationWorker]bool)
+	err := errNoValidationWorkers
+	for attempt := 0; attempt <= p.config.Retries; attempt++ {
+		worker := p.acquire(tried)
+		if worker == nil {
+			break
+		}
+		tried[worker] = true
+		var gsEnd GoGlobalState
+		gsEnd, err = p.validateOn(ctx, worker, input)
+		workerFailed := err != nil && ctx.Err() == nil && !isValidationFailure(err)
+		p.release(worker, workerFailed)
+		if err == nil {
+			return gsEnd, nil
+		}
+		if ctx.Err() != nil {
+			return GoGlobalState{}, ctx.Err()
+		}
+		if !workerFailed {
+			return GoGlobalState{}, err
+		}
+		log.Warn("validation worker failed", "url", worker.url, "blockNr", input.BlockNumber, "attempt", attempt, "err", err)
+	}
+	return GoGlobalState{}, err
+}
+
+func (p *remoteValidationPool) validateOn(ctx context.Context, worker *remoteValidationWorker, input *ValidationInput) (GoGlobalState, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.config.Timeout)
+	defer cancel()
+
+	withoutPreimages := *input
+	withoutPreimages.Preimages = nil
+	var id hexutil.Uint64
+	if err := worker.client.CallContext(ctx, &id, "validation_start", &withoutPreimages); err != nil {
+		return GoGlobalState{}, err
+	}
+	chunk := make(map[common.Hash]hexutil.Bytes)
+	chunkSize := 0
+	sendChunk := func() error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		err := worker.client.CallContext(ctx, nil, "validation_addPreimages", id, chunk)
+		chunk = make(map[common.Hash]hexutil.Bytes)
+		chunkSize = 0
+		return err
+	}
+	for hash, preimage := range input.Preimages {
+		chunk[hash] = preimage
+		chunkSize += len(hash) + len(preimage)
+		if chunkSize >= remoteValidationChunkSize {
+			if err := sendChunk(); err != nil {
+				return GoGlobalState{}, err
+			}
+		}
+	}
+	if err := sendChunk(); err != nil {
+		return GoGlobalState{}, err
+	}
+	var gsEnd GoGlobalState
+	if err := worker.client.CallContext(ctx, &gsEnd, "validation_validate", id); err != nil {
+		return GoGlobalState{}, err
+	}
+	return gsEnd, nil
+}
+
+func (p *remoteValidationPool) close() {
+	for _, worker := range p.workers {
+		worker.client.Close()
+	}
+}
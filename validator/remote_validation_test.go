@@ -0,0 +1,186 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
+	"github.com/tenderly/nitro/go-ethereum/rpc"
+)
+
+// fakeValidationAPI serves the validation worker protocol, "validating" by returning the start state
+// with its position advanced if it received all the expected preimages
+type fakeValidationAPI struct {
+	fail      bool
+	invalid   bool // validations fail like running the machine failed
+	mutex     sync.Mutex
+	inputs    map[uint64]*ValidationInput
+	nextId    uint64
+	chunks    int
+	validated int
+}
+
+func (a *fakeValidationAPI) Start(input *ValidationInput) (hexutil.Uint64, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	input.Preimages = make(map[common.Hash][]byte)
+	a.nextId++
+	a.inputs[a.nextId] = input
+	return hexutil.Uint64(a.nextId), nil
+}
+
+func (a *fakeValidationAPI) AddPreimages(id hexutil.Uint64, preimages map[common.Hash]hexutil.Bytes) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.chunks++
+	for hash, preimage := range preimages {
+		a.inputs[uint64(id)].Preimages[hash] = preimage
+	}
+	return nil
+}
+
+func (a *fakeValidationAPI) Validate(id hexutil.Uint64) (*GoGlobalState, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.fail {
+		return nil, errors.New("worker failure")
+	}
+	if a.invalid {
+		return nil, validationFailedError{errors.New("machine failed")}
+	}
+	a.validated++
+	input := a.inputs[uint64(id)]
+	gsEnd := input.StartState
+	gsEnd.PosInBatch += uint64(len(input.Preimages))
+	return &gsEnd, nil
+}
+
+func startFakeValidationWorker(t *testing.T, fail bool) (*fakeValidationAPI, string) {
+	api := &fakeValidationAPI{fail: fail, inputs: make(map[uint64]*ValidationInput)}
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("validation", api); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(rpcServer)
+	t.Cleanup(server.Close)
+	return api, server.URL
+}
+
+func testValidationInput(numPreimages int, preimageSize int) *ValidationInput {
+	preimages := make(map[common.Hash][]byte)
+	for i := 0; i < numPreimages; i++ {
+		preimage := make([]byte, preimageSize)
+		preimage[0] = byte(i)
+		preimage[1] = byte(i >> 8)
+		preimages[common.BytesToHash(preimage[:2])] = preimage
+	}
+	return &ValidationInput{
+		BlockNumber: 7,
+		StartState:  GoGlobalState{Batch: 3, PosInBatch: 1},
+		Preimages:   preimages,
+	}
+}
+
+func testRemoteValidationConfig(urls ...string) *RemoteValidationConfig {
+	config := DefaultRemoteValidationConfig
+	config.Urls = urls
+	config.Timeout = time.Minute
+	return &config
+}
+
+func TestRemoteValidationChunksPreimages(t *testing.T) {
+	api, url := startFakeValidationWorker(t, false)
+	pool, err := newRemoteValidationPool(testRemoteValidationConfig(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.close()
+
+	input := testValidationInput(10, remoteValidationChunkSize/4)
+	gsEnd, err := pool.Validate(context.Background(), input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gsEnd.PosInBatch != 11 || gsEnd.Batch != 3 {
+		t.Fatal("unexpected end state", gsEnd)
+	}
+	if api.chunks < 3 {
+		t.Fatal("expected preimages to be sent in several chunks, got", api.chunks)
+	}
+}
+
+func TestRemoteValidationRetriesOtherWorkers(t *testing.T) {
+	failing, failingUrl := startFakeValidationWorker(t, true)
+	working, workingUrl := startFakeValidationWorker(t, false)
+	pool, err := newRemoteValidationPool(testRemoteValidationConfig(failingUrl, workingUrl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.close()
+
+	for i := 0; i < 3; i++ {
+		if _, err := pool.Validate(context.Background(), testValidationInput(2, 64)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if working.validated != 3 {
+		t.Fatal("expected all validations to succeed on the working worker, got", working.validated)
+	}
+	// the failing worker is backed off after its first failure
+	if len(failing.inputs) > 1 {
+		t.Fatal("failing worker was retried during its backoff", len(failing.inputs))
+	}
+}
+
+func TestRemoteValidationAllWorkersFail(t *testing.T) {
+	_, url := startFakeValidationWorker(t, true)
+	pool, err := newRemoteValidationPool(testRemoteValidationConfig(url))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.close()
+
+	if _, err := pool.Validate(context.Background(), testValidationInput(1, 64)); err == nil {
+		t.Fatal("expected validation to fail")
+	}
+	// the only worker is now backed off
+	if _, err := pool.Validate(context.Background(), testValidationInput(1, 64)); !errors.Is(err, errNoValidationWorkers) {
+		t.Fatal("expected no workers to be available, got", err)
+	}
+}
+
+func TestRemoteValidationFailureIsNotRetried(t *testing.T) {
+	invalid, invalidUrl := startFakeValidationWorker(t, false)
+	invalid.invalid = true
+	other, otherUrl := startFakeValidationWorker(t, false)
+	pool, err := newRemoteValidationPool(testRemoteValidationConfig(invalidUrl, otherUrl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.close()
+
+	if _, err := pool.Validate(context.Background(), testValidationInput(1, 64)); !isValidationFailure(err) {
+		t.Fatal("expected the validation failure, got", err)
+	}
+	if other.validated != 0 {
+		t.Fatal("validation that failed running the machine was retried on another worker")
+	}
+	// the worker isn't backed off, as it didn't fail itself
+	invalid.mutex.Lock()
+	invalid.invalid = false
+	invalid.mutex.Unlock()
+	if _, err := pool.Validate(context.Background(), testValidationInput(1, 64)); err != nil {
+		t.Fatal(err)
+	}
+	if invalid.validated != 1 {
+		t.Fatal("worker was backed off after a validation failure")
+	}
+}
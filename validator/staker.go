@@ -17,12 +17,21 @@ import (
 	"github.com/tenderly/nitro/go-ethereum/core"
 	"github.com/tenderly/nitro/go-ethereum/core/types"
 	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
 	"github.com/pkg/errors"
 	flag "github.com/spf13/pflag"
 
+	"github.com/tenderly/nitro/arbnode/dataposter"
 	"github.com/tenderly/nitro/util/stopwaiter"
 )
 
+var (
+	stakerLatestStakedNodeGauge = metrics.NewRegisteredGauge("arb/staker/lateststakednode", nil)
+	stakerTransactionsCounter   = metrics.NewRegisteredCounter("arb/staker/transactions", nil)
+	stakerErrorCounter          = metrics.NewRegisteredCounter("arb/staker/errors", nil)
+	stakerChallengesCounter     = metrics.NewRegisteredCounter("arb/staker/challenges", nil)
+)
+
 type StakerStrategy uint8
 
 const (
@@ -52,14 +61,16 @@ func L1PostingStrategyAddOptions(prefix string, f *flag.FlagSet) {
 }
 
 type L1ValidatorConfig struct {
-	Enable             bool              `koanf:"enable"`
-	Strategy           string            `koanf:"strategy"`
-	StakerInterval     time.Duration     `koanf:"staker-interval"`
-	L1PostingStrategy  L1PostingStrategy `koanf:"posting-strategy"`
-	DisableChallenge   bool              `koanf:"disable-challenge"`
-	TargetMachineCount int               `koanf:"target-machine-count"`
-	ConfirmationBlocks int64             `koanf:"confirmation-blocks"`
-	Dangerous          DangerousConfig   `koanf:"dangerous"`
+	Enable             bool                        `koanf:"enable"`
+	Strategy           string                      `koanf:"strategy"`
+	StakerInterval     time.Duration               `koanf:"staker-interval"`
+	L1PostingStrategy  L1PostingStrategy           `koanf:"posting-strategy"`
+	DisableChallenge   bool                        `koanf:"disable-challenge"`
+	TargetMachineCount int                         `koanf:"target-machine-count"`
+	ConfirmationBlocks int64                       `koanf:"confirmation-blocks"`
+	UseDataPoster      bool                        `koanf:"use-data-poster"`
+	DataPoster         dataposter.DataPosterConfig `koanf:"data-poster"`
+	Dangerous          DangerousConfig             `koanf:"dangerous"`
 }
 
 var DefaultL1ValidatorConfig = L1ValidatorConfig{
@@ -70,6 +81,8 @@ var DefaultL1ValidatorConfig = L1ValidatorConfig{
 	DisableChallenge:   false,
 	TargetMachineCount: 4,
 	ConfirmationBlocks: 12,
+	UseDataPoster:      false,
+	DataPoster:         dataposter.DefaultDataPosterConfig,
 	Dangerous:          DangerousConfig{},
 }
 
@@ -81,6 +94,8 @@ func L1ValidatorConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Bool(prefix+".disable-challenge", DefaultL1ValidatorConfig.DisableChallenge, "disable validator challenge")
 	f.Int(prefix+".target-machine-count", DefaultL1ValidatorConfig.TargetMachineCount, "target machine count")
 	f.Int64(prefix+".confirmation-blocks", DefaultL1ValidatorConfig.ConfirmationBlocks, "confirmation blocks")
+	f.Bool(prefix+".use-data-poster", DefaultL1ValidatorConfig.UseDataPoster, "queue staker transactions through a persistent data poster which replaces stuck transactions")
+	dataposter.DataPosterConfigAddOptions(prefix+".data-poster", f)
 	DangerousConfigAddOptions(prefix+".dangerous", f)
 }
 
@@ -168,6 +183,10 @@ func NewStaker(
 }
 
 func (s *Staker) Start(ctxIn context.Context) {
+	dataPoster := s.wallet.DataPoster()
+	if dataPoster != nil {
+		dataPoster.Start(ctxIn)
+	}
 	s.StopWaiter.Start(ctxIn)
 	backoff := time.Second
 	s.CallIteratively(func(ctx context.Context) time.Duration {
@@ -175,8 +194,25 @@ func (s *Staker) Start(ctxIn context.Context) {
 		if err != nil {
 			log.Warn("error updating latest wasm module root", "err", err)
 		}
+		if dataPoster != nil {
+			// Acting again before our last transaction is included would likely duplicate it
+			pending, err := dataPoster.PendingTransactionCount(ctx)
+			if err != nil {
+				log.Warn("error checking for pending staker transactions", "err", err)
+				return s.config.StakerInterval
+			}
+			if pending > 0 {
+				return s.config.StakerInterval
+			}
+		}
 		arbTx, err := s.Act(ctx)
 		if err == nil && arbTx != nil {
+			stakerTransactionsCounter.Inc(1)
+		}
+		if err == nil && arbTx != nil && dataPoster != nil {
+			// The data poster takes care of getting the transaction included
+			log.Info("queued staker transaction", "hash", arbTx.Hash(), "nonce", arbTx.Nonce())
+		} else if err == nil && arbTx != nil {
 			_, err = s.l1Reader.WaitForTxApproval(ctx, arbTx)
 			err = errors.Wrap(err, "error waiting for tx receipt")
 			if err == nil {
@@ -187,6 +223,7 @@ func (s *Staker) Start(ctxIn context.Context) {
 			backoff = time.Second
 			return s.config.StakerInterval
 		}
+		stakerErrorCounter.Inc(1)
 		backoff *= 2
 		if backoff > time.Minute {
 			backoff = time.Minute
@@ -198,6 +235,13 @@ func (s *Staker) Start(ctxIn context.Context) {
 	})
 }
 
+func (s *Staker) StopAndWait() {
+	s.StopWaiter.StopAndWait()
+	if s.wallet.DataPoster() != nil {
+		s.wallet.DataPoster().StopAndWait()
+	}
+}
+
 func (s *Staker) shouldAct(ctx context.Context) bool {
 	var gasPriceHigh = false
 	var gasPriceFloat float64
@@ -275,6 +319,7 @@ func (s *Staker) Act(ctx context.Context) (*types.Transaction, error) {
 	if err != nil {
 		return nil, err
 	}
+	stakerLatestStakedNodeGauge.Update(int64(latestStakedNodeNum))
 	if rawInfo != nil {
 		rawInfo.LatestStakedNode = latestStakedNodeNum
 	}
@@ -618,6 +663,7 @@ func (s *Staker) createConflict(ctx context.Context, info *StakerInfo) error {
 		if err != nil {
 			return err
 		}
+		stakerChallengesCounter.Inc(1)
 		log.Warn("creating challenge", "node1", conflictInfo.Node1, "node2", conflictInfo.Node2, "otherStaker", staker2)
 		_, err = s.rollup.CreateChallenge(
 			s.builder.Auth(ctx),
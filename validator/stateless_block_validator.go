@@ -340,21 +340,8 @@ func SetMachinePreimageResolver(ctx context.Context, mach *ArbitratorMachine, pr
 		preimages = make(map[common.Hash][]byte)
 		recordNewPreimages = false
 	}
-
-	for _, batch := range batchInfo {
-		if len(batch.Data) >= 41 && arbstate.IsDASMessageHeaderByte(batch.Data[40]) {
-			if das == nil {
-				log.Error("No DAS configured, but sequencer message found with DAS header")
-				if bc.Config().ArbitrumChainParams.DataAvailabilityCommittee {
-					return errors.New("processing data availability chain without DAS configured")
-				}
-			} else {
-				_, err := arbstate.RecoverPayloadFromDasBatch(ctx, batch.Data, das, preimages)
-				if err != nil {
-					return err
-				}
-			}
-		}
+	if err := addDasPreimages(ctx, preimages, batchInfo, bc, das); err != nil {
+		return err
 	}
 
 	db := bc.StateCache().TrieDB()
@@ -385,10 +372,27 @@ func SetMachinePreimageResolver(ctx context.Context, mach *ArbitratorMachine, pr
 	})
 }
 
-func (v *StatelessBlockValidator) executeBlock(ctx context.Context, entry *validationEntry, moduleRoot common.Hash) (GoGlobalState, []byte, error) {
-	start := entry.StartPosition
-	gsStart := entry.start()
+// addDasPreimages adds the preimages of the data of any DAS batches to preimages
+func addDasPreimages(ctx context.Context, preimages map[common.Hash][]byte, batchInfo []BatchInfo, bc *core.BlockChain, das arbstate.DataAvailabilityReader) error {
+	for _, batch := range batchInfo {
+		if len(batch.Data) >= 41 && arbstate.IsDASMessageHeaderByte(batch.Data[40]) {
+			if das == nil {
+				log.Error("No DAS configured, but sequencer message found with DAS header")
+				if bc.Config().ArbitrumChainParams.DataAvailabilityCommittee {
+					return errors.New("processing data availability chain without DAS configured")
+				}
+			} else {
+				_, err := arbstate.RecoverPayloadFromDasBatch(ctx, batch.Data, das, preimages)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
 
+func (v *StatelessBlockValidator) executeBlock(ctx context.Context, entry *validationEntry, moduleRoot common.Hash) (GoGlobalState, []byte, error) {
 	basemachine, err := v.MachineLoader.GetMachine(ctx, moduleRoot, true)
 	if err != nil {
 		return GoGlobalState{}, nil, fmt.Errorf("unabled to get WASM machine: %w", err)
@@ -398,18 +402,6 @@ func (v *StatelessBlockValidator) executeBlock(ctx context.Context, entry *valid
 	if err != nil {
 		return GoGlobalState{}, nil, err
 	}
-	err = mach.SetGlobalState(gsStart)
-	if err != nil {
-		log.Error("error while setting global state for proving", "err", err, "gsStart", gsStart)
-		return GoGlobalState{}, nil, errors.New("error while setting global state for proving")
-	}
-	for _, batch := range entry.BatchInfo {
-		err = mach.AddSequencerInboxMessage(batch.Number, batch.Data)
-		if err != nil {
-			log.Error("error while trying to add sequencer msg for proving", "err", err, "seq", start.BatchNumber, "blockNr", entry.BlockNumber)
-			return GoGlobalState{}, nil, errors.New("error while trying to add sequencer msg for proving")
-		}
-	}
 	var delayedMsg []byte
 	if entry.HasDelayedMsg {
 		delayedMsg, err = v.inboxTracker.GetDelayedMessageBytes(entry.DelayedMsgNr)
@@ -417,10 +409,37 @@ func (v *StatelessBlockValidator) executeBlock(ctx context.Context, entry *valid
 			log.Error("error while trying to read delayed msg for proving", "err", err, "seq", entry.DelayedMsgNr, "blockNr", entry.BlockNumber)
 			return GoGlobalState{}, nil, errors.New("error while trying to read delayed msg for proving")
 		}
-		err = mach.AddDelayedInboxMessage(entry.DelayedMsgNr, delayedMsg)
+	}
+	gsEnd, err := runValidationMachine(ctx, mach, moduleRoot, &ValidationInput{
+		BlockNumber:   entry.BlockNumber,
+		StartState:    entry.start(),
+		BatchInfo:     entry.BatchInfo,
+		HasDelayedMsg: entry.HasDelayedMsg,
+		DelayedMsgNr:  entry.DelayedMsgNr,
+		DelayedMsg:    delayedMsg,
+	})
+	return gsEnd, delayedMsg, err
+}
+
+// runValidationMachine runs a machine with its preimage resolver set from the start state of the input to its end
+func runValidationMachine(ctx context.Context, mach *ArbitratorMachine, moduleRoot common.Hash, input *ValidationInput) (GoGlobalState, error) {
+	err := mach.SetGlobalState(input.StartState)
+	if err != nil {
+		log.Error("error while setting global state for proving", "err", err, "gsStart", input.StartState)
+		return GoGlobalState{}, errors.New("error while setting global state for proving")
+	}
+	for _, batch := range input.BatchInfo {
+		err = mach.AddSequencerInboxMessage(batch.Number, batch.Data)
+		if err != nil {
+			log.Error("error while trying to add sequencer msg for proving", "err", err, "seq", input.StartState.Batch, "blockNr", input.BlockNumber)
+			return GoGlobalState{}, errors.New("error while trying to add sequencer msg for proving")
+		}
+	}
+	if input.HasDelayedMsg {
+		err = mach.AddDelayedInboxMessage(input.DelayedMsgNr, input.DelayedMsg)
 		if err != nil {
-			log.Error("error while trying to add delayed msg for proving", "err", err, "seq", entry.DelayedMsgNr, "blockNr", entry.BlockNumber)
-			return GoGlobalState{}, nil, errors.New("error while trying to add delayed msg for proving")
+			log.Error("error while trying to add delayed msg for proving", "err", err, "seq", input.DelayedMsgNr, "blockNr", input.BlockNumber)
+			return GoGlobalState{}, errors.New("error while trying to add delayed msg for proving")
 		}
 	}
 
@@ -429,72 +448,133 @@ func (v *StatelessBlockValidator) executeBlock(ctx context.Context, entry *valid
 		var count uint64 = 500000000
 		err = mach.Step(ctx, count)
 		if steps > 0 {
-			log.Debug("validation", "moduleRoot", moduleRoot, "block", entry.BlockNumber, "steps", steps)
+			log.Debug("validation", "moduleRoot", moduleRoot, "block", input.BlockNumber, "steps", steps)
 		}
 		if err != nil {
-			return GoGlobalState{}, nil, fmt.Errorf("machine execution failed with error: %w", err)
+			return GoGlobalState{}, fmt.Errorf("machine execution failed with error: %w", err)
 		}
 		steps += count
 	}
 	if mach.IsErrored() {
-		log.Error("machine entered errored state during attempted validation", "block", entry.BlockNumber)
-		return GoGlobalState{}, nil, errors.New("machine entered errored state during attempted validation")
+		log.Error("machine entered errored state during attempted validation", "block", input.BlockNumber)
+		return GoGlobalState{}, errors.New("machine entered errored state during attempted validation")
 	}
-	return mach.GetGlobalState(), delayedMsg, nil
+	return mach.GetGlobalState(), nil
 }
 
-func (v *StatelessBlockValidator) ValidateBlock(ctx context.Context, header *types.Header, moduleRoot common.Hash) (bool, error) {
+// validationInput collects everything needed to validate the entry with the module root, so it can be run without the node's state.
+// The entry must have been prepared with its preimages recorded.
+func (v *StatelessBlockValidator) validationInput(ctx context.Context, entry *validationEntry, moduleRoot common.Hash) (*ValidationInput, error) {
+	if entry.Preimages == nil {
+		return nil, errors.New("validation entry has no recorded preimages")
+	}
+	preimages := make(map[common.Hash][]byte, len(entry.Preimages))
+	for hash, preimage := range entry.Preimages {
+		preimages[hash] = preimage
+	}
+	if err := addDasPreimages(ctx, preimages, entry.BatchInfo, v.blockchain, v.daService); err != nil {
+		return nil, err
+	}
+	input := &ValidationInput{
+		BlockNumber:   entry.BlockNumber,
+		ModuleRoot:    moduleRoot,
+		StartState:    entry.start(),
+		BatchInfo:     entry.BatchInfo,
+		HasDelayedMsg: entry.HasDelayedMsg,
+		DelayedMsgNr:  entry.DelayedMsgNr,
+		Preimages:     preimages,
+	}
+	if entry.HasDelayedMsg {
+		delayedMsg, err := v.inboxTracker.GetDelayedMessageBytes(entry.DelayedMsgNr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read delayed message %d: %w", entry.DelayedMsgNr, err)
+		}
+		input.DelayedMsg = delayedMsg
+	}
+	return input, nil
+}
+
+// validationEntryForBlock creates a validation entry for the block with its sequencer message added
+func (v *StatelessBlockValidator) validationEntryForBlock(ctx context.Context, header *types.Header, producePreimages bool) (*validationEntry, error) {
 	if header == nil {
-		return false, errors.New("header not found")
+		return nil, errors.New("header not found")
 	}
 	blockNum := header.Number.Uint64()
 	msgIndex := arbutil.BlockNumberToMessageCount(blockNum, v.genesisBlockNum) - 1
 	prevHeader := v.blockchain.GetHeaderByNumber(blockNum - 1)
 	if prevHeader == nil {
-		return false, errors.New("prev header not found")
+		return nil, errors.New("prev header not found")
 	}
 	msg, err := v.streamer.GetMessage(msgIndex)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	preimages, readBatchInfo, hasDelayedMessage, delayedMsgToRead, err := BlockDataForValidation(ctx, v.blockchain, v.inboxReader, header, prevHeader, msg, false)
+	preimages, readBatchInfo, hasDelayedMessage, delayedMsgToRead, err := BlockDataForValidation(ctx, v.blockchain, v.inboxReader, header, prevHeader, msg, producePreimages)
 	if err != nil {
-		return false, fmt.Errorf("failed to get block data to validate: %w", err)
+		return nil, fmt.Errorf("failed to get block data to validate: %w", err)
 	}
 
 	batchCount, err := v.inboxTracker.GetBatchCount()
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	batch, err := FindBatchContainingMessageIndex(v.inboxTracker, msgIndex, batchCount)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	startPos, endPos, err := GlobalStatePositionsFor(v.inboxTracker, msgIndex, batch)
 	if err != nil {
-		return false, fmt.Errorf("failed calculating position for validation: %w", err)
+		return nil, fmt.Errorf("failed calculating position for validation: %w", err)
 	}
 
 	entry, err := newValidationEntry(prevHeader, header, hasDelayedMessage, delayedMsgToRead, preimages, readBatchInfo)
 	if err != nil {
-		return false, fmt.Errorf("failed to create validation entry %w", err)
+		return nil, fmt.Errorf("failed to create validation entry %w", err)
 	}
 	entry.StartPosition = startPos
 	entry.EndPosition = endPos
 
 	seqMsg, err := v.inboxReader.GetSequencerMessageBytes(ctx, startPos.BatchNumber)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 	entry.BatchInfo = append(entry.BatchInfo, BatchInfo{
 		Number: startPos.BatchNumber,
 		Data:   seqMsg,
 	})
+	return entry, nil
+}
 
+func (v *StatelessBlockValidator) ValidateBlock(ctx context.Context, header *types.Header, moduleRoot common.Hash) (bool, error) {
+	entry, err := v.validationEntryForBlock(ctx, header, false)
+	if err != nil {
+		return false, err
+	}
 	gsEnd, _, err := v.executeBlock(ctx, entry, moduleRoot)
 	if err != nil {
 		return false, err
 	}
 	return gsEnd == entry.expectedEnd(), nil
 }
+
+// ValidationBundle records the block and returns a bundle to replay its validation with the module root
+func (v *StatelessBlockValidator) ValidationBundle(ctx context.Context, header *types.Header, moduleRoot common.Hash) (*ValidationBundle, error) {
+	entry, err := v.validationEntryForBlock(ctx, header, true)
+	if err != nil {
+		return nil, err
+	}
+	return v.validationBundleForEntry(ctx, entry, moduleRoot)
+}
+
+func (v *StatelessBlockValidator) validationBundleForEntry(ctx context.Context, entry *validationEntry, moduleRoot common.Hash) (*ValidationBundle, error) {
+	input, err := v.validationInput(ctx, entry, moduleRoot)
+	if err != nil {
+		return nil, err
+	}
+	return &ValidationBundle{
+		BlockHash:   entry.BlockHash,
+		ExpectedEnd: entry.expectedEnd(),
+		Input:       input,
+	}, nil
+}
@@ -0,0 +1,245 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
+)
+
+// ValidationBundleVersion is the version of the bundle format written by this code.
+// Bundles of other versions are rejected when read.
+const ValidationBundleVersion = 1
+
+const (
+	validationBundleManifestFile  = "manifest.json"
+	validationBundlePreimagesFile = "preimages.bin"
+)
+
+// ValidationBundle is everything needed to replay the validation of a block, and the result it should have
+type ValidationBundle struct {
+	BlockHash   common.Hash
+	ExpectedEnd GoGlobalState
+	Input       *ValidationInput
+}
+
+type ValidationBundleMessage struct {
+	Number uint64 `json:"number"`
+	File   string `json:"file"`
+}
+
+// ValidationBundleManifest describes a bundle and names the files holding its data.
+// The preimages file is a sequence of entries, each a 32 byte hash, the 8 byte little endian
+// length of the preimage, and the preimage itself.
+type ValidationBundleManifest struct {
+	Version          uint64                    `json:"version"`
+	BlockNumber      uint64                    `json:"blockNumber"`
+	BlockHash        common.Hash               `json:"blockHash"`
+	ModuleRoot       common.Hash               `json:"moduleRoot"`
+	StartState       GoGlobalState             `json:"startState"`
+	ExpectedEndState GoGlobalState             `json:"expectedEndState"`
+	Batches          []ValidationBundleMessage `json:"batches"`
+	DelayedMessage   *ValidationBundleMessage  `json:"delayedMessage,omitempty"`
+	PreimagesFile    string                    `json:"preimagesFile"`
+	PreimageCount    uint64                    `json:"preimageCount"`
+}
+
+// ExportedValidationBundle is a bundle as its manifest and the contents of the files it names.
+// It's written to disk as a directory, or returned whole over RPC.
+type ExportedValidationBundle struct {
+	Manifest ValidationBundleManifest `json:"manifest"`
+	Files    map[string]hexutil.Bytes `json:"files"`
+}
+
+func (b *ValidationBundle) Export() *ExportedValidationBundle {
+	input := b.Input
+	files := make(map[string]hexutil.Bytes)
+	manifest := ValidationBundleManifest{
+		Version:          ValidationBundleVersion,
+		BlockNumber:      input.BlockNumber,
+		BlockHash:        b.BlockHash,
+		ModuleRoot:       input.ModuleRoot,
+		StartState:       input.StartState,
+		ExpectedEndState: b.ExpectedEnd,
+		Batches:          []ValidationBundleMessage{},
+		PreimagesFile:    validationBundlePreimagesFile,
+		PreimageCount:    uint64(len(input.Preimages)),
+	}
+	for _, batch := range input.BatchInfo {
+		file := fmt.Sprintf("sequencer_%d.bin", batch.Number)
+		manifest.Batches = append(manifest.Batches, ValidationBundleMessage{Number: batch.Number, File: file})
+		files[file] = batch.Data
+	}
+	if input.HasDelayedMsg {
+		file := fmt.Sprintf("delayed_%d.bin", input.DelayedMsgNr)
+		manifest.DelayedMessage = &ValidationBundleMessage{Number: input.DelayedMsgNr, File: file}
+		files[file] = input.DelayedMsg
+	}
+	var preimages bytes.Buffer
+	for hash, preimage := range input.Preimages {
+		var lenBytes [8]byte
+		binary.LittleEndian.PutUint64(lenBytes[:], uint64(len(preimage)))
+		preimages.Write(hash[:])
+		preimages.Write(lenBytes[:])
+		preimages.Write(preimage)
+	}
+	files[validationBundlePreimagesFile] = preimages.Bytes()
+	return &ExportedValidationBundle{Manifest: manifest, Files: files}
+}
+
+func (e *ExportedValidationBundle) file(name string) ([]byte, error) {
+	data, ok := e.Files[name]
+	if !ok {
+		return nil, fmt.Errorf("validation bundle is missing file %v", name)
+	}
+	return data, nil
+}
+
+// Bundle checks the exported bundle is complete and returns the bundle it describes
+func (e *ExportedValidationBundle) Bundle() (*ValidationBundle, error) {
+	manifest := &e.Manifest
+	if manifest.Version != ValidationBundleVersion {
+		return nil, fmt.Errorf("unsupported validation bundle version %v, expected %v", manifest.Version, ValidationBundleVersion)
+	}
+	input := &ValidationInput{
+		BlockNumber: manifest.BlockNumber,
+		ModuleRoot:  manifest.ModuleRoot,
+		StartState:  manifest.StartState,
+		Preimages:   make(map[common.Hash][]byte),
+	}
+	for _, batch := range manifest.Batches {
+		data, err := e.file(batch.File)
+		if err != nil {
+			return nil, err
+		}
+		input.BatchInfo = append(input.BatchInfo, BatchInfo{Number: batch.Number, Data: data})
+	}
+	if manifest.DelayedMessage != nil {
+		data, err := e.file(manifest.DelayedMessage.File)
+		if err != nil {
+			return nil, err
+		}
+		input.HasDelayedMsg = true
+		input.DelayedMsgNr = manifest.DelayedMessage.Number
+		input.DelayedMsg = data
+	}
+	preimages, err := e.file(manifest.PreimagesFile)
+	if err != nil {
+		return nil, err
+	}
+	reader := bytes.NewReader(preimages)
+	for reader.Len() > 0 {
+		var hash common.Hash
+		var lenBytes [8]byte
+		if _, err := io.ReadFull(reader, hash[:]); err != nil {
+			return nil, fmt.Errorf("truncated preimages file: %w", err)
+		}
+		if _, err := io.ReadFull(reader, lenBytes[:]); err != nil {
+			return nil, fmt.Errorf("truncated preimages file: %w", err)
+		}
+		length := binary.LittleEndian.Uint64(lenBytes[:])
+		if length > uint64(reader.Len()) {
+			return nil, errors.New("truncated preimages file")
+		}
+		preimage := make([]byte, length)
+		if _, err := io.ReadFull(reader, preimage); err != nil {
+			return nil, err
+		}
+		input.Preimages[hash] = preimage
+	}
+	if uint64(len(input.Preimages)) != manifest.PreimageCount {
+		return nil, fmt.Errorf("validation bundle has %v preimages, manifest expects %v", len(input.Preimages), manifest.PreimageCount)
+	}
+	return &ValidationBundle{
+		BlockHash:   manifest.BlockHash,
+		ExpectedEnd: manifest.ExpectedEndState,
+		Input:       input,
+	}, nil
+}
+
+// WriteDir writes the manifest and files of the bundle into the directory, creating it if needed
+func (e *ExportedValidationBundle) WriteDir(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	manifest, err := json.MarshalIndent(&e.Manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, validationBundleManifestFile), manifest, 0644); err != nil { //nolint:gosec
+		return err
+	}
+	for name, data := range e.Files {
+		if filepath.Base(name) != name {
+			return fmt.Errorf("invalid validation bundle file name %v", name)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil { //nolint:gosec
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadValidationBundle reads a bundle from either a directory written by WriteDir,
+// or a JSON file holding an exported bundle as returned over RPC
+func ReadValidationBundle(path string) (*ExportedValidationBundle, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var exported ExportedValidationBundle
+		if err := json.Unmarshal(data, &exported); err != nil {
+			return nil, fmt.Errorf("failed to parse validation bundle %v: %w", path, err)
+		}
+		return &exported, nil
+	}
+
+	manifestData, err := os.ReadFile(filepath.Join(path, validationBundleManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	exported := &ExportedValidationBundle{Files: make(map[string]hexutil.Bytes)}
+	if err := json.Unmarshal(manifestData, &exported.Manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse validation bundle manifest: %w", err)
+	}
+	names := []string{exported.Manifest.PreimagesFile}
+	for _, batch := range exported.Manifest.Batches {
+		names = append(names, batch.File)
+	}
+	if exported.Manifest.DelayedMessage != nil {
+		names = append(names, exported.Manifest.DelayedMessage.File)
+	}
+	for _, name := range names {
+		if filepath.Base(name) != name {
+			return nil, fmt.Errorf("invalid validation bundle file name %v", name)
+		}
+		data, err := os.ReadFile(filepath.Join(path, name))
+		if err != nil {
+			return nil, err
+		}
+		exported.Files[name] = data
+	}
+	return exported, nil
+}
+
+// ReplayValidationBundle runs the bundle's input through the machine for its module root, returning the end state
+func ReplayValidationBundle(ctx context.Context, machineLoader *NitroMachineLoader, bundle *ValidationBundle) (GoGlobalState, error) {
+	return ValidateStateless(ctx, machineLoader, bundle.Input)
+}
@@ -0,0 +1,112 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/crypto"
+)
+
+func testValidationBundle() *ValidationBundle {
+	preimages := make(map[common.Hash][]byte)
+	for i := 0; i < 5; i++ {
+		preimage := bytes.Repeat([]byte{byte(i)}, 100*i)
+		preimages[crypto.Keccak256Hash(preimage)] = preimage
+	}
+	return &ValidationBundle{
+		BlockHash:   common.HexToHash("0x1234"),
+		ExpectedEnd: GoGlobalState{BlockHash: common.HexToHash("0x1234"), SendRoot: common.HexToHash("0x56"), Batch: 4, PosInBatch: 0},
+		Input: &ValidationInput{
+			BlockNumber: 20,
+			ModuleRoot:  common.HexToHash("0xabcd"),
+			StartState:  GoGlobalState{BlockHash: common.HexToHash("0x1111"), Batch: 3, PosInBatch: 2},
+			BatchInfo: []BatchInfo{
+				{Number: 2, Data: []byte("second batch")},
+				{Number: 3, Data: []byte("third batch")},
+			},
+			HasDelayedMsg: true,
+			DelayedMsgNr:  9,
+			DelayedMsg:    []byte("delayed"),
+			Preimages:     preimages,
+		},
+	}
+}
+
+func TestValidationBundleDirRoundTrip(t *testing.T) {
+	bundle := testValidationBundle()
+	dir := filepath.Join(t.TempDir(), "bundle")
+	if err := bundle.Export().WriteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ReadValidationBundle(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := exported.Bundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bundle, read) {
+		t.Fatal("bundle changed writing and reading it", bundle, read)
+	}
+}
+
+func TestValidationBundleJsonRoundTrip(t *testing.T) {
+	bundle := testValidationBundle()
+	data, err := json.Marshal(bundle.Export())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "bundle.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	exported, err := ReadValidationBundle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, err := exported.Bundle()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bundle, read) {
+		t.Fatal("bundle changed encoding and decoding it", bundle, read)
+	}
+}
+
+func TestValidationBundleRejectsBadBundles(t *testing.T) {
+	exported := testValidationBundle().Export()
+	exported.Manifest.Version = ValidationBundleVersion + 1
+	if _, err := exported.Bundle(); err == nil {
+		t.Fatal("expected bundle of unknown version to be rejected")
+	}
+
+	exported = testValidationBundle().Export()
+	preimages := exported.Files[exported.Manifest.PreimagesFile]
+	exported.Files[exported.Manifest.PreimagesFile] = preimages[:len(preimages)-1]
+	if _, err := exported.Bundle(); err == nil {
+		t.Fatal("expected bundle with truncated preimages to be rejected")
+	}
+
+	exported = testValidationBundle().Export()
+	delete(exported.Files, exported.Manifest.Batches[0].File)
+	if _, err := exported.Bundle(); err == nil {
+		t.Fatal("expected bundle missing a batch to be rejected")
+	}
+
+	// the preimage count isn't trusted to allocate for
+	exported = testValidationBundle().Export()
+	exported.Manifest.PreimageCount = math.MaxUint64
+	if _, err := exported.Bundle(); err == nil {
+		t.Fatal("expected bundle with the wrong preimage count to be rejected")
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2022, Offchain Labs, Inc.
+// For license information, see https://github.com/nitro/blob/master/LICENSE
+
+package validator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/tenderly/nitro/go-ethereum/common"
+	"github.com/tenderly/nitro/go-ethereum/common/hexutil"
+	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/rpc"
+
+	"github.com/tenderly/nitro/cmd/genericconf"
+)
+
+// ValidationInput is everything needed to run a block through the prover,
+// so it can be validated without access to the node's state.
+type ValidationInput struct {
+	BlockNumber   uint64
+	ModuleRoot    common.Hash
+	StartState    GoGlobalState
+	BatchInfo     []BatchInfo
+	HasDelayedMsg bool
+	DelayedMsgNr  uint64
+	DelayedMsg    []byte
+	Preimages     map[common.Hash][]byte
+}
+
+var errMachineUnavailable = errors.New("unabled to get WASM machine")
+
+// ValidateStateless runs the machine for the input's module root, resolving preimages only from the input,
+// and returns its end state.
+func ValidateStateless(ctx context.Context, machineLoader *NitroMachineLoader, input *ValidationInput) (GoGlobalState, error) {
+	basemachine, err := machineLoader.GetMachine(ctx, input.ModuleRoot, true)
+	if err != nil {
+		return GoGlobalState{}, fmt.Errorf("%w: %v", errMachineUnavailable, err)
+	}
+	mach := basemachine.Clone()
+	err = mach.SetPreimageResolver(func(hash common.Hash) ([]byte, error) {
+		if preimage, ok := input.Preimages[hash]; ok {
+			return preimage, nil
+		}
+		return nil, fmt.Errorf("preimage %v not in validation input", hash)
+	})
+	if err != nil {
+		return GoGlobalState{}, err
+	}
+	return runValidationMachine(ctx, mach, input.ModuleRoot, input)
+}
+
+// ValidationWorkerServerTimeoutsDefault are geth's server timeouts, except for the write timeout.
+// The response to validation_validate is only written once the validation is done, so the write timeout
+// has to outlast the nodes' remote validation timeout.
+var ValidationWorkerServerTimeoutsDefault = genericconf.HTTPServerTimeoutConfig{
+	ReadTimeout:       genericconf.HTTPServerTimeoutConfigDefault.ReadTimeout,
+	ReadHeaderTimeout: genericconf.HTTPServerTimeoutConfigDefault.ReadHeaderTimeout,
+	WriteTimeout:      DefaultRemoteValidationConfig.Timeout + 10*time.Minute,
+	IdleTimeout:       genericconf.HTTPServerTimeoutConfigDefault.IdleTimeout,
+}
+
+// validationFailedCode is the error code of validations that failed running the machine,
+// rather than failing for lack of the worker, so they'd fail on any worker
+const validationFailedCode = -32020
+
+type validationFailedError struct {
+	error
+}
+
+func (e validationFailedError) ErrorCode() int {
+	return validationFailedCode
+}
+
+const (
+	validationSessionExpiry = 10 * time.Minute
+	maxValidationSessions   = 64
+)
+
+// validationSession is a validation whose input is being uploaded
+type validationSession struct {
+	input       *ValidationInput
+	lastUpdated time.Time
+}
+
+// ValidationWorker runs validations sent by nodes. It's stateless apart from the inputs being uploaded.
+type ValidationWorker struct {
+	machineLoader *NitroMachineLoader
+	runs          chan struct{} // limits the concurrent validation runs
+
+	mutex    sync.Mutex
+	sessions map[uint64]*validationSession
+	nextId   uint64
+}
+
+func NewValidationWorker(machineLoader *NitroMachineLoader, concurrentRuns int) *ValidationWorker {
+	return &ValidationWorker{
+		machineLoader: machineLoader,
+		runs:          make(chan struct{}, concurrentRuns),
+		sessions:      make(map[uint64]*validationSession),
+	}
+}
+
+// ValidationWorkerAPI is served in the "validation" namespace.
+// A validation is started with its input without preimages, which are then sent in chunks, as the input can
+// be larger than an RPC request allows.
+type ValidationWorkerAPI struct {
+	worker *ValidationWorker
+}
+
+// Start begins a validation, returning the id to send its preimages and run it with
+func (a *ValidationWorkerAPI) Start(ctx context.Context, input *ValidationInput) (hexutil.Uint64, error) {
+	w := a.worker
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	now := time.Now()
+	for id, session := range w.sessions {
+		if now.Sub(session.lastUpdated) > validationSessionExpiry {
+			delete(w.sessions, id)
+		}
+	}
+	if len(w.sessions) >= maxValidationSessions {
+		return 0, errors.New("too many validations in progress")
+	}
+	if input.Preimages == nil {
+		input.Preimages = make(map[common.Hash][]byte)
+	}
+	w.nextId++
+	w.sessions[w.nextId] = &validationSession{input: input, lastUpdated: now}
+	return hexutil.Uint64(w.nextId), nil
+}
+
+// AddPreimages adds preimages to the input of a started validation
+func (a *ValidationWorkerAPI) AddPreimages(ctx context.Context, id hexutil.Uint64, preimages map[common.Hash]hexutil.Bytes) error {
+	w := a.worker
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	session, ok := w.sessions[uint64(id)]
+	if !ok {
+		return fmt.Errorf("unknown or expired validation %d", id)
+	}
+	for hash, preimage := range preimages {
+		session.input.Preimages[hash] = preimage
+	}
+	session.lastUpdated = time.Now()
+	return nil
+}
+
+// Validate runs a started validation once a run is available, returning the end state of the machine
+func (a *ValidationWorkerAPI) Validate(ctx context.Context, id hexutil.Uint64) (*GoGlobalState, error) {
+	w := a.worker
+	w.mutex.Lock()
+	session, ok := w.sessions[uint64(id)]
+	delete(w.sessions, uint64(id))
+	w.mutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown or expired validation %d", id)
+	}
+
+	select {
+	case w.runs <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-w.runs }()
+
+	input := session.input
+	start := time.Now()
+	gsEnd, err := ValidateStateless(ctx, w.machineLoader, input)
+	if err != nil {
+		log.Warn("validation failed", "blockNr", input.BlockNumber, "moduleRoot", input.ModuleRoot, "err", err)
+		if ctx.Err() == nil && !errors.Is(err, errMachineUnavailable) {
+			return nil, validationFailedError{err}
+		}
+		return nil, err
+	}
+	log.Info("validation done", "blockNr", input.BlockNumber, "moduleRoot", input.ModuleRoot, "time", time.Since(start))
+	return &gsEnd, nil
+}
+
+func StartValidationWorkerServer(ctx context.Context, addr string, portNum uint64, rpcServerTimeouts genericconf.HTTPServerTimeoutConfig, worker *ValidationWorker) (*http.Server, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("%s:%d", addr, portNum))
+	if err != nil {
+		return nil, err
+	}
+	return StartValidationWorkerServerOnListener(ctx, listener, rpcServerTimeouts, worker)
+}
+
+func StartValidationWorkerServerOnListener(ctx context.Context, listener net.Listener, rpcServerTimeouts genericconf.HTTPServerTimeoutConfig, worker *ValidationWorker) (*http.Server, error) {
+	rpcServer := rpc.NewServer()
+	err := rpcServer.RegisterName("validation", &ValidationWorkerAPI{worker: worker})
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{
+		Handler:           rpcServer,
+		ReadTimeout:       rpcServerTimeouts.ReadTimeout,
+		ReadHeaderTimeout: rpcServerTimeouts.ReadHeaderTimeout,
+		WriteTimeout:      rpcServerTimeouts.WriteTimeout,
+		IdleTimeout:       rpcServerTimeouts.IdleTimeout,
+	}
+
+	go func() {
+		err := srv.Serve(listener)
+		if err != nil {
+			return
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+	return srv, nil
+}
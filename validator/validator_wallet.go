@@ -7,6 +7,7 @@ import (
 	"context"
 	"math/big"
 	"strings"
+	"time"
 
 	"github.com/tenderly/nitro/go-ethereum"
 	"github.com/tenderly/nitro/go-ethereum/accounts/abi"
@@ -14,6 +15,7 @@ import (
 	"github.com/tenderly/nitro/go-ethereum/common"
 	"github.com/tenderly/nitro/go-ethereum/core/types"
 	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/arbnode/dataposter"
 	"github.com/tenderly/nitro/solgen/go/rollupgen"
 	"github.com/pkg/errors"
 )
@@ -44,9 +46,12 @@ type ValidatorWallet struct {
 	rollupAddress     common.Address
 	walletFactoryAddr common.Address
 	rollupFromBlock   int64
+	dataPoster        *dataposter.DataPoster[struct{}]
 }
 
-func NewValidatorWallet(address *common.Address, walletFactoryAddr, rollupAddress common.Address, l1Reader L1ReaderInterface, auth *bind.TransactOpts, rollupFromBlock int64, onWalletCreated func(common.Address)) (*ValidatorWallet, error) {
+// If dataPoster is non-nil, wallet transactions are queued through it rather than sent directly,
+// so that they're tracked across restarts and replaced if they get stuck.
+func NewValidatorWallet(address *common.Address, walletFactoryAddr, rollupAddress common.Address, l1Reader L1ReaderInterface, auth *bind.TransactOpts, rollupFromBlock int64, onWalletCreated func(common.Address), dataPoster *dataposter.DataPoster[struct{}]) (*ValidatorWallet, error) {
 	var con *rollupgen.ValidatorWallet
 	if address != nil {
 		var err error
@@ -64,6 +69,7 @@ func NewValidatorWallet(address *common.Address, walletFactoryAddr, rollupAddres
 		rollupAddress:     rollupAddress,
 		walletFactoryAddr: walletFactoryAddr,
 		rollupFromBlock:   rollupFromBlock,
+		dataPoster:        dataPoster,
 	}, nil
 }
 
@@ -83,12 +89,45 @@ func (v *ValidatorWallet) RollupAddress() common.Address {
 	return v.rollupAddress
 }
 
-func (v *ValidatorWallet) executeTransaction(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
-	oldAuthValue := v.auth.Value
-	v.auth.Value = tx.Value()
-	defer (func() { v.auth.Value = oldAuthValue })()
+// May be nil if wallet transactions are sent directly
+func (v *ValidatorWallet) DataPoster() *dataposter.DataPoster[struct{}] {
+	return v.dataPoster
+}
+
+// getAuth returns the auth to build a wallet transaction with.
+// If using a data poster, the transaction is built but not sent, and must be passed to postTransaction.
+func (v *ValidatorWallet) getAuth(ctx context.Context, value *big.Int) (*bind.TransactOpts, error) {
+	auth := *v.auth
+	auth.Context = ctx
+	auth.Value = value
+	if v.dataPoster != nil {
+		nonce, _, err := v.dataPoster.GetNextNonceAndMeta(ctx)
+		if err != nil {
+			return nil, err
+		}
+		auth.Nonce = new(big.Int).SetUint64(nonce)
+		auth.NoSend = true
+	}
+	return &auth, nil
+}
 
-	return v.con.ExecuteTransaction(v.auth, tx.Data(), *tx.To(), tx.Value())
+func (v *ValidatorWallet) postTransaction(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	if v.dataPoster == nil {
+		return tx, nil
+	}
+	return v.dataPoster.PostTransaction(ctx, time.Now(), tx.Nonce(), struct{}{}, *tx.To(), tx.Data(), tx.Gas(), tx.Value())
+}
+
+func (v *ValidatorWallet) executeTransaction(ctx context.Context, tx *types.Transaction) (*types.Transaction, error) {
+	auth, err := v.getAuth(ctx, tx.Value())
+	if err != nil {
+		return nil, err
+	}
+	arbTx, err := v.con.ExecuteTransaction(auth, tx.Data(), *tx.To(), tx.Value())
+	if err != nil {
+		return nil, err
+	}
+	return v.postTransaction(ctx, arbTx)
 }
 
 func (v *ValidatorWallet) createWalletIfNeeded(ctx context.Context) error {
@@ -166,14 +205,19 @@ func (v *ValidatorWallet) ExecuteTransactions(ctx context.Context, builder *Vali
 		return nil, err
 	}
 
-	oldAuthValue := v.auth.Value
-	v.auth.Value = new(big.Int).Sub(totalAmount, balanceInContract)
-	if v.auth.Value.Sign() < 0 {
-		v.auth.Value.SetInt64(0)
+	callValue := new(big.Int).Sub(totalAmount, balanceInContract)
+	if callValue.Sign() < 0 {
+		callValue.SetInt64(0)
 	}
-	defer (func() { v.auth.Value = oldAuthValue })()
-
-	arbTx, err := v.con.ExecuteTransactions(v.auth, data, dest, amount)
+	auth, err := v.getAuth(ctx, callValue)
+	if err != nil {
+		return nil, err
+	}
+	arbTx, err := v.con.ExecuteTransactions(auth, data, dest, amount)
+	if err != nil {
+		return nil, err
+	}
+	arbTx, err = v.postTransaction(ctx, arbTx)
 	if err != nil {
 		return nil, err
 	}
@@ -182,7 +226,15 @@ func (v *ValidatorWallet) ExecuteTransactions(ctx context.Context, builder *Vali
 }
 
 func (v *ValidatorWallet) TimeoutChallenges(ctx context.Context, manager common.Address, challenges []uint64) (*types.Transaction, error) {
-	return v.con.TimeoutChallenges(v.auth, manager, challenges)
+	auth, err := v.getAuth(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	arbTx, err := v.con.TimeoutChallenges(auth, manager, challenges)
+	if err != nil {
+		return nil, err
+	}
+	return v.postTransaction(ctx, arbTx)
 }
 
 func CreateValidatorWallet(
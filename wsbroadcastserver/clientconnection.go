@@ -5,7 +5,6 @@ package wsbroadcastserver
 
 import (
 	"context"
-	"encoding/json"
 	"math/rand"
 	"net"
 	"strconv"
@@ -14,8 +13,8 @@ import (
 	"time"
 
 	"github.com/gobwas/ws"
-	"github.com/gobwas/ws/wsutil"
 	"github.com/mailru/easygo/netpoll"
+	"github.com/tenderly/nitro/arbutil"
 	"github.com/tenderly/nitro/util/stopwaiter"
 )
 
@@ -32,19 +31,31 @@ type ClientConnection struct {
 
 	lastHeardUnix int64
 	out           chan []byte
+
+	// zero if the client didn't request a starting sequence number
+	requestedSeqNum arbutil.MessageIndex
+	// true if the client negotiated per message deflate compression
+	compression bool
 }
 
-func NewClientConnection(conn net.Conn, desc *netpoll.Desc, clientManager *ClientManager) *ClientConnection {
+func NewClientConnection(conn net.Conn, desc *netpoll.Desc, clientManager *ClientManager, requestedSeqNum arbutil.MessageIndex, compression bool) *ClientConnection {
 	return &ClientConnection{
-		conn:          conn,
-		desc:          desc,
-		Name:          conn.RemoteAddr().String() + strconv.Itoa(rand.Intn(10)),
-		clientManager: clientManager,
-		lastHeardUnix: time.Now().Unix(),
-		out:           make(chan []byte, clientManager.settings.MaxSendQueue),
+		conn:            conn,
+		desc:            desc,
+		Name:            conn.RemoteAddr().String() + strconv.Itoa(rand.Intn(10)),
+		clientManager:   clientManager,
+		lastHeardUnix:   time.Now().Unix(),
+		out:             make(chan []byte, clientManager.settings.MaxSendQueue),
+		requestedSeqNum: requestedSeqNum,
+		compression:     compression,
 	}
 }
 
+// RequestedSeqNum is the sequence number the client asked catch-up to start at
+func (cc *ClientConnection) RequestedSeqNum() arbutil.MessageIndex {
+	return cc.requestedSeqNum
+}
+
 func (cc *ClientConnection) Start(parentCtx context.Context) {
 	cc.StopWaiter.Start(parentCtx)
 	cc.LaunchThread(func(ctx context.Context) {
@@ -103,21 +114,18 @@ func (cc *ClientConnection) readRequest(ctx context.Context, timeout time.Durati
 
 	atomic.StoreInt64(&cc.lastHeardUnix, time.Now().Unix())
 
-	return ReadData(ctx, cc.conn, nil, timeout, ws.StateServerSide)
+	return ReadData(ctx, cc.conn, nil, timeout, ws.StateServerSide, cc.compression)
 }
 
 func (cc *ClientConnection) Write(x interface{}) error {
-	writer := wsutil.NewWriter(cc.conn, ws.StateServerSide, ws.OpText)
-	encoder := json.NewEncoder(writer)
-
-	cc.ioMutex.Lock()
-	defer cc.ioMutex.Unlock()
-
-	if err := encoder.Encode(x); err != nil {
+	notCompressed, compressed, err := serializeMessage(x, !cc.compression, cc.compression)
+	if err != nil {
 		return err
 	}
-
-	return writer.Flush()
+	if cc.compression {
+		return cc.writeRaw(compressed)
+	}
+	return cc.writeRaw(notCompressed)
 }
 
 func (cc *ClientConnection) writeRaw(p []byte) error {
@@ -5,22 +5,37 @@ package wsbroadcastserver
 
 import (
 	"bytes"
+	"compress/flate"
 	"context"
 	"encoding/json"
+	"io"
 	"net"
 	"sync/atomic"
 	"time"
 
 	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/go-ethereum/metrics"
+	"github.com/tenderly/nitro/arbutil"
 	"github.com/tenderly/nitro/util/stopwaiter"
 	"github.com/pkg/errors"
 
 	"github.com/gobwas/ws"
 	"github.com/gobwas/ws-examples/src/gopool"
-	"github.com/gobwas/ws/wsutil"
+	"github.com/gobwas/ws/wsflate"
 	"github.com/mailru/easygo/netpoll"
 )
 
+var (
+	clientsGauge            = metrics.NewRegisteredGauge("arb/feed/server/clients", nil)
+	clientsConnectedCounter = metrics.NewRegisteredCounter("arb/feed/server/clients/connected", nil)
+	clientsSlowCounter      = metrics.NewRegisteredCounter("arb/feed/server/clients/disconnected/slow", nil)
+	clientsTimedOutCounter  = metrics.NewRegisteredCounter("arb/feed/server/clients/disconnected/timeout", nil)
+	broadcastsCounter       = metrics.NewRegisteredCounter("arb/feed/server/broadcasts", nil)
+	broadcastBytesCounter   = metrics.NewRegisteredCounter("arb/feed/server/broadcasts/bytes", nil)
+	sendQueueHistogram      = metrics.NewRegisteredHistogram("arb/feed/server/sendqueue", nil, metrics.NewExpDecaySample(1028, 0.015))
+	sendQueueMaxGauge       = metrics.NewRegisteredGauge("arb/feed/server/sendqueue/max", nil)
+)
+
 /* Protocol-specific client catch-up logic can be injected using this interface. */
 type CatchupBuffer interface {
 	OnRegisterClient(context.Context, *ClientConnection) error
@@ -66,15 +81,16 @@ func (cm *ClientManager) registerClient(ctx context.Context, clientConnection *C
 
 	clientConnection.Start(ctx)
 	cm.clientPtrMap[clientConnection] = true
-	atomic.AddInt32(&cm.clientCount, 1)
+	clientsGauge.Update(int64(atomic.AddInt32(&cm.clientCount, 1)))
+	clientsConnectedCounter.Inc(1)
 
 	return nil
 }
 
 // Register registers new connection as a Client.
-func (cm *ClientManager) Register(conn net.Conn, desc *netpoll.Desc) *ClientConnection {
+func (cm *ClientManager) Register(conn net.Conn, desc *netpoll.Desc, requestedSeqNum arbutil.MessageIndex, compression bool) *ClientConnection {
 	createClient := ClientConnectionAction{
-		NewClientConnection(conn, desc, cm),
+		NewClientConnection(conn, desc, cm, requestedSeqNum, compression),
 		true,
 	}
 
@@ -104,7 +120,7 @@ func (cm *ClientManager) removeClientImpl(clientConnection *ClientConnection) {
 		log.Warn("Failed to close client connection", "err", err)
 	}
 
-	atomic.AddInt32(&cm.clientCount, -1)
+	clientsGauge.Update(int64(atomic.AddInt32(&cm.clientCount, -1)))
 }
 
 func (cm *ClientManager) removeClient(clientConnection *ClientConnection) {
@@ -128,6 +144,47 @@ func (cm *ClientManager) ClientCount() int32 {
 	return atomic.LoadInt32(&cm.clientCount)
 }
 
+// serializeMessage encodes m as a websocket text frame, without compression and/or with
+// per message deflate compression, depending on which are requested
+func serializeMessage(m interface{}, enableNotCompressedOutput, enableCompressedOutput bool) ([]byte, []byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to encode message")
+	}
+	// keep the trailing newline json.Encoder used to write
+	data = append(data, '\n')
+
+	var notCompressed, compressed bytes.Buffer
+	if enableNotCompressedOutput {
+		if err := ws.WriteFrame(&notCompressed, ws.NewTextFrame(data)); err != nil {
+			return nil, nil, errors.Wrap(err, "unable to write message")
+		}
+	}
+	if enableCompressedOutput {
+		var payload bytes.Buffer
+		// Close would end the deflate stream with a final block, which permessage-deflate forbids
+		flateWriter := wsflate.NewWriter(&payload, func(w io.Writer) wsflate.Compressor {
+			f, _ := flate.NewWriter(w, flate.BestCompression)
+			return f
+		})
+		if _, err := flateWriter.Write(data); err != nil {
+			return nil, nil, errors.Wrap(err, "unable to compress message")
+		}
+		if err := flateWriter.Flush(); err != nil {
+			return nil, nil, errors.Wrap(err, "unable to compress message")
+		}
+		frame := ws.NewTextFrame(payload.Bytes())
+		frame.Header, err = wsflate.SetBit(frame.Header)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to compress message")
+		}
+		if err := ws.WriteFrame(&compressed, frame); err != nil {
+			return nil, nil, errors.Wrap(err, "unable to write compressed message")
+		}
+	}
+	return notCompressed.Bytes(), compressed.Bytes(), nil
+}
+
 // Broadcast sends batch item to all clients.
 func (cm *ClientManager) Broadcast(bm interface{}) {
 	cm.broadcastChan <- bm
@@ -138,26 +195,43 @@ func (cm *ClientManager) doBroadcast(bm interface{}) ([]*ClientConnection, error
 		return nil, err
 	}
 
-	var buf bytes.Buffer
-	writer := wsutil.NewWriter(&buf, ws.StateServerSide, ws.OpText)
-	encoder := json.NewEncoder(writer)
-	if err := encoder.Encode(bm); err != nil {
-		return nil, errors.Wrap(err, "unable to encode message")
+	// The message is serialized once for each encoding in use, and shared between clients
+	var sendNotCompressed, sendCompressed bool
+	for client := range cm.clientPtrMap {
+		if client.compression {
+			sendCompressed = true
+		} else {
+			sendNotCompressed = true
+		}
 	}
-	if err := writer.Flush(); err != nil {
-		return nil, errors.Wrap(err, "unable to flush message")
+	notCompressed, compressed, err := serializeMessage(bm, sendNotCompressed, sendCompressed)
+	if err != nil {
+		return nil, err
 	}
 
+	broadcastsCounter.Inc(1)
 	clientDeleteList := make([]*ClientConnection, 0, len(cm.clientPtrMap))
+	maxSendQueue := 0
 	for client := range cm.clientPtrMap {
-		if len(client.out) == cm.settings.MaxSendQueue {
+		queued := len(client.out)
+		sendQueueHistogram.Update(int64(queued))
+		if queued > maxSendQueue {
+			maxSendQueue = queued
+		}
+		if queued == cm.settings.MaxSendQueue {
 			// Queue for client too backed up, disconnect instead of blocking on channel send
-			log.Info("disconnecting because send queue too large", "client", client.Name, "size", len(client.out))
+			log.Info("disconnecting because send queue too large", "client", client.Name, "size", queued)
+			clientsSlowCounter.Inc(1)
 			clientDeleteList = append(clientDeleteList, client)
+		} else if client.compression {
+			client.out <- compressed
+			broadcastBytesCounter.Inc(int64(len(compressed)))
 		} else {
-			client.out <- buf.Bytes()
+			client.out <- notCompressed
+			broadcastBytesCounter.Inc(int64(len(notCompressed)))
 		}
 	}
+	sendQueueMaxGauge.Update(int64(maxSendQueue))
 
 	return clientDeleteList, nil
 }
@@ -175,6 +249,7 @@ func (cm *ClientManager) verifyClients() []*ClientConnection {
 		diff := time.Since(client.GetLastHeard())
 		if diff > cm.settings.ClientTimeout {
 			log.Info("disconnecting because connection timed out", "client", client.Name)
+			clientsTimedOutCounter.Inc(1)
 			clientDeleteList = append(clientDeleteList, client)
 		} else {
 			err := client.Ping()
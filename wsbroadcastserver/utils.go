@@ -11,9 +11,11 @@ import (
 	"net"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/tenderly/nitro/go-ethereum/log"
 	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
 	"github.com/gobwas/ws/wsutil"
 )
 
@@ -63,15 +65,20 @@ func (cr *chainedReader) add(r io.Reader) *chainedReader {
 	return cr
 }
 
-func ReadData(ctx context.Context, conn net.Conn, earlyFrameData io.Reader, idleTimeout time.Duration, state ws.State) ([]byte, ws.OpCode, error) {
+func ReadData(ctx context.Context, conn net.Conn, earlyFrameData io.Reader, idleTimeout time.Duration, state ws.State, compression bool) ([]byte, ws.OpCode, error) {
 
+	if compression {
+		state |= ws.StateExtended
+	}
 	controlHandler := wsutil.ControlFrameHandler(conn, state)
+	var msg wsflate.MessageState
 	reader := wsutil.Reader{
 		Source:          (&chainedReader{}).add(earlyFrameData).add(conn),
 		State:           state,
-		CheckUTF8:       true,
+		CheckUTF8:       !compression,
 		SkipHeaderCheck: false,
 		OnIntermediate:  controlHandler,
+		Extensions:      []wsutil.RecvExtension{&msg},
 	}
 
 	// Remove timeout when leaving this function
@@ -119,6 +126,21 @@ func ReadData(ctx context.Context, conn net.Conn, earlyFrameData io.Reader, idle
 			continue
 		}
 
+		if msg.IsCompressed() {
+			if !compression {
+				return nil, 0, errors.New("received compressed frame even though compression wasn't negotiated")
+			}
+			flateReader := wsflate.NewReader(&reader, wsflate.DefaultHelper.Decompressor)
+			data, err := ioutil.ReadAll(flateReader)
+			if err != nil {
+				return nil, 0, err
+			}
+			if header.OpCode == ws.OpText && !utf8.Valid(data) {
+				return nil, 0, wsutil.ErrInvalidUTF8
+			}
+			return data, header.OpCode, nil
+		}
+
 		data, err := ioutil.ReadAll(&reader)
 
 		return data, header.OpCode, err
@@ -8,27 +8,37 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/tenderly/nitro/go-ethereum/log"
+	"github.com/tenderly/nitro/arbutil"
 	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
 	"github.com/gobwas/ws-examples/src/gopool"
 	"github.com/mailru/easygo/netpoll"
 	flag "github.com/spf13/pflag"
 )
 
+// HTTPHeaderRequestedSequenceNumber is set by feed clients during the websocket upgrade
+// to request that catch-up starts at the given sequence number
+const HTTPHeaderRequestedSequenceNumber = "Arbitrum-Requested-Sequence-Number"
+
 type BroadcasterConfig struct {
-	Enable        bool          `koanf:"enable"`
-	Addr          string        `koanf:"addr"`
-	IOTimeout     time.Duration `koanf:"io-timeout"`
-	Port          string        `koanf:"port"`
-	Ping          time.Duration `koanf:"ping"`
-	ClientTimeout time.Duration `koanf:"client-timeout"`
-	Queue         int           `koanf:"queue"`
-	Workers       int           `koanf:"workers"`
-	MaxSendQueue  int           `koanf:"max-send-queue"`
+	Enable            bool          `koanf:"enable"`
+	Addr              string        `koanf:"addr"`
+	IOTimeout         time.Duration `koanf:"io-timeout"`
+	Port              string        `koanf:"port"`
+	Ping              time.Duration `koanf:"ping"`
+	ClientTimeout     time.Duration `koanf:"client-timeout"`
+	Queue             int           `koanf:"queue"`
+	Workers           int           `koanf:"workers"`
+	MaxSendQueue      int           `koanf:"max-send-queue"`
+	Signed            bool          `koanf:"signed"`
+	EnableCompression bool          `koanf:"enable-compression"`
 }
 
 func BroadcasterConfigAddOptions(prefix string, f *flag.FlagSet) {
@@ -41,30 +51,36 @@ func BroadcasterConfigAddOptions(prefix string, f *flag.FlagSet) {
 	f.Int(prefix+".queue", DefaultBroadcasterConfig.Queue, "queue size")
 	f.Int(prefix+".workers", DefaultBroadcasterConfig.Workers, "number of threads to reserve for HTTP to WS upgrade")
 	f.Int(prefix+".max-send-queue", DefaultBroadcasterConfig.MaxSendQueue, "maximum number of messages allowed to accumulate before client is disconnected")
+	f.Bool(prefix+".signed", DefaultBroadcasterConfig.Signed, "sign broadcast messages")
+	f.Bool(prefix+".enable-compression", DefaultBroadcasterConfig.EnableCompression, "enable per message deflate compression support")
 }
 
 var DefaultBroadcasterConfig = BroadcasterConfig{
-	Enable:        false,
-	Addr:          "",
-	IOTimeout:     5 * time.Second,
-	Port:          "9642",
-	Ping:          5 * time.Second,
-	ClientTimeout: 15 * time.Second,
-	Queue:         100,
-	Workers:       100,
-	MaxSendQueue:  4096,
+	Enable:            false,
+	Addr:              "",
+	IOTimeout:         5 * time.Second,
+	Port:              "9642",
+	Ping:              5 * time.Second,
+	ClientTimeout:     15 * time.Second,
+	Queue:             100,
+	Workers:           100,
+	MaxSendQueue:      4096,
+	Signed:            false,
+	EnableCompression: true,
 }
 
 var DefaultTestBroadcasterConfig = BroadcasterConfig{
-	Enable:        false,
-	Addr:          "0.0.0.0",
-	IOTimeout:     2 * time.Second,
-	Port:          "0",
-	Ping:          5 * time.Second,
-	ClientTimeout: 15 * time.Second,
-	Queue:         1,
-	Workers:       100,
-	MaxSendQueue:  4096,
+	Enable:            false,
+	Addr:              "0.0.0.0",
+	IOTimeout:         2 * time.Second,
+	Port:              "0",
+	Ping:              5 * time.Second,
+	ClientTimeout:     15 * time.Second,
+	Queue:             1,
+	Workers:           100,
+	MaxSendQueue:      4096,
+	Signed:            false,
+	EnableCompression: true,
 }
 
 type WSBroadcastServer struct {
@@ -117,8 +133,33 @@ func (s *WSBroadcastServer) Start(ctx context.Context) error {
 
 		safeConn := deadliner{conn, s.settings.IOTimeout}
 
+		var requestedSeqNum arbutil.MessageIndex
+		compressionExt := wsflate.Extension{
+			// Messages are compressed once and shared between clients, so no context takeover is allowed
+			Parameters: wsflate.DefaultParameters,
+		}
+		upgrader := ws.Upgrader{
+			OnHeader: func(key []byte, value []byte) error {
+				if !strings.EqualFold(string(key), HTTPHeaderRequestedSequenceNumber) {
+					return nil
+				}
+				num, err := strconv.ParseUint(string(value), 10, 64)
+				if err != nil {
+					return ws.RejectConnectionError(
+						ws.RejectionStatus(http.StatusBadRequest),
+						ws.RejectionReason(fmt.Sprintf("invalid %s header", HTTPHeaderRequestedSequenceNumber)),
+					)
+				}
+				requestedSeqNum = arbutil.MessageIndex(num)
+				return nil
+			},
+		}
+		if s.settings.EnableCompression {
+			upgrader.Negotiate = compressionExt.Negotiate
+		}
+
 		// Zero-copy upgrade to WebSocket connection.
-		hs, err := ws.Upgrade(safeConn)
+		hs, err := upgrader.Upgrade(safeConn)
 		if err != nil {
 			log.Warn("websocket upgrade error", "connection_name", nameConn(safeConn), "err", err)
 			_ = safeConn.Close()
@@ -136,7 +177,8 @@ func (s *WSBroadcastServer) Start(ctx context.Context) error {
 		}
 
 		// Register incoming client in clientManager.
-		client := clientManager.Register(safeConn, desc)
+		_, compression := compressionExt.Accepted()
+		client := clientManager.Register(safeConn, desc, requestedSeqNum, compression)
 
 		// Subscribe to events about conn.
 		err = s.poller.Start(desc, func(ev netpoll.Event) {